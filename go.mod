@@ -1,8 +1,8 @@
 module github.com/aclements/objbrowse
 
-go 1.14
+go 1.16
 
 require (
 	github.com/aclements/go-moremath v0.0.0-20190830160640-d16893ddf098
-	golang.org/x/arch v0.0.0-20200511175325-f7c78586839d
+	golang.org/x/arch v0.14.0
 )
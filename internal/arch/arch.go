@@ -4,6 +4,11 @@
 
 package arch
 
+import (
+	"encoding/binary"
+	"strconv"
+)
+
 type Arch struct {
 	// GoArch is the GOARCH value for this architecture.
 	GoArch string
@@ -11,19 +16,111 @@ type Arch struct {
 	// PtrSize is the number of bytes in a pointer.
 	PtrSize int
 
+	// ByteOrder is the byte order of this architecture.
+	ByteOrder binary.ByteOrder
+
 	// MinFrameSize is the number of bytes at the bottom of every
 	// stack frame except for empty leaf frames. This includes,
 	// for example, space for a saved LR (because that space is
 	// always reserved), but does not include the return PC pushed
 	// on x86 by CALL (because that is added only on a call).
 	MinFrameSize int
+
+	// PCQuantum is the minimum instruction size and alignment in
+	// bytes. Instructions may be larger than this.
+	PCQuantum int
+
+	// Regs is the Go assembler names of the general-purpose
+	// registers, indexed by register number.
+	Regs []string
+
+	// FloatRegs is the Go assembler names of the floating-point
+	// registers, indexed by register number.
+	FloatRegs []string
 }
 
 var (
-	AMD64 = &Arch{"amd64", 8, 0}
-	I386  = &Arch{"386", 4, 0}
+	AMD64 = &Arch{"amd64", 8, binary.LittleEndian, 0, 1, regsAMD64, numbered("X", 16)}
+	I386  = &Arch{"386", 4, binary.LittleEndian, 0, 1, regsAMD64[:8], numbered("X", 8)}
+	ARM64 = &Arch{"arm64", 8, binary.LittleEndian, 8, 4, regsARM64, numbered("F", 32)}
+	// Go doesn't emit compressed instructions, but C toolchains
+	// do, so riscv64 code may only be 2 byte aligned.
+	RISCV64 = &Arch{"riscv64", 8, binary.LittleEndian, 8, 2, numbered("X", 32), numbered("F", 32)}
+	// The ppc64 ELFv1 and ELFv2 ABIs both reserve 32 bytes at the
+	// bottom of each frame.
+	PPC64   = &Arch{"ppc64", 8, binary.BigEndian, 32, 4, regsPPC64, numbered("F", 32)}
+	PPC64LE = &Arch{"ppc64le", 8, binary.LittleEndian, 32, 4, regsPPC64, numbered("F", 32)}
+	// s390x instructions are 2, 4, or 6 bytes.
+	S390X = &Arch{"s390x", 8, binary.BigEndian, 8, 2, regsS390X, numbered("F", 16)}
+	Wasm  = &Arch{"wasm", 8, binary.LittleEndian, 0, 1, nil, nil}
 )
 
+// numbered returns the register names prefix+"0" through
+// prefix+(n-1).
+func numbered(prefix string, n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = prefix + strconv.Itoa(i)
+	}
+	return out
+}
+
+var regsAMD64 = []string{
+	"AX", "CX", "DX", "BX", "SP", "BP", "SI", "DI",
+	"R8", "R9", "R10", "R11", "R12", "R13", "R14", "R15",
+}
+
+// regsARM64 follows the Go assembler, which calls R28 "g" and
+// register 31 "RSP" when it's the stack pointer.
+var regsARM64 = []string{
+	"R0", "R1", "R2", "R3", "R4", "R5", "R6", "R7",
+	"R8", "R9", "R10", "R11", "R12", "R13", "R14", "R15",
+	"R16", "R17", "R18", "R19", "R20", "R21", "R22", "R23",
+	"R24", "R25", "R26", "R27", "g", "R29", "R30", "RSP",
+}
+
+// regsPPC64 follows the Go assembler, which calls R30 "g".
+var regsPPC64 = []string{
+	"R0", "R1", "R2", "R3", "R4", "R5", "R6", "R7",
+	"R8", "R9", "R10", "R11", "R12", "R13", "R14", "R15",
+	"R16", "R17", "R18", "R19", "R20", "R21", "R22", "R23",
+	"R24", "R25", "R26", "R27", "R28", "R29", "g", "R31",
+}
+
+// regsS390X follows the Go assembler, which calls R13 "g" and R15
+// "SP".
+var regsS390X = []string{
+	"R0", "R1", "R2", "R3", "R4", "R5", "R6", "R7",
+	"R8", "R9", "R10", "R11", "R12", "g", "R14", "SP",
+}
+
+// Uint16 decodes a 2 byte integer from the start of b in a's byte
+// order.
+func (a *Arch) Uint16(b []byte) uint16 {
+	return a.ByteOrder.Uint16(b)
+}
+
+// Uint32 decodes a 4 byte integer from the start of b in a's byte
+// order.
+func (a *Arch) Uint32(b []byte) uint32 {
+	return a.ByteOrder.Uint32(b)
+}
+
+// Uint64 decodes an 8 byte integer from the start of b in a's byte
+// order.
+func (a *Arch) Uint64(b []byte) uint64 {
+	return a.ByteOrder.Uint64(b)
+}
+
+// Ptr decodes a pointer-sized integer from the start of b in a's
+// byte order.
+func (a *Arch) Ptr(b []byte) uint64 {
+	if a.PtrSize == 4 {
+		return uint64(a.Uint32(b))
+	}
+	return a.Uint64(b)
+}
+
 func (a *Arch) String() string {
 	if a == nil {
 		return "<nil>"
@@ -0,0 +1,427 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/arch/arm64/arm64asm"
+)
+
+type arm64Seq []arm64Inst
+
+func (s arm64Seq) Len() int {
+	return len(s)
+}
+
+func (s arm64Seq) Get(i int) Inst {
+	return &s[i]
+}
+
+func disasmARM64(text []byte, pc uint64) Seq {
+	var out arm64Seq
+	for len(text) > 0 {
+		// arm64 instructions are always 4 bytes.
+		size := 4
+		if len(text) < size {
+			size = len(text)
+		}
+		inst, err := arm64asm.Decode(text)
+		if err != nil {
+			inst = arm64asm.Inst{}
+		}
+		out = append(out, arm64Inst{inst, pc, size})
+
+		text = text[size:]
+		pc += uint64(size)
+	}
+	return out
+}
+
+type arm64Inst struct {
+	arm64asm.Inst
+	pc  uint64
+	len int
+}
+
+func (i *arm64Inst) GoSyntax(symname func(uint64) (string, uint64)) string {
+	if i.Op == 0 {
+		return "?"
+	}
+	return arm64asm.GoSyntax(i.Inst, i.pc, symname, nil)
+}
+
+// GNUSyntax returns the GNU assembler syntax. symname is ignored.
+func (i *arm64Inst) GNUSyntax(symname func(uint64) (string, uint64)) string {
+	if i.Op == 0 {
+		return "?"
+	}
+	return arm64asm.GNUSyntax(i.Inst)
+}
+
+// IntelSyntax returns GNU syntax, since there's no Intel syntax for
+// this architecture.
+func (i *arm64Inst) IntelSyntax(symname func(uint64) (string, uint64)) string {
+	return i.GNUSyntax(symname)
+}
+
+func (i *arm64Inst) PC() uint64 {
+	return i.pc
+}
+
+func (i *arm64Inst) Len() int {
+	return i.len
+}
+
+func (i *arm64Inst) Control() Control {
+	var c Control
+	var target arm64asm.Arg
+	switch i.Op {
+	default:
+		return c
+	case arm64asm.B:
+		c.Type = ControlJump
+		target = i.Args[0]
+		if _, ok := i.Args[0].(arm64asm.Cond); ok {
+			// B.cond
+			c.Conditional = true
+			target = i.Args[1]
+		}
+	case arm64asm.BR:
+		c.Type = ControlJump
+		target = i.Args[0]
+	case arm64asm.BL, arm64asm.BLR:
+		c.Type = ControlCall
+		target = i.Args[0]
+	case arm64asm.RET:
+		c.Type = ControlRet
+		return c
+	case arm64asm.BRK, arm64asm.HLT:
+		c.Type = ControlExit
+		return c
+	case arm64asm.CBZ, arm64asm.CBNZ:
+		c.Type = ControlJump
+		c.Conditional = true
+		target = i.Args[1]
+	case arm64asm.TBZ, arm64asm.TBNZ:
+		c.Type = ControlJump
+		c.Conditional = true
+		target = i.Args[2]
+	}
+	if rel, ok := target.(arm64asm.PCRel); ok {
+		c.TargetPC = uint64(int64(i.pc) + int64(rel))
+	}
+	c.Target = target
+	return c
+}
+
+type locARM64Reg uint8
+
+const (
+	locR0  locARM64Reg = 0
+	locRSP             = locR0 + 31
+	locV0              = locRSP + 1
+)
+
+func (l locARM64Reg) is(Loc)          {}
+func (l locARM64Reg) IsPartial() bool { return false }
+func (l locARM64Reg) less(o Loc) bool {
+	if o == LocMem {
+		return false
+	}
+	return l < o.(locARM64Reg)
+}
+func (l locARM64Reg) String() string {
+	switch {
+	case l < locRSP:
+		return fmt.Sprintf("R%d", l-locR0)
+	case l == locRSP:
+		return "RSP"
+	case l < locV0+32:
+		return fmt.Sprintf("V%d", l-locV0)
+	}
+	return fmt.Sprintf("locARM64Reg(%d)", l)
+}
+
+// arm64Regs maps register names, as printed by arm64asm, to Locs.
+// The zero registers aren't locations, so they don't appear.
+var arm64Regs = make(map[string]locARM64Reg)
+
+func init() {
+	for i := 0; i < 31; i++ {
+		n := strconv.Itoa(i)
+		arm64Regs["W"+n] = locR0 + locARM64Reg(i)
+		arm64Regs["X"+n] = locR0 + locARM64Reg(i)
+	}
+	arm64Regs["SP"] = locRSP
+	arm64Regs["WSP"] = locRSP
+	for i := 0; i < 32; i++ {
+		n := strconv.Itoa(i)
+		for _, prefix := range []string{"B", "H", "S", "D", "Q", "V"} {
+			arm64Regs[prefix+n] = locV0 + locARM64Reg(i)
+		}
+	}
+}
+
+// arm64ArgRegs returns the register Locs mentioned by arg.
+//
+// arm64asm doesn't export the registers of many argument types, so
+// this finds them in the argument's text.
+//
+// TODO: Register lists like "{V0.B16-V3.B16}" only report the first
+// and last register.
+func arm64ArgRegs(arg arm64asm.Arg) []locARM64Reg {
+	if _, ok := arg.(arm64asm.Systemreg); ok {
+		// System register names look like "S3_3_C4_C2_0".
+		return nil
+	}
+	var out []locARM64Reg
+	isWord := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }
+	for _, f := range strings.FieldsFunc(arg.String(), isWord) {
+		if loc, ok := arm64Regs[f]; ok {
+			out = append(out, loc)
+		}
+	}
+	return out
+}
+
+// arm64Writes returns the number of leading arguments of op that it
+// writes, and whether those are read-modify-write.
+func arm64Writes(op arm64asm.Op) (n int, rmw bool) {
+	switch op {
+	case arm64asm.B, arm64asm.BL, arm64asm.BR, arm64asm.BLR, arm64asm.RET,
+		arm64asm.CBZ, arm64asm.CBNZ, arm64asm.TBZ, arm64asm.TBNZ,
+		arm64asm.CMP, arm64asm.CMN, arm64asm.TST, arm64asm.CCMP, arm64asm.CCMN,
+		arm64asm.FCMP, arm64asm.FCMPE, arm64asm.FCCMP, arm64asm.FCCMPE,
+		arm64asm.PRFM, arm64asm.PRFUM, arm64asm.MSR, arm64asm.SYS,
+		arm64asm.NOP, arm64asm.HINT, arm64asm.DMB, arm64asm.DSB, arm64asm.ISB,
+		arm64asm.SEV, arm64asm.SEVL, arm64asm.CLREX,
+		arm64asm.BRK, arm64asm.HLT, arm64asm.SVC, arm64asm.HVC, arm64asm.SMC,
+		arm64asm.DC, arm64asm.IC, arm64asm.AT, arm64asm.TLBI, arm64asm.ERET, arm64asm.DRPS,
+		arm64asm.DCPS1, arm64asm.DCPS2, arm64asm.DCPS3:
+		return 0, false
+	case arm64asm.STXR, arm64asm.STXRB, arm64asm.STXRH, arm64asm.STXP,
+		arm64asm.STLXR, arm64asm.STLXRB, arm64asm.STLXRH, arm64asm.STLXP:
+		// These write a status register.
+		return 1, false
+	case arm64asm.LDP, arm64asm.LDNP, arm64asm.LDPSW, arm64asm.LDXP, arm64asm.LDAXP:
+		return 2, false
+	case arm64asm.MOVK, arm64asm.BFI, arm64asm.BFM, arm64asm.BFXIL, arm64asm.INS,
+		arm64asm.FMLA, arm64asm.FMLS, arm64asm.MLA, arm64asm.MLS,
+		arm64asm.SLI, arm64asm.SRI, arm64asm.BIF, arm64asm.BIT, arm64asm.BSL, arm64asm.TBX:
+		return 1, true
+	}
+	if arm64IsStore(op) {
+		return 0, false
+	}
+	return 1, false
+}
+
+func arm64IsLoad(op arm64asm.Op) bool {
+	return strings.HasPrefix(op.String(), "LD")
+}
+
+func arm64IsStore(op arm64asm.Op) bool {
+	return strings.HasPrefix(op.String(), "ST")
+}
+
+// memSize returns the number of bytes accessed by a load or store,
+// or 0 if unknown.
+func (inst *arm64Inst) memSize() int {
+	name := inst.Op.String()
+	var size int
+	switch {
+	case strings.HasSuffix(name, "B"):
+		size = 1
+	case strings.HasSuffix(name, "H"):
+		size = 2
+	case strings.HasSuffix(name, "SW"):
+		size = 4
+	default:
+		// The size follows the data register.
+		data := inst.Args[0]
+		if n, _ := arm64Writes(inst.Op); n == 1 && arm64IsStore(inst.Op) {
+			// Skip the status register.
+			data = inst.Args[1]
+		}
+		reg, ok := data.(arm64asm.Reg)
+		if !ok {
+			return 0
+		}
+		switch {
+		case reg <= arm64asm.WZR, arm64asm.S0 <= reg && reg <= arm64asm.S31:
+			size = 4
+		case reg <= arm64asm.XZR, arm64asm.D0 <= reg && reg <= arm64asm.D31:
+			size = 8
+		case arm64asm.B0 <= reg && reg <= arm64asm.B31:
+			size = 1
+		case arm64asm.H0 <= reg && reg <= arm64asm.H31:
+			size = 2
+		case arm64asm.Q0 <= reg && reg <= arm64asm.Q31:
+			size = 16
+		default:
+			return 0
+		}
+	}
+	switch inst.Op {
+	case arm64asm.LDP, arm64asm.LDNP, arm64asm.LDPSW, arm64asm.LDXP, arm64asm.LDAXP,
+		arm64asm.STP, arm64asm.STNP, arm64asm.STXP, arm64asm.STLXP:
+		size *= 2
+	}
+	return size
+}
+
+// memArg returns the memory operand of inst and its index in
+// inst.Args, or -1 if it has none.
+func (inst *arm64Inst) memArg() (arm64asm.Arg, int) {
+	if !arm64IsLoad(inst.Op) && !arm64IsStore(inst.Op) {
+		return nil, -1
+	}
+	for i, arg := range inst.Args {
+		switch arg.(type) {
+		case arm64asm.MemImmediate, arm64asm.MemExtend, arm64asm.PCRel:
+			return arg, i
+		}
+	}
+	return nil, -1
+}
+
+// arm64MemDisp returns the offset of a MemImmediate. arm64asm doesn't
+// export it, so this parses it from the operand's text, which looks
+// like "[X0,#8]".
+func arm64MemDisp(m arm64asm.MemImmediate) int64 {
+	if m.Mode != arm64asm.AddrOffset && m.Mode != arm64asm.AddrPreIndex {
+		// Post-indexed operands access the base address.
+		return 0
+	}
+	s := m.String()
+	i, j := strings.Index(s, ",#"), strings.Index(s, "]")
+	if i < 0 || j < i {
+		return 0
+	}
+	disp, _ := strconv.ParseInt(s[i+2:j], 0, 64)
+	return disp
+}
+
+func (inst *arm64Inst) Prefixes() []Prefix {
+	return nil
+}
+
+func (inst *arm64Inst) MemArgs() []MemArg {
+	arg, _ := inst.memArg()
+	if arg == nil {
+		return nil
+	}
+	ma := MemArg{Size: inst.memSize()}
+	switch arg := arg.(type) {
+	case arm64asm.MemImmediate:
+		if regs := arm64ArgRegs(arg.Base); len(regs) > 0 {
+			ma.Base = regs[0]
+		}
+		ma.Disp = arm64MemDisp(arg)
+	case arm64asm.MemExtend:
+		if regs := arm64ArgRegs(arg.Base); len(regs) > 0 {
+			ma.Base = regs[0]
+		}
+		if regs := arm64ArgRegs(arg.Index); len(regs) > 0 {
+			ma.Index = regs[0]
+		}
+		ma.Scale = 1
+		if !arg.ShiftMustBeZero {
+			ma.Scale <<= arg.Amount
+		}
+	case arm64asm.PCRel:
+		// Resolve PC-relative (literal) loads.
+		ma.Disp = int64(inst.pc) + int64(arg)
+	}
+	if arm64IsLoad(inst.Op) {
+		ma.Read = true
+	} else {
+		ma.Write = true
+	}
+
+	// Go syntax reorders and combines arguments in various ways,
+	// so find the memory operand in the Go syntax itself.
+	ma.Arg = goSyntaxMemArg(inst.GoSyntax(nil))
+	if ma.Arg < 0 {
+		return nil
+	}
+	return []MemArg{ma}
+}
+
+func (inst *arm64Inst) Effects() (read, write LocSet) {
+	// TODO: Flags effects?
+	read, write = make(LocSet, 4), make(LocSet, 4)
+	add := func(loc locARM64Reg, e effect) {
+		if e&r != 0 {
+			read.Add(loc)
+		}
+		if e&w != 0 {
+			write.Add(loc)
+		}
+	}
+	if inst.Op == 0 {
+		return
+	}
+
+	nWrite, rmw := arm64Writes(inst.Op)
+	for i, arg := range inst.Args {
+		if arg == nil {
+			break
+		}
+		switch arg := arg.(type) {
+		case arm64asm.MemImmediate:
+			e := r
+			if arg.Mode != arm64asm.AddrOffset {
+				// Writeback
+				e = rw
+			}
+			for _, loc := range arm64ArgRegs(arg.Base) {
+				add(loc, e)
+			}
+			if arg.Mode == arm64asm.AddrPostReg {
+				// The increment register is printed as
+				// part of the operand.
+				for _, loc := range arm64ArgRegs(arg)[1:] {
+					add(loc, r)
+				}
+			}
+			continue
+		}
+		e := r
+		if i < nWrite {
+			e = w
+			if rmw {
+				e = rw
+			}
+		}
+		for _, loc := range arm64ArgRegs(arg) {
+			add(loc, e)
+		}
+	}
+	if _, i := inst.memArg(); i >= 0 {
+		if arm64IsLoad(inst.Op) {
+			read.Add(LocMem)
+		} else {
+			write.Add(LocMem)
+		}
+	}
+
+	// Implicit effects.
+	switch inst.Op {
+	case arm64asm.BL, arm64asm.BLR:
+		// Calls write the link register.
+		add(locR0+30, w)
+	case arm64asm.RET:
+		if inst.Args[0] == nil {
+			add(locR0+30, r)
+		}
+	}
+
+	return
+}
@@ -7,6 +7,7 @@ package asm
 import (
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/aclements/objbrowse/internal/arch"
 )
@@ -17,11 +18,20 @@ import (
 // Disasm disassembles machine code for the given architecture. pc is
 // the program counter at which text begins.
 func Disasm(arch *arch.Arch, text []byte, pc uint64) (Seq, error) {
+	if arch == nil {
+		return nil, fmt.Errorf("unknown assembly architecture")
+	}
 	switch arch.GoArch {
 	case "amd64":
 		return disasmX86(text, pc, 64), nil
 	case "386":
 		return disasmX86(text, pc, 32), nil
+	case "arm64":
+		return disasmARM64(text, pc), nil
+	case "riscv64":
+		return disasmRISCV64(text, pc), nil
+	case "wasm":
+		return disasmWasm(text, pc), nil
 	}
 	return nil, fmt.Errorf("unsupported assembly architecture: %s", arch)
 }
@@ -40,6 +50,15 @@ type Inst interface {
 	// symbol lookup fails.
 	GoSyntax(symname func(addr uint64) (string, uint64)) string
 
+	// GNUSyntax returns the GNU assembler syntax representation
+	// of this instruction. On x86, this is AT&T syntax.
+	GNUSyntax(symname func(addr uint64) (string, uint64)) string
+
+	// IntelSyntax returns the Intel syntax representation of
+	// this instruction. Architectures other than x86 don't have
+	// an Intel syntax, so they return GNU syntax.
+	IntelSyntax(symname func(addr uint64) (string, uint64)) string
+
 	// PC returns the address of this instruction.
 	PC() uint64
 
@@ -53,18 +72,158 @@ type Inst interface {
 	// Effects returns the read and write sets of this
 	// instruction.
 	Effects() (read, write LocSet)
+
+	// MemArgs returns the memory operands of this instruction.
+	MemArgs() []MemArg
+
+	// Prefixes returns the decoded prefixes of this instruction,
+	// in encoding order. Only x86 has prefixes.
+	Prefixes() []Prefix
+}
+
+// A Prefix is an instruction prefix, such as x86 LOCK or REP.
+type Prefix struct {
+	// Name is the name of the prefix, such as "LOCK", "REP",
+	// "CS", or "REX.W".
+	Name string
+
+	// Implicit indicates the prefix is implied by the
+	// instruction or has no effect, so it doesn't appear in
+	// the instruction's syntax.
+	Implicit bool
+}
+
+// Syntax is an assembly language syntax.
+type Syntax int
+
+const (
+	SyntaxGo Syntax = iota
+	SyntaxGNU
+	SyntaxIntel
+)
+
+var syntaxNames = []string{"go", "gnu", "intel"}
+
+// ParseSyntax parses the name of an assembly syntax: "go", "gnu"
+// (or "att"), or "intel".
+func ParseSyntax(name string) (Syntax, error) {
+	if name == "att" {
+		return SyntaxGNU, nil
+	}
+	for i, n := range syntaxNames {
+		if n == name {
+			return Syntax(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown assembly syntax %q", name)
+}
+
+func (s Syntax) String() string {
+	if 0 <= s && int(s) < len(syntaxNames) {
+		return syntaxNames[s]
+	}
+	return fmt.Sprintf("Syntax(%d)", int(s))
+}
+
+// Format returns inst in syntax s.
+func (s Syntax) Format(inst Inst, symname func(addr uint64) (string, uint64)) string {
+	switch s {
+	case SyntaxGNU:
+		return inst.GNUSyntax(symname)
+	case SyntaxIntel:
+		return inst.IntelSyntax(symname)
+	}
+	return inst.GoSyntax(symname)
 }
 
 // Arg is an argument to an instruction.
 type Arg interface {
 }
 
+// MemArg is a memory operand of an instruction. The address of the
+// operand is Base + Index*Scale + Disp.
+type MemArg struct {
+	// Arg is the index of this operand in the instruction's
+	// arguments, in the order they appear in GoSyntax.
+	Arg int
+
+	// Base and Index are the base and index registers of the
+	// address, or nil if not present. If both are nil, Disp
+	// is an absolute address. PC-relative addresses are
+	// resolved to absolute addresses.
+	Base, Index Loc
+	Scale       int64
+	Disp        int64
+
+	// Size is the number of bytes accessed, or 0 if unknown.
+	Size int
+
+	// Read and Write indicate whether the instruction reads
+	// and/or writes this operand.
+	Read, Write bool
+}
+
+// A Ref is a static address computed by a memory operand, such as a
+// PC-relative or absolute address. Unlike MemArgs, this includes
+// operands that compute an address without accessing it, like LEA.
+type Ref struct {
+	// Arg is the index of this operand in the instruction's
+	// arguments, in the order they appear in GoSyntax.
+	Arg int
+
+	// Addr is the effective address of the operand.
+	Addr uint64
+}
+
+// Refs returns the static addresses computed by inst's memory
+// operands. Currently this only supports x86.
+func Refs(inst Inst) []Ref {
+	if j, ok := inst.(jumpTableInst); ok {
+		inst = j.Inst
+	}
+	if r, ok := inst.(interface{ refs() []Ref }); ok {
+		return r.refs()
+	}
+	return nil
+}
+
+// goSyntaxMemArg returns the index of the memory operand in the Go
+// syntax of an instruction with at most one memory operand, or -1 if
+// there is none. Arguments are numbered as they're split by ", ",
+// and memory operands are the only arguments of the form "x(y)".
+func goSyntaxMemArg(syntax string) int {
+	i := strings.Index(syntax, " ")
+	if i < 0 {
+		return -1
+	}
+	for j, a := range strings.Split(syntax[i+1:], ", ") {
+		if strings.Contains(a, "(") && strings.HasSuffix(a, ")") {
+			return j
+		}
+	}
+	return -1
+}
+
 // Control captures control-flow effects of an instruction.
 type Control struct {
 	Type        ControlType
 	Conditional bool
 	TargetPC    uint64
 	Target      Arg
+
+	// Targets are the possible targets of an indirect jump, if
+	// known, such as from a jump table. See WithJumpTables.
+	Targets []uint64
+}
+
+// FallsThrough returns whether execution may continue to the next
+// instruction after an instruction with control-flow effects c.
+func (c Control) FallsThrough() bool {
+	switch c.Type {
+	case ControlNone, ControlCall:
+		return true
+	}
+	return c.Conditional
 }
 
 type ControlType uint8
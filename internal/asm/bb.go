@@ -5,9 +5,12 @@
 package asm
 
 import (
+	"context"
 	"fmt"
 	"math/big"
 	"sort"
+
+	"github.com/aclements/go-moremath/graph/graphalg"
 )
 
 // TODO: Put this in another package?
@@ -34,11 +37,16 @@ type BasicBlock struct {
 	//
 	// Type        len(Succs)
 	// None        1
-	// Jump        1 or 2 depending on Control.Conditional
+	// Jump        1 or 2 depending on Control.Conditional, or
+	//             len(Control.Targets) for a jump table
 	// Ret         0 or 1 depending on Control.Conditional
-	// JumpUnknown 0 or 1 depending on Control.Conditional
+	// JumpUnknown any number, plus 1 if Control.Conditional
 	// Exit        0 or 1 depending on Control.Conditional
 	//
+	// The successors of a JumpUnknown block are unresolved: they
+	// are a guess at where an indirect jump, such as through a
+	// jump table, may go. See BasicBlocks.
+	//
 	// TODO: Be consistent about true/false order?
 	Succs []Edge
 
@@ -69,14 +77,23 @@ type Edge struct {
 // empty block will be created if necessary). Unreachable blocks do
 // not appear in the output.
 //
+// Indirect jumps end a block with Control.Type ControlJumpUnknown.
+// Since these could go anywhere in seq, each such block gets an edge
+// to every block that would otherwise have no predecessors, such as
+// the targets of a jump table. This keeps jump table targets in the
+// graph, though they may have extra predecessors.
+//
 // If the control-flow graph cannot be computed, this returns an
-// error. Currently, the only reason for this is computed jumps.
-func BasicBlocks(seq Seq) ([]*BasicBlock, error) {
+// error. It returns ctx.Err() if ctx is done first.
+func BasicBlocks(ctx context.Context, seq Seq) ([]*BasicBlock, error) {
 	// Find the start of each basic block.
 	var startPCs []uint64
 	pcs := make(map[uint64]int, seq.Len())
 	newBlock := true
 	for i := 0; i < seq.Len(); i++ {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		inst := seq.Get(i)
 		pc := inst.PC()
 		pcs[pc] = i
@@ -90,6 +107,7 @@ func BasicBlocks(seq Seq) ([]*BasicBlock, error) {
 		switch c.Type {
 		case ControlJump:
 			newBlock = true
+			startPCs = append(startPCs, c.Targets...)
 			if c.TargetPC == 0 {
 				// Unknown target.
 				//
@@ -164,6 +182,7 @@ func BasicBlocks(seq Seq) ([]*BasicBlock, error) {
 		from.Succs = append(from.Succs, Edge{to, len(to.Preds)})
 		to.Preds = append(to.Preds, Edge{from, len(from.Succs) - 1})
 	}
+	var unknown []*BasicBlock
 	for i, bb := range bbs {
 		next := false
 		var alt *BasicBlock
@@ -176,11 +195,24 @@ func BasicBlocks(seq Seq) ([]*BasicBlock, error) {
 			if bb.Control.Conditional {
 				next = true
 			}
+			if bb.Control.TargetPC == 0 && len(bb.Control.Targets) > 0 {
+				// Jump table. Add an edge to each
+				// distinct target in this function.
+				seen := make(map[*BasicBlock]bool)
+				for _, pc := range bb.Control.Targets {
+					if tbb, ok := bbPCs[pc]; ok && !seen[tbb] {
+						seen[tbb] = true
+						addEdge(bb, tbb)
+					}
+				}
+				break
+			}
 			if bb.Control.TargetPC == 0 {
 				// Jump to unknown PC. Turn this into
 				// a ControlJumpUnknown, since it
 				// could go anywhere.
 				bb.Control.Type = ControlJumpUnknown
+				unknown = append(unknown, bb)
 				break
 			}
 			tbb, ok := bbPCs[bb.Control.TargetPC]
@@ -192,6 +224,12 @@ func BasicBlocks(seq Seq) ([]*BasicBlock, error) {
 				alt = tbb
 			}
 
+		case ControlJumpUnknown:
+			if bb.Control.Conditional {
+				next = true
+			}
+			unknown = append(unknown, bb)
+
 		case ControlRet, ControlExit:
 			if bb.Control.Conditional {
 				next = true
@@ -216,6 +254,25 @@ func BasicBlocks(seq Seq) ([]*BasicBlock, error) {
 		}
 	}
 
+	// Connect unknown jumps to blocks that have no other
+	// predecessors.
+	if len(unknown) > 0 {
+		var orphans []*BasicBlock
+		for _, bb := range bbs[1:] {
+			if len(bb.Preds) == 0 {
+				orphans = append(orphans, bb)
+			}
+		}
+		for _, from := range unknown {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			for _, to := range orphans {
+				addEdge(from, to)
+			}
+		}
+	}
+
 	// Delete unreachable blocks.
 	var reachable big.Int
 	nReachable := 0
@@ -271,6 +328,33 @@ func BasicBlocks(seq Seq) ([]*BasicBlock, error) {
 	return bbs, nil
 }
 
+// Dominators returns the immediate dominator of each block in bbs,
+// indexed by block ID. The entry block has no immediate dominator and
+// is assigned -1. bbs must be the result of BasicBlocks. It returns
+// ctx.Err() if ctx is done first.
+func Dominators(ctx context.Context, bbs []*BasicBlock) ([]int, error) {
+	idom := graphalg.IDom(ctxGraph{BasicBlockGraph(bbs), ctx}, 0)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return idom, nil
+}
+
+// ctxGraph is a BasicBlockGraph whose blocks have no predecessors
+// once ctx is done. This makes graphalg.IDom, which we can't
+// interrupt directly, converge immediately.
+type ctxGraph struct {
+	BasicBlockGraph
+	ctx context.Context
+}
+
+func (g ctxGraph) In(i int) []int {
+	if g.ctx.Err() != nil {
+		return nil
+	}
+	return g.BasicBlockGraph.In(i)
+}
+
 type BasicBlockGraph []*BasicBlock
 
 func (g BasicBlockGraph) NumNodes() int {
@@ -0,0 +1,131 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asm
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aclements/objbrowse/internal/arch"
+)
+
+// A Range is a range of addresses [Start, End).
+type Range struct {
+	Start, End uint64
+}
+
+// DisasmData is like Disasm, but treats the parts of text in the
+// data ranges as data rather than instructions. This is useful for
+// data embedded in code, such as jump tables, which would otherwise
+// disassemble as garbage instructions and throw off the following
+// instructions. Each data range becomes a single data Inst with no
+// effects.
+func DisasmData(arch *arch.Arch, text []byte, pc uint64, data []Range) (Seq, error) {
+	// Clip data to text.
+	end := pc + uint64(len(text))
+	var ranges []Range
+	for _, r := range data {
+		if r.Start < pc {
+			r.Start = pc
+		}
+		if r.End > end {
+			r.End = end
+		}
+		if r.Start < r.End {
+			ranges = append(ranges, r)
+		}
+	}
+	if len(ranges) == 0 {
+		return Disasm(arch, text, pc)
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+
+	var out instSeq
+	code := func(start, end uint64) error {
+		if start >= end {
+			return nil
+		}
+		seq, err := Disasm(arch, text[start-pc:end-pc], start)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < seq.Len(); i++ {
+			out = append(out, seq.Get(i))
+		}
+		return nil
+	}
+	next := pc
+	for _, r := range ranges {
+		if r.End <= next {
+			// Overlaps the previous range.
+			continue
+		}
+		if r.Start < next {
+			r.Start = next
+		}
+		if err := code(next, r.Start); err != nil {
+			return nil, err
+		}
+		out = append(out, &dataInst{r.Start, text[r.Start-pc : r.End-pc]})
+		next = r.End
+	}
+	if err := code(next, end); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// instSeq is a Seq of arbitrary instructions.
+type instSeq []Inst
+
+func (s instSeq) Len() int {
+	return len(s)
+}
+
+func (s instSeq) Get(i int) Inst {
+	return s[i]
+}
+
+// dataInst is a pseudo-instruction for data in code.
+type dataInst struct {
+	pc   uint64
+	data []byte
+}
+
+func (i *dataInst) GoSyntax(symname func(uint64) (string, uint64)) string {
+	return fmt.Sprintf("DATA %d bytes", len(i.data))
+}
+
+func (i *dataInst) GNUSyntax(symname func(uint64) (string, uint64)) string {
+	return i.GoSyntax(symname)
+}
+
+func (i *dataInst) IntelSyntax(symname func(uint64) (string, uint64)) string {
+	return i.GoSyntax(symname)
+}
+
+func (i *dataInst) PC() uint64 {
+	return i.pc
+}
+
+func (i *dataInst) Len() int {
+	return len(i.data)
+}
+
+func (i *dataInst) Control() Control {
+	return Control{}
+}
+
+func (i *dataInst) Effects() (read, write LocSet) {
+	return make(LocSet), make(LocSet)
+}
+
+func (i *dataInst) MemArgs() []MemArg {
+	return nil
+}
+
+func (i *dataInst) Prefixes() []Prefix {
+	return nil
+}
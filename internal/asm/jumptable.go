@@ -0,0 +1,339 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asm
+
+import (
+	"context"
+
+	"golang.org/x/arch/x86/x86asm"
+
+	"github.com/aclements/objbrowse/internal/arch"
+)
+
+// A JumpTable is an indirect jump through a table of code addresses,
+// such as compilers produce for dense switch statements.
+type JumpTable struct {
+	// Inst is the index of the indirect jump instruction in the
+	// Seq.
+	Inst int
+
+	// Addr and Size give the location of the table in memory.
+	Addr, Size uint64
+
+	// Targets are the PCs in the table, in table order. This may
+	// contain duplicates.
+	Targets []uint64
+}
+
+// A ReadFunc reads size bytes of memory at addr. It may return fewer
+// bytes, or nil if addr isn't mapped.
+type ReadFunc func(addr, size uint64) ([]byte, error)
+
+// maxJumpTable is the maximum number of entries read from a jump
+// table if its bounds check can't be found.
+const maxJumpTable = 1024
+
+// JumpTables finds the jump tables used by indirect jumps in seq,
+// which must have been disassembled for arch. It uses read to read
+// the tables. All targets of a table must be in seq.
+//
+// Currently this only recognizes x86 jump tables. It handles tables
+// of absolute addresses, as used by Go:
+//
+//	LEAQ table(IP), CX
+//	JMP 0(CX)(AX*8)
+//
+// and tables of 32-bit offsets from the table, as used by C
+// compilers for position-independent code:
+//
+//	LEAQ table(IP), DX
+//	MOVSXD 0(DX)(AX*4), AX
+//	ADDQ DX, AX
+//	JMP AX
+//
+// The number of entries comes from the bounds check on the index
+// that precedes the jump. If there isn't one, JumpTables reads
+// entries until it finds one that isn't in seq.
+//
+// It returns ctx.Err() if ctx is done first.
+func JumpTables(ctx context.Context, arch *arch.Arch, seq Seq, read ReadFunc) ([]JumpTable, error) {
+	if seq.Len() == 0 {
+		return nil, nil
+	}
+	s := make(x86Insts, seq.Len())
+	for i := range s {
+		switch inst := seq.Get(i).(type) {
+		case *x86Inst:
+			s[i] = inst
+		case *dataInst:
+			// Leave nil.
+		default:
+			return nil, nil
+		}
+	}
+	last := seq.Get(seq.Len() - 1)
+	lo, hi := seq.Get(0).PC(), last.PC()+uint64(last.Len())
+	var out []JumpTable
+	for i := range s {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if s[i] == nil || s[i].Op != x86asm.JMP {
+			continue
+		}
+		t, ok := s.jumpTable(i, arch)
+		if !ok {
+			continue
+		}
+		n := s.jumpTableLen(i, t.index)
+		bounded := n > 0
+		if !bounded {
+			n = maxJumpTable
+		}
+		data, err := read(t.addr, uint64(n*t.entSize))
+		if err != nil {
+			return nil, err
+		}
+		if bounded && len(data) < n*t.entSize {
+			continue
+		}
+		jt := JumpTable{Inst: i, Addr: t.addr}
+		for len(data) >= t.entSize {
+			var target uint64
+			if t.relative {
+				target = t.base + uint64(int32(arch.Uint32(data)))
+			} else if t.entSize == 4 {
+				target = uint64(arch.Uint32(data))
+			} else {
+				target = arch.Uint64(data)
+			}
+			if target < lo || target >= hi {
+				break
+			}
+			jt.Targets = append(jt.Targets, target)
+			data = data[t.entSize:]
+		}
+		if len(jt.Targets) == 0 || bounded && len(jt.Targets) != n {
+			continue
+		}
+		jt.Size = uint64(len(jt.Targets) * t.entSize)
+		out = append(out, jt)
+	}
+	return out, nil
+}
+
+// x86JumpTable describes the table used by an x86 indirect jump.
+type x86JumpTable struct {
+	addr    uint64
+	index   locX86Reg
+	entSize int
+	// relative indicates entries are 32-bit offsets from base.
+	relative bool
+	base     uint64
+}
+
+// x86Insts is a sequence of x86 instructions. Data in the sequence
+// is nil.
+type x86Insts []*x86Inst
+
+// jumpTable recognizes the table used by the indirect jump at s[i].
+func (s x86Insts) jumpTable(i int, arch *arch.Arch) (t x86JumpTable, ok bool) {
+	switch arg := s[i].Args[0].(type) {
+	case x86asm.Mem:
+		// JMP through the table.
+		t.addr, t.index, ok = s.tableAddr(i, arg)
+		t.entSize = arch.PtrSize
+		return t, ok && int(arg.Scale) == t.entSize
+
+	case x86asm.Reg:
+		reg, _, ok := x86RegLoc(arg)
+		if !ok {
+			return t, false
+		}
+		j := s.lastWrite(i, reg)
+		if j < 0 {
+			return t, false
+		}
+		switch s[j].Op {
+		case x86asm.MOV:
+			// Load from the table, then jump.
+			mem, ok := s[j].Args[1].(x86asm.Mem)
+			if !ok {
+				return t, false
+			}
+			t.addr, t.index, ok = s.tableAddr(j, mem)
+			t.entSize = arch.PtrSize
+			return t, ok && int(mem.Scale) == t.entSize
+
+		case x86asm.ADD:
+			// Load an offset from the table, add the
+			// base, then jump. Either argument of the ADD
+			// may be the offset.
+			dst, ok1 := s[j].Args[0].(x86asm.Reg)
+			src, ok2 := s[j].Args[1].(x86asm.Reg)
+			if !ok1 || !ok2 {
+				return t, false
+			}
+			for _, regs := range [][2]x86asm.Reg{{src, dst}, {dst, src}} {
+				off, _, ok1 := x86RegLoc(regs[0])
+				base, _, ok2 := x86RegLoc(regs[1])
+				if !ok1 || !ok2 {
+					continue
+				}
+				k := s.lastWrite(j, off)
+				if k < 0 || s[k].Op != x86asm.MOVSXD {
+					continue
+				}
+				mem, ok := s[k].Args[1].(x86asm.Mem)
+				if !ok || mem.Scale != 4 {
+					continue
+				}
+				t.addr, t.index, ok1 = s.tableAddr(k, mem)
+				t.base, ok2 = s.regAddr(j, base)
+				if ok1 && ok2 {
+					t.entSize, t.relative = 4, true
+					return t, true
+				}
+			}
+		}
+	}
+	return t, false
+}
+
+// tableAddr returns the address of a table indexed by mem, an
+// argument of s[i], and the register of the index.
+func (s x86Insts) tableAddr(i int, mem x86asm.Mem) (addr uint64, index locX86Reg, ok bool) {
+	index, _, ok = x86RegLoc(mem.Index)
+	if mem.Index == 0 || !ok {
+		return 0, 0, false
+	}
+	switch mem.Base {
+	case 0:
+		addr = 0
+	case x86asm.IP, x86asm.EIP, x86asm.RIP:
+		addr = s[i].pc + uint64(s[i].Inst.Len)
+	default:
+		base, _, ok := x86RegLoc(mem.Base)
+		if !ok {
+			return 0, 0, false
+		}
+		if addr, ok = s.regAddr(i, base); !ok {
+			return 0, 0, false
+		}
+	}
+	return s[i].addr(int64(addr) + mem.Disp), index, true
+}
+
+// regAddr returns the constant address in reg before s[i], if reg
+// was set by a LEA of a PC-relative or absolute address, or a MOV of
+// an immediate.
+func (s x86Insts) regAddr(i int, reg locX86Reg) (uint64, bool) {
+	j := s.lastWrite(i, reg)
+	if j < 0 {
+		return 0, false
+	}
+	switch s[j].Op {
+	case x86asm.LEA:
+		mem, ok := s[j].Args[1].(x86asm.Mem)
+		if !ok || mem.Index != 0 {
+			break
+		}
+		switch mem.Base {
+		case 0:
+			return s[j].addr(mem.Disp), true
+		case x86asm.IP, x86asm.EIP, x86asm.RIP:
+			return s[j].addr(int64(s[j].pc) + int64(s[j].Inst.Len) + mem.Disp), true
+		}
+	case x86asm.MOV:
+		if imm, ok := s[j].Args[1].(x86asm.Imm); ok {
+			return s[j].addr(int64(imm)), true
+		}
+	}
+	return 0, false
+}
+
+// lastWrite returns the index of the instruction that last wrote reg
+// before s[i] in the same basic block, or -1 if there isn't one.
+func (s x86Insts) lastWrite(i int, reg locX86Reg) int {
+	const maxBack = 8
+	for j := i - 1; j >= 0 && j >= i-maxBack; j-- {
+		if s[j] == nil || s[j].Control().Type != ControlNone {
+			break
+		}
+		if _, w := s[j].Effects(); w.Has(reg) {
+			return j
+		}
+	}
+	return -1
+}
+
+// jumpTableLen returns the number of entries in the table used by
+// the jump at s[i], given the bounds check of index before the jump,
+// or 0 if there's no bounds check.
+func (s x86Insts) jumpTableLen(i int, index locX86Reg) int {
+	// Look for CMP index, $n followed by JA or JAE. This is
+	// usually at the end of the previous block.
+	const maxBack = 16
+	for j := i - 1; j > 0 && j >= i-maxBack; j-- {
+		if s[j] == nil || s[j].Op != x86asm.CMP {
+			continue
+		}
+		reg, ok1 := s[j].Args[0].(x86asm.Reg)
+		imm, ok2 := s[j].Args[1].(x86asm.Imm)
+		if !ok1 || !ok2 || imm < 0 || imm >= maxJumpTable {
+			continue
+		}
+		if loc, _, ok := x86RegLoc(reg); !ok || loc != index {
+			continue
+		}
+		if j+1 < len(s) && s[j+1] != nil {
+			switch s[j+1].Op {
+			case x86asm.JA:
+				return int(imm) + 1
+			case x86asm.JAE:
+				return int(imm)
+			}
+		}
+		return 0
+	}
+	return 0
+}
+
+// WithJumpTables returns seq with the Control of each indirect jump
+// in tables updated with the targets of the table.
+func WithJumpTables(seq Seq, tables []JumpTable) Seq {
+	if len(tables) == 0 {
+		return seq
+	}
+	jts := make(map[int][]uint64, len(tables))
+	for _, t := range tables {
+		jts[t.Inst] = t.Targets
+	}
+	return jumpTableSeq{seq, jts}
+}
+
+type jumpTableSeq struct {
+	Seq
+	targets map[int][]uint64
+}
+
+func (s jumpTableSeq) Get(i int) Inst {
+	inst := s.Seq.Get(i)
+	if targets, ok := s.targets[i]; ok {
+		return jumpTableInst{inst, targets}
+	}
+	return inst
+}
+
+type jumpTableInst struct {
+	Inst
+	targets []uint64
+}
+
+func (i jumpTableInst) Control() Control {
+	c := i.Inst.Control()
+	c.Targets = i.targets
+	return c
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asm
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aclements/go-moremath/graph"
+	"github.com/aclements/go-moremath/graph/graphalg"
+)
+
+// A Loop is a natural loop in a control-flow graph.
+type Loop struct {
+	// Header is the ID of the block that dominates the loop.
+	Header int
+
+	// Blocks are the IDs of the blocks in the loop, including
+	// Header and the blocks of nested loops, in increasing
+	// order.
+	Blocks []int
+
+	// Parent is the index of the innermost loop enclosing this
+	// loop, or -1 if this is an outermost loop.
+	Parent int
+
+	// Depth is the nesting depth of this loop. Outermost loops
+	// have depth 1.
+	Depth int
+}
+
+// LoopInfo describes the loops of a control-flow graph.
+type LoopInfo struct {
+	// Loops are the natural loops of the graph, ordered so outer
+	// loops come before the loops they enclose.
+	Loops []Loop
+
+	// Depth is the loop nesting depth of each block, indexed by
+	// block ID. Blocks that aren't in any loop have depth 0.
+	Depth []int
+
+	// Irreducible marks the blocks, indexed by block ID, that are
+	// in a cycle with no single header that dominates it. Only
+	// the natural loops of the graph contribute to Depth, so
+	// these blocks have depth 0 unless they're also in a natural
+	// loop.
+	Irreducible []bool
+}
+
+// Loops finds the natural loops of bbs, which must be the result of
+// BasicBlocks. idom must be the result of Dominators(bbs).
+//
+// A natural loop is identified by a back edge from a block to a
+// block that dominates it. Back edges to the same header form a
+// single loop.
+//
+// It returns ctx.Err() if ctx is done first.
+func Loops(ctx context.Context, bbs []*BasicBlock, idom []int) (*LoopInfo, error) {
+	dominates := func(a, b int) bool {
+		for ; b != -1; b = idom[b] {
+			if a == b {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Find back edges and the forward graph without them.
+	tails := make(map[int][]int)
+	var headers []int
+	fwd := make(graph.IntGraph, len(bbs))
+	for _, b := range bbs {
+		for _, e := range b.Succs {
+			h := e.Block.ID
+			if !dominates(h, b.ID) {
+				fwd[b.ID] = append(fwd[b.ID], h)
+				continue
+			}
+			if tails[h] == nil {
+				headers = append(headers, h)
+			}
+			tails[h] = append(tails[h], b.ID)
+		}
+	}
+
+	// Collect the body of each loop by walking backward from its
+	// back edges to the header.
+	info := &LoopInfo{Depth: make([]int, len(bbs)), Irreducible: make([]bool, len(bbs))}
+	inLoop := make([][]bool, len(headers))
+	for i, h := range headers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		in := make([]bool, len(bbs))
+		in[h] = true
+		work := append([]int(nil), tails[h]...)
+		for len(work) > 0 {
+			b := work[len(work)-1]
+			work = work[:len(work)-1]
+			if in[b] {
+				continue
+			}
+			in[b] = true
+			for _, e := range bbs[b].Preds {
+				work = append(work, e.Block.ID)
+			}
+		}
+		loop := Loop{Header: h, Parent: -1}
+		for b, ok := range in {
+			if ok {
+				loop.Blocks = append(loop.Blocks, b)
+			}
+		}
+		info.Loops = append(info.Loops, loop)
+		inLoop[i] = in
+	}
+
+	// Order loops from outermost to innermost. An enclosing
+	// loop is always strictly larger than the loops it encloses.
+	order := make([]int, len(info.Loops))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(info.Loops[order[i]].Blocks) > len(info.Loops[order[j]].Blocks)
+	})
+	loops := make([]Loop, len(order))
+	ins := make([][]bool, len(order))
+	for i, j := range order {
+		loops[i], ins[i] = info.Loops[j], inLoop[j]
+	}
+	info.Loops = loops
+
+	// The parent of a loop is the innermost earlier loop that
+	// contains its header.
+	for i := range loops {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for j := i - 1; j >= 0; j-- {
+			if ins[j][loops[i].Header] {
+				loops[i].Parent = j
+				break
+			}
+		}
+		loops[i].Depth = 1
+		if p := loops[i].Parent; p != -1 {
+			loops[i].Depth = loops[p].Depth + 1
+		}
+		for _, b := range loops[i].Blocks {
+			info.Depth[b]++
+		}
+	}
+
+	// Any cycle left after removing back edges is irreducible.
+	sccs := graphalg.SCC(fwd, 0)
+	for c := 0; c < sccs.NumNodes(); c++ {
+		if nodes := sccs.Subnodes(c); len(nodes) > 1 {
+			for _, b := range nodes {
+				info.Irreducible[b] = true
+			}
+		}
+	}
+
+	return info, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asm
+
+import "fmt"
+
+// A MemAccess is a memory operand that may access a location of
+// interest.
+type MemAccess struct {
+	// Inst is the index of the accessing instruction in the Seq.
+	Inst int
+
+	MemArg
+}
+
+// An SPAdjFunc returns the number of bytes the stack pointer at pc is
+// below the stack pointer on entry to the function. It returns false
+// if this is unknown.
+type SPAdjFunc func(pc uint64) (int64, bool)
+
+// FrameAccesses returns the memory operands in seq that may access
+// bytes [off, off+size) of the stack frame. off is relative to the
+// stack pointer on entry to the function, so locals are at negative
+// offsets.
+func FrameAccesses(seq Seq, spAdj SPAdjFunc, off, size int64) []MemAccess {
+	var out []MemAccess
+	for i := 0; i < seq.Len(); i++ {
+		inst := seq.Get(i)
+		for _, ma := range inst.MemArgs() {
+			if !isSP(ma.Base) || ma.Index != nil {
+				continue
+			}
+			adj, ok := spAdj(inst.PC())
+			if !ok {
+				continue
+			}
+			if overlaps(ma.Disp-adj, ma.size(), off, size) {
+				out = append(out, MemAccess{i, ma})
+			}
+		}
+	}
+	return out
+}
+
+// ArgAccesses returns the memory operands in seq that may access the
+// same location as memory argument arg of instruction i.
+//
+// For stack pointer-relative operands, this uses spAdj to find the
+// location in the frame, and returns other operands that overlap that
+// location. spAdj may be nil, in which case these are treated like
+// other register-relative operands. For absolute addresses, this
+// returns operands that overlap the same address. Otherwise, it
+// returns operands that use the same address expression.
+//
+// TODO: Operands with the same address expression may refer to
+// different locations if the registers are redefined between them.
+// Use SSA to check that they use the same register values.
+func ArgAccesses(seq Seq, spAdj SPAdjFunc, i, arg int) ([]MemAccess, error) {
+	if i < 0 || i >= seq.Len() {
+		return nil, fmt.Errorf("instruction %d out of range", i)
+	}
+	inst := seq.Get(i)
+	var target MemArg
+	found := false
+	for _, ma := range inst.MemArgs() {
+		if ma.Arg == arg {
+			target, found = ma, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("argument %d of %#x is not a memory operand", arg, inst.PC())
+	}
+
+	if spAdj != nil && isSP(target.Base) && target.Index == nil {
+		adj, ok := spAdj(inst.PC())
+		if !ok {
+			return nil, fmt.Errorf("unknown stack pointer at %#x", inst.PC())
+		}
+		return FrameAccesses(seq, spAdj, target.Disp-adj, target.size()), nil
+	}
+
+	var out []MemAccess
+	for j := 0; j < seq.Len(); j++ {
+		for _, ma := range seq.Get(j).MemArgs() {
+			if target.Base == nil && target.Index == nil {
+				if ma.Base != nil || ma.Index != nil {
+					continue
+				}
+				if !overlaps(ma.Disp, ma.size(), target.Disp, target.size()) {
+					continue
+				}
+			} else if ma.Base != target.Base || ma.Index != target.Index || ma.Scale != target.Scale || ma.Disp != target.Disp {
+				continue
+			}
+			out = append(out, MemAccess{j, ma})
+		}
+	}
+	return out, nil
+}
+
+// size returns the size of m for the purposes of overlap tests.
+func (m *MemArg) size() int64 {
+	if m.Size == 0 {
+		// Unknown size. Assume at least one byte.
+		return 1
+	}
+	return int64(m.Size)
+}
+
+func overlaps(off1, size1, off2, size2 int64) bool {
+	return off1 < off2+size2 && off2 < off1+size1
+}
+
+// isSP returns whether l is the hardware stack pointer register.
+func isSP(l Loc) bool {
+	switch l := l.(type) {
+	case locX86Reg:
+		return l == locAX+4
+	case locARM64Reg:
+		return l == locRSP
+	case locRISCV64Reg:
+		return l == locRX0+2
+	}
+	return false
+}
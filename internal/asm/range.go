@@ -0,0 +1,69 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asm
+
+import "github.com/aclements/objbrowse/internal/arch"
+
+// maxAlignTries is the number of start addresses AlignRange tries
+// before giving up and starting at the anchor.
+const maxAlignTries = 16
+
+// AlignRange clips r to text, which starts at pc, and adjusts r.Start
+// so that disassembling text from r.Start decodes an instruction at
+// anchor, which should be an instruction boundary. This makes it
+// possible to disassemble part of a large function without decoding
+// everything before it. anchor is clipped to r.
+//
+// With variable-length instructions, a sequence decoded from an
+// arbitrary address usually falls in step with the real instruction
+// boundaries within a few instructions, so AlignRange moves r.Start
+// forward until that happens by anchor.
+func AlignRange(arch *arch.Arch, text []byte, pc uint64, r Range, anchor uint64) Range {
+	end := pc + uint64(len(text))
+	if r.Start < pc {
+		r.Start = pc
+	}
+	if r.End > end || r.End == 0 {
+		r.End = end
+	}
+	if r.Start >= r.End {
+		return Range{r.Start, r.Start}
+	}
+	if anchor < r.Start {
+		anchor = r.Start
+	} else if anchor >= r.End {
+		anchor = r.End - 1
+	}
+
+	if r.Start == pc {
+		// text starts at an instruction boundary.
+		return r
+	}
+
+	q := uint64(arch.PCQuantum)
+	start := anchor - (anchor-r.Start)/q*q
+	for try := 0; start < anchor && try < maxAlignTries; try, start = try+1, start+q {
+		// Decode a little past anchor to find the instruction
+		// that covers it.
+		stop := anchor + 16
+		if stop > end {
+			stop = end
+		}
+		seq, err := Disasm(arch, text[start-pc:stop-pc], start)
+		if err != nil {
+			break
+		}
+		for i := 0; i < seq.Len(); i++ {
+			if ipc := seq.Get(i).PC(); ipc == anchor {
+				r.Start = start
+				return r
+			} else if ipc > anchor {
+				break
+			}
+		}
+	}
+	r.Start = anchor
+	return r
+}
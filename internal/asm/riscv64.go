@@ -0,0 +1,305 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asm
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/arch/riscv64/riscv64asm"
+)
+
+type riscv64Seq []riscv64Inst
+
+func (s riscv64Seq) Len() int {
+	return len(s)
+}
+
+func (s riscv64Seq) Get(i int) Inst {
+	return &s[i]
+}
+
+func disasmRISCV64(text []byte, pc uint64) Seq {
+	var out riscv64Seq
+	for len(text) > 0 {
+		inst, err := riscv64asm.Decode(text)
+		// Instructions are 4 bytes, or 2 bytes if compressed.
+		size := inst.Len
+		if err != nil || size == 0 {
+			inst = riscv64asm.Inst{}
+			size = 2
+			if text[0]&3 == 3 {
+				size = 4
+			}
+		}
+		if size > len(text) {
+			size = len(text)
+		}
+		out = append(out, riscv64Inst{inst, pc, size})
+
+		text = text[size:]
+		pc += uint64(size)
+	}
+	return out
+}
+
+type riscv64Inst struct {
+	riscv64asm.Inst
+	pc  uint64
+	len int
+}
+
+func (i *riscv64Inst) GoSyntax(symname func(uint64) (string, uint64)) string {
+	if i.Op == 0 {
+		return "?"
+	}
+	return riscv64asm.GoSyntax(i.Inst, i.pc, symname, nil)
+}
+
+// GNUSyntax returns the GNU assembler syntax. symname is ignored.
+func (i *riscv64Inst) GNUSyntax(symname func(uint64) (string, uint64)) string {
+	if i.Op == 0 {
+		return "?"
+	}
+	return riscv64asm.GNUSyntax(i.Inst)
+}
+
+// IntelSyntax returns GNU syntax, since there's no Intel syntax for
+// this architecture.
+func (i *riscv64Inst) IntelSyntax(symname func(uint64) (string, uint64)) string {
+	return i.GNUSyntax(symname)
+}
+
+func (i *riscv64Inst) PC() uint64 {
+	return i.pc
+}
+
+func (i *riscv64Inst) Len() int {
+	return i.len
+}
+
+func (i *riscv64Inst) Control() Control {
+	var c Control
+	var target riscv64asm.Arg
+	switch i.Op {
+	default:
+		return c
+	case riscv64asm.JAL:
+		// JAL with a link register is a call.
+		c.Type = ControlJump
+		if i.Args[0].(riscv64asm.Reg) != riscv64asm.X0 {
+			c.Type = ControlCall
+		}
+		target = i.Args[1]
+	case riscv64asm.JALR:
+		c.Type = ControlJump
+		if i.Args[0].(riscv64asm.Reg) != riscv64asm.X0 {
+			c.Type = ControlCall
+		} else if ro := i.Args[1].(riscv64asm.RegOffset); ro.OfsReg == riscv64asm.X1 && ro.Ofs.Imm == 0 {
+			// JALR X0, 0(X1)
+			c.Type = ControlRet
+			return c
+		}
+		target = i.Args[1]
+	case riscv64asm.BEQ, riscv64asm.BNE, riscv64asm.BLT, riscv64asm.BGE, riscv64asm.BLTU, riscv64asm.BGEU:
+		c.Type = ControlJump
+		c.Conditional = true
+		target = i.Args[2]
+	case riscv64asm.EBREAK:
+		c.Type = ControlExit
+		return c
+	}
+	if off, ok := target.(riscv64asm.Simm); ok {
+		c.TargetPC = uint64(int64(i.pc) + int64(off.Imm))
+	}
+	c.Target = target
+	return c
+}
+
+type locRISCV64Reg uint8
+
+const (
+	locRX0 locRISCV64Reg = 0
+	locRF0               = locRX0 + 32
+)
+
+func (l locRISCV64Reg) is(Loc)          {}
+func (l locRISCV64Reg) IsPartial() bool { return false }
+func (l locRISCV64Reg) less(o Loc) bool {
+	if o == LocMem {
+		return false
+	}
+	return l < o.(locRISCV64Reg)
+}
+func (l locRISCV64Reg) String() string {
+	switch {
+	case l < locRF0:
+		return fmt.Sprintf("X%d", l-locRX0)
+	case l < locRF0+32:
+		return fmt.Sprintf("F%d", l-locRF0)
+	}
+	return fmt.Sprintf("locRISCV64Reg(%d)", l)
+}
+
+// riscv64ArgReg returns the register Loc used by arg, if any. X0 is
+// hard-wired to zero, so it isn't a location.
+func riscv64ArgReg(arg riscv64asm.Arg) (locRISCV64Reg, bool) {
+	var reg riscv64asm.Reg
+	switch arg := arg.(type) {
+	case riscv64asm.Reg:
+		reg = arg
+	case riscv64asm.RegOffset:
+		reg = arg.OfsReg
+	case riscv64asm.AmoReg:
+		// riscv64asm doesn't export the register, but it's
+		// printed as "(xN)".
+		var n int
+		if _, err := fmt.Sscanf(arg.String(), "(x%d)", &n); err != nil {
+			return 0, false
+		}
+		reg = riscv64asm.X0 + riscv64asm.Reg(n)
+	default:
+		return 0, false
+	}
+	switch {
+	case riscv64asm.X1 <= reg && reg <= riscv64asm.X31:
+		return locRX0 + locRISCV64Reg(reg-riscv64asm.X0), true
+	case riscv64asm.F0 <= reg && reg <= riscv64asm.F31:
+		return locRF0 + locRISCV64Reg(reg-riscv64asm.F0), true
+	}
+	return 0, false
+}
+
+// riscv64Mem describes the memory effect of a load, store, or atomic
+// instruction.
+type riscv64Mem struct {
+	size        int
+	read, write bool
+}
+
+// mem returns the memory effect of inst, or false if it doesn't
+// access memory.
+func (inst *riscv64Inst) mem() (riscv64Mem, bool) {
+	name := inst.Op.String()
+	switch inst.Op {
+	case riscv64asm.LB, riscv64asm.LBU:
+		return riscv64Mem{1, true, false}, true
+	case riscv64asm.LH, riscv64asm.LHU, riscv64asm.FLH:
+		return riscv64Mem{2, true, false}, true
+	case riscv64asm.LW, riscv64asm.LWU, riscv64asm.FLW:
+		return riscv64Mem{4, true, false}, true
+	case riscv64asm.LD, riscv64asm.FLD:
+		return riscv64Mem{8, true, false}, true
+	case riscv64asm.FLQ:
+		return riscv64Mem{16, true, false}, true
+	case riscv64asm.SB:
+		return riscv64Mem{1, false, true}, true
+	case riscv64asm.SH, riscv64asm.FSH:
+		return riscv64Mem{2, false, true}, true
+	case riscv64asm.SW, riscv64asm.FSW:
+		return riscv64Mem{4, false, true}, true
+	case riscv64asm.SD, riscv64asm.FSD:
+		return riscv64Mem{8, false, true}, true
+	case riscv64asm.FSQ:
+		return riscv64Mem{16, false, true}, true
+	}
+	// Atomics are named like "AMOADD.W.AQ" and "LR.D".
+	var m riscv64Mem
+	switch {
+	case strings.HasPrefix(name, "LR."):
+		m.read = true
+	case strings.HasPrefix(name, "SC."):
+		m.write = true
+	case strings.HasPrefix(name, "AMO"):
+		m.read, m.write = true, true
+	default:
+		return m, false
+	}
+	if strings.Contains(name, ".W") {
+		m.size = 4
+	} else if strings.Contains(name, ".D") {
+		m.size = 8
+	}
+	return m, true
+}
+
+// writesDest returns whether inst writes its first argument.
+func (inst *riscv64Inst) writesDest() bool {
+	switch inst.Op {
+	case riscv64asm.BEQ, riscv64asm.BNE, riscv64asm.BLT, riscv64asm.BGE, riscv64asm.BLTU, riscv64asm.BGEU,
+		riscv64asm.FENCE, riscv64asm.FENCE_I, riscv64asm.ECALL, riscv64asm.EBREAK:
+		return false
+	}
+	if m, ok := inst.mem(); ok && m.write && !m.read && !strings.HasPrefix(inst.Op.String(), "SC.") {
+		// Plain stores. SC writes a status register.
+		return false
+	}
+	return true
+}
+
+func (inst *riscv64Inst) Prefixes() []Prefix {
+	return nil
+}
+
+func (inst *riscv64Inst) MemArgs() []MemArg {
+	m, ok := inst.mem()
+	if !ok {
+		return nil
+	}
+	ma := MemArg{Size: m.size, Read: m.read, Write: m.write}
+	found := false
+	for _, arg := range inst.Args {
+		switch arg := arg.(type) {
+		case riscv64asm.RegOffset:
+			ma.Disp = int64(arg.Ofs.Imm)
+		case riscv64asm.AmoReg:
+		default:
+			continue
+		}
+		if loc, ok := riscv64ArgReg(arg); ok {
+			ma.Base = loc
+		}
+		found = true
+		break
+	}
+	if !found {
+		return nil
+	}
+	ma.Arg = goSyntaxMemArg(inst.GoSyntax(nil))
+	if ma.Arg < 0 {
+		return nil
+	}
+	return []MemArg{ma}
+}
+
+func (inst *riscv64Inst) Effects() (read, write LocSet) {
+	read, write = make(LocSet, 4), make(LocSet, 4)
+	if inst.Op == 0 {
+		return
+	}
+	for i, arg := range inst.Args {
+		if arg == nil {
+			break
+		}
+		loc, ok := riscv64ArgReg(arg)
+		if !ok {
+			continue
+		}
+		if i == 0 && inst.writesDest() {
+			write.Add(loc)
+		} else {
+			read.Add(loc)
+		}
+	}
+	if m, ok := inst.mem(); ok {
+		if m.read {
+			read.Add(LocMem)
+		}
+		if m.write {
+			write.Add(LocMem)
+		}
+	}
+	return
+}
@@ -0,0 +1,517 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package asm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WebAssembly disassembly.
+//
+// Instructions are printed in the WebAssembly text format (e.g.,
+// "i32.load offset=8, align=4") rather than Go assembler syntax,
+// since that's what WebAssembly tools use. Operands that refer to
+// function, local, or global indexes are printed as indexes.
+
+type wasmSeq []wasmInst
+
+func (s wasmSeq) Len() int {
+	return len(s)
+}
+
+func (s wasmSeq) Get(i int) Inst {
+	return &s[i]
+}
+
+type wasmInst struct {
+	pc      uint64
+	len     int
+	op      string
+	args    []string
+	control Control
+	mem     wasmMemEffect
+}
+
+type wasmMemEffect uint8
+
+const (
+	wasmMemNone wasmMemEffect = iota
+	wasmMemLoad
+	wasmMemStore
+)
+
+// Immediate operand kinds.
+type wasmImm uint8
+
+const (
+	wasmImmNone wasmImm = iota
+	wasmImmBlockType
+	wasmImmLabel
+	wasmImmBrTable
+	wasmImmIndex // A single function, local, global, or table index
+	wasmImmCallIndirect
+	wasmImmMemArg
+	wasmImmZero // A reserved zero byte (memory index)
+	wasmImmI32
+	wasmImmI64
+	wasmImmF32
+	wasmImmF64
+	wasmImmRefType
+	wasmImmSelect
+)
+
+type wasmOp struct {
+	name string
+	imm  wasmImm
+	mem  wasmMemEffect
+}
+
+var wasmOps = map[byte]wasmOp{
+	0x00: {"unreachable", wasmImmNone, 0},
+	0x01: {"nop", wasmImmNone, 0},
+	0x02: {"block", wasmImmBlockType, 0},
+	0x03: {"loop", wasmImmBlockType, 0},
+	0x04: {"if", wasmImmBlockType, 0},
+	0x05: {"else", wasmImmNone, 0},
+	0x0b: {"end", wasmImmNone, 0},
+	0x0c: {"br", wasmImmLabel, 0},
+	0x0d: {"br_if", wasmImmLabel, 0},
+	0x0e: {"br_table", wasmImmBrTable, 0},
+	0x0f: {"return", wasmImmNone, 0},
+	0x10: {"call", wasmImmIndex, 0},
+	0x11: {"call_indirect", wasmImmCallIndirect, 0},
+	0x12: {"return_call", wasmImmIndex, 0},
+	0x13: {"return_call_indirect", wasmImmCallIndirect, 0},
+	0x1a: {"drop", wasmImmNone, 0},
+	0x1b: {"select", wasmImmNone, 0},
+	0x1c: {"select", wasmImmSelect, 0},
+	0x20: {"local.get", wasmImmIndex, 0},
+	0x21: {"local.set", wasmImmIndex, 0},
+	0x22: {"local.tee", wasmImmIndex, 0},
+	0x23: {"global.get", wasmImmIndex, 0},
+	0x24: {"global.set", wasmImmIndex, 0},
+	0x25: {"table.get", wasmImmIndex, 0},
+	0x26: {"table.set", wasmImmIndex, 0},
+	0x3f: {"memory.size", wasmImmZero, 0},
+	0x40: {"memory.grow", wasmImmZero, 0},
+	0x41: {"i32.const", wasmImmI32, 0},
+	0x42: {"i64.const", wasmImmI64, 0},
+	0x43: {"f32.const", wasmImmF32, 0},
+	0x44: {"f64.const", wasmImmF64, 0},
+	0xd0: {"ref.null", wasmImmRefType, 0},
+	0xd1: {"ref.is_null", wasmImmNone, 0},
+	0xd2: {"ref.func", wasmImmIndex, 0},
+}
+
+// wasmMiscOps are the 0xfc-prefixed instructions, indexed by their
+// second opcode.
+var wasmMiscOps = []struct {
+	name string
+	imms []wasmImm
+}{
+	{"i32.trunc_sat_f32_s", nil},
+	{"i32.trunc_sat_f32_u", nil},
+	{"i32.trunc_sat_f64_s", nil},
+	{"i32.trunc_sat_f64_u", nil},
+	{"i64.trunc_sat_f32_s", nil},
+	{"i64.trunc_sat_f32_u", nil},
+	{"i64.trunc_sat_f64_s", nil},
+	{"i64.trunc_sat_f64_u", nil},
+	{"memory.init", []wasmImm{wasmImmIndex, wasmImmZero}},
+	{"data.drop", []wasmImm{wasmImmIndex}},
+	{"memory.copy", []wasmImm{wasmImmZero, wasmImmZero}},
+	{"memory.fill", []wasmImm{wasmImmZero}},
+	{"table.init", []wasmImm{wasmImmIndex, wasmImmIndex}},
+	{"elem.drop", []wasmImm{wasmImmIndex}},
+	{"table.copy", []wasmImm{wasmImmIndex, wasmImmIndex}},
+	{"table.grow", []wasmImm{wasmImmIndex}},
+	{"table.size", []wasmImm{wasmImmIndex}},
+	{"table.fill", []wasmImm{wasmImmIndex}},
+}
+
+func init() {
+	// Memory instructions, 0x28 through 0x3e.
+	for i, name := range strings.Fields(`
+		i32.load i64.load f32.load f64.load
+		i32.load8_s i32.load8_u i32.load16_s i32.load16_u
+		i64.load8_s i64.load8_u i64.load16_s i64.load16_u i64.load32_s i64.load32_u`) {
+		wasmOps[byte(0x28+i)] = wasmOp{name, wasmImmMemArg, wasmMemLoad}
+	}
+	for i, name := range strings.Fields(`
+		i32.store i64.store f32.store f64.store
+		i32.store8 i32.store16 i64.store8 i64.store16 i64.store32`) {
+		wasmOps[byte(0x36+i)] = wasmOp{name, wasmImmMemArg, wasmMemStore}
+	}
+
+	// Numeric instructions without immediates, 0x45 through 0xc4.
+	for i, name := range strings.Fields(`
+		i32.eqz i32.eq i32.ne i32.lt_s i32.lt_u i32.gt_s i32.gt_u i32.le_s i32.le_u i32.ge_s i32.ge_u
+		i64.eqz i64.eq i64.ne i64.lt_s i64.lt_u i64.gt_s i64.gt_u i64.le_s i64.le_u i64.ge_s i64.ge_u
+		f32.eq f32.ne f32.lt f32.gt f32.le f32.ge
+		f64.eq f64.ne f64.lt f64.gt f64.le f64.ge
+		i32.clz i32.ctz i32.popcnt i32.add i32.sub i32.mul i32.div_s i32.div_u i32.rem_s i32.rem_u
+		i32.and i32.or i32.xor i32.shl i32.shr_s i32.shr_u i32.rotl i32.rotr
+		i64.clz i64.ctz i64.popcnt i64.add i64.sub i64.mul i64.div_s i64.div_u i64.rem_s i64.rem_u
+		i64.and i64.or i64.xor i64.shl i64.shr_s i64.shr_u i64.rotl i64.rotr
+		f32.abs f32.neg f32.ceil f32.floor f32.trunc f32.nearest f32.sqrt
+		f32.add f32.sub f32.mul f32.div f32.min f32.max f32.copysign
+		f64.abs f64.neg f64.ceil f64.floor f64.trunc f64.nearest f64.sqrt
+		f64.add f64.sub f64.mul f64.div f64.min f64.max f64.copysign
+		i32.wrap_i64 i32.trunc_f32_s i32.trunc_f32_u i32.trunc_f64_s i32.trunc_f64_u
+		i64.extend_i32_s i64.extend_i32_u i64.trunc_f32_s i64.trunc_f32_u i64.trunc_f64_s i64.trunc_f64_u
+		f32.convert_i32_s f32.convert_i32_u f32.convert_i64_s f32.convert_i64_u f32.demote_f64
+		f64.convert_i32_s f64.convert_i32_u f64.convert_i64_s f64.convert_i64_u f64.promote_f32
+		i32.reinterpret_f32 i64.reinterpret_f64 f32.reinterpret_i32 f64.reinterpret_i64
+		i32.extend8_s i32.extend16_s i64.extend8_s i64.extend16_s i64.extend32_s`) {
+		wasmOps[byte(0x45+i)] = wasmOp{name, wasmImmNone, 0}
+	}
+}
+
+var wasmValTypes = map[byte]string{
+	0x7f: "i32",
+	0x7e: "i64",
+	0x7d: "f32",
+	0x7c: "f64",
+	0x7b: "v128",
+	0x70: "funcref",
+	0x6f: "externref",
+}
+
+func wasmValType(b byte) string {
+	if name, ok := wasmValTypes[b]; ok {
+		return name
+	}
+	return fmt.Sprintf("type(%#x)", b)
+}
+
+var errWasmTruncated = errors.New("truncated instruction")
+
+// wasmReader decodes the immediates of a WebAssembly instruction.
+type wasmReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *wasmReader) byte() byte {
+	if r.pos >= len(r.b) {
+		r.err = errWasmTruncated
+		return 0
+	}
+	r.pos++
+	return r.b[r.pos-1]
+}
+
+func (r *wasmReader) uleb() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		r.err = errWasmTruncated
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *wasmReader) sleb() int64 {
+	var v int64
+	var shift uint
+	for {
+		b := r.byte()
+		if r.err != nil {
+			return 0
+		}
+		v |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				v |= -1 << shift
+			}
+			return v
+		}
+		if shift >= 70 {
+			r.err = errWasmTruncated
+			return 0
+		}
+	}
+}
+
+func (r *wasmReader) fixed(n int) []byte {
+	if r.pos+n > len(r.b) {
+		r.err = errWasmTruncated
+		return nil
+	}
+	r.pos += n
+	return r.b[r.pos-n : r.pos]
+}
+
+// imm decodes an immediate of kind imm and returns its text.
+func (r *wasmReader) imm(imm wasmImm) []string {
+	switch imm {
+	case wasmImmBlockType:
+		if r.pos < len(r.b) && r.b[r.pos] == 0x40 {
+			// Empty block type.
+			r.pos++
+			return nil
+		}
+		if r.pos < len(r.b) && wasmValTypes[r.b[r.pos]] != "" {
+			return []string{"(result " + wasmValType(r.byte()) + ")"}
+		}
+		return []string{fmt.Sprintf("(type %d)", r.sleb())}
+	case wasmImmLabel, wasmImmIndex:
+		return []string{strconv.FormatUint(r.uleb(), 10)}
+	case wasmImmBrTable:
+		n := r.uleb()
+		var out []string
+		for i := uint64(0); i <= n && r.err == nil; i++ {
+			out = append(out, strconv.FormatUint(r.uleb(), 10))
+		}
+		return out
+	case wasmImmCallIndirect:
+		typ := r.uleb()
+		table := r.uleb()
+		return []string{fmt.Sprintf("(type %d)", typ), fmt.Sprintf("table=%d", table)}
+	case wasmImmMemArg:
+		align := r.uleb()
+		off := r.uleb()
+		return []string{fmt.Sprintf("offset=%d", off), fmt.Sprintf("align=%d", uint64(1)<<(align&63))}
+	case wasmImmZero:
+		r.byte()
+		return nil
+	case wasmImmI32, wasmImmI64:
+		return []string{strconv.FormatInt(r.sleb(), 10)}
+	case wasmImmF32:
+		b := r.fixed(4)
+		if b == nil {
+			return nil
+		}
+		f := math.Float32frombits(binary.LittleEndian.Uint32(b))
+		return []string{strconv.FormatFloat(float64(f), 'g', -1, 32)}
+	case wasmImmF64:
+		b := r.fixed(8)
+		if b == nil {
+			return nil
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(b))
+		return []string{strconv.FormatFloat(f, 'g', -1, 64)}
+	case wasmImmRefType:
+		return []string{wasmValType(r.byte())}
+	case wasmImmSelect:
+		n := r.uleb()
+		var out []string
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			out = append(out, wasmValType(r.byte()))
+		}
+		return []string{"(result " + strings.Join(out, " ") + ")"}
+	}
+	return nil
+}
+
+// disasmWasm disassembles a WebAssembly function body, including its
+// local variable declarations, which appear as a pseudo-instruction.
+// pc is the address of the body in the module.
+func disasmWasm(text []byte, pc uint64) Seq {
+	var out wasmSeq
+	r := &wasmReader{b: text}
+
+	// Decode local declarations.
+	n := r.uleb()
+	var locals []string
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		count := r.uleb()
+		typ := wasmValType(r.byte())
+		if count == 1 {
+			locals = append(locals, typ)
+		} else {
+			locals = append(locals, fmt.Sprintf("%s*%d", typ, count))
+		}
+	}
+	if r.err != nil {
+		return wasmSeq{{pc: pc, len: len(text), op: "?"}}
+	}
+	if r.pos > 0 {
+		out = append(out, wasmInst{pc: pc, len: r.pos, op: "locals", args: locals})
+	}
+
+	for r.pos < len(text) {
+		start := r.pos
+		r.err = nil
+		inst := wasmInst{pc: pc + uint64(start)}
+		op := r.byte()
+		if info, ok := wasmOps[op]; ok {
+			inst.op = info.name
+			inst.args = r.imm(info.imm)
+			inst.mem = info.mem
+		} else if op == 0xfc {
+			sub := r.uleb()
+			if r.err == nil && sub < uint64(len(wasmMiscOps)) {
+				info := wasmMiscOps[sub]
+				inst.op = info.name
+				for _, imm := range info.imms {
+					inst.args = append(inst.args, r.imm(imm)...)
+				}
+			} else {
+				r.err = fmt.Errorf("unknown opcode 0xfc %d", sub)
+			}
+		} else {
+			r.err = fmt.Errorf("unknown opcode %#x", op)
+		}
+		if r.err != nil {
+			// Skip one byte and try to resynchronize.
+			inst = wasmInst{pc: pc + uint64(start), op: "?"}
+			r.pos = start + 1
+		}
+		inst.len = r.pos - start
+		out = append(out, inst)
+	}
+
+	wasmResolveControl(out)
+	return out
+}
+
+// wasmResolveControl computes the control-flow effects of the
+// structured control instructions in seq.
+func wasmResolveControl(seq wasmSeq) {
+	type frame struct {
+		op string
+		pc uint64
+		// fixups are instructions that branch to the end of
+		// this frame.
+		fixups []int
+	}
+	// The function body itself is the outermost frame. Branching
+	// to it returns.
+	stack := []*frame{{op: "func"}}
+	target := func(depth string) (*frame, bool) {
+		d, err := strconv.Atoi(depth)
+		if err != nil || d >= len(stack) {
+			return nil, false
+		}
+		return stack[len(stack)-1-d], true
+	}
+	branch := func(i int, f *frame, conditional bool) {
+		c := &seq[i].control
+		c.Conditional = conditional
+		switch f.op {
+		case "func":
+			c.Type = ControlRet
+		case "loop":
+			c.Type = ControlJump
+			c.TargetPC = f.pc
+		default:
+			c.Type = ControlJump
+			f.fixups = append(f.fixups, i)
+		}
+	}
+
+	for i := range seq {
+		inst := &seq[i]
+		switch inst.op {
+		case "block", "loop":
+			stack = append(stack, &frame{op: inst.op, pc: inst.pc})
+		case "if":
+			// If the condition is false, this jumps to the
+			// else or the end.
+			f := &frame{op: inst.op, pc: inst.pc}
+			inst.control = Control{Type: ControlJump, Conditional: true}
+			f.fixups = append(f.fixups, i)
+			stack = append(stack, f)
+		case "else":
+			f := stack[len(stack)-1]
+			if f.op != "if" || len(f.fixups) == 0 {
+				break
+			}
+			// The if jumps to just after the else, and the
+			// end of the then branch jumps to the end.
+			seq[f.fixups[0]].control.TargetPC = inst.pc + uint64(inst.len)
+			f.fixups = f.fixups[1:]
+			inst.control = Control{Type: ControlJump}
+			f.fixups = append(f.fixups, i)
+		case "end":
+			f := stack[len(stack)-1]
+			if f.op == "func" {
+				inst.control = Control{Type: ControlRet}
+				break
+			}
+			stack = stack[:len(stack)-1]
+			for _, j := range f.fixups {
+				seq[j].control.TargetPC = inst.pc
+			}
+		case "br", "br_if":
+			f, ok := target(inst.args[0])
+			if !ok {
+				inst.control = Control{Type: ControlJump, Conditional: inst.op == "br_if"}
+				break
+			}
+			branch(i, f, inst.op == "br_if")
+		case "br_table":
+			// This could go to several places. Leave the
+			// target unknown.
+			inst.control = Control{Type: ControlJump}
+		case "return", "return_call", "return_call_indirect":
+			inst.control = Control{Type: ControlRet}
+		case "unreachable":
+			inst.control = Control{Type: ControlExit}
+		case "call", "call_indirect":
+			inst.control = Control{Type: ControlCall}
+		}
+	}
+}
+
+func (i *wasmInst) GoSyntax(symname func(uint64) (string, uint64)) string {
+	if len(i.args) == 0 {
+		return i.op
+	}
+	return i.op + " " + strings.Join(i.args, ", ")
+}
+
+// GNUSyntax returns the WebAssembly text format, like GoSyntax.
+func (i *wasmInst) GNUSyntax(symname func(uint64) (string, uint64)) string {
+	return i.GoSyntax(symname)
+}
+
+// IntelSyntax returns the WebAssembly text format, like GoSyntax.
+func (i *wasmInst) IntelSyntax(symname func(uint64) (string, uint64)) string {
+	return i.GoSyntax(symname)
+}
+
+func (i *wasmInst) PC() uint64 {
+	return i.pc
+}
+
+func (i *wasmInst) Len() int {
+	return i.len
+}
+
+func (i *wasmInst) Control() Control {
+	return i.control
+}
+
+func (i *wasmInst) Effects() (read, write LocSet) {
+	// TODO: Track locals and the operand stack.
+	read, write = make(LocSet), make(LocSet)
+	switch i.mem {
+	case wasmMemLoad:
+		read.Add(LocMem)
+	case wasmMemStore:
+		write.Add(LocMem)
+	}
+	return
+}
+
+func (i *wasmInst) Prefixes() []Prefix {
+	return nil
+}
+
+func (i *wasmInst) MemArgs() []MemArg {
+	// Memory operands are addresses in linear memory, which is a
+	// separate address space from the module, so there's nothing
+	// useful to report.
+	return nil
+}
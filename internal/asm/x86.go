@@ -51,7 +51,41 @@ func (i *x86Inst) GoSyntax(symname func(uint64) (string, uint64)) string {
 	if i.Op == 0 {
 		return "?"
 	}
-	return x86asm.GoSyntax(i.Inst, i.pc, symname)
+	return x86asm.GoSyntax(i.Inst, i.pc, i.symname(symname))
+}
+
+func (i *x86Inst) GNUSyntax(symname func(uint64) (string, uint64)) string {
+	if i.Op == 0 {
+		return "?"
+	}
+	return x86asm.GNUSyntax(i.Inst, i.pc, i.symname(symname))
+}
+
+func (i *x86Inst) IntelSyntax(symname func(uint64) (string, uint64)) string {
+	if i.Op == 0 {
+		return "?"
+	}
+	return x86asm.IntelSyntax(i.Inst, i.pc, i.symname(symname))
+}
+
+// symname adapts a symbol lookup function for x86asm.
+func (i *x86Inst) symname(symname func(uint64) (string, uint64)) x86asm.SymLookup {
+	if i.Mode == 32 && symname != nil {
+		// x86asm sign-extends 32-bit absolute addresses.
+		return func(addr uint64) (string, uint64) {
+			return symname(uint64(uint32(addr)))
+		}
+	}
+	return symname
+}
+
+// addr truncates an address computed from i's operands to i's
+// address size.
+func (i *x86Inst) addr(v int64) uint64 {
+	if i.Mode == 32 {
+		return uint64(uint32(v))
+	}
+	return uint64(v)
 }
 
 func (i *x86Inst) PC() uint64 {
@@ -105,7 +139,7 @@ func (i *x86Inst) Control() Control {
 		panic(fmt.Sprintf("expected one argument, got %s", i))
 	}
 	if rel, ok := i.Args[0].(x86asm.Rel); ok {
-		c.TargetPC = uint64(int64(i.pc) + int64(i.Inst.Len) + int64(rel))
+		c.TargetPC = i.addr(int64(i.pc) + int64(i.Inst.Len) + int64(rel))
 	}
 	c.Target = i.Args[0]
 	return c
@@ -144,6 +178,149 @@ func (l locX86Reg) String() string {
 	return fmt.Sprintf("locX86Reg(%d)", l)
 }
 
+// x86RegLoc returns the Loc of register reg. rmw indicates that a
+// write to reg only writes part of the Loc, so it is effectively a
+// read-modify-write of the Loc.
+func x86RegLoc(reg x86asm.Reg) (loc locX86Reg, rmw bool, ok bool) {
+	switch {
+	case x86asm.AL <= reg && reg <= x86asm.R15B:
+		// 8- and 16-bit writes modify *part* of a
+		// register, making these read/write of the
+		// larger register.
+		return locX86Reg(reg-x86asm.AL) + locAX, true, true
+	case x86asm.AX <= reg && reg <= x86asm.R15W:
+		return locX86Reg(reg-x86asm.AX) + locAX, true, true
+	case x86asm.EAX <= reg && reg <= x86asm.R15L:
+		// These are zero-extended to 64 bits, and
+		// hence *not* RMW.
+		return locX86Reg(reg-x86asm.EAX) + locAX, false, true
+	case x86asm.RAX <= reg && reg <= x86asm.R15:
+		return locX86Reg(reg-x86asm.RAX) + locAX, false, true
+	case x86asm.F0 <= reg && reg <= x86asm.F7:
+		return locX86Reg(reg-x86asm.F0) + locF0, false, true
+	case x86asm.M0 <= reg && reg <= x86asm.M7:
+		return locX86Reg(reg-x86asm.M0) + locM0, false, true
+	case x86asm.X0 <= reg && reg <= x86asm.X15:
+		return locX86Reg(reg-x86asm.X0) + locX0, false, true
+	case x86asm.ES <= reg && reg <= x86asm.GS:
+		return locX86Reg(reg-x86asm.ES) + locES, false, true
+	}
+	return 0, false, false
+}
+
+// argEffects returns the effect of inst on each of its explicit
+// arguments.
+func (inst *x86Inst) argEffects() []effect {
+	narg := len(inst.Args)
+	for i, arg := range inst.Args {
+		if arg == nil {
+			narg = i
+			break
+		}
+	}
+	return x86Args[x86ArgsKey{inst.Op, narg}]
+}
+
+func (inst *x86Inst) MemArgs() []MemArg {
+	var out []MemArg
+	effects := inst.argEffects()
+	narg := len(inst.Args)
+	for i, arg := range inst.Args {
+		if arg == nil {
+			narg = i
+			break
+		}
+	}
+	for i, arg := range inst.Args[:narg] {
+		mem, ok := arg.(x86asm.Mem)
+		if !ok {
+			continue
+		}
+		if inst.Op == x86asm.LEA {
+			// LEA doesn't actually access memory.
+			continue
+		}
+		// Go syntax reverses the order of the arguments.
+		ma := MemArg{Arg: narg - 1 - i, Scale: int64(mem.Scale), Disp: mem.Disp, Size: inst.MemBytes}
+		switch mem.Base {
+		case 0:
+		case x86asm.IP, x86asm.EIP, x86asm.RIP:
+			// Resolve PC-relative addresses.
+			ma.Disp += int64(inst.pc) + int64(inst.Inst.Len)
+		default:
+			if loc, _, ok := x86RegLoc(mem.Base); ok {
+				ma.Base = loc
+			}
+		}
+		if mem.Index != 0 {
+			if loc, _, ok := x86RegLoc(mem.Index); ok {
+				ma.Index = loc
+			}
+		}
+		if ma.Base == nil && ma.Index == nil {
+			ma.Disp = int64(inst.addr(ma.Disp))
+		} else if inst.Mode == 32 {
+			// x86asm zero-extends 32-bit displacements.
+			ma.Disp = int64(int32(ma.Disp))
+		}
+		if i < len(effects) {
+			ma.Read = effects[i]&r != 0
+			ma.Write = effects[i]&w != 0
+		} else {
+			// Unknown effects. Be conservative.
+			ma.Read, ma.Write = true, true
+		}
+		out = append(out, ma)
+	}
+	return out
+}
+
+func (inst *x86Inst) Prefixes() []Prefix {
+	var out []Prefix
+	for _, p := range inst.Prefix {
+		if p == 0 {
+			break
+		}
+		implicit := p&(x86asm.PrefixImplicit|x86asm.PrefixIgnored) != 0
+		out = append(out, Prefix{p.String(), implicit})
+	}
+	return out
+}
+
+func (inst *x86Inst) refs() []Ref {
+	var out []Ref
+	narg := len(inst.Args)
+	for i, arg := range inst.Args {
+		if arg == nil {
+			narg = i
+			break
+		}
+	}
+	for i, arg := range inst.Args[:narg] {
+		mem, ok := arg.(x86asm.Mem)
+		if !ok || mem.Index != 0 || mem.Segment != 0 {
+			// Segment-relative addresses are usually TLS
+			// offsets, not addresses.
+			continue
+		}
+		var addr int64
+		switch mem.Base {
+		case 0:
+			if mem.Disp == 0 {
+				continue
+			}
+			addr = mem.Disp
+		case x86asm.IP, x86asm.EIP, x86asm.RIP:
+			addr = int64(inst.pc) + int64(inst.Inst.Len) + mem.Disp
+		default:
+			continue
+		}
+		// Go syntax reverses the order of the arguments.
+		out = append(out, Ref{Arg: narg - 1 - i, Addr: inst.addr(addr)})
+	}
+	return out
+}
+
 func (inst *x86Inst) Effects() (read, write LocSet) {
 	// TODO: Separate each argument? Tricky with implicit effects.
 	//
@@ -183,33 +360,8 @@ func (inst *x86Inst) Effects() (read, write LocSet) {
 			return
 		}
 
-		var rmw bool
-		var loc locX86Reg
-		switch {
-		case x86asm.AL <= reg && reg <= x86asm.R15B:
-			// 8- and 16-bit writes modify *part* of a
-			// register, making these read/write of the
-			// larger register.
-			rmw = true
-			loc = locX86Reg(reg-x86asm.AL) + locAX
-		case x86asm.AX <= reg && reg <= x86asm.R15W:
-			rmw = true
-			loc = locX86Reg(reg-x86asm.AX) + locAX
-		case x86asm.EAX <= reg && reg <= x86asm.R15L:
-			// These are zero-extended to 64 bits, and
-			// hence *not* RMW.
-			loc = locX86Reg(reg-x86asm.EAX) + locAX
-		case x86asm.RAX <= reg && reg <= x86asm.R15:
-			loc = locX86Reg(reg-x86asm.RAX) + locAX
-		case x86asm.F0 <= reg && reg <= x86asm.F7:
-			loc = locX86Reg(reg-x86asm.F0) + locF0
-		case x86asm.M0 <= reg && reg <= x86asm.M7:
-			loc = locX86Reg(reg-x86asm.M0) + locM0
-		case x86asm.X0 <= reg && reg <= x86asm.X15:
-			loc = locX86Reg(reg-x86asm.X0) + locX0
-		case x86asm.ES <= reg && reg <= x86asm.GS:
-			loc = locX86Reg(reg-x86asm.ES) + locES
-		default:
+		loc, rmw, ok := x86RegLoc(reg)
+		if !ok {
 			panic(fmt.Sprintf("unknown register %s in %s", reg, inst.Inst))
 		}
 		if rmw && e == w {
@@ -224,22 +376,12 @@ func (inst *x86Inst) Effects() (read, write LocSet) {
 	}
 
 	// Argument effects.
-	var effects []effect
 	switch inst.Op {
 	case x86asm.MOVHPD, x86asm.MOVHPS, x86asm.MOVLPD, x86asm.MOVLPS:
 		// TODO
 		panic("not implemented")
-	default:
-		narg := len(inst.Args)
-		for i, arg := range inst.Args {
-			if arg == nil {
-				narg = i
-				break
-			}
-		}
-		effects = x86Args[x86ArgsKey{inst.Op, narg}]
 	}
-	for i, effect := range effects {
+	for i, effect := range inst.argEffects() {
 		arg := inst.Args[i]
 		switch arg := arg.(type) {
 		case x86asm.Reg:
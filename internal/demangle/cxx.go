@@ -0,0 +1,1442 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package demangle decodes mangled symbol names into human-readable
+// form.
+package demangle
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrNotMangled is returned when a name is not in the expected
+// mangled form.
+var ErrNotMangled = errors.New("not a mangled name")
+
+// IsCxx returns whether name looks like an Itanium C++ ABI mangled
+// name. This is the mangling used by GCC and Clang on nearly all
+// non-Windows platforms.
+func IsCxx(name string) bool {
+	return strings.HasPrefix(name, "_Z") || strings.HasPrefix(name, "__Z")
+}
+
+// Cxx demangles an Itanium C++ ABI mangled name, producing the same
+// output as GNU c++filt. If name is not a mangled name, it returns
+// ErrNotMangled. If name cannot be demangled, it returns some other
+// error.
+func Cxx(name string) (out string, err error) {
+	if strings.HasPrefix(name, "__Z") {
+		// Mach-O adds an extra leading underscore.
+		name = name[1:]
+	}
+	if !strings.HasPrefix(name, "_Z") {
+		return "", ErrNotMangled
+	}
+	// Strip any ELF symbol version and add it back at the end.
+	var version string
+	if i := strings.IndexByte(name, '@'); i >= 0 {
+		name, version = name[:i], name[i:]
+	}
+
+	st := &cxxState{str: name, pos: 2}
+	defer func() {
+		if e := recover(); e != nil {
+			if e, ok := e.(cxxError); ok {
+				out, err = "", e
+				return
+			}
+			panic(e)
+		}
+	}()
+	n := st.encoding(true)
+	n = st.cloneSuffixes(n)
+	if st.pos != len(st.str) {
+		st.fail("unparsed characters at end of name")
+	}
+
+	var p cxxPrinter
+	n.left(&p)
+	n.right(&p)
+	return string(p.buf) + version, nil
+}
+
+type cxxError struct {
+	pos int
+	msg string
+}
+
+func (e cxxError) Error() string {
+	return fmt.Sprintf("demangling failed at offset %d: %s", e.pos, e.msg)
+}
+
+// cxxState is the parser state for an Itanium C++ mangled name.
+type cxxState struct {
+	str string
+	pos int
+
+	// subs is the substitution table.
+	subs []cxxNode
+
+	// tmplArgs is the most recent template argument list of the
+	// encoding's name, used to resolve template parameters.
+	tmplArgs []cxxNode
+}
+
+func (st *cxxState) fail(msg string) {
+	panic(cxxError{st.pos, msg})
+}
+
+func (st *cxxState) peek() byte {
+	if st.pos >= len(st.str) {
+		return 0
+	}
+	return st.str[st.pos]
+}
+
+func (st *cxxState) peekAt(i int) byte {
+	if st.pos+i >= len(st.str) {
+		return 0
+	}
+	return st.str[st.pos+i]
+}
+
+func (st *cxxState) hasPrefix(s string) bool {
+	return st.pos <= len(st.str) && strings.HasPrefix(st.str[st.pos:], s)
+}
+
+func (st *cxxState) advance(n int) {
+	if st.pos+n > len(st.str) {
+		st.fail("unexpected end of name")
+	}
+	st.pos += n
+}
+
+func (st *cxxState) expect(c byte) {
+	if st.peek() != c {
+		st.fail(fmt.Sprintf("expected %q", c))
+	}
+	st.pos++
+}
+
+// number parses a decimal number, which may be negative if prefixed
+// by "n".
+func (st *cxxState) number() int {
+	neg := false
+	if st.peek() == 'n' {
+		neg = true
+		st.pos++
+	}
+	start := st.pos
+	val := 0
+	for isDigit(st.peek()) {
+		if val > (math.MaxInt32-9)/10 {
+			st.fail("number too large")
+		}
+		val = val*10 + int(st.peek()-'0')
+		st.pos++
+	}
+	if start == st.pos {
+		st.fail("expected number")
+	}
+	if neg {
+		return -val
+	}
+	return val
+}
+
+// seqID parses a base-36 sequence ID terminated by "_". An empty
+// sequence ID is -1.
+func (st *cxxState) seqID() int {
+	if st.peek() == '_' {
+		st.pos++
+		return -1
+	}
+	val := 0
+	for {
+		c := st.peek()
+		if val > (math.MaxInt32-35)/36 {
+			st.fail("sequence ID too large")
+		}
+		switch {
+		case isDigit(c):
+			val = val*36 + int(c-'0')
+		case 'A' <= c && c <= 'Z':
+			val = val*36 + int(c-'A') + 10
+		case c == '_':
+			st.pos++
+			return val
+		default:
+			st.fail("bad sequence ID")
+		}
+		st.pos++
+	}
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// nameInfo records properties of a parsed <name> that affect the
+// enclosing encoding.
+type nameInfo struct {
+	// template indicates the name ends in template arguments.
+	template bool
+	// noReturn indicates the name is a constructor, destructor,
+	// or conversion operator, which never have a mangled return
+	// type.
+	noReturn bool
+	// cv and ref are the method qualifiers from a nested name.
+	cv, ref string
+}
+
+// encoding parses an <encoding>. If top is true, this is the
+// outermost encoding of the mangled name.
+func (st *cxxState) encoding(top bool) cxxNode {
+	switch {
+	case st.peek() == 'T' || st.hasPrefix("GV") || st.hasPrefix("GR") || st.hasPrefix("GTt"):
+		return st.specialName()
+	}
+
+	name, info := st.name()
+	switch st.peek() {
+	case 0, 'E', '.':
+		// Data name.
+		return name
+	}
+
+	// Function name with a <bare-function-type>.
+	var ret cxxNode
+	if info.template && !info.noReturn {
+		ret = st.typ()
+	}
+	params := st.params()
+	return &cxxFunction{name: name, ret: ret, params: params, cv: info.cv, ref: info.ref}
+}
+
+// params parses a list of parameter types, ending at the end of the
+// enclosing construct.
+func (st *cxxState) params() []cxxNode {
+	var params []cxxNode
+	for {
+		switch st.peek() {
+		case 0, 'E', '.':
+			if len(params) == 0 {
+				st.fail("missing function parameters")
+			}
+			if len(params) == 1 && isVoid(params[0]) {
+				return nil
+			}
+			return params
+		}
+		if st.hasPrefix("R") && len(params) > 0 && st.peekAt(1) == 'E' {
+			// Trailing ref-qualifier on a function type.
+			return params
+		}
+		if st.hasPrefix("O") && len(params) > 0 && st.peekAt(1) == 'E' {
+			return params
+		}
+		params = append(params, st.typ())
+	}
+}
+
+func isVoid(n cxxNode) bool {
+	b, ok := n.(*cxxName)
+	return ok && b.s == "void"
+}
+
+// cloneSuffixes parses any GCC clone suffixes, such as ".cold" or
+// ".isra.0".
+func (st *cxxState) cloneSuffixes(n cxxNode) cxxNode {
+	for st.peek() == '.' {
+		start := st.pos
+		st.pos++
+		c := st.peek()
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || isDigit(c)) {
+			st.fail("bad clone suffix")
+		}
+		for c := st.peek(); 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'; c = st.peek() {
+			st.pos++
+		}
+		// Numeric parts belong to the same clone.
+		for st.peek() == '.' && isDigit(st.peekAt(1)) {
+			st.pos++
+			for isDigit(st.peek()) {
+				st.pos++
+			}
+		}
+		n = &cxxSuffix{n, " [clone " + st.str[start:st.pos] + "]"}
+	}
+	return n
+}
+
+func (st *cxxState) specialName() cxxNode {
+	switch {
+	case st.hasPrefix("TV"):
+		st.advance(2)
+		return &cxxPrefixed{"vtable for ", st.typ()}
+	case st.hasPrefix("TT"):
+		st.advance(2)
+		return &cxxPrefixed{"VTT for ", st.typ()}
+	case st.hasPrefix("TI"):
+		st.advance(2)
+		return &cxxPrefixed{"typeinfo for ", st.typ()}
+	case st.hasPrefix("TS"):
+		st.advance(2)
+		return &cxxPrefixed{"typeinfo name for ", st.typ()}
+	case st.hasPrefix("TH"):
+		st.advance(2)
+		n, _ := st.name()
+		return &cxxPrefixed{"TLS init function for ", n}
+	case st.hasPrefix("TW"):
+		st.advance(2)
+		n, _ := st.name()
+		return &cxxPrefixed{"TLS wrapper function for ", n}
+	case st.hasPrefix("Th"):
+		st.advance(1)
+		st.callOffset('h')
+		return &cxxPrefixed{"non-virtual thunk to ", st.encoding(false)}
+	case st.hasPrefix("Tv"):
+		st.advance(1)
+		st.callOffset('v')
+		return &cxxPrefixed{"virtual thunk to ", st.encoding(false)}
+	case st.hasPrefix("Tc"):
+		st.advance(2)
+		st.callOffset(0)
+		st.callOffset(0)
+		return &cxxPrefixed{"covariant return thunk to ", st.encoding(false)}
+	case st.hasPrefix("TC"):
+		st.advance(2)
+		derived := st.typ()
+		st.number()
+		st.expect('_')
+		base := st.typ()
+		return &cxxPrefixed{"construction vtable for ", &cxxJoin{base, "-in-", derived}}
+	case st.hasPrefix("GV"):
+		st.advance(2)
+		n, _ := st.name()
+		return &cxxPrefixed{"guard variable for ", n}
+	case st.hasPrefix("GR"):
+		st.advance(2)
+		n, _ := st.name()
+		id := 0
+		if st.peek() != '_' {
+			id = st.seqID() + 1
+		} else {
+			st.pos++
+		}
+		return &cxxPrefixed{fmt.Sprintf("reference temporary #%d for ", id), n}
+	case st.hasPrefix("GTt"):
+		st.advance(3)
+		return &cxxPrefixed{"transaction clone for ", st.encoding(false)}
+	}
+	st.fail("unknown special name")
+	return nil
+}
+
+// callOffset parses a <call-offset>. If kind is non-zero, the offset
+// must be of that kind.
+func (st *cxxState) callOffset(kind byte) {
+	c := st.peek()
+	if kind != 0 && c != kind {
+		st.fail("bad call offset")
+	}
+	switch c {
+	case 'h':
+		st.pos++
+		st.number()
+		st.expect('_')
+	case 'v':
+		st.pos++
+		st.number()
+		st.expect('_')
+		st.number()
+		st.expect('_')
+	default:
+		st.fail("bad call offset")
+	}
+}
+
+// name parses a <name>.
+func (st *cxxState) name() (cxxNode, nameInfo) {
+	switch st.peek() {
+	case 'N':
+		return st.nestedName()
+	case 'Z':
+		return st.localName()
+	}
+
+	var n cxxNode
+	var info nameInfo
+	if st.hasPrefix("St") {
+		st.advance(2)
+		u, _ := st.unqualifiedName(nil)
+		n = &cxxQualified{&cxxName{"std"}, u}
+	} else if st.peek() == 'S' {
+		// Must be an <unscoped-template-name>.
+		n = st.substitution()
+		if st.peek() != 'I' {
+			return n, info
+		}
+	} else {
+		n, info.noReturn = st.unqualifiedName(nil)
+	}
+
+	if st.peek() == 'I' {
+		st.subs = append(st.subs, n)
+		args := st.templateArgs(true)
+		n = &cxxTemplate{n, args}
+		info.template = true
+	}
+	return n, info
+}
+
+// nestedName parses a <nested-name>.
+func (st *cxxState) nestedName() (cxxNode, nameInfo) {
+	st.expect('N')
+	var info nameInfo
+	info.cv = st.cvQualifiers()
+	switch {
+	case st.hasPrefix("R"):
+		info.ref = " &"
+		st.pos++
+	case st.hasPrefix("O"):
+		info.ref = " &&"
+		st.pos++
+	}
+
+	var prefix cxxNode
+	for st.peek() != 'E' {
+		var n cxxNode
+		info.template = false
+		switch c := st.peek(); {
+		case c == 'S' && st.peekAt(1) == 't':
+			if prefix != nil {
+				st.fail("unexpected std:: in nested name")
+			}
+			st.advance(2)
+			prefix = &cxxName{"std"}
+			continue
+		case c == 'S':
+			if prefix != nil {
+				st.fail("unexpected substitution in nested name")
+			}
+			prefix = st.substitution()
+			continue
+		case c == 'I':
+			if prefix == nil {
+				st.fail("template arguments without a name")
+			}
+			n = &cxxTemplate{prefix, st.templateArgs(true)}
+			info.template = true
+			prefix = nil
+		case c == 'T':
+			if prefix != nil {
+				st.fail("unexpected template parameter in nested name")
+			}
+			n = st.templateParam()
+		case c == 'D' && (st.peekAt(1) == 't' || st.peekAt(1) == 'T'):
+			n = st.decltype()
+		case c == 'M':
+			// Closure prefix for lambdas in initializers.
+			st.pos++
+			continue
+		default:
+			var noReturn bool
+			n, noReturn = st.unqualifiedName(prefix)
+			info.noReturn = noReturn
+		}
+		if prefix != nil {
+			n = &cxxQualified{prefix, n}
+		}
+		prefix = n
+		if st.peek() != 'E' {
+			st.subs = append(st.subs, prefix)
+		}
+	}
+	st.pos++
+	if prefix == nil {
+		st.fail("empty nested name")
+	}
+	return prefix, info
+}
+
+func (st *cxxState) cvQualifiers() string {
+	var r, v, k bool
+	for {
+		switch st.peek() {
+		case 'r':
+			r = true
+		case 'V':
+			v = true
+		case 'K':
+			k = true
+		default:
+			var q string
+			if k {
+				q += " const"
+			}
+			if v {
+				q += " volatile"
+			}
+			if r {
+				q += " restrict"
+			}
+			return q
+		}
+		st.pos++
+	}
+}
+
+// localName parses a <local-name>.
+func (st *cxxState) localName() (cxxNode, nameInfo) {
+	st.expect('Z')
+	enc := st.encoding(false)
+	st.expect('E')
+	if st.peek() == 's' {
+		st.pos++
+		st.discriminator()
+		return &cxxQualified{enc, &cxxName{"string literal"}}, nameInfo{}
+	}
+	if st.peek() == 'd' {
+		// Default argument scope.
+		st.pos++
+		if st.peek() != '_' {
+			st.number()
+		}
+		st.expect('_')
+	}
+	n, info := st.name()
+	st.discriminator()
+	return &cxxQualified{enc, n}, info
+}
+
+func (st *cxxState) discriminator() {
+	if st.peek() != '_' {
+		return
+	}
+	st.pos++
+	if st.peek() == '_' {
+		st.pos++
+		st.number()
+		st.expect('_')
+		return
+	}
+	if !isDigit(st.peek()) {
+		st.fail("bad discriminator")
+	}
+	st.pos++
+}
+
+// unqualifiedName parses an <unqualified-name>. prefix is the
+// enclosing scope, if any, which is needed to name constructors and
+// destructors. noReturn indicates the name is a constructor,
+// destructor, or conversion operator.
+func (st *cxxState) unqualifiedName(prefix cxxNode) (n cxxNode, noReturn bool) {
+	if st.peek() == 'L' {
+		// Internal linkage.
+		st.pos++
+	}
+	switch c := st.peek(); {
+	case isDigit(c):
+		n = st.sourceName()
+	case c == 'C' && (isDigit(st.peekAt(1)) || st.peekAt(1) == 'I'):
+		st.pos++
+		if st.peek() == 'I' {
+			// Inheriting constructor.
+			st.pos++
+			if c := st.peek(); c != '1' && c != '2' && c != '3' {
+				st.fail("bad constructor")
+			}
+			st.pos++
+			st.typ()
+		} else {
+			st.pos++
+		}
+		n, noReturn = &cxxName{ctorName(st, prefix)}, true
+	case c == 'D' && isDigit(st.peekAt(1)):
+		st.advance(2)
+		n, noReturn = &cxxName{"~" + ctorName(st, prefix)}, true
+	case c == 'U' && st.peekAt(1) == 't':
+		st.advance(2)
+		id := 1
+		if st.peek() != '_' {
+			id = st.number() + 2
+		}
+		st.expect('_')
+		n = &cxxName{fmt.Sprintf("{unnamed type#%d}", id)}
+	case c == 'U' && st.peekAt(1) == 'l':
+		st.advance(2)
+		var params []cxxNode
+		for st.peek() != 'E' {
+			params = append(params, st.typ())
+		}
+		st.pos++
+		if len(params) == 1 && isVoid(params[0]) {
+			params = nil
+		}
+		id := 1
+		if st.peek() != '_' {
+			id = st.number() + 2
+		}
+		st.expect('_')
+		n = &cxxLambda{params, id}
+	case 'a' <= c && c <= 'z':
+		n, noReturn = st.operatorName()
+	default:
+		st.fail("bad unqualified name")
+	}
+
+	// ABI tags.
+	for st.peek() == 'B' {
+		st.pos++
+		tag := st.sourceName()
+		n = &cxxSuffix{n, "[abi:" + tag.s + "]"}
+	}
+	return n, noReturn
+}
+
+// ctorName returns the name of a constructor in scope prefix.
+func ctorName(st *cxxState, prefix cxxNode) string {
+	for {
+		switch n := prefix.(type) {
+		case *cxxName:
+			return n.s
+		case *cxxQualified:
+			prefix = n.name
+		case *cxxTemplate:
+			prefix = n.name
+		case *cxxSuffix:
+			prefix = n.n
+		case *cxxStdSub:
+			return n.ctor
+		default:
+			st.fail("constructor with unknown class name")
+		}
+	}
+}
+
+func (st *cxxState) sourceName() *cxxName {
+	n := st.number()
+	if n <= 0 || st.pos+n > len(st.str) {
+		st.fail("bad source name length")
+	}
+	id := st.str[st.pos : st.pos+n]
+	st.pos += n
+	if strings.HasPrefix(id, "_GLOBAL_") && len(id) > 9 && (id[8] == '.' || id[8] == '_' || id[8] == '$') && id[9] == 'N' {
+		id = "(anonymous namespace)"
+	}
+	return &cxxName{id}
+}
+
+var cxxOperators = map[string]string{
+	"nw": "new", "na": "new[]", "dl": "delete", "da": "delete[]",
+	"ps": "+", "ng": "-", "ad": "&", "de": "*", "co": "~",
+	"pl": "+", "mi": "-", "ml": "*", "dv": "/", "rm": "%",
+	"an": "&", "or": "|", "eo": "^", "aS": "=",
+	"pL": "+=", "mI": "-=", "mL": "*=", "dV": "/=", "rM": "%=",
+	"aN": "&=", "oR": "|=", "eO": "^=",
+	"ls": "<<", "rs": ">>", "lS": "<<=", "rS": ">>=",
+	"eq": "==", "ne": "!=", "lt": "<", "gt": ">", "le": "<=", "ge": ">=",
+	"ss": "<=>", "nt": "!", "aa": "&&", "oo": "||",
+	"pp": "++", "mm": "--", "cm": ",", "pm": "->*", "pt": "->",
+	"cl": "()", "ix": "[]", "qu": "?",
+	"st": "sizeof ", "sz": "sizeof ", "at": "alignof ", "az": "alignof ",
+	"aw": "co_await",
+}
+
+func (st *cxxState) operatorName() (cxxNode, bool) {
+	if st.pos+2 > len(st.str) {
+		st.fail("bad operator name")
+	}
+	code := st.str[st.pos : st.pos+2]
+	st.pos += 2
+	switch code {
+	case "cv":
+		return &cxxPrefixed{"operator ", st.typ()}, true
+	case "li":
+		return &cxxName{"operator\"\" " + st.sourceName().s}, false
+	}
+	if code[0] == 'v' && isDigit(code[1]) {
+		// Vendor extended operator.
+		return &cxxName{"operator " + st.sourceName().s}, false
+	}
+	op, ok := cxxOperators[code]
+	if !ok {
+		st.fail("unknown operator " + code)
+	}
+	if 'a' <= op[0] && op[0] <= 'z' {
+		return &cxxName{"operator " + op}, false
+	}
+	return &cxxName{"operator" + op}, false
+}
+
+// stdSubs are the special abbreviations for std names. Like c++filt,
+// we always print these in full.
+var stdSubs = map[byte]*cxxStdSub{
+	'a': {"std::allocator", "allocator"},
+	'b': {"std::basic_string", "basic_string"},
+	's': {"std::basic_string<char, std::char_traits<char>, std::allocator<char> >", "basic_string"},
+	'i': {"std::basic_istream<char, std::char_traits<char> >", "basic_istream"},
+	'o': {"std::basic_ostream<char, std::char_traits<char> >", "basic_ostream"},
+	'd': {"std::basic_iostream<char, std::char_traits<char> >", "basic_iostream"},
+}
+
+func (st *cxxState) substitution() cxxNode {
+	st.expect('S')
+	if sub, ok := stdSubs[st.peek()]; ok {
+		st.pos++
+		return sub
+	}
+	id := st.seqID() + 1
+	if id < 0 || id >= len(st.subs) {
+		st.fail("substitution index out of range")
+	}
+	return st.subs[id]
+}
+
+func (st *cxxState) templateArgs(record bool) []cxxNode {
+	st.expect('I')
+	var args []cxxNode
+	for st.peek() != 'E' {
+		args = append(args, st.templateArg())
+	}
+	st.pos++
+	if record {
+		st.tmplArgs = args
+	}
+	return args
+}
+
+func (st *cxxState) templateArg() cxxNode {
+	switch st.peek() {
+	case 'X':
+		st.pos++
+		e := st.expression()
+		st.expect('E')
+		return e
+	case 'L':
+		return st.exprPrimary()
+	case 'J':
+		st.pos++
+		var args []cxxNode
+		for st.peek() != 'E' {
+			args = append(args, st.templateArg())
+		}
+		st.pos++
+		return &cxxPack{args}
+	}
+	return st.typ()
+}
+
+func (st *cxxState) templateParam() cxxNode {
+	st.expect('T')
+	idx := st.seqID() + 1
+	if idx < 0 || idx >= len(st.tmplArgs) {
+		st.fail("template parameter out of range")
+	}
+	return st.tmplArgs[idx]
+}
+
+var cxxBuiltins = map[byte]string{
+	'v': "void", 'w': "wchar_t", 'b': "bool", 'c': "char",
+	'a': "signed char", 'h': "unsigned char", 's': "short",
+	't': "unsigned short", 'i': "int", 'j': "unsigned int",
+	'l': "long", 'm': "unsigned long", 'x': "long long",
+	'y': "unsigned long long", 'n': "__int128",
+	'o': "unsigned __int128", 'f': "float", 'd': "double",
+	'e': "long double", 'g': "__float128", 'z': "...",
+}
+
+var cxxDBuiltins = map[byte]string{
+	'd': "decimal64", 'e': "decimal128", 'f': "decimal32",
+	'h': "half", 'i': "char32_t", 's': "char16_t", 'u': "char8_t",
+	'a': "auto", 'c': "decltype(auto)", 'n': "decltype(nullptr)",
+}
+
+// typ parses a <type>.
+func (st *cxxState) typ() cxxNode {
+	c := st.peek()
+	if b, ok := cxxBuiltins[c]; ok {
+		st.pos++
+		return &cxxName{b}
+	}
+
+	var n cxxNode
+	switch c {
+	case 'u':
+		st.pos++
+		n = st.sourceName()
+	case 'D':
+		c2 := st.peekAt(1)
+		if b, ok := cxxDBuiltins[c2]; ok {
+			st.advance(2)
+			return &cxxName{b}
+		}
+		switch c2 {
+		case 'F':
+			st.advance(2)
+			bits := st.number()
+			st.expect('_')
+			return &cxxName{fmt.Sprintf("_Float%d", bits)}
+		case 'p':
+			st.advance(2)
+			n = expandPack(st.typ())
+		case 't', 'T':
+			n = st.decltype()
+		default:
+			st.fail("unknown D type")
+		}
+	case 'r', 'V', 'K':
+		cv := st.cvQualifiers()
+		sub := st.typ()
+		if fn, ok := sub.(*cxxFuncType); ok {
+			// Qualifiers on a function type are method
+			// qualifiers.
+			fn2 := *fn
+			fn2.cv = cv
+			n = &fn2
+		} else {
+			n = &cxxQual{sub, cv}
+		}
+	case 'P':
+		st.pos++
+		n = &cxxPointer{st.typ(), "*"}
+	case 'R':
+		st.pos++
+		n = makeRef(st.typ(), "&")
+	case 'O':
+		st.pos++
+		n = makeRef(st.typ(), "&&")
+	case 'C':
+		st.pos++
+		n = &cxxSuffix{st.typ(), " _Complex"}
+	case 'G':
+		st.pos++
+		n = &cxxSuffix{st.typ(), " _Imaginary"}
+	case 'F':
+		n = st.functionType()
+	case 'A':
+		st.pos++
+		var dim string
+		if isDigit(st.peek()) {
+			dim = fmt.Sprint(st.number())
+		} else if st.peek() != '_' {
+			var p cxxPrinter
+			e := st.expression()
+			e.left(&p)
+			e.right(&p)
+			dim = string(p.buf)
+		}
+		st.expect('_')
+		n = &cxxArray{st.typ(), dim}
+	case 'M':
+		st.pos++
+		class := st.typ()
+		member := st.typ()
+		n = &cxxPtrToMember{class, member}
+	case 'T':
+		n = st.templateParam()
+		if st.peek() == 'I' {
+			// Template template parameter.
+			st.subs = append(st.subs, n)
+			n = &cxxTemplate{n, st.templateArgs(false)}
+		}
+	case 'S':
+		if st.peekAt(1) == 't' {
+			n = st.typeName()
+			break
+		}
+		n = st.substitution()
+		if st.peek() != 'I' {
+			// Substitutions aren't themselves
+			// substitution candidates.
+			return n
+		}
+		n = &cxxTemplate{n, st.templateArgs(false)}
+	default:
+		// <class-enum-type>
+		n = st.typeName()
+	}
+	st.subs = append(st.subs, n)
+	return n
+}
+
+// expandPack expands a pack expansion pattern n. If n refers to an
+// argument pack, this returns a pack of n instantiated with each
+// element. Otherwise, it returns the pattern followed by "...".
+func expandPack(n cxxNode) cxxNode {
+	pack := findPack(n)
+	if pack == nil {
+		return &cxxSuffix{n, "..."}
+	}
+	elems := make([]cxxNode, len(pack.elems))
+	for i, elem := range pack.elems {
+		elems[i] = substPack(n, pack, elem)
+	}
+	return &cxxPack{elems}
+}
+
+func findPack(n cxxNode) *cxxPack {
+	switch n := n.(type) {
+	case *cxxPack:
+		return n
+	case *cxxPointer:
+		return findPack(n.child)
+	case *cxxQual:
+		return findPack(n.child)
+	case *cxxTemplate:
+		for _, arg := range n.args {
+			if p := findPack(arg); p != nil {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+func substPack(n cxxNode, pack *cxxPack, elem cxxNode) cxxNode {
+	switch n := n.(type) {
+	case *cxxPack:
+		if n == pack {
+			return elem
+		}
+	case *cxxPointer:
+		if n.sym != "*" {
+			return makeRef(substPack(n.child, pack, elem), n.sym)
+		}
+		return &cxxPointer{substPack(n.child, pack, elem), n.sym}
+	case *cxxQual:
+		return &cxxQual{substPack(n.child, pack, elem), n.quals}
+	case *cxxTemplate:
+		args := make([]cxxNode, len(n.args))
+		for i, arg := range n.args {
+			args[i] = substPack(arg, pack, elem)
+		}
+		return &cxxTemplate{n.name, args}
+	}
+	return n
+}
+
+// makeRef returns a reference to child, where sym is "&" or "&&",
+// applying C++ reference collapsing rules.
+func makeRef(child cxxNode, sym string) cxxNode {
+	if r, ok := child.(*cxxPointer); ok && r.sym != "*" {
+		if sym == "&" || r.sym == "&" {
+			return &cxxPointer{r.child, "&"}
+		}
+		return r
+	}
+	return &cxxPointer{child, sym}
+}
+
+// typeName parses a <name> in a type context. Template arguments in
+// type names don't affect the template parameters of the enclosing
+// encoding.
+func (st *cxxState) typeName() cxxNode {
+	save := st.tmplArgs
+	n, _ := st.name()
+	st.tmplArgs = save
+	return n
+}
+
+func (st *cxxState) functionType() cxxNode {
+	st.expect('F')
+	if st.peek() == 'Y' {
+		// extern "C"
+		st.pos++
+	}
+	ret := st.typ()
+	var params []cxxNode
+	var ref string
+	for st.peek() != 'E' {
+		if (st.peek() == 'R' || st.peek() == 'O') && st.peekAt(1) == 'E' {
+			if st.peek() == 'R' {
+				ref = " &"
+			} else {
+				ref = " &&"
+			}
+			st.pos++
+			continue
+		}
+		params = append(params, st.typ())
+	}
+	st.pos++
+	if len(params) == 1 && isVoid(params[0]) {
+		params = nil
+	}
+	return &cxxFuncType{ret: ret, params: params, ref: ref}
+}
+
+func (st *cxxState) decltype() cxxNode {
+	st.expect('D')
+	if st.peek() != 't' && st.peek() != 'T' {
+		st.fail("expected decltype")
+	}
+	st.pos++
+	e := st.expression()
+	st.expect('E')
+	return &cxxPrefixed{"decltype ", &cxxParen{e}}
+}
+
+// expression parses a limited subset of <expression>, sufficient for
+// common decltype and template argument uses.
+func (st *cxxState) expression() cxxNode {
+	switch {
+	case st.peek() == 'L':
+		return st.exprPrimary()
+	case st.peek() == 'T':
+		return st.templateParam()
+	case st.hasPrefix("fp"):
+		st.advance(2)
+		st.cvQualifiers()
+		idx := 1
+		if st.peek() != '_' {
+			idx = st.number() + 2
+		}
+		st.expect('_')
+		return &cxxName{fmt.Sprintf("{parm#%d}", idx)}
+	case st.hasPrefix("sr"):
+		st.advance(2)
+		scope := st.typ()
+		n, _ := st.unqualifiedName(nil)
+		if st.peek() == 'I' {
+			n = &cxxTemplate{n, st.templateArgs(false)}
+		}
+		return &cxxQualified{scope, n}
+	case st.hasPrefix("cv"):
+		st.advance(2)
+		t := st.typ()
+		e := st.expression()
+		return &cxxJoin{&cxxParen{t}, "", e}
+	case st.hasPrefix("cl"):
+		st.advance(2)
+		fn := st.expression()
+		var args []cxxNode
+		for st.peek() != 'E' {
+			args = append(args, st.expression())
+		}
+		st.pos++
+		return &cxxCall{fn, args}
+	case st.hasPrefix("st") || st.hasPrefix("at"):
+		op := cxxOperators[st.str[st.pos:st.pos+2]]
+		st.advance(2)
+		return &cxxPrefixed{op, &cxxParen{st.typ()}}
+	case isDigit(st.peek()):
+		return st.sourceName()
+	}
+
+	if st.pos+2 > len(st.str) {
+		st.fail("bad expression")
+	}
+	code := st.str[st.pos : st.pos+2]
+	op, ok := cxxOperators[code]
+	if !ok {
+		st.fail("unsupported expression " + code)
+	}
+	st.pos += 2
+	switch code {
+	case "ps", "ng", "ad", "de", "co", "nt", "sz", "az":
+		return &cxxPrefixed{op, exprParen(st.expression())}
+	case "pp", "mm":
+		// Prefix forms are encoded with a leading "_".
+		if st.peek() == '_' {
+			st.pos++
+			return &cxxPrefixed{op, exprParen(st.expression())}
+		}
+		return &cxxSuffix{exprParen(st.expression()), op}
+	case "qu":
+		c := st.expression()
+		t := st.expression()
+		f := st.expression()
+		return &cxxJoin{exprParen(c), "?", &cxxJoin{exprParen(t), " : ", exprParen(f)}}
+	}
+	l := st.expression()
+	r := st.expression()
+	return &cxxJoin{exprParen(l), op, exprParen(r)}
+}
+
+// exprParen parenthesizes operand n of an expression if necessary.
+// Like c++filt, this parenthesizes everything except function
+// parameter references.
+func exprParen(n cxxNode) cxxNode {
+	if n, ok := n.(*cxxName); ok && strings.HasPrefix(n.s, "{") {
+		return n
+	}
+	return &cxxParen{n}
+}
+
+// exprPrimary parses an <expr-primary>.
+func (st *cxxState) exprPrimary() cxxNode {
+	st.expect('L')
+	if st.hasPrefix("_Z") {
+		st.advance(2)
+		n := st.encoding(false)
+		st.expect('E')
+		return n
+	}
+	t := st.typ()
+	start := st.pos
+	if st.peek() == 'n' {
+		st.pos++
+	}
+	for st.peek() != 'E' && st.peek() != 0 {
+		st.pos++
+	}
+	val := st.str[start:st.pos]
+	st.expect('E')
+	if strings.HasPrefix(val, "n") {
+		val = "-" + val[1:]
+	}
+
+	if b, ok := t.(*cxxName); ok {
+		switch b.s {
+		case "int":
+			return &cxxName{val}
+		case "unsigned int":
+			return &cxxName{val + "u"}
+		case "long":
+			return &cxxName{val + "l"}
+		case "unsigned long":
+			return &cxxName{val + "ul"}
+		case "long long":
+			return &cxxName{val + "ll"}
+		case "unsigned long long":
+			return &cxxName{val + "ull"}
+		case "bool":
+			switch val {
+			case "0":
+				return &cxxName{"false"}
+			case "1":
+				return &cxxName{"true"}
+			}
+		case "decltype(nullptr)":
+			if val == "" {
+				return &cxxName{"nullptr"}
+			}
+		}
+	}
+	return &cxxJoin{&cxxParen{t}, "", &cxxName{val}}
+}
+
+// cxxPrinter accumulates demangled output.
+type cxxPrinter struct {
+	buf []byte
+}
+
+func (p *cxxPrinter) s(s string) {
+	p.buf = append(p.buf, s...)
+}
+
+func (p *cxxPrinter) last() byte {
+	if len(p.buf) == 0 {
+		return 0
+	}
+	return p.buf[len(p.buf)-1]
+}
+
+func (p *cxxPrinter) print(n cxxNode) {
+	n.left(p)
+	n.right(p)
+}
+
+func (p *cxxPrinter) list(ns []cxxNode) {
+	first := true
+	for _, n := range ns {
+		if pack, ok := n.(*cxxPack); ok && len(pack.elems) == 0 {
+			continue
+		}
+		if !first {
+			p.s(", ")
+		}
+		first = false
+		p.print(n)
+	}
+}
+
+// A cxxNode is a node in the demangled AST.
+//
+// Types are printed in two parts because declarators such as
+// pointers to functions wrap around the name: left prints everything
+// before the declarator's name, and right prints everything after.
+type cxxNode interface {
+	left(p *cxxPrinter)
+	right(p *cxxPrinter)
+}
+
+// hasRight returns whether n prints a right-hand component.
+func hasRight(n cxxNode) bool {
+	switch n := n.(type) {
+	case *cxxFuncType, *cxxArray:
+		return true
+	case *cxxPointer:
+		return hasRight(n.child)
+	case *cxxPtrToMember:
+		return hasRight(n.member)
+	case *cxxQual:
+		return hasRight(n.child)
+	}
+	return false
+}
+
+// wraps returns whether pointers to n must be parenthesized.
+func wraps(n cxxNode) bool {
+	switch n := n.(type) {
+	case *cxxFuncType, *cxxArray:
+		return true
+	case *cxxQual:
+		return wraps(n.child)
+	}
+	return false
+}
+
+type cxxName struct {
+	s string
+}
+
+func (n *cxxName) left(p *cxxPrinter)  { p.s(n.s) }
+func (n *cxxName) right(p *cxxPrinter) {}
+
+type cxxStdSub struct {
+	name string
+	ctor string
+}
+
+func (n *cxxStdSub) left(p *cxxPrinter)  { p.s(n.name) }
+func (n *cxxStdSub) right(p *cxxPrinter) {}
+
+type cxxQualified struct {
+	scope, name cxxNode
+}
+
+func (n *cxxQualified) left(p *cxxPrinter) {
+	p.print(n.scope)
+	p.s("::")
+	p.print(n.name)
+}
+func (n *cxxQualified) right(p *cxxPrinter) {}
+
+type cxxTemplate struct {
+	name cxxNode
+	args []cxxNode
+}
+
+func (n *cxxTemplate) left(p *cxxPrinter) {
+	p.print(n.name)
+	if p.last() == '<' {
+		// Avoid "operator<<<".
+		p.s(" ")
+	}
+	p.s("<")
+	p.list(n.args)
+	if p.last() == '>' {
+		p.s(" ")
+	}
+	p.s(">")
+}
+func (n *cxxTemplate) right(p *cxxPrinter) {}
+
+type cxxPack struct {
+	elems []cxxNode
+}
+
+func (n *cxxPack) left(p *cxxPrinter)  { p.list(n.elems) }
+func (n *cxxPack) right(p *cxxPrinter) {}
+
+type cxxLambda struct {
+	params []cxxNode
+	id     int
+}
+
+func (n *cxxLambda) left(p *cxxPrinter) {
+	p.s("{lambda(")
+	p.list(n.params)
+	p.s(fmt.Sprintf(")#%d}", n.id))
+}
+func (n *cxxLambda) right(p *cxxPrinter) {}
+
+// cxxPrefixed is a prefix string followed by a node.
+type cxxPrefixed struct {
+	prefix string
+	n      cxxNode
+}
+
+func (n *cxxPrefixed) left(p *cxxPrinter) {
+	p.s(n.prefix)
+	p.print(n.n)
+}
+func (n *cxxPrefixed) right(p *cxxPrinter) {}
+
+// cxxSuffix is a node followed by a suffix string.
+type cxxSuffix struct {
+	n      cxxNode
+	suffix string
+}
+
+func (n *cxxSuffix) left(p *cxxPrinter) {
+	p.print(n.n)
+	p.s(n.suffix)
+}
+func (n *cxxSuffix) right(p *cxxPrinter) {}
+
+type cxxJoin struct {
+	a   cxxNode
+	sep string
+	b   cxxNode
+}
+
+func (n *cxxJoin) left(p *cxxPrinter) {
+	p.print(n.a)
+	p.s(n.sep)
+	p.print(n.b)
+}
+func (n *cxxJoin) right(p *cxxPrinter) {}
+
+type cxxParen struct {
+	n cxxNode
+}
+
+func (n *cxxParen) left(p *cxxPrinter) {
+	p.s("(")
+	p.print(n.n)
+	p.s(")")
+}
+func (n *cxxParen) right(p *cxxPrinter) {}
+
+type cxxCall struct {
+	fn   cxxNode
+	args []cxxNode
+}
+
+func (n *cxxCall) left(p *cxxPrinter) {
+	p.print(n.fn)
+	p.s("(")
+	p.list(n.args)
+	p.s(")")
+}
+func (n *cxxCall) right(p *cxxPrinter) {}
+
+type cxxQual struct {
+	child cxxNode
+	quals string
+}
+
+func (n *cxxQual) left(p *cxxPrinter) {
+	n.child.left(p)
+	p.s(n.quals)
+}
+func (n *cxxQual) right(p *cxxPrinter) { n.child.right(p) }
+
+type cxxPointer struct {
+	child cxxNode
+	sym   string
+}
+
+func (n *cxxPointer) left(p *cxxPrinter) {
+	n.child.left(p)
+	if wraps(n.child) {
+		if _, ok := n.child.(*cxxArray); ok {
+			p.s(" ")
+		}
+		p.s("(")
+	}
+	p.s(n.sym)
+}
+
+func (n *cxxPointer) right(p *cxxPrinter) {
+	if wraps(n.child) {
+		p.s(")")
+	}
+	n.child.right(p)
+}
+
+type cxxFuncType struct {
+	ret     cxxNode
+	params  []cxxNode
+	cv, ref string
+}
+
+func (n *cxxFuncType) left(p *cxxPrinter) {
+	n.ret.left(p)
+	if !hasRight(n.ret) {
+		p.s(" ")
+	}
+}
+
+func (n *cxxFuncType) right(p *cxxPrinter) {
+	p.s("(")
+	p.list(n.params)
+	p.s(")")
+	n.ret.right(p)
+	p.s(n.cv)
+	p.s(n.ref)
+}
+
+type cxxArray struct {
+	elem cxxNode
+	dim  string
+}
+
+func (n *cxxArray) left(p *cxxPrinter) { n.elem.left(p) }
+
+func (n *cxxArray) right(p *cxxPrinter) {
+	if p.last() != ']' {
+		p.s(" ")
+	}
+	p.s("[" + n.dim + "]")
+	n.elem.right(p)
+}
+
+type cxxPtrToMember struct {
+	class, member cxxNode
+}
+
+func (n *cxxPtrToMember) left(p *cxxPrinter) {
+	n.member.left(p)
+	if wraps(n.member) {
+		p.s("(")
+	} else {
+		p.s(" ")
+	}
+	p.print(n.class)
+	p.s("::*")
+}
+
+func (n *cxxPtrToMember) right(p *cxxPrinter) {
+	if wraps(n.member) {
+		p.s(")")
+	}
+	n.member.right(p)
+}
+
+// cxxFunction is a function <encoding>.
+type cxxFunction struct {
+	name    cxxNode
+	ret     cxxNode
+	params  []cxxNode
+	cv, ref string
+}
+
+func (n *cxxFunction) left(p *cxxPrinter) {
+	if n.ret != nil {
+		n.ret.left(p)
+		if !hasRight(n.ret) {
+			p.s(" ")
+		}
+	}
+	p.print(n.name)
+}
+
+func (n *cxxFunction) right(p *cxxPrinter) {
+	p.s("(")
+	p.list(n.params)
+	p.s(")")
+	if n.ret != nil {
+		n.ret.right(p)
+	}
+	p.s(n.cv)
+	p.s(n.ref)
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package demangle
+
+import "testing"
+
+// cxxTests are checked against GNU c++filt.
+var cxxTests = []struct {
+	in, out string
+}{
+	{"_ZN3foo3barEv", "foo::bar()"},
+	{"_ZNSt6vectorIiSaIiEE9push_backERKi", "std::vector<int, std::allocator<int> >::push_back(int const&)"},
+	{"_ZN1A1fIiEEvT_", "void A::f<int>(int)"},
+	{"_Z1fPFviE", "f(void (*)(int))"},
+	{"_ZNK1A1gEv", "A::g() const"},
+	{"_Z1fRA3_i", "f(int (&) [3])"},
+	{"_ZTV1A", "vtable for A"},
+	{"_Z1fM1AFviE", "f(void (A::*)(int))"},
+	{"_ZN12_GLOBAL__N_13fooEv", "(anonymous namespace)::foo()"},
+	{"_ZZ4mainENKUlvE_clEv", "main::{lambda()#1}::operator()() const"},
+	{"_ZNSt7__cxx1112basic_stringIcSt11char_traitsIcESaIcEEC1EPKcRKS3_", "std::__cxx11::basic_string<char, std::char_traits<char>, std::allocator<char> >::basic_string(char const*, std::allocator<char> const&)"},
+	{"_Z3maxIiET_S0_S0_", "int max<int>(int, int)"},
+	{"_ZN1AC2Ev", "A::A()"},
+	{"_ZN1AD0Ev", "A::~A()"},
+	{"_Z1fv.cold", "f() [clone .cold]"},
+	{"_ZL3barv", "bar()"},
+	{"_Z1fILi5EEvv", "void f<5>()"},
+	{"_ZSt4cout", "std::cout"},
+	{"_ZNSoD1Ev", "std::basic_ostream<char, std::char_traits<char> >::~basic_ostream()"},
+	{"_ZdlPv", "operator delete(void*)"},
+	{"_Znwm", "operator new(unsigned long)"},
+	{"_ZN1AcviEv", "A::operator int()"},
+	{"_ZplRK1AS1_", "operator+(A const&, A const&)"},
+	{"_Z1fPVKi", "f(int const volatile*)"},
+	{"_Z1fPrVKi", "f(int const volatile restrict*)"},
+	{"_ZN1AIiE1fIcEEvT_", "void A<int>::f<char>(char)"},
+	{"_Z1fIJidEEvDpT_", "void f<int, double>(int, double)"},
+	{"_ZTVN10__cxxabiv117__class_type_infoE", "vtable for __cxxabiv1::__class_type_info"},
+	{"_ZThn8_N1B1fEv", "non-virtual thunk to B::f()"},
+	{"_ZGVZ4mainE1x", "guard variable for main::x"},
+	{"_Z1fv.constprop.0.isra.0", "f() [clone .constprop.0] [clone .isra.0]"},
+	{"_ZSt4endlIcSt11char_traitsIcEERSt13basic_ostreamIT_T0_ES6_", "std::basic_ostream<char, std::char_traits<char> >& std::endl<char, std::char_traits<char> >(std::basic_ostream<char, std::char_traits<char> >&)"},
+	{"_Z1fILb1EEvv", "void f<true>()"},
+	{"_Z1fILj5EEvv", "void f<5u>()"},
+	{"_Z1fILc65EEvv", "void f<(char)65>()"},
+	{"_Z1fIiEDTplfp_fp_ET_", "decltype ({parm#1}+{parm#1}) f<int>(int)"},
+	{"_ZNKSt5ctypeIcE8do_widenEc", "std::ctype<char>::do_widen(char) const"},
+	{"_ZNSt8ios_base4InitC1Ev", "std::ios_base::Init::Init()"},
+	{"_Z1fPFPFivEvE", "f(int (*(*)())())"},
+	{"_ZN1A1BUt_E", "A::B::{unnamed type#1}"},
+	{"_ZNSsC1Ev", "std::basic_string<char, std::char_traits<char>, std::allocator<char> >::basic_string()"},
+	{"__ZN3foo3barEv", "foo::bar()"},
+}
+
+func TestCxx(t *testing.T) {
+	for _, test := range cxxTests {
+		got, err := Cxx(test.in)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.in, err)
+		} else if got != test.out {
+			t.Errorf("%s:\nwant %s\ngot  %s", test.in, test.out, got)
+		}
+	}
+}
+
+func TestCxxBad(t *testing.T) {
+	for _, in := range []string{"main", "_Z", "_ZN1A", "_Z1fv.", "_Z1fPFv", "_ZCI", "_Z1AT2000000000000_", "_ZS2000000000000_"} {
+		if got, err := Cxx(in); err == nil {
+			t.Errorf("%s: want error, got %q", in, got)
+		}
+	}
+	if _, err := Cxx("main"); err != ErrNotMangled {
+		t.Errorf("main: want ErrNotMangled, got %v", err)
+	}
+}
+
+func TestCxxMutations(t *testing.T) {
+	var seeds []string
+	for _, test := range cxxTests {
+		seeds = append(seeds, test.in)
+	}
+	mutate(seeds, func(in string) {
+		defer func() {
+			if err := recover(); err != nil {
+				t.Errorf("%q: panic: %v", in, err)
+			}
+		}()
+		Cxx(in)
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package demangle
+
+// A Formatter turns a kind of mangled symbol name into a readable
+// display name.
+type Formatter struct {
+	// Name is a short identifier for this formatter, such as
+	// "cxx".
+	Name string
+
+	// Label describes this formatter to users.
+	Label string
+
+	// Match returns whether name looks like a name this
+	// formatter handles. It should be cheap.
+	Match func(name string) bool
+
+	// Format returns the display form of name. It returns an
+	// error if name can't be formatted.
+	Format func(name string) (string, error)
+}
+
+// Formatters are the available name formatters. Legacy Rust names are
+// also valid C++ names, so Rust comes first.
+var Formatters = []*Formatter{
+	{"rust", "Rust", IsRust, Rust},
+	{"cxx", "C++", IsCxx, Cxx},
+	{"go", "Go generics", IsGoGeneric, GoGeneric},
+}
+
+// FormatterByName returns the formatter with the given name, or nil.
+func FormatterByName(name string) *Formatter {
+	for _, f := range Formatters {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Format returns the display form of name using the first of fs that
+// matches and succeeds, or "" if none do. fs should be in the same
+// order as Formatters.
+func Format(name string, fs []*Formatter) string {
+	for _, f := range fs {
+		if f.Match(name) {
+			if out, ok := f.try(name); ok {
+				return out
+			}
+		}
+	}
+	return ""
+}
+
+// try formats name using f. Symbol names come from untrusted binaries,
+// so a formatter that panics is treated like one that fails.
+func (f *Formatter) try(name string) (out string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			out, ok = "", false
+		}
+	}()
+	out, err := f.Format(name)
+	return out, err == nil
+}
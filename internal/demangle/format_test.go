@@ -0,0 +1,17 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package demangle
+
+import "testing"
+
+func TestFormatPanic(t *testing.T) {
+	bad := &Formatter{"bad", "Bad", IsCxx, func(string) (string, error) { panic("bad") }}
+	if got := Format("_Z1fv", []*Formatter{bad}); got != "" {
+		t.Errorf("want \"\", got %q", got)
+	}
+	if got, want := Format("_Z1fv", []*Formatter{bad, FormatterByName("cxx")}), "f()"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package demangle
+
+import "strings"
+
+// IsGoGeneric returns whether name looks like the name of a Go
+// generic instantiation or dictionary, such as "pkg.F[go.shape.int]"
+// or "pkg..dict.F[int]".
+func IsGoGeneric(name string) bool {
+	return strings.Contains(name, "[") && !IsCxx(name)
+}
+
+// GoGeneric formats the name of a Go generic instantiation so that
+// instantiations of the same generic function or method sort
+// together. The type arguments of each instantiated name are moved
+// to the end, shape type arguments are shown as their underlying
+// type, and dictionaries are labeled. For example,
+//
+//	pkg.(*T[go.shape.int]).M    becomes  pkg.(*T).M [int]
+//	pkg..dict.F[string]         becomes  pkg.F [string] (dictionary)
+//
+// If name has no type arguments, it returns ErrNotMangled.
+func GoGeneric(name string) (string, error) {
+	if !IsGoGeneric(name) {
+		return "", ErrNotMangled
+	}
+
+	dict := false
+	if i := strings.Index(name, "..dict."); i >= 0 {
+		dict = true
+		name = name[:i+1] + name[i+len("..dict."):]
+	}
+
+	// Split out bracketed type argument lists.
+	var base strings.Builder
+	var groups []string
+	for i := 0; i < len(name); i++ {
+		if name[i] != '[' {
+			base.WriteByte(name[i])
+			continue
+		}
+		// Find the matching "]" and split the arguments at
+		// top-level commas.
+		var args []string
+		depth, start := 0, i+1
+		j := i + 1
+		for ; j < len(name); j++ {
+			switch name[j] {
+			case '[', '(', '{':
+				depth++
+				continue
+			case ')', '}':
+				depth--
+				continue
+			case ']':
+				if depth > 0 {
+					depth--
+					continue
+				}
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+			args = append(args, goShape(name[start:j]))
+			start = j + 1
+			if name[j] == ']' {
+				break
+			}
+		}
+		if j == len(name) {
+			// Unbalanced brackets.
+			return "", ErrNotMangled
+		}
+		groups = append(groups, strings.Join(args, ", "))
+		i = j
+	}
+
+	out := base.String() + " [" + strings.Join(groups, "; ") + "]"
+	if dict {
+		out += " (dictionary)"
+	}
+	return out, nil
+}
+
+// goShape returns the type a type argument stands for. Shape types,
+// which are named "go.shape.T" or, in Go 1.18, "go.shape.T_N", are
+// replaced with T.
+func goShape(arg string) string {
+	if !strings.HasPrefix(arg, "go.shape.") {
+		return arg
+	}
+	arg = strings.TrimPrefix(arg, "go.shape.")
+	if i := strings.LastIndexByte(arg, '_'); i >= 0 && i+1 < len(arg) && strings.Trim(arg[i+1:], "0123456789") == "" {
+		arg = arg[:i]
+	}
+	return arg
+}
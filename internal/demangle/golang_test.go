@@ -0,0 +1,37 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package demangle
+
+import "testing"
+
+func TestGoGeneric(t *testing.T) {
+	for _, test := range []struct {
+		in, out string
+	}{
+		{"internal/strconv.shortFloat[go.shape.float32]", "internal/strconv.shortFloat [float32]"},
+		{"internal/strconv..dict.shortFloat[float64]", "internal/strconv.shortFloat [float64] (dictionary)"},
+		{"internal/sync.(*HashTrieMap[go.shape.interface {},go.shape.interface {}]).Load", "internal/sync.(*HashTrieMap).Load [interface {}, interface {}]"},
+		{"main.F[go.shape.int_0]", "main.F [int]"},
+		{"main.F[go.shape.*uint8]", "main.F [*uint8]"},
+		{"main.F[go.shape.struct { a int; b [2]int }]", "main.F [struct { a int; b [2]int }]"},
+		{"main.F[map[string]int]", "main.F [map[string]int]"},
+		{"main.T[int].M[go.shape.string]", "main.T.M [int; string]"},
+	} {
+		got, err := GoGeneric(test.in)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.in, err)
+		} else if got != test.out {
+			t.Errorf("%s:\nwant %s\ngot  %s", test.in, test.out, got)
+		}
+	}
+}
+
+func TestGoGenericBad(t *testing.T) {
+	for _, in := range []string{"main.main", "main.F[int", "_ZN3foo3barEv"} {
+		if got, err := GoGeneric(in); err == nil {
+			t.Errorf("%s: want error, got %s", in, got)
+		}
+	}
+}
@@ -0,0 +1,739 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package demangle
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// IsRust returns whether name looks like a Rust mangled name, using
+// either the v0 mangling scheme ("_R...") or the legacy scheme, which
+// is an Itanium C++ ABI nested name ending in a hash component
+// ("_ZN...17h0123456789abcdefE").
+func IsRust(name string) bool {
+	if strings.HasPrefix(name, "__") {
+		name = name[1:]
+	}
+	if strings.HasPrefix(name, "_R") {
+		return true
+	}
+	return rustLegacyBody(name) != ""
+}
+
+// Rust demangles a Rust symbol name, omitting the crate
+// disambiguators and hash that rustc adds for uniqueness. This
+// matches the output of rustfilt. If name is not a Rust mangled name,
+// it returns ErrNotMangled. If name cannot be demangled, it returns
+// some other error.
+func Rust(name string) (out string, err error) {
+	if strings.HasPrefix(name, "__") {
+		// Mach-O adds an extra leading underscore.
+		name = name[1:]
+	}
+	if body := rustLegacyBody(name); body != "" {
+		return rustLegacy(body)
+	}
+	if !strings.HasPrefix(name, "_R") {
+		return "", ErrNotMangled
+	}
+	// v0 names contain only [A-Za-z0-9_]. Anything after that is
+	// a vendor-specific suffix, such as ".llvm.1234".
+	end := 2
+	for end < len(name) && (isDigit(name[end]) || isRustAlpha(name[end]) || name[end] == '_') {
+		end++
+	}
+
+	st := &rustState{str: name[:end], pos: 2}
+	defer func() {
+		if e := recover(); e != nil {
+			if e, ok := e.(rustError); ok {
+				out, err = "", e
+				return
+			}
+			panic(e)
+		}
+	}()
+	if isDigit(st.peek()) {
+		st.fail("unsupported encoding version")
+	}
+	st.path(true)
+	// Skip the instantiating crate, if any.
+	if st.pos < len(st.str) {
+		st.skip++
+		st.path(false)
+		st.skip--
+	}
+	if st.pos != len(st.str) {
+		st.fail("unparsed characters at end of name")
+	}
+	return string(st.buf), nil
+}
+
+// rustLegacyBody returns the path components of a legacy Rust name,
+// without the "_ZN" prefix, the hash component, or the "E" suffix. If
+// name is not a legacy Rust name, it returns "".
+func rustLegacyBody(name string) string {
+	if !strings.HasPrefix(name, "_ZN") {
+		return ""
+	}
+	// Strip any LLVM suffix, such as ".llvm.1234".
+	if i := strings.IndexByte(name, '.'); i >= 0 && strings.HasSuffix(name[:i], "E") {
+		name = name[:i]
+	}
+	const hashLen = len("17h0123456789abcdefE")
+	if len(name) < len("_ZN")+hashLen || !strings.HasSuffix(name, "E") {
+		return ""
+	}
+	hash := name[len(name)-hashLen:]
+	if !strings.HasPrefix(hash, "17h") || strings.Trim(hash[3:len(hash)-1], "0123456789abcdef") != "" {
+		return ""
+	}
+	return name[len("_ZN") : len(name)-hashLen]
+}
+
+// rustLegacyEscapes maps the escapes in legacy Rust identifiers to
+// the characters they represent.
+var rustLegacyEscapes = map[string]string{
+	"SP": "@", "BP": "*", "RF": "&", "LT": "<", "GT": ">",
+	"LP": "(", "RP": ")", "C": ",",
+}
+
+// rustLegacy demangles the components of a legacy Rust name.
+func rustLegacy(body string) (string, error) {
+	var buf strings.Builder
+	for body != "" {
+		n := 0
+		for n < len(body) && isDigit(body[n]) {
+			n++
+		}
+		l, err := strconv.Atoi(body[:n])
+		if err != nil || n+l > len(body) {
+			return "", fmt.Errorf("bad legacy Rust component %q", body)
+		}
+		ident := body[n : n+l]
+		body = body[n+l:]
+
+		if buf.Len() > 0 {
+			buf.WriteString("::")
+		}
+		if strings.HasPrefix(ident, "_$") {
+			ident = ident[1:]
+		}
+		for ident != "" {
+			switch {
+			case strings.HasPrefix(ident, ".."):
+				buf.WriteString("::")
+				ident = ident[2:]
+			case ident[0] == '$':
+				end := strings.IndexByte(ident[1:], '$')
+				if end < 0 {
+					return "", fmt.Errorf("unterminated escape in %q", ident)
+				}
+				esc := ident[1 : end+1]
+				ident = ident[end+2:]
+				if s, ok := rustLegacyEscapes[esc]; ok {
+					buf.WriteString(s)
+				} else if c, err := strconv.ParseUint(strings.TrimPrefix(esc, "u"), 16, 32); err == nil && esc[0] == 'u' && utf8.ValidRune(rune(c)) {
+					buf.WriteRune(rune(c))
+				} else {
+					return "", fmt.Errorf("bad escape $%s$", esc)
+				}
+			case ident[0] == '.':
+				buf.WriteByte('.')
+				ident = ident[1:]
+			default:
+				end := strings.IndexAny(ident, "$.")
+				if end < 0 {
+					end = len(ident)
+				}
+				buf.WriteString(ident[:end])
+				ident = ident[end:]
+			}
+		}
+	}
+	return buf.String(), nil
+}
+
+type rustError struct {
+	pos int
+	msg string
+}
+
+func (e rustError) Error() string {
+	return fmt.Sprintf("demangling failed at offset %d: %s", e.pos, e.msg)
+}
+
+// rustState is the parser and printer state for a Rust v0 mangled
+// name. It prints as it parses.
+type rustState struct {
+	str string
+	pos int
+	buf []byte
+
+	// skip, if non-zero, suppresses printing.
+	skip int
+
+	// depth is the number of lifetimes bound by enclosing binders.
+	depth int
+
+	// backrefs are the targets of the backrefs being expanded.
+	backrefs []int
+
+	// work counts bytes printed, including skipped bytes, and
+	// backrefs expanded.
+	work int
+}
+
+// maxRustWork limits the work counted by rustState.work. Backrefs can
+// make the output exponentially longer than the mangled name.
+const maxRustWork = 1 << 16
+
+// addWork adds n to st.work and fails if it exceeds maxRustWork.
+func (st *rustState) addWork(n int) {
+	st.work += n
+	if st.work > maxRustWork {
+		st.fail("demangled name too long")
+	}
+}
+
+func (st *rustState) fail(msg string) {
+	panic(rustError{st.pos, msg})
+}
+
+func (st *rustState) peek() byte {
+	if st.pos >= len(st.str) {
+		return 0
+	}
+	return st.str[st.pos]
+}
+
+func (st *rustState) next() byte {
+	c := st.peek()
+	if c == 0 {
+		st.fail("unexpected end of name")
+	}
+	st.pos++
+	return c
+}
+
+func (st *rustState) eat(c byte) bool {
+	if st.peek() == c {
+		st.pos++
+		return true
+	}
+	return false
+}
+
+func (st *rustState) print(s string) {
+	st.addWork(len(s))
+	if st.skip == 0 {
+		st.buf = append(st.buf, s...)
+	}
+}
+
+func isRustAlpha(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// base62 parses a <base-62-number>, which is "_" for 0 or a base-62
+// encoding of the value minus 1 followed by "_". It fails if the
+// number doesn't fit in a uint64.
+func (st *rustState) base62() uint64 {
+	if st.eat('_') {
+		return 0
+	}
+	var val uint64
+	for {
+		c := st.next()
+		var d byte
+		switch {
+		case isDigit(c):
+			d = c - '0'
+		case 'a' <= c && c <= 'z':
+			d = c - 'a' + 10
+		case 'A' <= c && c <= 'Z':
+			d = c - 'A' + 36
+		case c == '_':
+			if val == math.MaxUint64 {
+				st.fail("base-62 number too large")
+			}
+			return val + 1
+		default:
+			st.pos--
+			st.fail("bad base-62 number")
+		}
+		if val > (math.MaxUint64-uint64(d))/62 {
+			st.fail("base-62 number too large")
+		}
+		val = val*62 + uint64(d)
+	}
+}
+
+// optBase62 parses an optional <base-62-number> prefixed by tag. It
+// returns 0 if it's absent and the number plus 1 otherwise.
+func (st *rustState) optBase62(tag byte) uint64 {
+	if !st.eat(tag) {
+		return 0
+	}
+	return st.base62() + 1
+}
+
+// decimal parses a <decimal-number>.
+func (st *rustState) decimal() int {
+	if st.eat('0') {
+		return 0
+	}
+	if !isDigit(st.peek()) {
+		st.fail("expected number")
+	}
+	val := 0
+	for isDigit(st.peek()) {
+		val = val*10 + int(st.next()-'0')
+		if val > len(st.str) {
+			st.fail("number too large")
+		}
+	}
+	return val
+}
+
+// ident parses an <undisambiguated-identifier>.
+func (st *rustState) ident() string {
+	puny := st.eat('u')
+	n := st.decimal()
+	st.eat('_')
+	if st.pos+n > len(st.str) {
+		st.fail("identifier too long")
+	}
+	s := st.str[st.pos : st.pos+n]
+	st.pos += n
+	if puny {
+		var ok bool
+		if s, ok = punycodeDecode(s); !ok {
+			st.fail("bad punycode identifier")
+		}
+	}
+	return s
+}
+
+// backref parses a <backref> and calls f with the parser positioned
+// at its target.
+func (st *rustState) backref(f func()) {
+	start := st.pos - 1
+	i := int(st.base62())
+	if i < 0 || i+2 >= start {
+		st.fail("bad backref")
+	}
+	// A backref inside the span of its own target would expand
+	// forever.
+	for _, t := range st.backrefs {
+		if t == i {
+			st.fail("recursive backref")
+		}
+	}
+	st.addWork(1)
+	st.backrefs = append(st.backrefs, i)
+	saved := st.pos
+	st.pos = i + 2
+	f()
+	st.pos = saved
+	st.backrefs = st.backrefs[:len(st.backrefs)-1]
+}
+
+// path parses and prints a <path>. If value is true, the path names
+// a value, so generic arguments are printed with "::<...>".
+func (st *rustState) path(value bool) {
+	switch c := st.next(); c {
+	case 'C':
+		st.optBase62('s')
+		st.print(st.ident())
+	case 'M':
+		st.implPath()
+		st.print("<")
+		st.typ()
+		st.print(">")
+	case 'X':
+		st.implPath()
+		st.print("<")
+		st.typ()
+		st.print(" as ")
+		st.path(false)
+		st.print(">")
+	case 'Y':
+		st.print("<")
+		st.typ()
+		st.print(" as ")
+		st.path(false)
+		st.print(">")
+	case 'N':
+		ns := st.next()
+		st.path(value)
+		dis := st.optBase62('s')
+		name := st.ident()
+		switch {
+		case 'A' <= ns && ns <= 'Z':
+			st.print("::{")
+			switch ns {
+			case 'C':
+				st.print("closure")
+			case 'S':
+				st.print("shim")
+			default:
+				st.print(string(ns))
+			}
+			if name != "" {
+				st.print(":" + name)
+			}
+			st.print(fmt.Sprintf("#%d}", dis))
+		case 'a' <= ns && ns <= 'z':
+			if name != "" {
+				st.print("::" + name)
+			}
+		default:
+			st.fail("bad namespace")
+		}
+	case 'I':
+		st.path(value)
+		if value {
+			st.print("::")
+		}
+		st.print("<")
+		for i := 0; !st.eat('E'); i++ {
+			if i > 0 {
+				st.print(", ")
+			}
+			st.genericArg()
+		}
+		st.print(">")
+	case 'B':
+		st.backref(func() { st.path(value) })
+	default:
+		st.pos--
+		st.fail("bad path")
+	}
+}
+
+// implPath parses an <impl-path>, which is never printed.
+func (st *rustState) implPath() {
+	st.skip++
+	st.optBase62('s')
+	st.path(false)
+	st.skip--
+}
+
+func (st *rustState) genericArg() {
+	switch {
+	case st.eat('L'):
+		st.lifetime(st.base62())
+	case st.eat('K'):
+		st.constant()
+	default:
+		st.typ()
+	}
+}
+
+// lifetime prints the lifetime with de Bruijn index i.
+func (st *rustState) lifetime(i uint64) {
+	if i == 0 {
+		st.print("'_")
+		return
+	}
+	if i > uint64(st.depth) {
+		st.fail("bad lifetime index")
+	}
+	d := st.depth - int(i)
+	if d < 26 {
+		st.print("'" + string(rune('a'+d)))
+	} else {
+		st.print(fmt.Sprintf("'_%d", d))
+	}
+}
+
+// binder parses an optional <binder> and prints it as "for<...> ".
+// It returns the number of lifetimes bound, which the caller must
+// subtract from st.depth when the binder goes out of scope.
+func (st *rustState) binder() int {
+	n := int(st.optBase62('G'))
+	if n == 0 {
+		return 0
+	}
+	// A real name never binds more lifetimes than it has bytes
+	// left. Checking this keeps a bad name from printing an
+	// enormous binder.
+	if n < 0 || n > len(st.str)-st.pos {
+		st.fail("too many bound lifetimes")
+	}
+	st.print("for<")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			st.print(", ")
+		}
+		st.depth++
+		st.lifetime(1)
+	}
+	st.print("> ")
+	return n
+}
+
+var rustBasicTypes = map[byte]string{
+	'a': "i8", 'b': "bool", 'c': "char", 'd': "f64", 'e': "str",
+	'f': "f32", 'h': "u8", 'i': "isize", 'j': "usize", 'l': "i32",
+	'm': "u32", 'n': "i128", 'o': "u128", 's': "i16", 't': "u16",
+	'u': "()", 'v': "...", 'x': "i64", 'y': "u64", 'z': "!", 'p': "_",
+}
+
+// typ parses and prints a <type>.
+func (st *rustState) typ() {
+	c := st.next()
+	if s, ok := rustBasicTypes[c]; ok {
+		st.print(s)
+		return
+	}
+	switch c {
+	case 'A':
+		st.print("[")
+		st.typ()
+		st.print("; ")
+		st.constant()
+		st.print("]")
+	case 'S':
+		st.print("[")
+		st.typ()
+		st.print("]")
+	case 'T':
+		st.print("(")
+		n := 0
+		for ; !st.eat('E'); n++ {
+			if n > 0 {
+				st.print(", ")
+			}
+			st.typ()
+		}
+		if n == 1 {
+			st.print(",")
+		}
+		st.print(")")
+	case 'R', 'Q':
+		st.print("&")
+		if st.eat('L') {
+			if i := st.base62(); i != 0 {
+				st.lifetime(i)
+				st.print(" ")
+			}
+		}
+		if c == 'Q' {
+			st.print("mut ")
+		}
+		st.typ()
+	case 'P':
+		st.print("*const ")
+		st.typ()
+	case 'O':
+		st.print("*mut ")
+		st.typ()
+	case 'F':
+		bound := st.binder()
+		if st.eat('U') {
+			st.print("unsafe ")
+		}
+		if st.eat('K') {
+			abi := "C"
+			if !st.eat('C') {
+				abi = strings.Replace(st.ident(), "_", "-", -1)
+			}
+			st.print(`extern "` + abi + `" `)
+		}
+		st.print("fn(")
+		for i := 0; !st.eat('E'); i++ {
+			if i > 0 {
+				st.print(", ")
+			}
+			st.typ()
+		}
+		st.print(")")
+		if st.peek() == 'u' {
+			st.pos++
+		} else {
+			st.print(" -> ")
+			st.typ()
+		}
+		st.depth -= bound
+	case 'D':
+		st.print("dyn ")
+		bound := st.binder()
+		for i := 0; !st.eat('E'); i++ {
+			if i > 0 {
+				st.print(" + ")
+			}
+			st.dynTrait()
+		}
+		st.depth -= bound
+		if !st.eat('L') {
+			st.fail("expected lifetime")
+		}
+		if i := st.base62(); i != 0 {
+			st.print(" + ")
+			st.lifetime(i)
+		}
+	case 'B':
+		st.backref(st.typ)
+	default:
+		st.pos--
+		st.path(false)
+	}
+}
+
+// dynTrait parses and prints a <dyn-trait>, which is a trait path
+// followed by associated type bindings. The bindings are printed
+// inside the trait's generic arguments.
+func (st *rustState) dynTrait() {
+	// If the path ends in generic arguments, reopen them.
+	mark := len(st.buf)
+	st.path(false)
+	open := st.skip == 0 && len(st.buf) > mark && st.buf[len(st.buf)-1] == '>'
+	if open {
+		st.buf = st.buf[:len(st.buf)-1]
+	}
+	for st.eat('p') {
+		if open {
+			st.print(", ")
+		} else {
+			st.print("<")
+			open = true
+		}
+		st.print(st.ident() + " = ")
+		st.typ()
+	}
+	if open {
+		st.print(">")
+	}
+}
+
+// constant parses and prints a <const>.
+func (st *rustState) constant() {
+	if st.eat('B') {
+		st.backref(st.constant)
+		return
+	}
+	if st.eat('p') {
+		st.print("_")
+		return
+	}
+	ty := st.next()
+	neg := st.eat('n')
+	start := st.pos
+	for st.peek() != '_' {
+		st.next()
+	}
+	hex := st.str[start:st.pos]
+	st.pos++
+	val, err := strconv.ParseUint(hex, 16, 64)
+	if hex != "" && err != nil {
+		st.fail("bad constant")
+	}
+	switch ty {
+	case 'a', 's', 'l', 'x', 'n', 'i', 'h', 't', 'm', 'y', 'o', 'j':
+		if neg {
+			st.print("-")
+		}
+		st.print(strconv.FormatUint(val, 10))
+	case 'b':
+		switch {
+		case val == 0 && !neg:
+			st.print("false")
+		case val == 1 && !neg:
+			st.print("true")
+		default:
+			st.fail("bad bool constant")
+		}
+	case 'c':
+		if neg || !utf8.ValidRune(rune(val)) || val > utf8.MaxRune {
+			st.fail("bad char constant")
+		}
+		st.print(strconv.QuoteRune(rune(val)))
+	default:
+		st.pos = start
+		st.fail("unsupported constant type")
+	}
+}
+
+// punycodeDecode decodes a Rust v0 Punycode identifier, which is
+// RFC 3492 Punycode with "_" in place of "-" as the delimiter.
+func punycodeDecode(s string) (string, bool) {
+	const (
+		base        = 36
+		tmin        = 1
+		tmax        = 26
+		skew        = 38
+		damp        = 700
+		initialBias = 72
+		initialN    = 128
+	)
+	var out []rune
+	if i := strings.LastIndexByte(s, '_'); i >= 0 {
+		out = []rune(s[:i])
+		s = s[i+1:]
+	}
+	n, bias, i := rune(initialN), initialBias, 0
+	adapt := func(delta, numPoints int, first bool) int {
+		if first {
+			delta /= damp
+		} else {
+			delta /= 2
+		}
+		delta += delta / numPoints
+		k := 0
+		for delta > ((base-tmin)*tmax)/2 {
+			delta /= base - tmin
+			k += base
+		}
+		return k + (base-tmin+1)*delta/(delta+skew)
+	}
+	for len(s) > 0 {
+		oldi, w := i, 1
+		for k := base; ; k += base {
+			if len(s) == 0 {
+				return "", false
+			}
+			c := s[0]
+			s = s[1:]
+			var digit int
+			switch {
+			case 'a' <= c && c <= 'z':
+				digit = int(c - 'a')
+			case '0' <= c && c <= '9':
+				digit = int(c-'0') + 26
+			default:
+				return "", false
+			}
+			i += digit * w
+			t := k - bias
+			if t < tmin {
+				t = tmin
+			} else if t > tmax {
+				t = tmax
+			}
+			if digit < t {
+				break
+			}
+			w *= base - t
+			if i > 1<<24 || w > 1<<24 {
+				return "", false
+			}
+		}
+		bias = adapt(i-oldi, len(out)+1, oldi == 0)
+		n += rune(i / (len(out) + 1))
+		i %= len(out) + 1
+		if !utf8.ValidRune(n) {
+			return "", false
+		}
+		out = append(out[:i], append([]rune{n}, out[i:]...)...)
+		i++
+	}
+	return string(out), true
+}
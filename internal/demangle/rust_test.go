@@ -0,0 +1,116 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package demangle
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// rustTests give the expected output without crate disambiguators
+// or hashes, like rustfilt.
+var rustTests = []struct {
+	in, out string
+}{
+	// Legacy mangling.
+	{"_ZN3foo17h05af221e174051e9E", "foo"},
+	{"_ZN4core3ptr13drop_in_place17h0123456789abcdefE", "core::ptr::drop_in_place"},
+	{"__ZN4core3ptr13drop_in_place17h0123456789abcdefE", "core::ptr::drop_in_place"},
+	{"_ZN4core3ptr13drop_in_place17h0123456789abcdefE.llvm.42", "core::ptr::drop_in_place"},
+	{"_ZN71_$LT$Test$u20$$u2b$$u20$$u27$static$u20$as$u20$foo..Bar$LT$Test$GT$$GT$3bar17h930b740aa94f1d3aE", "<Test + 'static as foo::Bar<Test>>::bar"},
+	{"_ZN3std2rt10lang_start28_$u7b$$u7b$closure$u7d$$u7d$17h0123456789abcdefE", "std::rt::lang_start::{{closure}}"},
+
+	// v0 mangling.
+	{"_RNvC6_123foo3bar", "123foo::bar"},
+	{"_RNvC7mycrateu9bcher_kva", "mycrate::bücher"},
+	{"_RNCNCNgCs6DXkGYLi8lr_2cc5spawn00B5_", "cc::spawn::{closure#0}::{closure#0}"},
+	{"_RINbNbCskIICzLVDPPb_5alloc5alloc8box_freeDINbNiB4_5boxed5FnBoxuEp6OutputuEL_ECs1iopQbuBiw2_3std", "alloc::alloc::box_free::<dyn alloc::boxed::FnBox<(), Output = ()>>"},
+	{"_RMCs4fqI2P2rA04_13const_genericINtB0_8UnsignedKhb_E", "<const_generic::Unsigned<11>>"},
+	{"_RMCs4fqI2P2rA04_13const_genericINtB0_6SignedKs98_E", "<const_generic::Signed<152>>"},
+	{"_RMCs4fqI2P2rA04_13const_genericINtB0_6SignedKanb_E", "<const_generic::Signed<-11>>"},
+	{"_RMCs4fqI2P2rA04_13const_genericINtB0_4BoolKb1_E", "<const_generic::Bool<true>>"},
+	{"_RMCs4fqI2P2rA04_13const_genericINtB0_4CharKc76_E", "<const_generic::Char<'v'>>"},
+	{"_RNvNvMCs4fqI2P2rA04_13const_genericINtB4_3FooKpE3foo3FOO", "<const_generic::Foo<_>>::foo::FOO"},
+}
+
+func TestRust(t *testing.T) {
+	for _, test := range rustTests {
+		if !IsRust(test.in) {
+			t.Errorf("IsRust(%s) = false, want true", test.in)
+		}
+		got, err := Rust(test.in)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", test.in, err)
+		} else if got != test.out {
+			t.Errorf("%s:\nwant %s\ngot  %s", test.in, test.out, got)
+		}
+	}
+}
+
+func TestRustBad(t *testing.T) {
+	for _, in := range []string{
+		"main.main",
+		"_ZN3foo3barEv",
+		"_RNvC6_123foo",
+		"_RNvC6_123foo3barX",
+		"_RB_",
+		// Huge binder.
+		"_RINvC1a1fFGzzzzzzzz_EuEE",
+		// Backref past the end of a uint64.
+		"_RNvBzzzzzzzzzzz_1a",
+		// Backref whose index is negative as an int.
+		"_RNvBaZl8N0y58Md_1a",
+		// Base-62 number one more than MaxUint64.
+		"_RNvBlYGhA16ahyf_1a",
+		// Backref into its own span.
+		"_RINANAB000_I",
+	} {
+		if got, err := Rust(in); err == nil {
+			t.Errorf("%s: want error, got %s", in, got)
+		}
+	}
+}
+
+// mutate calls f with many random mutations of each of seeds. It's a
+// cheap fuzzer for checking that demanglers fail cleanly on malformed
+// names.
+func mutate(seeds []string, f func(string)) {
+	const alphabet = "_0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ$."
+	r := rand.New(rand.NewSource(1))
+	for _, seed := range seeds {
+		for i := 0; i < 2000; i++ {
+			b := []byte(seed)
+			for n := 1 + r.Intn(3); n > 0 && len(b) > 0; n-- {
+				j := r.Intn(len(b))
+				switch r.Intn(4) {
+				case 0: // Replace a byte.
+					b[j] = alphabet[r.Intn(len(alphabet))]
+				case 1: // Insert a byte.
+					b = append(b[:j], append([]byte{alphabet[r.Intn(len(alphabet))]}, b[j:]...)...)
+				case 2: // Delete a byte.
+					b = append(b[:j], b[j+1:]...)
+				case 3: // Truncate.
+					b = b[:j]
+				}
+			}
+			f(string(b))
+		}
+	}
+}
+
+func TestRustMutations(t *testing.T) {
+	var seeds []string
+	for _, test := range rustTests {
+		seeds = append(seeds, test.in)
+	}
+	mutate(seeds, func(in string) {
+		defer func() {
+			if err := recover(); err != nil {
+				t.Fatalf("%s: panic: %v", in, err)
+			}
+		}()
+		Rust(in)
+	})
+}
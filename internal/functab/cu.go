@@ -0,0 +1,63 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package functab
+
+import "sort"
+
+// A CompUnit is a compilation unit recorded in the function table.
+type CompUnit struct {
+	// Files are the names of the source files of the compilation
+	// unit.
+	Files []string
+}
+
+// CompUnits returns the compilation units of the functions in ft.
+// Layouts before Go 1.16 don't record compilation units, so for these
+// it returns a single unit with all of the files in ft.
+func (ft *FuncTab) CompUnits() []CompUnit {
+	if !ft.layout.split {
+		var files []string
+		for _, file := range ft.files {
+			if file != "" {
+				files = append(files, file)
+			}
+		}
+		return []CompUnit{{files}}
+	}
+
+	// Compilation units are identified by their offset in cutab
+	// and extend to the next compilation unit.
+	seen := make(map[uint32]bool)
+	var offs []uint32
+	for _, f := range ft.Funcs {
+		if !seen[f.Raw.CUOffset] {
+			seen[f.Raw.CUOffset] = true
+			offs = append(offs, f.Raw.CUOffset)
+		}
+	}
+	sort.Slice(offs, func(i, j int) bool { return offs[i] < offs[j] })
+
+	n := uint32(len(ft.cutab) / 4)
+	cus := make([]CompUnit, 0, len(offs))
+	for i, off := range offs {
+		end := n
+		if i+1 < len(offs) && offs[i+1] < n {
+			end = offs[i+1]
+		}
+		var cu CompUnit
+		for j := off; j < end; j++ {
+			fileOff := ft.order.Uint32(ft.cutab[4*j:])
+			if fileOff == ^uint32(0) {
+				// Unused file.
+				continue
+			}
+			if file, ok := cString(ft.filetab, uint64(fileOff)); ok {
+				cu.Files = append(cu.Files, file)
+			}
+		}
+		cus = append(cus, cu)
+	}
+	return cus
+}
@@ -4,7 +4,10 @@
 
 package functab
 
-import "encoding/binary"
+import (
+	"bytes"
+	"encoding/binary"
+)
 
 type decoder struct {
 	order   binary.ByteOrder
@@ -83,3 +86,15 @@ func (d *decoder) Uvarint() uint64 {
 	d.pos += uint64(read)
 	return val
 }
+
+// cString returns the NUL-terminated string at offset off in data.
+func cString(data []byte, off uint64) (string, bool) {
+	if off >= uint64(len(data)) {
+		return "", false
+	}
+	end := bytes.IndexByte(data[off:], 0)
+	if end < 0 {
+		return "", false
+	}
+	return string(data[off : off+uint64(end)]), true
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package functab
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FuncFlag is a set of flags describing a function. These are only
+// recorded by Go 1.17 and later.
+//
+// Whether a function is NOSPLIT isn't recorded in the function table.
+type FuncFlag uint8
+
+const (
+	// FuncFlagTopFrame indicates the function appears at the top
+	// of its stack, like runtime.goexit, so tracebacks stop at
+	// it.
+	FuncFlagTopFrame FuncFlag = 1 << iota
+
+	// FuncFlagSPWrite indicates the function writes an arbitrary
+	// value to SP, so tracebacks can't unwind past it and it
+	// can't be asynchronously preempted.
+	FuncFlagSPWrite
+
+	// FuncFlagAsm indicates the function is implemented in
+	// assembly. This is recorded by Go 1.18 and later.
+	FuncFlagAsm
+)
+
+var funcFlagNames = []string{"TOPFRAME", "SPWRITE", "ASM"}
+
+func (f FuncFlag) String() string {
+	var names []string
+	for i, name := range funcFlagNames {
+		if f&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if rest := f &^ (1<<uint(len(funcFlagNames)) - 1); rest != 0 || len(names) == 0 {
+		names = append(names, "0x"+strconv.FormatUint(uint64(rest), 16))
+	}
+	return strings.Join(names, "|")
+}
+
+// Flags returns f's flags.
+func (f *Func) Flags() FuncFlag {
+	return FuncFlag(f.Raw.Flag)
+}
+
+// IsTopFrame returns whether f is the top frame of its stack.
+func (f *Func) IsTopFrame() bool {
+	return f.Flags()&FuncFlagTopFrame != 0
+}
+
+// WritesSP returns whether f writes an arbitrary value to SP.
+func (f *Func) WritesSP() bool {
+	return f.Flags()&FuncFlagSPWrite != 0
+}
+
+// IsAsm returns whether f is implemented in assembly.
+func (f *Func) IsAsm() bool {
+	return f.Flags()&FuncFlagAsm != 0
+}
@@ -5,41 +5,129 @@
 package functab
 
 import (
-	"bytes"
 	"debug/dwarf"
 	"encoding/binary"
 	"fmt"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/aclements/objbrowse/internal/obj"
 )
 
 type FuncTab struct {
+	// Funcs are the functions in the table, sorted by PC. Each
+	// function extends to the start of the next, and the last
+	// extends to EndPC.
 	Funcs []*Func
 	EndPC uint64
 
-	// PCDATA and FUNCDATA indexes
+	// Version is the first Go release that uses this function
+	// table's layout, such as "go1.2" or "go1.18".
+	Version string
+
+	// Indexes maps the names of the runtime's PCDATA and FUNCDATA
+	// index constants, such as "_PCDATA_StackMapIndex", to their
+	// values, as recorded in the binary's DWARF or, if it has no
+	// DWARF, as used by the release. These vary between Go
+	// versions:
+	//
+	//	PCDATA                 1.9-1.11  1.12-1.15  1.16+
+	//	_PCDATA_RegMapIndex        -          0      -
+	//	_PCDATA_UnsafePoint        -          -      0
+	//	_PCDATA_StackMapIndex      0          1      1
+	//	_PCDATA_InlTreeIndex       1          2      2
+	//	_PCDATA_ArgLiveIndex       -          -      3 (1.18+)
+	//
+	//	FUNCDATA                   1.9-1.11  1.12-1.15  1.16+
+	//	_FUNCDATA_ArgsPointerMaps        0          0      0
+	//	_FUNCDATA_LocalsPointerMaps      1          1      1
+	//	_FUNCDATA_RegPointerMaps         -          2      -
+	//	_FUNCDATA_StackObjects           -          3      2
+	//	_FUNCDATA_InlTree                2          4      3
+	//	_FUNCDATA_OpenCodedDeferInfo     -   5 (1.14+)     4
+	//	_FUNCDATA_ArgInfo                -          -      5 (1.17+)
+	//	_FUNCDATA_ArgLiveInfo            -          -      6 (1.18+)
+	//
+	// Use the values in Indexes rather than these tables when
+	// possible.
 	Indexes map[string]int64
 
 	_PCDATA_StackMapIndex       int
 	_FUNCDATA_ArgsPointerMaps   int
 	_FUNCDATA_LocalsPointerMaps int
+
+	layout *pclnLayout
+
+	// files is the file name table of layouts that aren't split.
+	// Index 0 is unused.
+	files []string
+
+	// pclntab is the raw function table.
+	pclntab []byte
+
+	// funcnametab, cutab, filetab, and pctab are the function
+	// name, compilation unit, file name, and PC value tables.
+	// In layouts that aren't split, funcnametab and pctab are
+	// the whole pclntab, and cutab and filetab are nil.
+	funcnametab, cutab, filetab, pctab []byte
+
+	order binary.ByteOrder
+
+	// inlinedCallSize is the size of a runtime.inlinedCall, which
+	// varies between Go versions.
+	inlinedCallSize int
 }
 
 type Func struct {
 	PC       uint64
 	Name     string
 	PCSP     PCData
+	PCFile   PCData
+	PCLine   PCData
 	PCData   []PCData
 	FuncData []FuncData
 	ft       *FuncTab
+
+	// lines is the decoded PCFile and PCLine tables.
+	lines *lineTables
+	// inline is the decoded inline tree index table.
+	inline *inlineTable
+	// sp is the decoded PCSP table.
+	sp *lazyPCTable
+	// live is the decoded stack maps.
+	live *lazyLiveness
+
+	// Raw is the undecoded _func structure for this function.
+	Raw RawFunc
 }
 
-type symtabHdr struct {
-	Magic     uint32
-	_         uint16
-	PCQuantum uint8
-	PtrSize   uint8
+// RawFunc is a direct decoding of a runtime _func structure. Offsets
+// are exactly as they appear in the binary, so they're relative to
+// the start of the pclntab or, in later layouts, to the table they
+// index. In layouts that record FUNCDATA as offsets, FuncData holds
+// the offsets rather than pointers.
+type RawFunc struct {
+	Entry       uint64
+	NameOff     int32
+	Args        int32
+	DeferReturn uint32
+	PCSP        uint32
+	PCFile      uint32
+	PCLn        uint32
+	NPCData     uint32
+	CUOffset    uint32
+	StartLine   int32
+	FuncID      uint8
+	Flag        uint8
+	NFuncData   uint8
+
+	// PCData is the table of pcdata offsets.
+	PCData []uint32
+
+	// FuncData is the table of funcdata pointers.
+	FuncData []uint64
 }
 
 type fileInfo struct {
@@ -52,117 +140,315 @@ type fileInfo struct {
 // NewFuncTab decodes a Go function table from data, which should be
 // the contents of the "runtime.pclntab" symbol in the object file
 // given by obj.
-func NewFuncTab(data []byte, obj obj.Obj) (*FuncTab, error) {
-	var err error
-	var order binary.ByteOrder
-	var hdr symtabHdr
-	for _, order = range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
-		if err := binary.Read(bytes.NewBuffer(data), order, &hdr); err != nil {
-			return nil, err
+//
+// If data is malformed or isn't in a known format, NewFuncTab
+// returns an error.
+func NewFuncTab(data []byte, obj obj.Obj) (ft *FuncTab, err error) {
+	// The decoder doesn't check bounds, so a malformed table
+	// will cause an out of range panic.
+	defer func() {
+		if e := recover(); e != nil {
+			if e, ok := e.(runtime.Error); ok {
+				ft, err = nil, fmt.Errorf("malformed function table: %v", e)
+				return
+			}
+			panic(e)
 		}
-		if hdr.Magic == 0xfffffffb {
-			goto hdrGood
+	}()
+
+	layout, order, hdr, err := findLayout(data)
+	if err != nil {
+		return nil, err
+	}
+	// The header is self-describing, but it must agree with the
+	// object file, or we'd misread pointers elsewhere.
+	if a := obj.Info().Arch; a != nil {
+		if int(hdr.PtrSize) != a.PtrSize {
+			return nil, fmt.Errorf("function table pointer size %d does not match %s pointer size %d", hdr.PtrSize, a, a.PtrSize)
+		}
+		if order != a.ByteOrder {
+			return nil, fmt.Errorf("function table byte order %s does not match %s", order, a)
 		}
 	}
-	return nil, fmt.Errorf("bad magic word in header %#x", hdr.Magic)
-hdrGood:
 
 	d := decoder{order: order, ptrSize: int(hdr.PtrSize), data: data, pos: 8}
 	fi := &fileInfo{obj, d.order, d.ptrSize, hdr.PCQuantum}
 
-	ft := new(FuncTab)
+	ft = &FuncTab{Version: layout.version, layout: layout, pclntab: data, order: order}
 
-	// Read func PC/offset table.
+	// Find the function PC/offset table and the tables it refers
+	// to.
 	//
 	// See cmd/link/internal/ld/pcln.go:pclntab
-	nfunc := d.Ptr()
+	var nfunc, textStart uint64
+	var functab, funcBase []byte
+	if !layout.split {
+		// Everything is relative to the pclntab and the
+		// function table directly follows the header.
+		nfunc = d.Ptr()
+		functab = data[d.pos:]
+		ft.funcnametab, ft.pctab, funcBase = data, data, data
+	} else {
+		nfunc = d.Ptr()
+		d.Ptr() // Number of files
+		if layout.relative {
+			textStart = d.Ptr()
+		}
+		var offs [5]uint64
+		var tabs [5][]byte
+		for i := range tabs {
+			offs[i] = d.Ptr()
+			if offs[i] > uint64(len(data)) {
+				return nil, fmt.Errorf("function table header offset %#x out of range", offs[i])
+			}
+			tabs[i] = data[offs[i]:]
+		}
+		// The linker lays the tables out in order, so each
+		// ends where the next starts.
+		for i := 0; i+1 < len(tabs); i++ {
+			if offs[i] <= offs[i+1] {
+				tabs[i] = data[offs[i]:offs[i+1]]
+			}
+		}
+		ft.funcnametab, ft.cutab, ft.filetab, ft.pctab, functab = tabs[0], tabs[1], tabs[2], tabs[3], tabs[4]
+		// _func offsets are relative to the function table.
+		funcBase = functab
+	}
+
+	// Go 1.18 and later record entry points as offsets from the
+	// start of the text segment and FUNCDATA as offsets from
+	// go:func.*.
+	var goFunc uint64
+	if layout.relative {
+		syms, err := obj.Symbols()
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range syms {
+			switch s.Name {
+			case "runtime.text":
+				if textStart == 0 {
+					// The header wasn't relocated.
+					textStart = s.Value
+				}
+			case "go:func.*", "go.func.*":
+				goFunc = s.Value
+			}
+		}
+	}
+
+	// Read func PC/offset table.
+	fieldSize := uint64(d.ptrSize)
+	if layout.relative {
+		fieldSize = 4
+	}
+	if (2*nfunc+1)*fieldSize > uint64(len(functab)) {
+		return nil, fmt.Errorf("function table extends past end of pclntab")
+	}
+	fd := decoder{order: order, ptrSize: d.ptrSize, data: functab}
+	field := func() uint64 {
+		if layout.relative {
+			return uint64(fd.Uint32())
+		}
+		return fd.Ptr()
+	}
 	ft.Funcs = make([]*Func, nfunc)
 	offsets := make([]uint64, nfunc)
 	for i := range offsets {
-		d.Ptr() // PC (will read from func later)
-		offsets[i] = d.Ptr()
+		field() // PC (will read from func later)
+		offsets[i] = field()
+	}
+	ft.EndPC = field()
+	if layout.relative {
+		ft.EndPC += textStart
 	}
-	ft.EndPC = d.Ptr()
-	d.Uint32() // fileTabOffset
 
-	// Extract the PCDATA and FUNCDATA index definitions.
-	dw, err := obj.DWARF()
-	if err != nil {
-		return nil, err
+	// Read the file table. Later layouts look up file names
+	// through each function's compilation unit instead.
+	if !layout.split {
+		fileTabOffset := fd.Uint32()
+		if uint64(fileTabOffset)+4 <= uint64(len(data)) {
+			fd := decoder{order: order, data: data, pos: uint64(fileTabOffset)}
+			nfile := fd.Uint32()
+			if uint64(fileTabOffset)+4*uint64(nfile) > uint64(len(data)) {
+				return nil, fmt.Errorf("file table extends past end of function table")
+			}
+			ft.files = make([]string, nfile)
+			for i := 1; i < int(nfile); i++ {
+				off := fd.Uint32()
+				if uint64(off) >= uint64(len(data)) {
+					return nil, fmt.Errorf("file name %d out of range", i)
+				}
+				ft.files[i] = (&decoder{data: data, pos: uint64(off)}).CString()
+			}
+		}
 	}
-	ft.Indexes, err = getDataIndexes(dw)
-	if err != nil {
-		return nil, err
+
+	// Extract the PCDATA and FUNCDATA index definitions. If
+	// there's no DWARF, use the values for the layout's releases.
+	// If those varied, we can still decode everything but PCDATA
+	// and FUNCDATA.
+	ft.Indexes = make(map[string]int64)
+	if dw, err := obj.DWARF(); err == nil {
+		ft.Indexes, ft.inlinedCallSize, err = getDataIndexes(dw)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for name, val := range layout.indexes {
+			ft.Indexes[name] = val
+		}
 	}
 	fetchIndex := func(name string, out *int) {
 		val, ok := ft.Indexes[name]
-		if !ok && err == nil {
-			err = fmt.Errorf("missing definition of %s", name)
+		if !ok {
+			val = -1
 		}
 		*out = int(val)
 	}
 	fetchIndex("_PCDATA_StackMapIndex", &ft._PCDATA_StackMapIndex)
 	fetchIndex("_FUNCDATA_ArgsPointerMaps", &ft._FUNCDATA_ArgsPointerMaps)
 	fetchIndex("_FUNCDATA_LocalsPointerMaps", &ft._FUNCDATA_LocalsPointerMaps)
-	if err != nil {
-		return nil, err
+
+	// pcTab returns the PC value table at offset off.
+	pcTab := func(off uint32) []byte {
+		if uint64(off) >= uint64(len(ft.pctab)) {
+			return nil
+		}
+		return ft.pctab[off:]
 	}
 
 	// Read func structures.
+	d.data = funcBase
+	legacy := !layout.split && isLegacyFuncs(funcBase, offsets, d.ptrSize, order)
 	for i := range ft.Funcs {
+		if offsets[i] >= uint64(len(funcBase)) {
+			return nil, fmt.Errorf("function %d offset %#x out of range", i, offsets[i])
+		}
 		d.pos = offsets[i]
 
 		// Fixed struct.
 		// See runtime/runtime2.go:_func
-		pc := d.Ptr()
-		nameoff := d.Int32()
-		d.Int32()  // args
-		d.Uint32() // deferreturn
-		pcspOff := d.Uint32()
-		pcsp := PCData{fi, pc, data[pcspOff:]}
-		d.Uint32() // pcfile
-		d.Uint32() // pcln
-		npcdata := d.Uint32()
-		d.Uint8()  // funcID
-		d.Uint16() // unused
-		nfuncdata := d.Uint8()
+		var raw RawFunc
+		if layout.relative {
+			raw.Entry = textStart + uint64(d.Uint32())
+		} else {
+			raw.Entry = d.Ptr()
+		}
+		raw.NameOff = d.Int32()
+		raw.Args = d.Int32()
+		raw.DeferReturn = d.Uint32()
+		raw.PCSP = d.Uint32()
+		raw.PCFile = d.Uint32()
+		raw.PCLn = d.Uint32()
+		raw.NPCData = d.Uint32()
+		if layout.split {
+			raw.CUOffset = d.Uint32()
+		}
+		if layout.startLine {
+			raw.StartLine = d.Int32()
+		}
+		if legacy {
+			raw.NFuncData = uint8(d.Uint32())
+		} else {
+			raw.FuncID = d.Uint8()
+			// Flag is padding before Go 1.17, which is
+			// always 0.
+			raw.Flag = d.Uint8()
+			d.Uint8() // unused
+			raw.NFuncData = d.Uint8()
+		}
+		pc := raw.Entry
+		pcsp := PCData{fi, pc, pcTab(raw.PCSP)}
+		pcfile := PCData{fi, pc, pcTab(raw.PCFile)}
+		pcline := PCData{fi, pc, pcTab(raw.PCLn)}
 
 		// PC data offsets (npcdata * uint32)
-		pcdata := make([]PCData, npcdata)
+		pcdata := make([]PCData, raw.NPCData)
+		raw.PCData = make([]uint32, raw.NPCData)
 		for i := range pcdata {
 			off := d.Uint32()
-			pcdata[i] = PCData{fi, pc, data[off:]}
+			raw.PCData[i] = off
+			pcdata[i] = PCData{fi, pc, pcTab(off)}
 		}
 
-		// Func data offsets (nfuncdata * ptr)
-		if d.ptrSize == 8 && d.pos&4 != 0 {
-			// Func data is ptr-aligned.
-			d.pos += 4
-		}
-		funcdata := make([]FuncData, nfuncdata)
-		for i := range funcdata {
-			funcdata[i] = FuncData{fi, d.Ptr()}
+		funcdata := make([]FuncData, raw.NFuncData)
+		raw.FuncData = make([]uint64, raw.NFuncData)
+		if layout.relative {
+			// Func data offsets (nfuncdata * uint32)
+			for i := range funcdata {
+				off := d.Uint32()
+				raw.FuncData[i] = uint64(off)
+				if off != ^uint32(0) && goFunc != 0 {
+					funcdata[i] = FuncData{fi, goFunc + uint64(off)}
+				} else {
+					funcdata[i] = FuncData{fi, 0}
+				}
+			}
+		} else {
+			// Func data pointers (nfuncdata * ptr)
+			if d.ptrSize == 8 && d.pos&4 != 0 {
+				// Func data is ptr-aligned.
+				d.pos += 4
+			}
+			for i := range funcdata {
+				raw.FuncData[i] = d.Ptr()
+				funcdata[i] = FuncData{fi, raw.FuncData[i]}
+			}
 		}
 
-		// Get name.
-		d.pos = uint64(nameoff)
-		name := d.CString()
+		name, _ := ft.funcName(raw.NameOff)
 
-		fn := &Func{pc, name, pcsp, pcdata, funcdata, ft}
+		fn := &Func{pc, name, pcsp, pcfile, pcline, pcdata, funcdata, ft, new(lineTables), new(inlineTable), new(lazyPCTable), new(lazyLiveness), raw}
 		ft.Funcs[i] = fn
 	}
 
 	return ft, nil
 }
 
-func getDataIndexes(dw *dwarf.Data) (map[string]int64, error) {
+// isLegacyFuncs reports whether the _func structures at offsets in
+// data use the Go 1.2 through 1.10 layout, which ends with a 32-bit
+// nfuncdata rather than a funcID, padding, and an 8-bit nfuncdata.
+// Both use the same pclntab magic number, so we tell them apart by
+// the last byte of each _func. In little-endian binaries, this is the
+// high byte of the 32-bit nfuncdata, which is always 0, but it's the
+// 8-bit nfuncdata in later releases, which is rarely 0. In big-endian
+// binaries, this is the low byte of nfuncdata in both, so the
+// layouts differ only in funcID.
+func isLegacyFuncs(data []byte, offsets []uint64, ptrSize int, order binary.ByteOrder) bool {
+	if order != binary.LittleEndian {
+		return false
+	}
+	for _, off := range offsets {
+		last := off + uint64(ptrSize) + 8*4 - 1
+		if last < uint64(len(data)) && data[last] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// funcName returns the function name at offset off in the function
+// name table.
+func (ft *FuncTab) funcName(off int32) (string, bool) {
+	if off < 0 {
+		return "", false
+	}
+	return cString(ft.funcnametab, uint64(off))
+}
+
+// getDataIndexes returns the values of the runtime's PCDATA and
+// FUNCDATA index constants and the size of runtime.inlinedCall, or 0
+// if it isn't known.
+func getDataIndexes(dw *dwarf.Data) (map[string]int64, int, error) {
 	// Look for global runtime._(FUNCDATA|PCDATA)_* constants.
 	r := dw.Reader()
 	indexes := make(map[string]int64)
+	inlinedCallSize := 0
 	for {
 		ent, err := r.Next()
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		} else if ent == nil {
 			break
 		}
@@ -173,11 +459,23 @@ func getDataIndexes(dw *dwarf.Data) (map[string]int64, error) {
 		case dwarf.TagCompileUnit:
 			// Process children
 
+		case dwarf.TagStructType:
+			if name, _ := ent.Val(dwarf.AttrName).(string); name == "runtime.inlinedCall" {
+				size, _ := ent.Val(dwarf.AttrByteSize).(int64)
+				inlinedCallSize = int(size)
+			}
+			r.SkipChildren()
+
 		case dwarf.TagConstant:
 			name, ok := ent.Val(dwarf.AttrName).(string)
 			if !ok {
 				break
 			}
+			if strings.HasPrefix(name, "internal/abi.") {
+				// Go 1.21 moved these to internal/abi
+				// and dropped the leading underscore.
+				name = "runtime._" + name[len("internal/abi."):]
+			}
 			if !(strings.HasPrefix(name, "runtime._FUNCDATA_") ||
 				strings.HasPrefix(name, "runtime._PCDATA_")) {
 				break
@@ -195,11 +493,84 @@ func getDataIndexes(dw *dwarf.Data) (map[string]int64, error) {
 	for _, want := range []string{"_PCDATA_StackMapIndex",
 		"_FUNCDATA_ArgsPointerMaps", "_FUNCDATA_LocalsPointerMaps"} {
 		if _, ok := indexes[want]; !ok {
-			return nil, fmt.Errorf("missing definition of %s", want)
+			return nil, 0, fmt.Errorf("missing definition of %s", want)
 		}
 	}
 
-	return indexes, nil
+	return indexes, inlinedCallSize, nil
+}
+
+// lineTables is the lazily-decoded source position tables of a Func.
+type lineTables struct {
+	once       sync.Once
+	file, line PCTable
+}
+
+// SourceLine returns the source file and line of the instruction at
+// pc in f.
+func (f *Func) SourceLine(pc uint64) (file string, line int, ok bool) {
+	t := f.lines
+	t.once.Do(func() {
+		// Offset 0 means there's no table.
+		if f.Raw.PCFile != 0 && f.Raw.PCLn != 0 {
+			t.file = f.PCFile.Decode()
+			t.line = f.PCLine.Decode()
+		}
+	})
+	fileIdx, ok1 := t.file.Lookup(pc)
+	lineNo, ok2 := t.line.Lookup(pc)
+	if !ok1 || !ok2 {
+		return "", 0, false
+	}
+	file, ok = f.fileName(fileIdx)
+	return file, int(lineNo), ok
+}
+
+// FuncForPC returns the function containing pc, or nil if pc isn't
+// in any function.
+func (ft *FuncTab) FuncForPC(pc uint64) *Func {
+	i := sort.Search(len(ft.Funcs), func(i int) bool {
+		return ft.Funcs[i].PC > pc
+	}) - 1
+	if i < 0 || pc >= ft.EndPC {
+		return nil
+	}
+	return ft.Funcs[i]
+}
+
+// LineForPC returns the source file and line of the instruction at pc
+// and the function containing it. This only has to search for the
+// function, so it's cheap to call for every instruction of a
+// function.
+func (ft *FuncTab) LineForPC(pc uint64) (file string, line int, fn *Func, ok bool) {
+	fn = ft.FuncForPC(pc)
+	if fn == nil {
+		return "", 0, nil, false
+	}
+	file, line, ok = fn.SourceLine(pc)
+	return file, line, fn, ok
+}
+
+// lazyPCTable is a lazily-decoded PCTable.
+type lazyPCTable struct {
+	once sync.Once
+	tab  PCTable
+}
+
+// SPAdj returns the size of f's stack frame at pc, that is, how far
+// the stack pointer at pc is below the stack pointer at f's entry.
+// This is 0 at entry, grows in the prologue, and shrinks back before
+// returns and tail calls.
+func (f *Func) SPAdj(pc uint64) (int, bool) {
+	t := f.sp
+	t.once.Do(func() {
+		// Offset 0 means there's no table.
+		if f.Raw.PCSP != 0 {
+			t.tab = f.PCSP.Decode()
+		}
+	})
+	v, ok := t.tab.Lookup(pc)
+	return int(v), ok
 }
 
 type Liveness struct {
@@ -208,7 +579,9 @@ type Liveness struct {
 }
 
 func (f Func) Liveness() (Liveness, error) {
-	if len(f.PCData) <= f.ft._PCDATA_StackMapIndex {
+	if f.ft._PCDATA_StackMapIndex < 0 || len(f.PCData) <= f.ft._PCDATA_StackMapIndex ||
+		f.ft._FUNCDATA_ArgsPointerMaps < 0 || len(f.FuncData) <= f.ft._FUNCDATA_ArgsPointerMaps ||
+		f.ft._FUNCDATA_LocalsPointerMaps < 0 || len(f.FuncData) <= f.ft._FUNCDATA_LocalsPointerMaps {
 		return Liveness{}, nil
 	}
 
@@ -259,3 +632,27 @@ func (f Func) Liveness() (Liveness, error) {
 
 	return Liveness{stackMap, args, locals}, nil
 }
+
+// lazyLiveness is the lazily-decoded Liveness of a Func.
+type lazyLiveness struct {
+	once sync.Once
+	l    Liveness
+}
+
+// StackMap returns the pointer maps of f's arguments and locals at
+// pc, which must be a safe point, such as a call. Bit i of args is
+// set if word i of the arguments, starting at argp, holds a live
+// pointer. Bit i of locals is set if word i of the locals, which end
+// at varp, holds a live pointer. It returns false if f has no stack
+// maps or pc isn't a safe point.
+func (f *Func) StackMap(pc uint64) (args, locals Bitmap, ok bool) {
+	t := f.live
+	t.once.Do(func() {
+		t.l, _ = f.Liveness()
+	})
+	idx, ok := t.l.Index.Lookup(pc)
+	if !ok || idx < 0 || int(idx) >= len(t.l.Args) || int(idx) >= len(t.l.Locals) {
+		return Bitmap{}, Bitmap{}, false
+	}
+	return t.l.Args[idx], t.l.Locals[idx], true
+}
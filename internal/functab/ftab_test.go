@@ -0,0 +1,332 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package functab
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/aclements/objbrowse/internal/obj"
+)
+
+// testFunc is a function in a synthesized pclntab. Its file, line,
+// and SP adjustment are the same across its whole body.
+type testFunc struct {
+	name     string
+	pc, size uint64
+	file     string
+	line     int32
+	spAdj    int32
+}
+
+var testFuncs = []testFunc{
+	{"main.main", 0x401000, 0x40, "/src/main.go", 10, 24},
+	{"main.f", 0x401040, 0x20, "/src/f.go", 3, 0},
+	{"main.g", 0x401060, 0x10, "/src/main.go", 20, 8},
+}
+
+const testTextStart = 0x401000
+
+// tabWriter builds a little-endian table.
+type tabWriter struct {
+	b []byte
+}
+
+func (w *tabWriter) u32(v uint32) {
+	w.b = append(w.b, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(w.b[len(w.b)-4:], v)
+}
+
+func (w *tabWriter) u64(v uint64) {
+	w.b = append(w.b, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint64(w.b[len(w.b)-8:], v)
+}
+
+// str appends a NUL-terminated string and returns its offset.
+func (w *tabWriter) str(s string) uint32 {
+	off := uint32(len(w.b))
+	w.b = append(append(w.b, s...), 0)
+	return off
+}
+
+// pcValue appends a PC value table that maps size bytes to val and
+// returns its offset.
+func (w *tabWriter) pcValue(val int32, size uint64) uint32 {
+	off := uint32(len(w.b))
+	var buf [binary.MaxVarintLen64]byte
+	w.b = append(w.b, buf[:binary.PutVarint(buf[:], int64(val)+1)]...)
+	w.b = append(w.b, buf[:binary.PutUvarint(buf[:], size)]...)
+	w.b = append(w.b, 0)
+	return off
+}
+
+// buildPclntab synthesizes a little-endian 64-bit pclntab with layout
+// l for funcs, which must be sorted and contiguous. The PC quantum
+// is 1.
+func buildPclntab(l *pclnLayout, funcs []testFunc) []byte {
+	// Build the variable-length tables. Layouts that aren't split
+	// keep everything in one table.
+	var names, cutab, filetab, pctab tabWriter
+	pnames, pfiletab, ppctab := &names, &filetab, &pctab
+	if !l.split {
+		pfiletab, ppctab = &names, &names
+	}
+	// Offset 0 means no table.
+	ppctab.b = append(ppctab.b, 0)
+	type offsets struct{ name, sp, file, line uint32 }
+	offs := make([]offsets, len(funcs))
+	var files []string
+	fileIdx := make(map[string]int)
+	for i, f := range funcs {
+		idx, ok := fileIdx[f.file]
+		if !ok {
+			idx = len(files)
+			fileIdx[f.file] = idx
+			files = append(files, f.file)
+		}
+		if !l.split {
+			// Index 0 of the global file table is unused.
+			idx++
+		}
+		offs[i].name = pnames.str(f.name)
+		offs[i].sp = ppctab.pcValue(f.spAdj, f.size)
+		offs[i].file = ppctab.pcValue(int32(idx), f.size)
+		offs[i].line = ppctab.pcValue(f.line, f.size)
+	}
+	var fileOffs []uint32
+	for _, file := range files {
+		fileOffs = append(fileOffs, pfiletab.str(file))
+	}
+	n := uint64(len(funcs))
+	end := funcs[n-1].pc + funcs[n-1].size
+
+	var w tabWriter
+	w.u32(l.magic)
+	w.b = append(w.b, 0, 0, 1, 8)
+
+	if !l.split {
+		// Header, function table, file table offset, _func
+		// structures, then everything else.
+		const funcSize = 40
+		funcs0 := 16 + (2*n+1)*8 + 4
+		base := uint32(funcs0 + funcSize*n)
+		w.u64(n)
+		for i, f := range funcs {
+			w.u64(f.pc)
+			w.u64(funcs0 + funcSize*uint64(i))
+		}
+		w.u64(end)
+		w.u32(base + uint32(len(names.b)))
+		for i, f := range funcs {
+			w.u64(f.pc)
+			w.u32(base + offs[i].name)
+			w.u32(0) // args
+			w.u32(0) // deferreturn
+			w.u32(base + offs[i].sp)
+			w.u32(base + offs[i].file)
+			w.u32(base + offs[i].line)
+			w.u32(0) // npcdata
+			w.u32(0) // funcID, padding, nfuncdata
+		}
+		w.b = append(w.b, names.b...)
+		w.u32(uint32(len(files) + 1))
+		for _, off := range fileOffs {
+			w.u32(base + off)
+		}
+		return w.b
+	}
+
+	for _, off := range fileOffs {
+		cutab.u32(off)
+	}
+
+	var functab tabWriter
+	field := func(v uint64) {
+		if l.relative {
+			functab.u32(uint32(v))
+		} else {
+			functab.u64(v)
+		}
+	}
+	entry := func(pc uint64) uint64 {
+		if l.relative {
+			return pc - testTextStart
+		}
+		return pc
+	}
+	fieldSize, funcSize := uint64(8), uint64(8+8*4+4)
+	if l.relative {
+		fieldSize, funcSize = 4, 4+8*4+4
+	}
+	if l.startLine {
+		funcSize += 4
+	}
+	for i, f := range funcs {
+		field(entry(f.pc))
+		field((2*n+1)*fieldSize + funcSize*uint64(i))
+	}
+	field(entry(end))
+	for i, f := range funcs {
+		field(entry(f.pc))
+		functab.u32(offs[i].name)
+		functab.u32(0) // args
+		functab.u32(0) // deferreturn
+		functab.u32(offs[i].sp)
+		functab.u32(offs[i].file)
+		functab.u32(offs[i].line)
+		functab.u32(0) // npcdata
+		functab.u32(0) // cuOffset
+		if l.startLine {
+			functab.u32(uint32(f.line))
+		}
+		functab.u32(0) // funcID, flag, padding, nfuncdata
+	}
+
+	w.u64(n)
+	w.u64(uint64(len(files)))
+	if l.relative {
+		w.u64(testTextStart)
+	}
+	tabs := [][]byte{names.b, cutab.b, filetab.b, pctab.b, functab.b}
+	off := uint64(len(w.b) + 8*len(tabs))
+	for _, tab := range tabs {
+		w.u64(off)
+		off += uint64(len(tab))
+	}
+	for _, tab := range tabs {
+		w.b = append(w.b, tab...)
+	}
+	return w.b
+}
+
+// testObj is an obj.Obj with only a runtime.text symbol.
+type testObj struct{}
+
+func (testObj) Data(ptr, size uint64) ([]byte, error) { return nil, nil }
+func (testObj) Info() obj.ObjInfo                     { return obj.ObjInfo{} }
+func (testObj) Symbols() ([]obj.Sym, error) {
+	return []obj.Sym{{Name: "runtime.text", Value: testTextStart, Kind: obj.SymText}}, nil
+}
+func (testObj) SymbolData(s obj.Sym) ([]byte, error)       { return nil, nil }
+func (testObj) DWARF() (*dwarf.Data, error)                { return nil, errors.New("no DWARF") }
+func (testObj) BuildID() (string, error)                   { return "", obj.ErrNoBuildID }
+func (testObj) Sections() ([]obj.Section, error)           { return nil, nil }
+func (testObj) Relocations(s obj.Sym) ([]obj.Reloc, error) { return nil, obj.ErrNotSupported }
+
+func TestLayouts(t *testing.T) {
+	for _, l := range pclnLayouts {
+		t.Run(l.version, func(t *testing.T) {
+			ft, err := NewFuncTab(buildPclntab(l, testFuncs), testObj{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ft.Version != l.version {
+				t.Errorf("got version %s, want %s", ft.Version, l.version)
+			}
+			if want := uint64(0x401070); ft.EndPC != want {
+				t.Errorf("got EndPC %#x, want %#x", ft.EndPC, want)
+			}
+			if len(ft.Funcs) != len(testFuncs) {
+				t.Fatalf("got %d funcs, want %d", len(ft.Funcs), len(testFuncs))
+			}
+			for i, want := range testFuncs {
+				f := ft.Funcs[i]
+				if f.Name != want.name || f.PC != want.pc {
+					t.Errorf("func %d: got %s at %#x, want %s at %#x", i, f.Name, f.PC, want.name, want.pc)
+					continue
+				}
+				pc := want.pc + want.size - 1
+				file, line, ok := f.SourceLine(pc)
+				if !ok || file != want.file || line != int(want.line) {
+					t.Errorf("%s: got position %s:%d %v, want %s:%d", f.Name, file, line, ok, want.file, want.line)
+				}
+				if sp, ok := f.SPAdj(pc); !ok || sp != int(want.spAdj) {
+					t.Errorf("%s: got SP adjustment %d %v, want %d", f.Name, sp, ok, want.spAdj)
+				}
+				if l.startLine && f.Raw.StartLine != want.line {
+					t.Errorf("%s: got start line %d, want %d", f.Name, f.Raw.StartLine, want.line)
+				}
+			}
+		})
+	}
+}
+
+func TestBadMagic(t *testing.T) {
+	data := buildPclntab(pclnLayouts[0], testFuncs)
+	binary.LittleEndian.PutUint32(data, 0xfffffff7)
+	if _, err := NewFuncTab(data, testObj{}); err == nil {
+		t.Errorf("want error for unknown magic")
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	for _, l := range pclnLayouts {
+		data := buildPclntab(l, testFuncs)
+		for n := 0; n < len(data); n++ {
+			// This must not panic.
+			NewFuncTab(data[:n], testObj{})
+		}
+	}
+}
+
+func TestLegacyFuncs(t *testing.T) {
+	// Two 64-bit _funcs with nfuncdata 2.
+	data := make([]byte, 80)
+	data[39], data[79] = 2, 2
+	if isLegacyFuncs(data, []uint64{0, 40}, 8, binary.LittleEndian) {
+		t.Errorf("Go 1.11 _funcs detected as legacy")
+	}
+	data[39], data[79] = 0, 0
+	data[36], data[76] = 2, 2
+	if !isLegacyFuncs(data, []uint64{0, 40}, 8, binary.LittleEndian) {
+		t.Errorf("Go 1.10 _funcs not detected as legacy")
+	}
+}
+
+func TestFuncFlagString(t *testing.T) {
+	for flag, want := range map[FuncFlag]string{
+		0:                                "0x0",
+		FuncFlagSPWrite:                  "SPWRITE",
+		FuncFlagTopFrame | FuncFlagAsm:   "TOPFRAME|ASM",
+		FuncFlagSPWrite | FuncFlag(0x40): "SPWRITE|0x40",
+	} {
+		if got := flag.String(); got != want {
+			t.Errorf("%#x: got %s, want %s", uint8(flag), got, want)
+		}
+	}
+}
+
+func TestFuncForPC(t *testing.T) {
+	ft, err := NewFuncTab(buildPclntab(pclnLayouts[len(pclnLayouts)-1], testFuncs), testObj{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		pc   uint64
+		want string
+	}{
+		{0x400fff, ""},
+		{0x401000, "main.main"},
+		{0x40103f, "main.main"},
+		{0x401040, "main.f"},
+		{0x40106f, "main.g"},
+		{0x401070, ""},
+	} {
+		name := ""
+		if fn := ft.FuncForPC(test.pc); fn != nil {
+			name = fn.Name
+		}
+		if name != test.want {
+			t.Errorf("%#x: got func %q, want %q", test.pc, name, test.want)
+		}
+	}
+
+	file, line, fn, ok := ft.LineForPC(0x401048)
+	if !ok || fn.Name != "main.f" || file != "/src/f.go" || line != 3 {
+		t.Errorf("got %s:%d in %v %v, want /src/f.go:3 in main.f", file, line, fn, ok)
+	}
+}
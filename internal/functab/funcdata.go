@@ -17,6 +17,22 @@ func (f FuncData) Read(size uint64) ([]byte, error) {
 	return f.fi.mmap.Data(f.ptr, size)
 }
 
+// FuncDataBytes returns the first size bytes of f's FUNCDATA with the
+// given index, which is one of the _FUNCDATA_* values in
+// FuncTab.Indexes. The FUNCDATA formats don't record their own
+// sizes, so the caller must know how much to read. It returns false
+// if f doesn't have this FUNCDATA or it can't be read.
+func (f *Func) FuncDataBytes(index int, size uint64) ([]byte, bool) {
+	if index < 0 || index >= len(f.FuncData) || f.FuncData[index].ptr == 0 {
+		return nil, false
+	}
+	data, err := f.FuncData[index].Read(size)
+	if err != nil || uint64(len(data)) < size {
+		return nil, false
+	}
+	return data, true
+}
+
 func (f FuncData) StackMap() ([]Bitmap, error) {
 	// Read the header
 	hdr, err := f.Read(8)
@@ -0,0 +1,164 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package functab
+
+import "sync"
+
+// An InlineFrame is one frame of the inlining stack at a PC.
+type InlineFrame struct {
+	// Func is the name of the function.
+	Func string
+
+	// File and Line are the current position in Func. For all
+	// but the innermost frame, this is the call site of the
+	// next inner frame.
+	File string
+	Line int
+}
+
+// maxInlineDepth limits the depth of inlining stacks, in case the
+// inline tree is malformed.
+const maxInlineDepth = 1000
+
+// inlineTable is the lazily-decoded inline tree index table of a
+// Func.
+type inlineTable struct {
+	once  sync.Once
+	index PCTable
+	ok    bool
+}
+
+// inlinedCall is a decoded runtime.inlinedCall. Layouts with
+// inlineParentPC record parentPC instead of parent, file, and line.
+type inlinedCall struct {
+	parent   int32
+	file     int32
+	line     int32
+	parentPC uint32
+	name     string
+}
+
+// InlineStack returns the inlining stack at pc in f, innermost frame
+// first. The last frame is always f itself. If pc isn't in an
+// inlined call, this returns just f's frame. It returns nil if the
+// position of pc isn't known.
+func (f *Func) InlineStack(pc uint64) []InlineFrame {
+	file, line, ok := f.SourceLine(pc)
+	if !ok {
+		return nil
+	}
+	t := f.inline
+	t.once.Do(func() {
+		if idx, ok := f.ft.Indexes["_PCDATA_InlTreeIndex"]; ok {
+			t.index, t.ok = f.PCDataTable(int(idx))
+		}
+	})
+
+	lookup := func(pc uint64) int32 {
+		if t.ok {
+			if v, ok := t.index.Lookup(pc); ok {
+				return v
+			}
+		}
+		return -1
+	}
+
+	var stack []InlineFrame
+	ix := lookup(pc)
+	for depth := 0; ix >= 0 && depth < maxInlineDepth; depth++ {
+		call, ok := f.inlinedCall(ix)
+		if !ok {
+			break
+		}
+		stack = append(stack, InlineFrame{call.name, file, line})
+		// The position in the caller is the call site.
+		if f.ft.layout.inlineParentPC {
+			// The call site is the position of an
+			// instruction in the caller, which may itself
+			// be inlined.
+			pc = f.PC + uint64(call.parentPC)
+			if file, line, ok = f.SourceLine(pc); !ok {
+				file, line = "?", 0
+			}
+			ix = lookup(pc)
+			continue
+		}
+		if file, ok = f.fileName(call.file); !ok {
+			file = "?"
+		}
+		line = int(call.line)
+		ix = call.parent
+	}
+	return append(stack, InlineFrame{f.Name, file, line})
+}
+
+// inlinedCall returns entry i of f's inline tree.
+func (f *Func) inlinedCall(i int32) (inlinedCall, bool) {
+	idx, ok := f.ft.Indexes["_FUNCDATA_InlTree"]
+	if !ok || int(idx) >= len(f.FuncData) || f.FuncData[idx].ptr == 0 {
+		return inlinedCall{}, false
+	}
+	size := f.ft.inlinedCallSize
+	switch {
+	case f.ft.layout.inlineParentPC:
+		size = 16
+	case size == 0:
+		size = 20
+	}
+	fd := f.FuncData[idx]
+	data, err := fd.fi.mmap.Data(fd.ptr+uint64(i)*uint64(size), uint64(size))
+	if err != nil || len(data) < size {
+		return inlinedCall{}, false
+	}
+
+	var call inlinedCall
+	d := decoder{order: fd.fi.order, data: data}
+	if f.ft.layout.inlineParentPC {
+		// Go 1.20 and later.
+		d.Uint8()  // funcID
+		d.Bytes(3) // padding
+		call.name, ok = f.ft.funcName(d.Int32())
+		call.parentPC = d.Uint32()
+		return call, ok
+	}
+	if size == 16 {
+		// Go 1.11 and earlier.
+		call.parent = d.Int32()
+	} else {
+		// Go 1.12 through 1.19.
+		call.parent = int32(d.Int16())
+		d.Uint8() // funcID
+		d.Uint8() // padding
+	}
+	call.file = d.Int32()
+	call.line = d.Int32()
+	call.name, ok = f.ft.funcName(d.Int32())
+	return call, ok
+}
+
+// fileName returns the name of file i of f. In split layouts, i
+// indexes f's compilation unit's files; otherwise it indexes the
+// global file table.
+func (f *Func) fileName(i int32) (string, bool) {
+	ft := f.ft
+	if !ft.layout.split {
+		if i <= 0 || int(i) >= len(ft.files) {
+			return "", false
+		}
+		return ft.files[i], true
+	}
+	if i < 0 {
+		return "", false
+	}
+	cu := (uint64(f.Raw.CUOffset) + uint64(i)) * 4
+	if cu+4 > uint64(len(ft.cutab)) {
+		return "", false
+	}
+	off := ft.order.Uint32(ft.cutab[cu:])
+	if off == ^uint32(0) {
+		return "", false
+	}
+	return cString(ft.filetab, uint64(off))
+}
@@ -59,3 +59,13 @@ func (tab PCTable) Lookup(pc uint64) (val int32, ok bool) {
 	}
 	return tab.Values[i-1], true
 }
+
+// PCDataTable returns f's PCDATA table with the given index, which is
+// one of the _PCDATA_* values in FuncTab.Indexes. It returns false
+// if f doesn't have this table.
+func (f *Func) PCDataTable(index int) (PCTable, bool) {
+	if index < 0 || index >= len(f.PCData) || f.Raw.PCData[index] == 0 {
+		return PCTable{}, false
+	}
+	return f.PCData[index].Decode(), true
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package functab
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// A pclnLayout describes one version of the pclntab format. The
+// format changes are identified by the magic number in the pclntab
+// header.
+type pclnLayout struct {
+	// version is the first Go release that uses this layout.
+	version string
+
+	magic uint32
+
+	// split indicates the header is followed by the offsets of
+	// separate name, compilation unit, file, PC value, and
+	// function tables, rather than by the function table itself.
+	// Function names and PC value tables are relative to their
+	// own tables and file names are looked up through the
+	// function's compilation unit.
+	split bool
+
+	// relative indicates the header records the start of the
+	// text segment, function entry points are 32-bit offsets from
+	// it, and FUNCDATA are 32-bit offsets from the go:func.*
+	// symbol rather than pointers.
+	relative bool
+
+	// startLine indicates _func records the line number of the
+	// start of the function.
+	startLine bool
+
+	// inlineParentPC indicates inline tree entries identify the
+	// call site by the offset of an instruction in the caller
+	// rather than by a parent index, file, and line.
+	inlineParentPC bool
+
+	// indexes are the PCDATA and FUNCDATA index values of the
+	// releases that use this layout. NewFuncTab uses these if
+	// the binary has no DWARF. This is nil if they varied
+	// between releases.
+	indexes map[string]int64
+}
+
+// pclnLayouts is the list of known pclntab layouts.
+//
+// See cmd/link/internal/ld/pcln.go and the pcHeader and _func types
+// in runtime/runtime2.go and runtime/symtab.go for each release.
+//
+// To add a layout, add an entry here, teach NewFuncTab and
+// Func.inlinedCall about any new fields, and add a case to
+// TestLayouts.
+var pclnLayouts = []*pclnLayout{
+	{
+		version: "go1.2",
+		magic:   0xfffffffb,
+	},
+	{
+		version: "go1.16",
+		magic:   0xfffffffa,
+		split:   true,
+		indexes: indexes116,
+	},
+	{
+		version:  "go1.18",
+		magic:    0xfffffff0,
+		split:    true,
+		relative: true,
+		indexes:  indexes118,
+	},
+	{
+		version:        "go1.20",
+		magic:          0xfffffff1,
+		split:          true,
+		relative:       true,
+		startLine:      true,
+		inlineParentPC: true,
+		indexes:        indexes118,
+	},
+}
+
+var indexes116 = map[string]int64{
+	"_PCDATA_UnsafePoint":          0,
+	"_PCDATA_StackMapIndex":        1,
+	"_PCDATA_InlTreeIndex":         2,
+	"_FUNCDATA_ArgsPointerMaps":    0,
+	"_FUNCDATA_LocalsPointerMaps":  1,
+	"_FUNCDATA_StackObjects":       2,
+	"_FUNCDATA_InlTree":            3,
+	"_FUNCDATA_OpenCodedDeferInfo": 4,
+}
+
+var indexes118 = map[string]int64{
+	"_PCDATA_UnsafePoint":          0,
+	"_PCDATA_StackMapIndex":        1,
+	"_PCDATA_InlTreeIndex":         2,
+	"_PCDATA_ArgLiveIndex":         3,
+	"_FUNCDATA_ArgsPointerMaps":    0,
+	"_FUNCDATA_LocalsPointerMaps":  1,
+	"_FUNCDATA_StackObjects":       2,
+	"_FUNCDATA_InlTree":            3,
+	"_FUNCDATA_OpenCodedDeferInfo": 4,
+	"_FUNCDATA_ArgInfo":            5,
+	"_FUNCDATA_ArgLiveInfo":        6,
+}
+
+type symtabHdr struct {
+	Magic     uint32
+	_         uint16
+	PCQuantum uint8
+	PtrSize   uint8
+}
+
+// findLayout identifies the layout and byte order of the pclntab in
+// data from its header.
+func findLayout(data []byte) (*pclnLayout, binary.ByteOrder, symtabHdr, error) {
+	var hdr symtabHdr
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		if err := binary.Read(bytes.NewBuffer(data), order, &hdr); err != nil {
+			return nil, nil, hdr, err
+		}
+		for _, l := range pclnLayouts {
+			if hdr.Magic == l.magic {
+				return l, order, hdr, nil
+			}
+		}
+	}
+	return nil, nil, hdr, fmt.Errorf("bad magic word in header %#x", hdr.Magic)
+}
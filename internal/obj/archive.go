@@ -0,0 +1,266 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// archiveFile is a Unix ar archive, such as a Go package archive or
+// a C static library. It presents the symbols of all of its
+// recognized members as one object.
+type archiveFile struct {
+	members []archiveMember
+}
+
+type archiveMember struct {
+	name string
+	obj  Obj
+}
+
+const (
+	archiveMagic     = "!<arch>\n"
+	archiveHeaderLen = 60
+)
+
+var errNotArchive = errors.New("not an archive")
+
+// openArchive opens r as an ar archive. If r isn't an archive at
+// all, it returns errNotArchive.
+func openArchive(r io.ReaderAt) (Obj, error) {
+	var magic [len(archiveMagic)]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil || string(magic[:]) != archiveMagic {
+		return nil, errNotArchive
+	}
+
+	f := &archiveFile{}
+	var longNames []byte
+	// goErr is the error from the first Go object file member
+	// we couldn't read, if any, for reporting why an archive has
+	// no members.
+	var goErr error
+	off := int64(len(archiveMagic))
+	for {
+		var hdr [archiveHeaderLen]byte
+		if _, err := r.ReadAt(hdr[:], off); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if string(hdr[58:60]) != "`\n" {
+			return nil, fmt.Errorf("bad archive member header at %#x", off)
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(string(hdr[48:58])), 10, 64)
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("bad archive member size at %#x", off)
+		}
+		off += archiveHeaderLen
+		data := io.NewSectionReader(r, off, size)
+		// Members are padded to an even offset.
+		off += size + size&1
+
+		name := strings.TrimRight(string(hdr[0:16]), " ")
+		switch {
+		case name == "/" || name == "/SYM64/" || strings.HasPrefix(name, "__.SYMDEF"):
+			// Symbol index.
+			continue
+		case name == "//":
+			// GNU long name table. Read it through data so a
+			// bad size can't make us allocate more than the
+			// file holds.
+			var err error
+			longNames, err = io.ReadAll(data)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case strings.HasPrefix(name, "#1/"):
+			// BSD long name. The name precedes the data.
+			n, err := strconv.ParseInt(name[3:], 10, 64)
+			if err != nil || n > size {
+				return nil, fmt.Errorf("bad archive member name %q", name)
+			}
+			buf, err := io.ReadAll(io.NewSectionReader(data, 0, n))
+			if err != nil {
+				return nil, err
+			} else if int64(len(buf)) != n {
+				return nil, fmt.Errorf("bad archive member name %q", name)
+			}
+			name = string(bytes.TrimRight(buf, "\x00"))
+			data = io.NewSectionReader(data, n, size-n)
+		case strings.HasPrefix(name, "/"):
+			// GNU long name reference.
+			i, err := strconv.Atoi(name[1:])
+			if err != nil || i >= len(longNames) {
+				return nil, fmt.Errorf("bad archive member name %q", name)
+			}
+			name = string(longNames[i:])
+			if j := strings.Index(name, "/\n"); j >= 0 {
+				name = name[:j]
+			}
+		default:
+			name = strings.TrimSuffix(name, "/")
+		}
+		if name == "__.PKGDEF" {
+			// Go package export data.
+			continue
+		}
+
+		// Go object files (_go_.o) are in the Go toolchain's
+		// own format. Cgo and assembly objects are in the
+		// system object format. Skip unrecognized members.
+		var obj Obj
+		if h, ok := goObjHeader(data); ok {
+			obj, err = openGoobj(data)
+			if err != nil {
+				if goErr == nil {
+					goErr = fmt.Errorf("%s: %s: %v", name, h, err)
+				}
+				continue
+			}
+		} else if obj, err = Open(data); err != nil {
+			continue
+		}
+		f.members = append(f.members, archiveMember{name, obj})
+	}
+	if len(f.members) == 0 {
+		if goErr != nil {
+			return nil, fmt.Errorf("archive has no readable object files (%v)", goErr)
+		}
+		return nil, fmt.Errorf("archive has no recognized object files")
+	}
+	return f, nil
+}
+
+// goObjHeader reports whether r is a Go toolchain object file and,
+// if so, returns the start of its header, giving its target and Go
+// version, such as "go object linux amd64 go1.16".
+func goObjHeader(r io.ReaderAt) (string, bool) {
+	const magic = "go object "
+	var buf [128]byte
+	n, _ := r.ReadAt(buf[:], 0)
+	if !bytes.HasPrefix(buf[:n], []byte(magic)) {
+		return "", false
+	}
+	line := buf[:n]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	// The rest of the header lists build settings.
+	if f := strings.Fields(string(line)); len(f) > 5 {
+		return strings.Join(f[:5], " "), true
+	}
+	return string(line), true
+}
+
+func (f *archiveFile) Info() ObjInfo {
+	for _, m := range f.members {
+		if info := m.obj.Info(); info.Arch != nil {
+			return info
+		}
+	}
+	return ObjInfo{}
+}
+
+// Data returns data from the first member that has data at ptr. The
+// members of an archive are usually relocatable objects with
+// overlapping address ranges, so this is of limited use.
+func (f *archiveFile) Data(ptr, size uint64) ([]byte, error) {
+	for _, m := range f.members {
+		data, err := m.obj.Data(ptr, size)
+		if data != nil || err != nil {
+			return data, err
+		}
+	}
+	return nil, nil
+}
+
+// Symbols returns the symbols of all members. Each symbol's name and
+// section are prefixed with the name of its member, as in
+// "foo.o:sym". Unnamed symbols are left unnamed.
+func (f *archiveFile) Symbols() ([]Sym, error) {
+	var out []Sym
+	for i, m := range f.members {
+		syms, err := m.obj.Symbols()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", m.name, err)
+		}
+		for _, s := range syms {
+			if s.Name != "" {
+				s.Name = m.name + ":" + s.Name
+			}
+			if s.Section != "" {
+				s.Section = m.name + ":" + s.Section
+			}
+			s.member = i
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// memberSym returns the member that defines s and s as that member
+// knows it.
+func (f *archiveFile) memberSym(s Sym) (Obj, Sym, error) {
+	if s.member < 0 || s.member >= len(f.members) {
+		return nil, s, fmt.Errorf("symbol %q is not from this archive", s.Name)
+	}
+	m := f.members[s.member]
+	s.Name = strings.TrimPrefix(s.Name, m.name+":")
+	s.Section = strings.TrimPrefix(s.Section, m.name+":")
+	s.member = 0
+	return m.obj, s, nil
+}
+
+func (f *archiveFile) SymbolData(s Sym) ([]byte, error) {
+	obj, s, err := f.memberSym(s)
+	if err != nil {
+		return nil, err
+	}
+	return obj.SymbolData(s)
+}
+
+// BuildID returns ErrNoBuildID. Members of an archive may have build
+// IDs, but the archive as a whole doesn't.
+func (f *archiveFile) BuildID() (string, error) {
+	return "", ErrNoBuildID
+}
+
+// Sections returns the sections of all members. Like symbols, each
+// section's name is prefixed with the name of its member.
+func (f *archiveFile) Sections() ([]Section, error) {
+	var out []Section
+	for _, m := range f.members {
+		sects, err := m.obj.Sections()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", m.name, err)
+		}
+		for _, s := range sects {
+			s.Name = m.name + ":" + s.Name
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (f *archiveFile) Relocations(s Sym) ([]Reloc, error) {
+	obj, s, err := f.memberSym(s)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Relocations(s)
+}
+
+func (f *archiveFile) DWARF() (*dwarf.Data, error) {
+	// Each member has its own DWARF data, and there's no
+	// meaningful way to combine them.
+	return nil, fmt.Errorf("DWARF not supported for archives")
+}
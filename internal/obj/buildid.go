@@ -0,0 +1,65 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+)
+
+// ELF note types for build IDs.
+const (
+	elfNoteGNUBuildID = 3
+	elfNoteGoBuildID  = 4
+)
+
+// elfNote returns the descriptor of the note with the given name
+// and type in data, which is the contents of an ELF note section.
+func elfNote(data []byte, order binary.ByteOrder, name string, typ uint32) ([]byte, bool) {
+	align4 := func(n uint32) uint32 { return (n + 3) &^ 3 }
+	for len(data) >= 12 {
+		nameSize, descSize := order.Uint32(data), order.Uint32(data[4:])
+		ntype := order.Uint32(data[8:])
+		data = data[12:]
+		if uint64(align4(nameSize))+uint64(align4(descSize)) > uint64(len(data)) {
+			break
+		}
+		nname := string(bytes.TrimRight(data[:nameSize], "\x00"))
+		desc := data[align4(nameSize):][:descSize]
+		if nname == name && ntype == typ {
+			return desc, true
+		}
+		data = data[align4(nameSize)+align4(descSize):]
+	}
+	return nil, false
+}
+
+// goBuildIDPrefix and goBuildIDEnd bracket the Go build ID the
+// linker writes at the beginning of the text segment of binaries
+// that don't have a note section for it.
+const (
+	goBuildIDPrefix = "\xff Go build ID: \""
+	goBuildIDEnd    = "\"\n \xff"
+)
+
+// findGoBuildID returns the Go build ID in data, which should be the
+// beginning of the text segment.
+func findGoBuildID(data []byte) (string, bool) {
+	i := bytes.Index(data, []byte(goBuildIDPrefix))
+	if i < 0 {
+		return "", false
+	}
+	data = data[i:]
+	j := bytes.Index(data, []byte(goBuildIDEnd))
+	if j < 0 {
+		return "", false
+	}
+	id, err := strconv.Unquote(string(data[len(goBuildIDPrefix)-1 : j+1]))
+	if err != nil {
+		return "", false
+	}
+	return id, true
+}
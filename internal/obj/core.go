@@ -0,0 +1,213 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"fmt"
+	"io"
+)
+
+// coreFile is an ELF core dump. Its memory map comes from the
+// PT_LOAD segments of the core. Cores don't have symbols of their
+// own, so symbols come from the executable that produced the core,
+// if one is provided.
+type coreFile struct {
+	elf *elf.File
+
+	// exe is the executable that produced this core, or nil.
+	exe Obj
+	// bias is the difference between addresses in the core and
+	// addresses in exe. It's non-zero for position-independent
+	// executables.
+	bias uint64
+}
+
+// OpenCore opens r as an ELF core dump of exe. Symbols and memory
+// that's missing from the core, such as read-only text mappings,
+// come from exe. exe may be nil.
+func OpenCore(r io.ReaderAt, exe Obj) (Obj, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	if f.Type != elf.ET_CORE {
+		return nil, fmt.Errorf("not an ELF core file")
+	}
+	cf := &coreFile{elf: f, exe: exe}
+	if exe != nil {
+		if cf.bias, err = cf.findBias(); err != nil {
+			return nil, err
+		}
+	}
+	return cf, nil
+}
+
+// findBias returns the load bias of f.exe. It tries placing the
+// executable's text at each executable segment of the core and
+// picks the placement where the most text symbols match the memory
+// in the core. This only works if the core includes text mappings
+// (see coredump_filter in core(5)). Otherwise, it assumes the
+// executable isn't relocated.
+func (f *coreFile) findBias() (uint64, error) {
+	syms, err := f.exe.Symbols()
+	if err != nil {
+		return 0, err
+	}
+	var text []Sym
+	minText := ^uint64(0)
+	for _, s := range syms {
+		if s.Kind == SymText && s.HasAddr && s.Size != 0 {
+			text = append(text, s)
+			if s.Value < minText {
+				minText = s.Value
+			}
+		}
+	}
+	if len(text) == 0 {
+		return 0, nil
+	}
+	// Checking every symbol would be slow for large binaries.
+	const maxCheck = 64
+	if len(text) > maxCheck {
+		step := len(text) / maxCheck
+		for i := range text[:maxCheck] {
+			text[i] = text[i*step]
+		}
+		text = text[:maxCheck]
+	}
+
+	candidates := []uint64{0}
+	for _, p := range f.elf.Progs {
+		if p.Type == elf.PT_LOAD && p.Flags&elf.PF_X != 0 && p.Filesz != 0 {
+			candidates = append(candidates, p.Vaddr-(minText&^0xfff))
+		}
+	}
+	var best uint64
+	bestScore := 0
+	for _, bias := range candidates {
+		score := 0
+		for _, s := range text {
+			want, err := f.exe.SymbolData(s)
+			if err != nil {
+				return 0, err
+			}
+			got, err := f.coreData(s.Value+bias, s.Size)
+			if err != nil {
+				return 0, err
+			}
+			if len(got) != 0 && bytes.HasPrefix(want, got) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = bias, score
+		}
+	}
+	return best, nil
+}
+
+func (f *coreFile) Info() ObjInfo {
+	return ObjInfo{
+		elfArch(f.elf),
+	}
+}
+
+// coreData returns the data at ptr from the core itself. If ptr is
+// in a segment that's in memory but not included in the core, it
+// returns nil.
+func (f *coreFile) coreData(ptr, size uint64) ([]byte, error) {
+	for _, p := range f.elf.Progs {
+		if p.Type != elf.PT_LOAD || ptr < p.Vaddr || ptr >= p.Vaddr+p.Filesz {
+			continue
+		}
+		// Found it. Limit size.
+		if end := p.Vaddr + p.Filesz; ptr+size > end {
+			size = end - ptr
+		}
+		out := make([]byte, size)
+		_, err := p.ReadAt(out, int64(ptr-p.Vaddr))
+		return out, err
+	}
+	return nil, nil
+}
+
+func (f *coreFile) Data(ptr, size uint64) ([]byte, error) {
+	data, err := f.coreData(ptr, size)
+	if data != nil || err != nil || f.exe == nil {
+		return data, err
+	}
+	// The core doesn't include this memory, usually because
+	// it's a read-only mapping of the executable.
+	return f.exe.Data(ptr-f.bias, size)
+}
+
+func (f *coreFile) Symbols() ([]Sym, error) {
+	if f.exe == nil {
+		return nil, nil
+	}
+	syms, err := f.exe.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	for i := range syms {
+		if syms[i].HasAddr {
+			syms[i].Value += f.bias
+		}
+	}
+	return syms, nil
+}
+
+func (f *coreFile) SymbolData(s Sym) ([]byte, error) {
+	data, err := f.coreData(s.Value, s.Size)
+	if err != nil || uint64(len(data)) == s.Size || f.exe == nil {
+		return data, err
+	}
+	if s.HasAddr {
+		s.Value -= f.bias
+	}
+	return f.exe.SymbolData(s)
+}
+
+// BuildID returns the build ID of the executable.
+func (f *coreFile) BuildID() (string, error) {
+	if f.exe == nil {
+		return "", ErrNoBuildID
+	}
+	return f.exe.BuildID()
+}
+
+// Sections returns the sections of the executable, adjusted for the
+// load bias.
+func (f *coreFile) Sections() ([]Section, error) {
+	if f.exe == nil {
+		return nil, nil
+	}
+	sects, err := f.exe.Sections()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sects {
+		if sects[i].Flags&SectionAlloc != 0 {
+			sects[i].Addr += f.bias
+		}
+	}
+	return sects, nil
+}
+
+// DWARF returns the DWARF data of the executable. Addresses in it
+// are not adjusted for the load bias.
+func (f *coreFile) DWARF() (*dwarf.Data, error) {
+	if f.exe == nil {
+		return nil, fmt.Errorf("core file has no DWARF data; provide the executable")
+	}
+	return f.exe.DWARF()
+}
+
+func (f *coreFile) Relocations(s Sym) ([]Reloc, error) {
+	return nil, ErrNotSupported
+}
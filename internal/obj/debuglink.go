@@ -0,0 +1,90 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// debugLinkDir is the global directory searched for separate debug
+// files.
+const debugLinkDir = "/usr/lib/debug"
+
+// LoadDebugLink finds the separate debug file of o, which was opened
+// from path, and uses it for symbols and DWARF data that o lacks.
+// Stripped binaries often name their debug file in a .gnu_debuglink
+// section. If debug is non-empty, it is the path of the debug file
+// and LoadDebugLink doesn't check its CRC. Otherwise, LoadDebugLink
+// looks for the linked file next to path, in a .debug subdirectory
+// of path's directory, and under /usr/lib/debug.
+//
+// If o has no debug link and debug is empty, or o isn't an ELF file,
+// LoadDebugLink does nothing.
+func LoadDebugLink(o Obj, path, debug string) error {
+	f, ok := o.(*elfFile)
+	if !ok {
+		return nil
+	}
+
+	if debug != "" {
+		df, err := elf.Open(debug)
+		if err != nil {
+			return err
+		}
+		f.debug = df
+		return nil
+	}
+
+	sect := f.elf.Section(".gnu_debuglink")
+	if sect == nil {
+		return nil
+	}
+	data, err := sect.Data()
+	if err != nil {
+		return err
+	}
+	// The section contains a NUL-terminated file name, padding
+	// to a 4 byte boundary, and a CRC32 of the debug file.
+	i := bytes.IndexByte(data, 0)
+	if i < 0 || len(data) < (i+4)&^3+4 {
+		return fmt.Errorf("malformed .gnu_debuglink section")
+	}
+	name := string(data[:i])
+	crc := f.elf.ByteOrder.Uint32(data[(i+4)&^3:])
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	for _, cand := range []string{
+		filepath.Join(dir, name),
+		filepath.Join(dir, ".debug", name),
+		filepath.Join(debugLinkDir, dir, name),
+	} {
+		// Don't link a binary to itself.
+		if cand == filepath.Join(dir, filepath.Base(path)) {
+			continue
+		}
+		buf, err := ioutil.ReadFile(cand)
+		if err != nil {
+			continue
+		}
+		if crc32.ChecksumIEEE(buf) != crc {
+			return fmt.Errorf("debug file %s has wrong CRC", cand)
+		}
+		df, err := elf.NewFile(bytes.NewReader(buf))
+		if err != nil {
+			return fmt.Errorf("%s: %v", cand, err)
+		}
+		f.debug = df
+		return nil
+	}
+	return fmt.Errorf("debug file %s not found", name)
+}
@@ -0,0 +1,355 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GNU extension attributes used by split DWARF before DWARF 5.
+const (
+	attrGNUDwoName  dwarf.Attr = 0x2130
+	attrGNUDwoID    dwarf.Attr = 0x2131
+	attrGNUAddrBase dwarf.Attr = 0x2133
+)
+
+// A dwoSkeleton is a skeleton unit that refers to a split unit.
+type dwoSkeleton struct {
+	dwoName, compDir string
+	// id is the DWO ID that links the skeleton to its split
+	// unit, or 0 if unknown.
+	id uint64
+	// addr is the skeleton's contribution to .debug_addr, which
+	// split units use to encode addresses.
+	addr []byte
+}
+
+// SplitDWARF returns the DWARF data split out of o into separate
+// files by -gsplit-dwarf. path is the path of o. If dwp is
+// non-empty, it is the path of a DWARF package (.dwp) file that
+// holds all of the split units. Otherwise, SplitDWARF uses
+// path+".dwp" if it exists, or else the .dwo file named by each
+// skeleton unit, searched for in the unit's compilation directory
+// and next to path.
+//
+// debug/dwarf can't combine split units with their skeletons, so the
+// result has a separate *dwarf.Data for each .dwo file or for each
+// unit in a .dwp file.
+//
+// If o has no skeleton units, SplitDWARF returns nil, nil.
+func SplitDWARF(o Obj, path, dwp string) ([]*dwarf.Data, error) {
+	f, ok := o.(*elfFile)
+	if !ok {
+		// Only ELF toolchains split DWARF this way.
+		return nil, nil
+	}
+	skels, err := f.dwoSkeletons()
+	if err != nil || len(skels) == 0 {
+		return nil, err
+	}
+
+	if dwp == "" {
+		if _, err := os.Stat(path + ".dwp"); err == nil {
+			dwp = path + ".dwp"
+		}
+	}
+	if dwp != "" {
+		return openDWP(dwp, skels)
+	}
+
+	var out []*dwarf.Data
+	for _, skel := range skels {
+		var cands []string
+		if filepath.IsAbs(skel.dwoName) {
+			cands = append(cands, skel.dwoName)
+		} else if skel.compDir != "" {
+			cands = append(cands, filepath.Join(skel.compDir, skel.dwoName))
+		}
+		cands = append(cands,
+			filepath.Join(filepath.Dir(path), skel.dwoName),
+			filepath.Join(filepath.Dir(path), filepath.Base(skel.dwoName)))
+		var dwoPath string
+		for _, cand := range cands {
+			if _, err := os.Stat(cand); err == nil {
+				dwoPath = cand
+				break
+			}
+		}
+		if dwoPath == "" {
+			return nil, fmt.Errorf("split DWARF file %s not found", skel.dwoName)
+		}
+		d, err := openDWO(dwoPath, skel.addr)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// dwoSkeletons returns the skeleton units in f's DWARF data.
+func (f *elfFile) dwoSkeletons() ([]dwoSkeleton, error) {
+	d, err := f.DWARF()
+	if err != nil {
+		return nil, err
+	}
+	var addr []byte
+	if sect := f.elf.Section(".debug_addr"); sect != nil {
+		if addr, err = sect.Data(); err != nil {
+			return nil, err
+		}
+	}
+	var ids map[dwarf.Offset]uint64
+
+	var skels []dwoSkeleton
+	r := d.Reader()
+	for {
+		ent, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if ent == nil {
+			break
+		}
+		r.SkipChildren()
+		name, _ := ent.Val(dwarf.AttrDwoName).(string)
+		if name == "" {
+			name, _ = ent.Val(attrGNUDwoName).(string)
+		}
+		if name == "" {
+			continue
+		}
+		skel := dwoSkeleton{dwoName: name}
+		skel.compDir, _ = ent.Val(dwarf.AttrCompDir).(string)
+		if id, ok := ent.Val(attrGNUDwoID).(int64); ok {
+			skel.id = uint64(id)
+		} else {
+			// In DWARF 5, the DWO ID is in the unit
+			// header, which debug/dwarf doesn't expose.
+			if ids == nil {
+				if ids, err = f.dwoIDs(); err != nil {
+					return nil, err
+				}
+			}
+			skel.id = ids[ent.Offset]
+		}
+		base, ok := ent.Val(dwarf.AttrAddrBase).(int64)
+		if !ok {
+			base, ok = ent.Val(attrGNUAddrBase).(int64)
+		}
+		if ok && base >= 0 && base <= int64(len(addr)) {
+			skel.addr = addr[base:]
+		}
+		skels = append(skels, skel)
+	}
+	return skels, nil
+}
+
+// dwoIDs returns the DWO IDs of the DWARF 5 skeleton units in f,
+// indexed by the offset of each unit's top-level entry.
+func (f *elfFile) dwoIDs() (map[dwarf.Offset]uint64, error) {
+	const utSkeleton = 4
+	ids := make(map[dwarf.Offset]uint64)
+	sect := f.elf.Section(".debug_info")
+	if sect == nil {
+		return ids, nil
+	}
+	info, err := sect.Data()
+	if err != nil {
+		return nil, err
+	}
+	order := f.elf.ByteOrder
+	for off := uint64(0); off+4 <= uint64(len(info)); {
+		b := info[off:]
+		unitLen, hdr, offSize := uint64(order.Uint32(b)), uint64(4), uint64(4)
+		if unitLen == 0xffffffff {
+			if len(b) < 12 {
+				break
+			}
+			unitLen, hdr, offSize = order.Uint64(b[4:]), 12, 8
+		}
+		next := off + hdr + unitLen
+		// Version, unit type, address size, and abbrev offset
+		// precede the DWO ID.
+		if idOff := hdr + 4 + offSize; idOff+8 <= uint64(len(b)) && order.Uint16(b[hdr:]) >= 5 && b[hdr+2] == utSkeleton {
+			ids[dwarf.Offset(off+idOff+8)] = order.Uint64(b[idOff:])
+		}
+		if next <= off {
+			break
+		}
+		off = next
+	}
+	return ids, nil
+}
+
+// dwoSections reads the ".dwo" sections of f, indexed by their DWARF
+// section name, such as ".debug_info", as well as the index sections
+// of a DWARF package.
+func dwoSections(f *elf.File) (map[string][]byte, error) {
+	sects := make(map[string][]byte)
+	for _, sect := range f.Sections {
+		if !strings.HasSuffix(sect.Name, ".dwo") && sect.Name != ".debug_cu_index" {
+			continue
+		}
+		data, err := sect.Data()
+		if err != nil {
+			return nil, err
+		}
+		sects[strings.TrimSuffix(sect.Name, ".dwo")] = data
+	}
+	return sects, nil
+}
+
+// newDWO creates a dwarf.Data from the sections of a split unit.
+// addr is the .debug_addr contribution of the unit's skeleton, or
+// nil if unknown. order is the byte order of the sections.
+func newDWO(sects map[string][]byte, addr []byte, order binary.ByteOrder) (*dwarf.Data, error) {
+	// Likewise, split units don't have a string offsets base,
+	// so skip the DWARF 5 .debug_str_offsets header.
+	if info, so := sects[".debug_info"], sects[".debug_str_offsets"]; len(info) >= 6 && len(so) >= 8 {
+		// Split DWARF only supports the 32-bit format.
+		if order.Uint16(info[4:]) >= 5 {
+			sects[".debug_str_offsets"] = so[8:]
+		}
+	}
+	d, err := dwarf.New(sects[".debug_abbrev"], nil, nil, sects[".debug_info"], sects[".debug_line"], nil, nil, sects[".debug_str"])
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{".debug_str_offsets", ".debug_rnglists", ".debug_loclists", ".debug_line_str"} {
+		if data, ok := sects[name]; ok {
+			if err := d.AddSection(name, data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Split units don't have an address base of their own, so
+	// debug/dwarf uses 0. Hence, .debug_addr must start at the
+	// skeleton's contribution.
+	if addr != nil {
+		if err := d.AddSection(".debug_addr", addr); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+func openDWO(path string, addr []byte) (*dwarf.Data, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sects, err := dwoSections(f)
+	if err != nil {
+		return nil, err
+	}
+	d, err := newDWO(sects, addr, f.ByteOrder)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return d, nil
+}
+
+// dwpSectionNames maps DWARF package index section IDs to section
+// names. Version 2 is the GNU extension; version 5 is standard.
+var dwpSectionNames = map[uint32]map[uint32]string{
+	2: {1: ".debug_info", 3: ".debug_abbrev", 4: ".debug_line", 5: ".debug_loc", 6: ".debug_str_offsets"},
+	5: {1: ".debug_info", 3: ".debug_abbrev", 4: ".debug_line", 5: ".debug_loclists", 6: ".debug_str_offsets", 8: ".debug_rnglists"},
+}
+
+// openDWP opens a DWARF package file. The sections of a package
+// concatenate the contributions of each unit, which debug/dwarf
+// doesn't understand, so this uses the package's CU index to
+// create a separate dwarf.Data for each unit.
+func openDWP(path string, skels []dwoSkeleton) ([]*dwarf.Data, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sects, err := dwoSections(f)
+	if err != nil {
+		return nil, err
+	}
+	index, ok := sects[".debug_cu_index"]
+	if !ok {
+		return nil, fmt.Errorf("%s: no .debug_cu_index section", path)
+	}
+	bad := fmt.Errorf("%s: malformed .debug_cu_index", path)
+
+	order := f.ByteOrder
+	if len(index) < 16 {
+		return nil, bad
+	}
+	version := order.Uint32(index)
+	if version != 2 {
+		// Version 5 uses a 2 byte version and 2 bytes of
+		// padding.
+		version = uint32(order.Uint16(index))
+	}
+	names, ok := dwpSectionNames[version]
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported DWARF package version %d", path, version)
+	}
+	nCols := uint64(order.Uint32(index[4:]))
+	nUnits := uint64(order.Uint32(index[8:]))
+	nSlots := uint64(order.Uint32(index[12:]))
+	data := index[16:]
+	if uint64(len(data)) < nSlots*12+nCols*4+2*nUnits*nCols*4 {
+		return nil, bad
+	}
+	rows := data[nSlots*8 : nSlots*12]
+	cols := data[nSlots*12:]
+	offsets := cols[nCols*4:]
+	sizes := offsets[nUnits*nCols*4:]
+	u32 := func(b []byte, i uint64) uint64 {
+		return uint64(order.Uint32(b[i*4:]))
+	}
+	addrs := make(map[uint64][]byte)
+	for _, skel := range skels {
+		if skel.id != 0 {
+			addrs[skel.id] = skel.addr
+		}
+	}
+
+	var out []*dwarf.Data
+	for slot := uint64(0); slot < nSlots; slot++ {
+		row := u32(rows, slot)
+		if row == 0 {
+			continue
+		}
+		row--
+		if row >= nUnits {
+			return nil, bad
+		}
+		unit := map[string][]byte{".debug_str": sects[".debug_str"]}
+		for col := uint64(0); col < nCols; col++ {
+			name, ok := names[uint32(u32(cols, col))]
+			if !ok {
+				continue
+			}
+			off, size := u32(offsets, row*nCols+col), u32(sizes, row*nCols+col)
+			sect := sects[name]
+			if off+size > uint64(len(sect)) {
+				return nil, bad
+			}
+			unit[name] = sect[off : off+size]
+		}
+		id := order.Uint64(data[slot*8:])
+		d, err := newDWO(unit, addrs[id], order)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
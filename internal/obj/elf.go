@@ -7,14 +7,47 @@ package obj
 import (
 	"debug/dwarf"
 	"debug/elf"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/aclements/objbrowse/internal/arch"
 )
 
 type elfFile struct {
 	elf *elf.File
+
+	// debug is the separate debug info file for this object, or
+	// nil. See LoadDebugLink.
+	debug *elf.File
+
+	relocsOnce sync.Once
+	relocs     map[int][]elfReloc
+	relocsErr  error
+
+	// inflated caches the decompressed data of compressed
+	// sections.
+	inflatedMu sync.Mutex
+	inflated   map[*elf.Section][]byte
+}
+
+// An elfReloc is a relocation in a relocatable ELF object.
+type elfReloc struct {
+	// off is the offset of the relocation in its section.
+	off uint64
+	// typ is the machine-specific relocation type, such as
+	// elf.R_X86_64_PLT32.
+	typ uint32
+	// sym is the name of the target symbol. For relocations
+	// against a section symbol, this is the section name.
+	sym string
+	// addend is the explicit addend of a RELA relocation. For
+	// REL relocations, the addend is stored in the section data
+	// and this is 0.
+	addend int64
 }
 
 func openElf(r io.ReaderAt) (Obj, error) {
@@ -22,17 +55,42 @@ func openElf(r io.ReaderAt) (Obj, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &elfFile{f}, nil
+	if f.Type == elf.ET_CORE {
+		return &coreFile{elf: f}, nil
+	}
+	return &elfFile{elf: f}, nil
 }
 
 var elfToArch = map[elf.Machine]*arch.Arch{
-	elf.EM_X86_64: arch.AMD64,
-	elf.EM_386:    arch.I386,
+	elf.EM_X86_64:  arch.AMD64,
+	elf.EM_386:     arch.I386,
+	elf.EM_AARCH64: arch.ARM64,
+	elf.EM_RISCV:   arch.RISCV64,
+	elf.EM_PPC64:   arch.PPC64,
+	elf.EM_S390:    arch.S390X,
+}
+
+// elfArch returns the architecture of f, or nil if it's unknown.
+func elfArch(f *elf.File) *arch.Arch {
+	a := elfToArch[f.Machine]
+	switch f.Machine {
+	case elf.EM_PPC64:
+		// The machine is the same for both byte orders.
+		if f.Data == elf.ELFDATA2LSB {
+			a = arch.PPC64LE
+		}
+	case elf.EM_RISCV, elf.EM_S390:
+		// So is the pointer size.
+		if f.Class != elf.ELFCLASS64 {
+			a = nil
+		}
+	}
+	return a
 }
 
 func (f *elfFile) Info() ObjInfo {
 	return ObjInfo{
-		elfToArch[f.elf.Machine],
+		elfArch(f.elf),
 	}
 }
 
@@ -58,7 +116,15 @@ func (f *elfFile) Data(ptr, size uint64) ([]byte, error) {
 
 func (f *elfFile) Symbols() ([]Sym, error) {
 	syms, err := f.elf.Symbols()
-	if err != nil {
+	if err == elf.ErrNoSymbols && f.debug != nil {
+		// The debug file has the same section layout, so
+		// its symbols apply to this file.
+		syms, err = f.debug.Symbols()
+	}
+	if err == elf.ErrNoSymbols {
+		// Stripped binary.
+		return nil, nil
+	} else if err != nil {
 		return nil, err
 	}
 
@@ -88,9 +154,14 @@ func (f *elfFile) Symbols() ([]Sym, error) {
 				kind = SymROData
 			case elf.SHF_ALLOC | elf.SHF_WRITE:
 				kind = SymData
+				if sect.Type == elf.SHT_NOBITS {
+					kind = SymBSS
+				}
 			}
 		}
 		local := elf.ST_BIND(s.Info) == elf.STB_LOCAL
+		weak := elf.ST_BIND(s.Info) == elf.STB_WEAK
+		debug := elf.ST_TYPE(s.Info) == elf.STT_FILE
 		switch elf.ST_TYPE(s.Info) {
 		case elf.STT_FILE, elf.STT_TLS:
 			// STT_FILE symbols should also be absolute,
@@ -99,24 +170,164 @@ func (f *elfFile) Symbols() ([]Sym, error) {
 			// addresses.
 			hasAddr = false
 		}
+		if elf.ST_TYPE(s.Info) == elf.STT_TLS {
+			kind = SymTLS
+		}
 
-		sym := Sym{s.Name, s.Value, s.Size, kind, local, hasAddr, int(s.Section)}
+		sym := Sym{Name: s.Name, Value: s.Value, Size: s.Size, Kind: kind, Local: local, Weak: weak, Debug: debug, HasAddr: hasAddr, section: int(s.Section)}
 		out = append(out, sym)
 	}
-	synthesizeSizes(out)
+	plt, err := f.pltSyms()
+	if err != nil {
+		return nil, err
+	}
+	out = append(out, plt...)
+	sects, err := f.Sections()
+	if err != nil {
+		return nil, err
+	}
+	synthesizeSizes(out, sects)
+	nameSections(out, sects)
 	return out, nil
 }
 
 func (f *elfFile) SymbolData(s Sym) ([]byte, error) {
+	if s.Kind == SymBSS {
+		// BSS has no file data.
+		return make([]byte, s.Size), nil
+	}
+	if s.section <= 0 || s.section >= len(f.elf.Sections) {
+		return nil, nil
+	}
 	sect := f.elf.Sections[s.section]
+	if s.Kind == SymTLS {
+		return f.tlsData(sect, s)
+	}
 	if s.Value < sect.Addr {
 		return nil, fmt.Errorf("symbol %q starts before section %q", s.Name, sect.Name)
 	}
 	return f.sectData(sect, s.Value, s.Size)
 }
 
+// tlsData returns the initial data of TLS symbol s in section sect.
+func (f *elfFile) tlsData(sect *elf.Section, s Sym) ([]byte, error) {
+	if f.elf.Type == elf.ET_REL {
+		// In relocatable objects, TLS symbol values are
+		// offsets in their section.
+		return f.sectData(sect, sect.Addr+s.Value, s.Size)
+	}
+	// Otherwise, they're offsets in the TLS template, which is
+	// the PT_TLS segment. Past the file data, the template is
+	// zero-filled.
+	out := make([]byte, s.Size)
+	for _, p := range f.elf.Progs {
+		if p.Type != elf.PT_TLS {
+			continue
+		}
+		if s.Value >= p.Filesz {
+			return out, nil
+		}
+		flen := s.Size
+		if flen > p.Filesz-s.Value {
+			flen = p.Filesz - s.Value
+		}
+		_, err := p.ReadAt(out[:flen], int64(s.Value))
+		return out, err
+	}
+	return nil, fmt.Errorf("TLS symbol %q but no PT_TLS segment", s.Name)
+}
+
+func (f *elfFile) Relocations(s Sym) ([]Reloc, error) {
+	if !s.HasAddr || s.section <= 0 || s.section >= len(f.elf.Sections) {
+		return nil, nil
+	}
+	rs, err := f.sectRelocs(s.section)
+	if err != nil {
+		return nil, err
+	}
+	// In relocatable objects, relocation offsets are relative to
+	// the section. Otherwise, they're addresses.
+	var base uint64
+	if f.elf.Type == elf.ET_REL {
+		base = f.elf.Sections[s.section].Addr
+	}
+	i := sort.Search(len(rs), func(i int) bool {
+		return base+rs[i].off >= s.Value
+	})
+	var out []Reloc
+	for ; i < len(rs) && base+rs[i].off < s.Value+s.Size; i++ {
+		r := &rs[i]
+		out = append(out, Reloc{Offset: base + r.off - s.Value, Type: RelocType(r.typ), TypeName: elfRelocTypeName(f.elf.Machine, r.typ), Sym: r.sym, Addend: r.addend})
+	}
+	return out, nil
+}
+
+func (f *elfFile) BuildID() (string, error) {
+	if sect := f.elf.Section(".note.go.buildid"); sect != nil {
+		data, err := sect.Data()
+		if err != nil {
+			return "", err
+		}
+		if id, ok := elfNote(data, f.elf.ByteOrder, "Go", elfNoteGoBuildID); ok {
+			return string(id), nil
+		}
+	}
+	if sect := f.elf.Section(".note.gnu.build-id"); sect != nil {
+		data, err := sect.Data()
+		if err != nil {
+			return "", err
+		}
+		if id, ok := elfNote(data, f.elf.ByteOrder, "GNU", elfNoteGNUBuildID); ok {
+			return hex.EncodeToString(id), nil
+		}
+	}
+	return "", ErrNoBuildID
+}
+
+// Sections returns the ELF sections, omitting the null section at
+// index 0.
+func (f *elfFile) Sections() ([]Section, error) {
+	var out []Section
+	for _, sect := range f.elf.Sections[1:] {
+		out = append(out, elfSection(sect))
+	}
+	return out, nil
+}
+
+func elfSection(sect *elf.Section) Section {
+	var flags SectionFlags
+	if sect.Flags&elf.SHF_ALLOC != 0 {
+		flags |= SectionAlloc
+	}
+	if sect.Flags&elf.SHF_WRITE != 0 {
+		flags |= SectionWrite
+	}
+	if sect.Flags&elf.SHF_EXECINSTR != 0 {
+		flags |= SectionExec
+	}
+	offset := sect.Offset
+	if sect.Type == elf.SHT_NOBITS {
+		flags |= SectionNoBits
+		offset = 0
+	}
+	return Section{sect.Name, sect.Addr, sect.Size, offset, flags}
+}
+
 func (f *elfFile) sectData(sect *elf.Section, ptr, size uint64) ([]byte, error) {
 	out := make([]byte, size)
+	if sect.Type == elf.SHT_NOBITS {
+		return out, nil
+	}
+	if elfIsCompressed(sect) {
+		data, err := f.inflate(sect)
+		if err != nil {
+			return nil, err
+		}
+		if pos := ptr - sect.Addr; pos < uint64(len(data)) {
+			copy(out, data[pos:])
+		}
+		return out, nil
+	}
 	pos := ptr - sect.Addr
 	if pos >= sect.Size {
 		return out, nil
@@ -129,6 +340,171 @@ func (f *elfFile) sectData(sect *elf.Section, ptr, size uint64) ([]byte, error)
 	return out, err
 }
 
+// elfIsCompressed returns whether sect's file data is compressed,
+// either using SHF_COMPRESSED or, for older toolchains, as a
+// ".zdebug_" section.
+func elfIsCompressed(sect *elf.Section) bool {
+	return sect.Flags&elf.SHF_COMPRESSED != 0 || strings.HasPrefix(sect.Name, ".zdebug_")
+}
+
+// inflate returns the decompressed contents of sect.
+func (f *elfFile) inflate(sect *elf.Section) ([]byte, error) {
+	f.inflatedMu.Lock()
+	defer f.inflatedMu.Unlock()
+	if data, ok := f.inflated[sect]; ok {
+		return data, nil
+	}
+	// Section.Data understands both zlib and zstd compression
+	// headers, as well as the "ZLIB" header of .zdebug_
+	// sections.
+	data, err := sect.Data()
+	if err != nil {
+		return nil, fmt.Errorf("decompressing section %s: %v", sect.Name, err)
+	}
+	if f.inflated == nil {
+		f.inflated = make(map[*elf.Section][]byte)
+	}
+	f.inflated[sect] = data
+	return data, nil
+}
+
+// DWARF returns the DWARF data of f, or of its separate debug file
+// if f has no DWARF of its own. debug/elf decompresses compressed
+// debug sections, including .zdebug_ sections.
 func (f *elfFile) DWARF() (*dwarf.Data, error) {
+	if f.debug != nil && f.elf.Section(".debug_info") == nil && f.elf.Section(".zdebug_info") == nil {
+		return f.debug.DWARF()
+	}
 	return f.elf.DWARF()
 }
+
+// sectRelocs returns the relocations that apply to section i, sorted
+// by offset.
+func (f *elfFile) sectRelocs(i int) ([]elfReloc, error) {
+	f.relocsOnce.Do(func() {
+		f.relocs, f.relocsErr = f.readRelocs()
+	})
+	return f.relocs[i], f.relocsErr
+}
+
+// readRelocs reads all SHT_REL and SHT_RELA sections, such as
+// .rela.text, indexed by the section they apply to.
+func (f *elfFile) readRelocs() (map[int][]elfReloc, error) {
+	var syms []elf.Symbol
+	haveSyms := false
+	out := make(map[int][]elfReloc)
+	for _, rsect := range f.elf.Sections {
+		if rsect.Type != elf.SHT_REL && rsect.Type != elf.SHT_RELA {
+			continue
+		}
+		target := int(rsect.Info)
+		if target == 0 || target >= len(f.elf.Sections) {
+			// Dynamic relocations don't apply to a
+			// particular section.
+			continue
+		}
+		if !haveSyms {
+			var err error
+			syms, err = f.elf.Symbols()
+			if err != nil && err != elf.ErrNoSymbols {
+				return nil, err
+			}
+			haveSyms = true
+		}
+		relocs, err := f.decodeRelocs(rsect, syms)
+		if err != nil {
+			return nil, err
+		}
+		out[target] = append(out[target], relocs...)
+	}
+	for _, relocs := range out {
+		sort.Slice(relocs, func(i, j int) bool {
+			return relocs[i].off < relocs[j].off
+		})
+	}
+	return out, nil
+}
+
+// decodeRelocs decodes the relocations in rsect, which must be an
+// SHT_REL or SHT_RELA section. syms are the symbols the relocations
+// refer to.
+func (f *elfFile) decodeRelocs(rsect *elf.Section, syms []elf.Symbol) ([]elfReloc, error) {
+	data, err := rsect.Data()
+	if err != nil {
+		return nil, err
+	}
+
+	rela := rsect.Type == elf.SHT_RELA
+	var entSize int
+	switch {
+	case f.elf.Class == elf.ELFCLASS64 && rela:
+		entSize = 24
+	case f.elf.Class == elf.ELFCLASS64:
+		entSize = 16
+	case rela:
+		entSize = 12
+	default:
+		entSize = 8
+	}
+	order := f.elf.ByteOrder
+	relocs := make([]elfReloc, 0, len(data)/entSize)
+	for ; len(data) >= entSize; data = data[entSize:] {
+		var r elfReloc
+		var symIdx uint32
+		if f.elf.Class == elf.ELFCLASS64 {
+			r.off = order.Uint64(data)
+			info := order.Uint64(data[8:])
+			symIdx, r.typ = elf.R_SYM64(info), elf.R_TYPE64(info)
+			if rela {
+				r.addend = int64(order.Uint64(data[16:]))
+			}
+		} else {
+			r.off = uint64(order.Uint32(data))
+			info := order.Uint32(data[4:])
+			symIdx, r.typ = elf.R_SYM32(info), elf.R_TYPE32(info)
+			if rela {
+				r.addend = int64(int32(order.Uint32(data[8:])))
+			}
+		}
+		r.sym = f.relocSymName(syms, symIdx)
+		relocs = append(relocs, r)
+	}
+	return relocs, nil
+}
+
+// elfRelocTypeName returns the name of relocation type typ for
+// machine m, or "" if m isn't known.
+func elfRelocTypeName(m elf.Machine, typ uint32) string {
+	switch m {
+	case elf.EM_X86_64:
+		return elf.R_X86_64(typ).String()
+	case elf.EM_386:
+		return elf.R_386(typ).String()
+	case elf.EM_AARCH64:
+		return elf.R_AARCH64(typ).String()
+	case elf.EM_ARM:
+		return elf.R_ARM(typ).String()
+	case elf.EM_PPC64:
+		return elf.R_PPC64(typ).String()
+	case elf.EM_RISCV:
+		return elf.R_RISCV(typ).String()
+	case elf.EM_S390:
+		return elf.R_390(typ).String()
+	case elf.EM_MIPS:
+		return elf.R_MIPS(typ).String()
+	}
+	return ""
+}
+
+// relocSymName returns the name of symbol index i for a relocation.
+func (f *elfFile) relocSymName(syms []elf.Symbol, i uint32) string {
+	// Symbol 0 is the null symbol, which elf.File.Symbols omits.
+	if i == 0 || int(i) > len(syms) {
+		return ""
+	}
+	s := &syms[i-1]
+	if elf.ST_TYPE(s.Info) == elf.STT_SECTION && int(s.Section) < len(f.elf.Sections) {
+		return f.elf.Sections[s.Section].Name
+	}
+	return s.Name
+}
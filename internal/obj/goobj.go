@@ -0,0 +1,366 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aclements/objbrowse/internal/arch"
+)
+
+// goobjFile is a Go toolchain object file, such as the _go_.o member
+// of a Go package archive. This is the format defined by
+// cmd/internal/goobj. Go objects have no sections or load addresses,
+// so, like "go tool nm", we use the offset of each symbol's data in
+// the object as its address.
+type goobjFile struct {
+	data   []byte
+	arch   *arch.Arch
+	syms   []Sym
+	relocs map[uint64][]Reloc // By symbol address
+}
+
+// goobjMagic starts the binary part of a Go object file. This
+// identifies the format used since Go 1.20.
+const goobjMagic = "\x00go120ld"
+
+// Go object file blocks. Each block's offset is in the header.
+const (
+	goobjBlkAutolib = iota
+	goobjBlkPkgIdx
+	goobjBlkFile
+	goobjBlkSymdef
+	goobjBlkHashed64def
+	goobjBlkHasheddef
+	goobjBlkNonpkgdef
+	goobjBlkNonpkgref
+	goobjBlkRefFlags
+	goobjBlkHash64
+	goobjBlkHash
+	goobjBlkRelocIdx
+	goobjBlkAuxIdx
+	goobjBlkDataIdx
+	goobjBlkReloc
+	goobjBlkAux
+	goobjBlkData
+	goobjBlkRefName
+	goobjBlkEnd
+	goobjNBlk
+)
+
+const (
+	goobjSymSize     = 8 + 2 + 1 + 1 + 1 + 4 + 4
+	goobjRelocSize   = 4 + 1 + 2 + 8 + 8
+	goobjRefNameSize = 8 + 8
+	goobjABIStatic   = 0xffff
+)
+
+// Go package indexes with special meanings in symbol references.
+const (
+	goobjPkgIdxNone     = (1<<31 - 1) - iota // Non-package symbols
+	goobjPkgIdxHashed64                      // Short hashed symbols
+	goobjPkgIdxHashed                        // Hashed symbols
+	goobjPkgIdxBuiltin                       // Predefined runtime symbols
+	goobjPkgIdxSelf                          // Symbols defined in this package
+)
+
+var goobjToArch = map[string]*arch.Arch{
+	"amd64":   arch.AMD64,
+	"386":     arch.I386,
+	"arm64":   arch.ARM64,
+	"riscv64": arch.RISCV64,
+	"ppc64":   arch.PPC64,
+	"ppc64le": arch.PPC64LE,
+	"s390x":   arch.S390X,
+	"wasm":    arch.Wasm,
+}
+
+var errGoobjTruncated = errors.New("truncated Go object file")
+
+// openGoobj opens r as a Go object file.
+func openGoobj(r io.ReaderAt) (Obj, error) {
+	b, err := io.ReadAll(io.NewSectionReader(r, 0, math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+	// The text header gives the target and Go version and ends
+	// with "\n!\n".
+	i := bytes.Index(b, []byte("\n!\n"))
+	if !bytes.HasPrefix(b, []byte("go object ")) || i < 0 {
+		return nil, fmt.Errorf("not a Go object file")
+	}
+	hdr := strings.Fields(string(b[:i]))
+	b = b[i+3:]
+	if !bytes.HasPrefix(b, []byte(goobjMagic)) {
+		if len(hdr) > 4 {
+			return nil, fmt.Errorf("unsupported Go object file version %s", hdr[4])
+		}
+		return nil, fmt.Errorf("unsupported Go object file version")
+	}
+	f := &goobjFile{data: b, relocs: make(map[uint64][]Reloc)}
+	kinds := goobjKinds
+	if len(hdr) > 4 {
+		f.arch = goobjToArch[hdr[3]]
+		if minor, ok := goMinor(hdr[4]); ok && minor < 24 {
+			kinds = goobjKinds120
+		}
+	}
+	if err := f.readSyms(kinds); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// goMinor returns the minor version of a Go release such as
+// "go1.21.3".
+func goMinor(version string) (int, bool) {
+	if !strings.HasPrefix(version, "go1.") {
+		return 0, false
+	}
+	v := version[len("go1."):]
+	if i := strings.IndexFunc(v, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		v = v[:i]
+	}
+	minor, err := strconv.Atoi(v)
+	return minor, err == nil
+}
+
+// goobjKinds maps cmd/internal/objabi.SymKind values to symbol
+// kinds. Go 1.24 added FIPS variants of each kind, which shifted the
+// numbering, so goobjKinds120 is for Go 1.20 through 1.23.
+var goobjKinds = []SymKind{
+	SymUnknown,
+	SymText, SymText,
+	SymROData, SymROData,
+	SymData, SymData,
+	SymData, SymData,
+	SymBSS, SymBSS, SymTLS,
+}
+
+var goobjKinds120 = []SymKind{
+	SymUnknown,
+	SymText,
+	SymROData,
+	SymData,
+	SymData,
+	SymBSS, SymBSS, SymTLS,
+}
+
+// goobjReader decodes the binary part of a Go object file. Offsets
+// are from the start of the binary part.
+type goobjReader struct {
+	b   []byte
+	blk [goobjNBlk]uint32
+	err error
+}
+
+func (r *goobjReader) bytes(off, n uint32) []byte {
+	if r.err != nil || uint64(off)+uint64(n) > uint64(len(r.b)) {
+		r.err = errGoobjTruncated
+		return nil
+	}
+	return r.b[off : off+n]
+}
+
+func (r *goobjReader) uint32(off uint32) uint32 {
+	if b := r.bytes(off, 4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+// string decodes the string reference at off.
+func (r *goobjReader) string(off uint32) string {
+	n, soff := r.uint32(off), r.uint32(off+4)
+	return string(r.bytes(soff, n))
+}
+
+// count returns the number of size-byte elements in block blk.
+func (r *goobjReader) count(blk int, size uint32) uint32 {
+	if r.blk[blk+1] < r.blk[blk] {
+		r.err = errGoobjTruncated
+		return 0
+	}
+	return (r.blk[blk+1] - r.blk[blk]) / size
+}
+
+// sym returns the encoded definition of the i'th symbol. The
+// definition blocks are contiguous, so i indexes all of them.
+func (r *goobjReader) sym(i uint32) []byte {
+	return r.bytes(r.blk[goobjBlkSymdef]+i*goobjSymSize, goobjSymSize)
+}
+
+// index returns entries i and i+1 of index block blk, which give the
+// bounds of the i'th symbol's relocations or data.
+func (r *goobjReader) index(blk int, i uint32) (start, end uint32) {
+	start, end = r.uint32(r.blk[blk]+i*4), r.uint32(r.blk[blk]+i*4+4)
+	if end < start {
+		r.err = errGoobjTruncated
+		return 0, 0
+	}
+	return
+}
+
+func (f *goobjFile) readSyms(kinds []SymKind) error {
+	r := &goobjReader{b: f.data}
+	for i := range r.blk {
+		r.blk[i] = r.uint32(uint32(len(goobjMagic) + 8 + 4 + 4*i))
+	}
+	nself := r.count(goobjBlkSymdef, goobjSymSize)
+	nhashed64 := r.count(goobjBlkHashed64def, goobjSymSize)
+	nhashed := r.count(goobjBlkHasheddef, goobjSymSize)
+	ndef := nself + nhashed64 + nhashed + r.count(goobjBlkNonpkgdef, goobjSymSize)
+	nref := r.count(goobjBlkNonpkgref, goobjSymSize)
+	if r.err != nil {
+		return r.err
+	}
+
+	symName := func(i uint32) string {
+		return r.string(r.blk[goobjBlkSymdef] + i*goobjSymSize)
+	}
+
+	// Symbols defined in other packages are referenced by name.
+	refNames := make(map[[8]byte]string)
+	for i, n := uint32(0), r.count(goobjBlkRefName, goobjRefNameSize); i < n && r.err == nil; i++ {
+		off := r.blk[goobjBlkRefName] + i*goobjRefNameSize
+		var ref [8]byte
+		copy(ref[:], r.bytes(off, 8))
+		name := r.string(off + 8)
+		refNames[ref] = name
+		f.syms = append(f.syms, Sym{Name: name, Kind: SymUndef})
+	}
+	resolve := func(ref []byte) string {
+		pkg, idx := binary.LittleEndian.Uint32(ref), binary.LittleEndian.Uint32(ref[4:])
+		switch pkg {
+		case 0:
+			return ""
+		case goobjPkgIdxSelf:
+		case goobjPkgIdxHashed64:
+			idx += nself
+		case goobjPkgIdxHashed:
+			idx += nself + nhashed64
+		case goobjPkgIdxNone:
+			idx += nself + nhashed64 + nhashed
+		case goobjPkgIdxBuiltin:
+			// The builtin list is part of the toolchain,
+			// not the object.
+			return fmt.Sprintf("builtin#%d", idx)
+		default:
+			var key [8]byte
+			copy(key[:], ref)
+			return refNames[key]
+		}
+		if idx >= ndef+nref {
+			return ""
+		}
+		return symName(idx)
+	}
+
+	for i := uint32(0); i < ndef && r.err == nil; i++ {
+		s := r.sym(i)
+		name := symName(i)
+		if s == nil || name == "" {
+			// Not a real symbol.
+			continue
+		}
+		abi, typ := binary.LittleEndian.Uint16(s[8:]), s[10]
+		size := binary.LittleEndian.Uint32(s[13:])
+		kind := SymUnknown
+		if int(typ) < len(kinds) {
+			kind = kinds[typ]
+		}
+		start, end := r.index(goobjBlkDataIdx, i)
+		sym := Sym{Name: name, Size: uint64(size), Kind: kind, Local: abi == goobjABIStatic}
+		if end > start {
+			// Symbols without data, such as BSS symbols,
+			// would overlap the next symbol.
+			sym.Value = uint64(r.blk[goobjBlkData]) + uint64(start)
+			sym.HasAddr = true
+		}
+		f.syms = append(f.syms, sym)
+
+		rstart, rend := r.index(goobjBlkRelocIdx, i)
+		for j := rstart; j < rend && r.err == nil && sym.HasAddr; j++ {
+			rel := r.bytes(r.blk[goobjBlkReloc]+j*goobjRelocSize, goobjRelocSize)
+			if rel == nil {
+				break
+			}
+			f.relocs[sym.Value] = append(f.relocs[sym.Value], Reloc{
+				Offset: uint64(int32(binary.LittleEndian.Uint32(rel))),
+				Type:   RelocType(binary.LittleEndian.Uint16(rel[5:])),
+				Sym:    resolve(rel[15:]),
+				Addend: int64(binary.LittleEndian.Uint64(rel[7:])),
+			})
+		}
+		relocs := f.relocs[sym.Value]
+		sort.SliceStable(relocs, func(i, j int) bool { return relocs[i].Offset < relocs[j].Offset })
+	}
+	for i := ndef; i < ndef+nref && r.err == nil; i++ {
+		f.syms = append(f.syms, Sym{Name: symName(i), Kind: SymUndef})
+	}
+	return r.err
+}
+
+func (f *goobjFile) Info() ObjInfo {
+	return ObjInfo{f.arch}
+}
+
+func (f *goobjFile) Data(ptr, size uint64) ([]byte, error) {
+	if ptr >= uint64(len(f.data)) {
+		return nil, nil
+	}
+	if end := uint64(len(f.data)); ptr+size > end {
+		size = end - ptr
+	}
+	return f.data[ptr : ptr+size], nil
+}
+
+func (f *goobjFile) Symbols() ([]Sym, error) {
+	return append([]Sym(nil), f.syms...), nil
+}
+
+// SymbolData returns the contents of s. Go objects omit trailing
+// zeros, so this pads the data out to s.Size.
+func (f *goobjFile) SymbolData(s Sym) ([]byte, error) {
+	out := make([]byte, s.Size)
+	if s.HasAddr {
+		data, _ := f.Data(s.Value, s.Size)
+		copy(out, data)
+	}
+	return out, nil
+}
+
+func (f *goobjFile) BuildID() (string, error) {
+	return "", ErrNoBuildID
+}
+
+func (f *goobjFile) Sections() ([]Section, error) {
+	return nil, nil
+}
+
+// Relocations returns the relocations of s. The relocation types are
+// cmd/internal/objabi.RelocType values, which vary between Go
+// versions, so they're left unnamed.
+func (f *goobjFile) Relocations(s Sym) ([]Reloc, error) {
+	if !s.HasAddr {
+		return nil, nil
+	}
+	return append([]Reloc(nil), f.relocs[s.Value]...), nil
+}
+
+func (f *goobjFile) DWARF() (*dwarf.Data, error) {
+	// The compiler emits DWARF as symbols for the linker to
+	// assemble.
+	return nil, ErrNotSupported
+}
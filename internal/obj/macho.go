@@ -0,0 +1,345 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"debug/dwarf"
+	"debug/macho"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aclements/objbrowse/internal/arch"
+)
+
+type machoFile struct {
+	macho *macho.File
+}
+
+func openMachO(r io.ReaderAt) (Obj, error) {
+	f, err := macho.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	return &machoFile{f}, nil
+}
+
+// machoGoArch maps Mach-O CPU types to GOARCH values, including
+// architectures we can't disassemble, for selecting a slice of a
+// universal file.
+var machoGoArch = map[macho.Cpu]string{
+	macho.CpuAmd64: "amd64",
+	macho.Cpu386:   "386",
+	macho.CpuArm64: "arm64",
+	macho.CpuArm:   "arm",
+	macho.CpuPpc64: "ppc64",
+	macho.CpuPpc:   "ppc",
+}
+
+// openMachOFat opens the goarch slice of a universal ("fat") Mach-O
+// file. If r isn't a universal file, it returns macho.ErrNotFat.
+func openMachOFat(r io.ReaderAt, goarch string) (Obj, error) {
+	// macho.NewFatFile only returns ErrNotFat for thin Mach-O
+	// files, so check the magic ourselves.
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil || binary.BigEndian.Uint32(magic[:]) != macho.MagicFat {
+		return nil, macho.ErrNotFat
+	}
+	ff, err := macho.NewFatFile(r)
+	if err != nil {
+		return nil, err
+	}
+	var have []string
+	for _, fa := range ff.Arches {
+		name, ok := machoGoArch[fa.Cpu]
+		if !ok {
+			name = fa.Cpu.String()
+		}
+		if name == goarch {
+			return &machoFile{fa.File}, nil
+		}
+		have = append(have, name)
+	}
+	return nil, fmt.Errorf("universal Mach-O file has no %s slice; available architectures: %s", goarch, strings.Join(have, ", "))
+}
+
+var machoToArch = map[macho.Cpu]*arch.Arch{
+	macho.CpuAmd64: arch.AMD64,
+	macho.Cpu386:   arch.I386,
+	macho.CpuArm64: arch.ARM64,
+}
+
+func (f *machoFile) Info() ObjInfo {
+	return ObjInfo{
+		machoToArch[f.macho.Cpu],
+	}
+}
+
+// Mach-O section types and attributes.
+const (
+	machoSectionType          = 0xff
+	machoZerofill             = 0x1
+	machoGBZerofill           = 0xc
+	machoThreadLocalRegular   = 0x11
+	machoThreadLocalZerofill  = 0x12
+	machoAttrPureInstructions = 0x80000000
+	machoAttrSomeInstructions = 0x400
+
+	// machoProtWrite is the VM_PROT_WRITE segment protection.
+	machoProtWrite = 0x2
+)
+
+func machoIsZerofill(sect *macho.Section) bool {
+	switch sect.Flags & machoSectionType {
+	case machoZerofill, machoGBZerofill, machoThreadLocalZerofill:
+		return true
+	}
+	return false
+}
+
+func machoIsTLS(sect *macho.Section) bool {
+	switch sect.Flags & machoSectionType {
+	case machoThreadLocalRegular, machoThreadLocalZerofill:
+		return true
+	}
+	return false
+}
+
+func (f *machoFile) Data(ptr, size uint64) ([]byte, error) {
+	// Look up the section containing ptr. The __TEXT and __DATA
+	// segments are made up of these sections.
+	for _, sect := range f.macho.Sections {
+		end := sect.Addr + sect.Size
+		if sect.Addr <= ptr && ptr < end {
+			// Found it. Limit size.
+			if ptr+size > end {
+				size = end - ptr
+			}
+			return f.sectData(sect, ptr, size)
+		}
+	}
+	return nil, nil
+}
+
+// Mach-O nlist type bits.
+const (
+	machoNStab = 0xe0
+	machoNType = 0x0e
+	machoNExt  = 0x01
+
+	machoNUndf = 0x0
+	machoNAbs  = 0x2
+	machoNSect = 0xe
+
+	machoNWeakRef = 0x40
+	machoNWeakDef = 0x80
+)
+
+func (f *machoFile) Symbols() ([]Sym, error) {
+	if f.macho.Symtab == nil {
+		return nil, nil
+	}
+
+	var out []Sym
+	for _, s := range f.macho.Symtab.Syms {
+		if s.Type&machoNStab != 0 {
+			// Skip stab debugging entries.
+			continue
+		}
+		kind := SymUnknown
+		hasAddr := false
+		sectIdx := 0
+		switch s.Type & machoNType {
+		case machoNUndf:
+			kind = SymUndef
+			if s.Value != 0 {
+				// Common symbol. The value is the size.
+				kind = SymBSS
+			}
+		case machoNAbs:
+			kind = SymAbsolute
+		case machoNSect:
+			if s.Sect == 0 || int(s.Sect) > len(f.macho.Sections) {
+				// Ignore symbol.
+				continue
+			}
+			hasAddr = true
+			sectIdx = int(s.Sect)
+			sect := f.macho.Sections[s.Sect-1]
+			switch {
+			case sect.Flags&(machoAttrPureInstructions|machoAttrSomeInstructions) != 0:
+				kind = SymText
+			case machoIsTLS(sect):
+				kind = SymTLS
+			case machoIsZerofill(sect):
+				kind = SymBSS
+			case sect.Seg == "__TEXT" || sect.Seg == "__DATA_CONST":
+				kind = SymROData
+			default:
+				kind = SymData
+			}
+		}
+		local := s.Type&machoNExt == 0
+		weak := s.Desc&(machoNWeakRef|machoNWeakDef) != 0
+
+		// Mach-O prefixes C and Go symbol names with "_".
+		// Strip it so names match other formats.
+		name := strings.TrimPrefix(s.Name, "_")
+
+		// Mach-O doesn't record symbol sizes. synthesizeSizes
+		// will fill them in.
+		sym := Sym{Name: name, Value: s.Value, Kind: kind, Local: local, Weak: weak, HasAddr: hasAddr, section: sectIdx}
+		if kind == SymBSS && !hasAddr {
+			sym.Size = s.Value
+			sym.Value = 0
+		}
+		out = append(out, sym)
+	}
+	sects, err := f.Sections()
+	if err != nil {
+		return nil, err
+	}
+	synthesizeSizes(out, sects)
+	nameSections(out, sects)
+	return out, nil
+}
+
+func (f *machoFile) SymbolData(s Sym) ([]byte, error) {
+	if s.Kind == SymBSS {
+		// BSS has no file data.
+		return make([]byte, s.Size), nil
+	}
+	// Mach-O section numbers are 1-based. 0 means the symbol
+	// isn't in a section.
+	if s.section <= 0 || s.section > len(f.macho.Sections) {
+		return nil, nil
+	}
+	sect := f.macho.Sections[s.section-1]
+	if s.Value < sect.Addr {
+		return nil, fmt.Errorf("symbol %q starts before section %q", s.Name, sect.Name)
+	}
+	// Synthesized sizes may run past the end of the section.
+	size := s.Size
+	if end := sect.Addr + sect.Size; s.Value+size > end {
+		size = end - s.Value
+	}
+	return f.sectData(sect, s.Value, size)
+}
+
+func (f *machoFile) Relocations(s Sym) ([]Reloc, error) {
+	if s.section <= 0 || s.section > len(f.macho.Sections) {
+		return nil, nil
+	}
+	sect := f.macho.Sections[s.section-1]
+	// Relocation addresses are offsets from the start of the
+	// section. On amd64 and 386, addends are stored in the
+	// section data.
+	var out []Reloc
+	for _, r := range sect.Relocs {
+		addr := sect.Addr + uint64(r.Addr)
+		if addr < s.Value || addr >= s.Value+s.Size {
+			continue
+		}
+		var name string
+		switch {
+		case r.Scattered:
+			// Scattered relocations have no symbol.
+		case r.Extern:
+			if f.macho.Symtab != nil && int(r.Value) < len(f.macho.Symtab.Syms) {
+				name = strings.TrimPrefix(f.macho.Symtab.Syms[r.Value].Name, "_")
+			}
+		case r.Value >= 1 && int(r.Value) <= len(f.macho.Sections):
+			// Value is a 1-based section number.
+			name = f.macho.Sections[r.Value-1].Name
+		}
+		out = append(out, Reloc{Offset: addr - s.Value, Type: RelocType(r.Type), TypeName: f.relocTypeName(r.Type), Sym: name})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Offset < out[j].Offset
+	})
+	return out, nil
+}
+
+// relocTypeName returns the name of relocation type typ for f's CPU,
+// or "" if the CPU isn't known.
+func (f *machoFile) relocTypeName(typ uint8) string {
+	switch f.macho.Cpu {
+	case macho.CpuAmd64:
+		return macho.RelocTypeX86_64(typ).String()
+	case macho.CpuArm64:
+		return macho.RelocTypeARM64(typ).String()
+	case macho.Cpu386:
+		return macho.RelocTypeGeneric(typ).String()
+	case macho.CpuArm:
+		return macho.RelocTypeARM(typ).String()
+	}
+	return ""
+}
+
+func (f *machoFile) BuildID() (string, error) {
+	if sect := f.macho.Section("__text"); sect != nil {
+		data := make([]byte, 4096)
+		n, _ := sect.ReadAt(data, 0)
+		if id, ok := findGoBuildID(data[:n]); ok {
+			return id, nil
+		}
+	}
+	const lcUUID = 0x1b
+	for _, l := range f.macho.Loads {
+		raw := l.Raw()
+		if len(raw) >= 24 && f.macho.ByteOrder.Uint32(raw) == lcUUID {
+			u := raw[8:24]
+			return fmt.Sprintf("%X-%X-%X-%X-%X", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16]), nil
+		}
+	}
+	return "", ErrNoBuildID
+}
+
+func (f *machoFile) Sections() ([]Section, error) {
+	var out []Section
+	for _, sect := range f.macho.Sections {
+		var flags SectionFlags
+		if sect.Seg != "__DWARF" {
+			flags |= SectionAlloc
+		}
+		if sect.Flags&(machoAttrPureInstructions|machoAttrSomeInstructions) != 0 {
+			flags |= SectionExec
+		}
+		if seg := f.macho.Segment(sect.Seg); seg != nil && seg.Prot&machoProtWrite != 0 {
+			flags |= SectionWrite
+		}
+		offset := uint64(sect.Offset)
+		if machoIsZerofill(sect) {
+			flags |= SectionNoBits
+			offset = 0
+		}
+		out = append(out, Section{sect.Name, sect.Addr, sect.Size, offset, flags})
+	}
+	return out, nil
+}
+
+func (f *machoFile) sectData(sect *macho.Section, ptr, size uint64) ([]byte, error) {
+	out := make([]byte, size)
+	if machoIsZerofill(sect) {
+		// Zerofill sections have no file data.
+		return out, nil
+	}
+	pos := ptr - sect.Addr
+	if pos >= sect.Size {
+		return out, nil
+	}
+	flen := size
+	if flen > sect.Size-pos {
+		flen = sect.Size - pos
+	}
+	_, err := sect.ReadAt(out[:flen], int64(pos))
+	return out, err
+}
+
+func (f *machoFile) DWARF() (*dwarf.Data, error) {
+	return f.macho.DWARF()
+}
@@ -6,8 +6,11 @@ package obj
 
 import (
 	"debug/dwarf"
+	"debug/macho"
+	"errors"
 	"fmt"
 	"io"
+	"runtime"
 	"sort"
 
 	"github.com/aclements/objbrowse/internal/arch"
@@ -26,10 +29,39 @@ type Obj interface {
 	Mem
 	Info() ObjInfo
 	Symbols() ([]Sym, error)
+
+	// SymbolData returns the contents of s. BSS symbols occupy
+	// no space in the file, so for these it returns s.Size zero
+	// bytes.
 	SymbolData(s Sym) ([]byte, error)
+
 	DWARF() (*dwarf.Data, error)
+
+	// BuildID returns the build ID of the object. For Go
+	// binaries, this is the Go build ID. Otherwise, it's a
+	// linker-assigned ID, such as the GNU build ID or Mach-O
+	// UUID. If the object has no build ID, it returns
+	// ErrNoBuildID.
+	BuildID() (string, error)
+
+	// Sections returns the sections of the object, in the order
+	// they appear in the section table.
+	Sections() ([]Section, error)
+
+	// Relocations returns the relocations that apply to the
+	// data of s, sorted by offset. If the object format doesn't
+	// support relocations, it returns ErrNotSupported.
+	Relocations(s Sym) ([]Reloc, error)
 }
 
+// ErrNotSupported is returned by Obj methods that aren't implemented
+// for an object file format.
+var ErrNotSupported = errors.New("not supported for this object format")
+
+// ErrNoBuildID is returned by Obj.BuildID if the object doesn't have
+// a build ID.
+var ErrNoBuildID = errors.New("no build ID")
+
 type ObjInfo struct {
 	// Arch is the machine architecture of this object file, or
 	// nil if unknown.
@@ -43,12 +75,145 @@ type Sym struct {
 	// Local indicates this symbol's name is only meaningful
 	// within its compilation unit.
 	Local bool
+	// Weak indicates this symbol has weak binding.
+	Weak bool
+	// Debug indicates this symbol carries only debugging
+	// information (such as a source file name) and isn't part
+	// of the program.
+	Debug bool
 	// HasAddr indicates this symbol's Value is a meaningful
 	// address in the loaded object.
 	HasAddr bool
+	// SizeSynthesized indicates Size was not recorded in the
+	// object file and was instead guessed from the address of
+	// the next symbol. Such sizes may be wrong.
+	SizeSynthesized bool
+	// Section is the name of the section containing this
+	// symbol, or "" if it isn't in a section.
+	Section string
+	// section is the 1-based index of this symbol's section in
+	// the object's Sections, or 0 if it isn't in a section.
 	section int
+	// member is the index of the archive member defining this
+	// symbol, if this symbol is from an archive.
+	member int
+}
+
+// A Reloc is a relocation that patches a symbol's data.
+type Reloc struct {
+	// Offset is the offset of the patched bytes from the start
+	// of the symbol.
+	Offset uint64
+	Type   RelocType
+	// TypeName is the name of Type, such as "R_X86_64_PLT32", or
+	// "" if it's not known.
+	TypeName string
+	// Sym is the name of the target symbol. For relocations
+	// against a section rather than a symbol, this is the
+	// section name.
+	Sym    string
+	Addend int64
+}
+
+// A Section is a section of an object file.
+type Section struct {
+	Name string
+	// Addr is the address of the section when loaded.
+	Addr uint64
+	Size uint64
+	// Offset is the offset of the section's data in the file,
+	// or 0 if it has no file data.
+	Offset uint64
+	Flags  SectionFlags
+}
+
+// SectionFlags describes the contents and attributes of a section.
+type SectionFlags uint8
+
+const (
+	// SectionAlloc indicates the section occupies memory when
+	// the object is loaded.
+	SectionAlloc SectionFlags = 1 << iota
+	// SectionWrite indicates the section is writable.
+	SectionWrite
+	// SectionExec indicates the section contains instructions.
+	SectionExec
+	// SectionNoBits indicates the section has no file data and
+	// is zero-filled, like BSS.
+	SectionNoBits
+)
+
+// SectionName returns the name of the section containing s in o, or
+// "" if s isn't in a section.
+func SectionName(o Obj, s Sym) string {
+	if a, ok := o.(*archiveFile); ok {
+		m, s, err := a.memberSym(s)
+		if err != nil {
+			return ""
+		}
+		return SectionName(m, s)
+	}
+	// All backends number sections starting at 1, so 0 means
+	// no section.
+	if s.section <= 0 {
+		return ""
+	}
+	sects, err := o.Sections()
+	if err != nil || s.section > len(sects) {
+		return ""
+	}
+	return sects[s.section-1].Name
 }
 
+// SectionSyms returns a symbol spanning each allocated section of o.
+// This is useful for browsing objects that have no symbol table.
+func SectionSyms(o Obj) ([]Sym, error) {
+	if a, ok := o.(*archiveFile); ok {
+		var out []Sym
+		for i, m := range a.members {
+			syms, err := SectionSyms(m.obj)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", m.name, err)
+			}
+			for _, s := range syms {
+				s.Name = m.name + ":" + s.Name
+				s.Section = m.name + ":" + s.Section
+				s.member = i
+				out = append(out, s)
+			}
+		}
+		return out, nil
+	}
+
+	sects, err := o.Sections()
+	if err != nil {
+		return nil, err
+	}
+	var out []Sym
+	for i, sect := range sects {
+		if sect.Flags&SectionAlloc == 0 || sect.Size == 0 {
+			continue
+		}
+		var kind SymKind = SymROData
+		switch {
+		case sect.Flags&SectionExec != 0:
+			kind = SymText
+		case sect.Flags&SectionNoBits != 0:
+			kind = SymBSS
+		case sect.Flags&SectionWrite != 0:
+			kind = SymData
+		}
+		out = append(out, Sym{Name: sect.Name, Value: sect.Addr, Size: sect.Size, Kind: kind, Local: true, HasAddr: true, Section: sect.Name, section: i + 1})
+	}
+	return out, nil
+}
+
+// RelocType is a relocation type. Its meaning depends on the object
+// format and architecture. For example, for amd64 ELF objects it is
+// an elf.R_X86_64, and for amd64 Mach-O objects it is a
+// macho.RelocTypeX86_64.
+type RelocType uint32
+
 type SymKind uint8
 
 const (
@@ -59,31 +224,91 @@ const (
 	SymBSS              = 'B'
 	SymUndef            = 'U'
 	SymAbsolute         = 'A'
+	// SymTLS is a thread-local symbol. Its value is an offset
+	// in the thread-local storage block, not an address.
+	SymTLS = 'L'
 )
 
-// Open attempts to open r as a known object file format.
+// Open attempts to open r as a known object file format. If r
+// contains objects for several architectures, such as a universal
+// Mach-O file, Open selects the one for the host architecture.
 func Open(r io.ReaderAt) (Obj, error) {
+	return OpenArch(r, "")
+}
+
+// OpenArch is like Open, but if r contains objects for several
+// architectures, it selects the one for goarch, which is a GOARCH
+// value such as "arm64". If goarch is "", it selects the host
+// architecture.
+func OpenArch(r io.ReaderAt, goarch string) (Obj, error) {
+	if f, err := openArchive(r); err != errNotArchive {
+		return f, err
+	}
 	if f, err := openElf(r); err == nil {
 		return f, nil
 	}
 	if f, err := openPE(r); err == nil {
 		return f, nil
 	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	if f, err := openMachOFat(r, goarch); err != macho.ErrNotFat {
+		return f, err
+	}
+	if f, err := openMachO(r); err == nil {
+		return f, nil
+	}
+	if f, err := openPlan9(r); err == nil {
+		return f, nil
+	}
+	if f, err := openWasm(r); err == nil {
+		return f, nil
+	}
 	return nil, fmt.Errorf("unrecognized object file format")
 }
 
-// Assign sizes to 0-sized symbols based on the offset to the next
-// symbol.
-func synthesizeSizes(syms []Sym) {
-	// Sort by address.
-	sort.Slice(syms, func(i, j int) bool {
+// nameSections sets the Section field of each symbol in syms from
+// sects, which must be the sections of the object containing syms.
+func nameSections(syms []Sym, sects []Section) {
+	for i := range syms {
+		if s := syms[i].section; s > 0 && s <= len(sects) {
+			syms[i].Section = sects[s-1].Name
+		}
+	}
+}
+
+// synthesizeSizes assigns sizes to 0-sized symbols in syms. Each
+// such symbol extends to the next higher symbol in its section, or to
+// the end of its section. sects must be the sections of the object
+// containing syms. Symbols that aren't in a section are left alone.
+func synthesizeSizes(syms []Sym, sects []Section) {
+	// Sort by section, then address.
+	sort.SliceStable(syms, func(i, j int) bool {
+		if syms[i].section != syms[j].section {
+			return syms[i].section < syms[j].section
+		}
 		return syms[i].Value < syms[j].Value
 	})
 
-	// Assign size to 0-sized symbols.
-	for i := range syms {
-		if syms[i].Size == 0 && syms[i].Kind != SymUndef && i+1 < len(syms) {
-			syms[i].Size = syms[i+1].Value - syms[i].Value
+	// Assign size to 0-sized symbols, working backwards so we
+	// always know where the next symbol starts.
+	var next uint64
+	for i := len(syms) - 1; i >= 0; i-- {
+		s := &syms[i]
+		if s.section <= 0 || s.section > len(sects) {
+			continue
+		}
+		if i+1 == len(syms) || syms[i+1].section != s.section {
+			// s is the last symbol in its section.
+			sect := &sects[s.section-1]
+			next = sect.Addr + sect.Size
+		} else if syms[i+1].Value > s.Value {
+			next = syms[i+1].Value
+		}
+		if s.Size == 0 && s.Kind != SymUndef && next > s.Value {
+			s.Size = next - s.Value
+			s.SizeSynthesized = true
 		}
 	}
 }
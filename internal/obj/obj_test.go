@@ -0,0 +1,184 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSynthesizeSizes(t *testing.T) {
+	sects := []Section{
+		{Name: ".text", Addr: 0x1000, Size: 0x100},
+		{Name: ".data", Addr: 0x2000, Size: 0x100},
+	}
+	syms := []Sym{
+		{Name: "b", Value: 0x1040, section: 1},
+		{Name: "d", Value: 0x2000, Size: 0x8, section: 2},
+		{Name: "a", Value: 0x1000, section: 1},
+		{Name: "a2", Value: 0x1000, section: 1},
+		{Name: "e", Value: 0x2010, section: 2},
+		{Name: "u", Kind: SymUndef},
+	}
+	synthesizeSizes(syms, sects)
+
+	want := map[string]uint64{
+		"a": 0x40, "a2": 0x40, "b": 0xc0, "d": 0x8, "e": 0xf0, "u": 0,
+	}
+	for _, s := range syms {
+		if s.Size != want[s.Name] {
+			t.Errorf("%s: got size %#x, want %#x", s.Name, s.Size, want[s.Name])
+		}
+		if s.SizeSynthesized != (s.Name != "d" && s.Name != "u") {
+			t.Errorf("%s: got SizeSynthesized %v", s.Name, s.SizeSynthesized)
+		}
+	}
+}
+
+func TestPlan9EndSyms(t *testing.T) {
+	// Plan 9 symbols don't have sizes, and go:func.* is inside
+	// runtime.pclntab.
+	sects := []Section{
+		{Name: "data", Addr: 0x2000, Size: 0x1000},
+	}
+	syms := []Sym{
+		{Name: "runtime.pclntab", Value: 0x2000, section: 1},
+		{Name: "go:func.*", Value: 0x2400, section: 1},
+		{Name: "runtime.epclntab", Value: 0x2800, section: 1},
+		{Name: "runtime.symtab", Value: 0x2800, section: 1},
+		{Name: "main.x", Value: 0x2900, section: 1},
+	}
+	sizeFromEndSyms(syms, plan9EndSyms)
+	synthesizeSizes(syms, sects)
+
+	want := map[string]uint64{
+		"runtime.pclntab": 0x800, "go:func.*": 0x400,
+		"runtime.epclntab": 0x100, "runtime.symtab": 0x100, "main.x": 0x700,
+	}
+	for _, s := range syms {
+		if s.Size != want[s.Name] {
+			t.Errorf("%s: got size %#x, want %#x", s.Name, s.Size, want[s.Name])
+		}
+		if s.Name == "runtime.pclntab" && s.SizeSynthesized {
+			t.Errorf("%s: got SizeSynthesized true", s.Name)
+		}
+	}
+}
+
+func TestPLTStubs(t *testing.T) {
+	tests := []struct {
+		name   string
+		decode func(pc uint64, stub []byte) (uint64, bool)
+		pc     uint64
+		stub   []byte
+		slot   uint64
+	}{
+		// jmp *0x2fca(%rip); push $0; jmp .plt
+		{"amd64", pltAMD64, 0x1030,
+			[]byte{0xff, 0x25, 0xca, 0x2f, 0x00, 0x00, 0x68, 0x00, 0x00, 0x00, 0x00, 0xe9, 0xe0, 0xff, 0xff, 0xff},
+			0x4000},
+		// endbr64; bnd jmp *0x2fca(%rip)
+		{"amd64 ibt", pltAMD64, 0x1030,
+			[]byte{0xf3, 0x0f, 0x1e, 0xfa, 0xf2, 0xff, 0x25, 0xca, 0x2f, 0x00, 0x00, 0x0f, 0x1f, 0x44, 0x00, 0x00},
+			0x4005},
+		// jmp *0x10(%ebx)
+		{"386 pic", func(pc uint64, stub []byte) (uint64, bool) { return plt386(0x3000, stub) }, 0x1030,
+			[]byte{0xff, 0xa3, 0x10, 0x00, 0x00, 0x00, 0x68, 0x08, 0x00, 0x00, 0x00, 0xe9, 0xd0, 0xff, 0xff, 0xff},
+			0x3010},
+		// adrp x16, 0x11000; ldr x17, [x16, #0x18]
+		{"arm64", pltARM64, 0x400,
+			[]byte{0x90, 0x00, 0x00, 0xb0, 0x11, 0x0e, 0x40, 0xf9},
+			0x11018},
+		{"none", pltAMD64, 0x1020,
+			[]byte{0xff, 0x35, 0xe2, 0x2f, 0x00, 0x00},
+			0},
+	}
+	for _, test := range tests {
+		slot, ok := test.decode(test.pc, test.stub)
+		if ok != (test.slot != 0) || slot != test.slot {
+			t.Errorf("%s: got %#x, %v; want %#x", test.name, slot, ok, test.slot)
+		}
+	}
+}
+
+func TestGoArchive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go build in short mode")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module p\n",
+		"p.go":   "package p\n\nfunc F() int { return 42 }\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(goTool, "build", "-o", "p.a", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOFLAGS=")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, out)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "p.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	o, err := Open(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := o.Info().Arch; got == nil || got.GoArch != runtime.GOARCH {
+		t.Errorf("want arch %s, got %v", runtime.GOARCH, got)
+	}
+	syms, err := o.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fn *Sym
+	for i, s := range syms {
+		if s.Name == "_go_.o:p.F" {
+			fn = &syms[i]
+		}
+	}
+	if fn == nil {
+		t.Fatalf("no symbol _go_.o:p.F in %v", syms)
+	}
+	if fn.Kind != SymText || fn.Size == 0 {
+		t.Errorf("want non-empty text symbol, got %+v", *fn)
+	}
+	data, err := o.SymbolData(*fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(data)) != fn.Size {
+		t.Errorf("want %d bytes of data, got %d", fn.Size, len(data))
+	}
+}
+
+func TestArchiveBadLongNames(t *testing.T) {
+	// The long name table claims to be much larger than the
+	// archive.
+	var buf bytes.Buffer
+	buf.WriteString(archiveMagic)
+	fmt.Fprintf(&buf, "%-16s%-32s%-10d`\n", "//", "", int64(1e10-1))
+	buf.WriteString("a.o/\n")
+	_, err := Open(bytes.NewReader(buf.Bytes()))
+	if err == nil {
+		t.Fatal("opening bad archive succeeded, want error")
+	}
+}
@@ -9,7 +9,7 @@ import (
 	"debug/pe"
 	"fmt"
 	"io"
-	"sort"
+	"strings"
 
 	"github.com/aclements/objbrowse/internal/arch"
 )
@@ -38,9 +38,19 @@ func openPE(r io.ReaderAt) (Obj, error) {
 	return &peFile{f, imageBase}, nil
 }
 
+// PE section characteristics.
+const (
+	peSCNCntCode              = 0x20
+	peSCNCntInitializedData   = 0x40
+	peSCNCntUninitializedData = 0x80
+	peSCNMemDiscardable       = 0x2000000
+	peSCNMemWrite             = 0x80000000
+)
+
 var peToArch = map[uint16]*arch.Arch{
 	pe.IMAGE_FILE_MACHINE_AMD64: arch.AMD64,
 	pe.IMAGE_FILE_MACHINE_I386:  arch.I386,
+	pe.IMAGE_FILE_MACHINE_ARM64: arch.ARM64,
 }
 
 func (f *peFile) Info() ObjInfo {
@@ -50,7 +60,35 @@ func (f *peFile) Info() ObjInfo {
 }
 
 func (f *peFile) Data(ptr, size uint64) ([]byte, error) {
-	panic("not implemented")
+	// Look up the section containing ptr.
+	for _, sect := range f.pe.Sections {
+		addr := f.imageBase + uint64(sect.VirtualAddress)
+		end := addr + peSectSize(sect)
+		if ptr < addr || ptr >= end {
+			continue
+		}
+		// Found it. Limit size.
+		if ptr+size > end {
+			size = end - ptr
+		}
+		return f.sectData(sect, ptr-addr, size)
+	}
+	return nil, nil
+}
+
+// sectData returns size bytes at offset pos in sect. The part of the
+// section past its raw data is zero.
+func (f *peFile) sectData(sect *pe.Section, pos, size uint64) ([]byte, error) {
+	out := make([]byte, size)
+	if sect.Characteristics&peSCNCntUninitializedData != 0 || pos >= uint64(sect.Size) {
+		return out, nil
+	}
+	flen := size
+	if flen > uint64(sect.Size)-pos {
+		flen = uint64(sect.Size) - pos
+	}
+	_, err := sect.ReadAt(out[:flen], int64(pos))
+	return out, err
 }
 
 func (f *peFile) Symbols() ([]Sym, error) {
@@ -60,16 +98,11 @@ func (f *peFile) Symbols() ([]Sym, error) {
 		IMAGE_SYM_DEBUG     = -2
 
 		IMAGE_SYM_CLASS_STATIC = 3
-
-		IMAGE_SCN_CNT_CODE               = 0x20
-		IMAGE_SCN_CNT_INITIALIZED_DATA   = 0x40
-		IMAGE_SCN_CNT_UNINITIALIZED_DATA = 0x80
-		IMAGE_SCN_MEM_WRITE              = 0x80000000
 	)
 
 	var out []Sym
 	for _, s := range f.pe.Symbols {
-		sym := Sym{s.Name, uint64(s.Value), 0, SymUnknown, false, false, int(s.SectionNumber)}
+		sym := Sym{Name: s.Name, Value: uint64(s.Value), Kind: SymUnknown, section: int(s.SectionNumber)}
 		switch s.SectionNumber {
 		case IMAGE_SYM_UNDEFINED:
 			sym.Kind = SymUndef
@@ -85,17 +118,23 @@ func (f *peFile) Symbols() ([]Sym, error) {
 			sect := f.pe.Sections[int(s.SectionNumber)-1]
 			c := sect.Characteristics
 			switch {
-			case c&IMAGE_SCN_CNT_CODE != 0:
+			case c&peSCNCntCode != 0:
 				sym.Kind = SymText
-			case c&IMAGE_SCN_CNT_INITIALIZED_DATA != 0:
-				if c&IMAGE_SCN_MEM_WRITE != 0 {
+			case c&peSCNCntInitializedData != 0:
+				if c&peSCNMemWrite != 0 {
 					sym.Kind = SymData
 				} else {
 					sym.Kind = SymROData
 				}
-			case c&IMAGE_SCN_CNT_UNINITIALIZED_DATA != 0:
+			case c&peSCNCntUninitializedData != 0:
 				sym.Kind = SymBSS
 			}
+			if sect.Name == ".tls" || strings.HasPrefix(sect.Name, ".tls$") {
+				// The TLS template. These symbols do have
+				// addresses, but each thread has its own
+				// copy.
+				sym.Kind = SymTLS
+			}
 			sym.Local = s.StorageClass == IMAGE_SYM_CLASS_STATIC
 			sym.Value += f.imageBase + uint64(sect.VirtualAddress)
 			sym.HasAddr = true
@@ -104,45 +143,83 @@ func (f *peFile) Symbols() ([]Sym, error) {
 		out = append(out, sym)
 	}
 
-	sort.Slice(out, func(i, j int) bool { return out[i].Value < out[j].Value })
-	for i := range out {
-		sym1 := &out[i]
-		if i+1 < len(out) {
-			sym2 := out[i+1]
-			if sym1.section == sym2.section {
-				sym1.Size = sym2.Value - sym1.Value
-				continue
-			}
-		}
-		// Symbol is the last in its section.
-		sect := f.pe.Sections[sym1.section-1]
-		sym1.Size = uint64(sect.VirtualAddress) + uint64(sect.VirtualSize) - sym1.Value
+	// PE symbols don't have sizes, so infer them from the
+	// symbol layout.
+	sects, err := f.Sections()
+	if err != nil {
+		return nil, err
 	}
+	synthesizeSizes(out, sects)
+	nameSections(out, sects)
 
 	return out, nil
 }
 
+// peSectSize returns the size of sect when loaded.
+func peSectSize(sect *pe.Section) uint64 {
+	// In object files, sections have no virtual size, but the
+	// raw data size is accurate.
+	if sect.VirtualSize == 0 {
+		return uint64(sect.Size)
+	}
+	return uint64(sect.VirtualSize)
+}
+
 func (f *peFile) SymbolData(s Sym) ([]byte, error) {
+	if s.Kind == SymBSS {
+		// BSS has no file data.
+		return make([]byte, s.Size), nil
+	}
 	if s.section <= 0 || s.section-1 >= len(f.pe.Sections) {
 		return nil, nil
 	}
 	sect := f.pe.Sections[s.section-1]
-	if s.Value < uint64(sect.VirtualAddress) {
+	addr := f.imageBase + uint64(sect.VirtualAddress)
+	if s.Value < addr {
 		return nil, fmt.Errorf("symbol %q starts before section %q", s.Name, sect.Name)
 	}
-	out := make([]byte, s.Size)
-	pos := s.Value - (f.imageBase + uint64(sect.VirtualAddress))
-	if pos >= uint64(sect.Size) {
-		return out, nil
-	}
-	flen := s.Size
-	if flen > uint64(sect.Size)-pos {
-		flen = uint64(sect.Size) - pos
-	}
-	_, err := sect.ReadAt(out[:flen], int64(pos))
-	return out, err
+	return f.sectData(sect, s.Value-addr, s.Size)
 }
 
 func (f *peFile) DWARF() (*dwarf.Data, error) {
 	return f.pe.DWARF()
 }
+
+func (f *peFile) BuildID() (string, error) {
+	if sect := f.pe.Section(".text"); sect != nil {
+		data := make([]byte, 4096)
+		n, _ := sect.ReadAt(data, 0)
+		if id, ok := findGoBuildID(data[:n]); ok {
+			return id, nil
+		}
+	}
+	return "", ErrNoBuildID
+}
+
+func (f *peFile) Sections() ([]Section, error) {
+	var out []Section
+	for _, sect := range f.pe.Sections {
+		c := sect.Characteristics
+		var flags SectionFlags
+		if c&peSCNMemDiscardable == 0 {
+			flags |= SectionAlloc
+		}
+		if c&peSCNMemWrite != 0 {
+			flags |= SectionWrite
+		}
+		if c&peSCNCntCode != 0 {
+			flags |= SectionExec
+		}
+		offset := uint64(sect.Offset)
+		if c&peSCNCntUninitializedData != 0 {
+			flags |= SectionNoBits
+			offset = 0
+		}
+		out = append(out, Section{sect.Name, f.imageBase + uint64(sect.VirtualAddress), peSectSize(sect), offset, flags})
+	}
+	return out, nil
+}
+
+func (f *peFile) Relocations(s Sym) ([]Reloc, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,243 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"debug/dwarf"
+	"debug/plan9obj"
+	"fmt"
+	"io"
+
+	"github.com/aclements/objbrowse/internal/arch"
+)
+
+// plan9File is a Plan 9 a.out executable, such as those produced
+// by 6l and 8l.
+type plan9File struct {
+	plan9 *plan9obj.File
+
+	// segs are the text, data, and BSS segments, in that order.
+	// Symbol section indexes are 1-based indexes into segs.
+	segs [3]plan9Seg
+}
+
+type plan9Seg struct {
+	addr, size uint64
+	// sect is the file section backing this segment, or nil
+	// for BSS.
+	sect *plan9obj.Section
+}
+
+// Section indexes of plan9File segments.
+const (
+	plan9Text = 1 + iota
+	plan9Data
+	plan9BSS
+)
+
+// plan9DataRound is the alignment of the data segment for each
+// a.out magic number. The data segment starts at the first such
+// boundary after the end of text.
+var plan9DataRound = map[uint32]uint64{
+	plan9obj.Magic386:   4096,
+	plan9obj.MagicAMD64: 0x200000,
+	plan9obj.MagicARM:   4096,
+}
+
+func openPlan9(r io.ReaderAt) (Obj, error) {
+	f, err := plan9obj.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	pf := &plan9File{plan9: f}
+
+	text := f.Section("text")
+	data := f.Section("data")
+	if text == nil || data == nil {
+		return nil, fmt.Errorf("Plan 9 a.out has no text or data section")
+	}
+	// Text starts right after the header, which is loaded with
+	// it.
+	textAddr := f.LoadAddress + f.HdrSize
+	pf.segs[0] = plan9Seg{textAddr, uint64(text.Size), text}
+
+	round := plan9DataRound[f.Magic]
+	if round == 0 {
+		round = 4096
+	}
+	dataAddr := (textAddr + uint64(text.Size) + round - 1) &^ (round - 1)
+	pf.segs[1] = plan9Seg{dataAddr, uint64(data.Size), data}
+	pf.segs[2] = plan9Seg{dataAddr + uint64(data.Size), uint64(f.Bss), nil}
+	return pf, nil
+}
+
+var plan9ToArch = map[uint32]*arch.Arch{
+	plan9obj.MagicAMD64: arch.AMD64,
+	plan9obj.Magic386:   arch.I386,
+}
+
+func (f *plan9File) Info() ObjInfo {
+	return ObjInfo{
+		plan9ToArch[f.plan9.Magic],
+	}
+}
+
+func (f *plan9File) Data(ptr, size uint64) ([]byte, error) {
+	for i := range f.segs {
+		seg := &f.segs[i]
+		end := seg.addr + seg.size
+		if seg.addr <= ptr && ptr < end {
+			// Found it. Limit size.
+			if ptr+size > end {
+				size = end - ptr
+			}
+			return f.segData(seg, ptr, size)
+		}
+	}
+	return nil, nil
+}
+
+func (f *plan9File) Symbols() ([]Sym, error) {
+	syms, err := f.plan9.Symbols()
+	if err == plan9obj.ErrNoSymbols {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var out []Sym
+	for _, s := range syms {
+		// Upper case symbol types are global, lower case are
+		// static. Other types, such as file names and
+		// automatics, don't have addresses.
+		var kind SymKind
+		var sect int
+		switch s.Type {
+		case 'T', 't', 'L', 'l':
+			kind, sect = SymText, plan9Text
+		case 'D', 'd':
+			kind, sect = SymData, plan9Data
+		case 'B', 'b':
+			kind, sect = SymBSS, plan9BSS
+		default:
+			continue
+		}
+		if text := &f.segs[plan9Text-1]; kind == SymData && text.addr <= s.Value && s.Value < text.addr+text.size {
+			// Go puts read-only data, including
+			// runtime.pclntab, in the text segment, but
+			// still gives it type D.
+			kind, sect = SymROData, plan9Text
+		}
+		local := 'a' <= s.Type && s.Type <= 'z'
+		sym := Sym{Name: s.Name, Value: s.Value, Kind: kind, Local: local, HasAddr: true, section: sect}
+		out = append(out, sym)
+	}
+	// Plan 9 symbols don't have sizes. Some Go runtime tables
+	// contain other symbols, so size those from their end
+	// symbols before guessing the rest.
+	sizeFromEndSyms(out, plan9EndSyms)
+	sects, err := f.Sections()
+	if err != nil {
+		return nil, err
+	}
+	synthesizeSizes(out, sects)
+	nameSections(out, sects)
+	return out, nil
+}
+
+// plan9EndSyms maps from the names of Go runtime symbols to the
+// names of symbols marking their ends.
+var plan9EndSyms = map[string]string{
+	"runtime.pclntab": "runtime.epclntab",
+	"runtime.symtab":  "runtime.esymtab",
+}
+
+// sizeFromEndSyms sets the size of each symbol in syms named in ends
+// to the distance to its end symbol, if both are present.
+//
+// This matters for symbols like runtime.pclntab that contain other
+// symbols, such as go:func.*, where synthesizeSizes would stop at
+// the first contained symbol.
+func sizeFromEndSyms(syms []Sym, ends map[string]string) {
+	addrs := make(map[string]uint64)
+	for _, s := range syms {
+		addrs[s.Name] = s.Value
+	}
+	for i := range syms {
+		s := &syms[i]
+		if end, ok := addrs[ends[s.Name]]; ok && s.Size == 0 && end > s.Value {
+			s.Size = end - s.Value
+		}
+	}
+}
+
+func (f *plan9File) SymbolData(s Sym) ([]byte, error) {
+	if s.section < plan9Text || s.section > plan9BSS {
+		return nil, nil
+	}
+	seg := &f.segs[s.section-1]
+	if s.Value < seg.addr {
+		return nil, nil
+	}
+	return f.segData(seg, s.Value, s.Size)
+}
+
+func (f *plan9File) BuildID() (string, error) {
+	data, err := f.segData(&f.segs[plan9Text-1], f.segs[plan9Text-1].addr, 4096)
+	if err != nil {
+		return "", err
+	}
+	if id, ok := findGoBuildID(data); ok {
+		return id, nil
+	}
+	return "", ErrNoBuildID
+}
+
+// Sections returns the text, data, and BSS segments.
+func (f *plan9File) Sections() ([]Section, error) {
+	out := make([]Section, len(f.segs))
+	for i, name := range []string{"text", "data", "bss"} {
+		seg := &f.segs[i]
+		s := Section{Name: name, Addr: seg.addr, Size: seg.size, Flags: SectionAlloc}
+		switch i + 1 {
+		case plan9Text:
+			s.Flags |= SectionExec
+		case plan9Data:
+			s.Flags |= SectionWrite
+		case plan9BSS:
+			s.Flags |= SectionWrite | SectionNoBits
+		}
+		if seg.sect != nil {
+			s.Offset = uint64(seg.sect.Offset)
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+func (f *plan9File) segData(seg *plan9Seg, ptr, size uint64) ([]byte, error) {
+	out := make([]byte, size)
+	if seg.sect == nil {
+		return out, nil
+	}
+	pos := ptr - seg.addr
+	if pos >= seg.size {
+		return out, nil
+	}
+	flen := size
+	if flen > seg.size-pos {
+		flen = seg.size - pos
+	}
+	_, err := seg.sect.ReadAt(out[:flen], int64(pos))
+	return out, err
+}
+
+func (f *plan9File) DWARF() (*dwarf.Data, error) {
+	return nil, ErrNotSupported
+}
+
+func (f *plan9File) Relocations(s Sym) ([]Reloc, error) {
+	return nil, ErrNotSupported
+}
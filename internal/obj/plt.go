@@ -0,0 +1,157 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"debug/elf"
+	"encoding/binary"
+)
+
+// pltSyms returns a symbol for each PLT stub in f, named after the
+// dynamic symbol it calls, like "puts@plt". Linkers don't emit
+// symbols for PLT stubs, so without these, calls through the PLT
+// show up as bare addresses.
+//
+// This finds the GOT slot each stub jumps through, and the name of
+// the symbol from the dynamic relocation that fills that slot.
+func (f *elfFile) pltSyms() ([]Sym, error) {
+	var decode func(pc uint64, stub []byte) (uint64, bool)
+	switch f.elf.Machine {
+	case elf.EM_X86_64:
+		decode = pltAMD64
+	case elf.EM_386:
+		var gotPLT uint64
+		if sect := f.elf.Section(".got.plt"); sect != nil {
+			gotPLT = sect.Addr
+		}
+		decode = func(pc uint64, stub []byte) (uint64, bool) {
+			return plt386(gotPLT, stub)
+		}
+	case elf.EM_AARCH64:
+		decode = pltARM64
+	default:
+		return nil, nil
+	}
+
+	slots, err := f.gotSlots()
+	if err != nil || len(slots) == 0 {
+		return nil, err
+	}
+
+	var out []Sym
+	for i, sect := range f.elf.Sections {
+		switch sect.Name {
+		case ".plt", ".plt.sec", ".plt.got":
+		default:
+			continue
+		}
+		data, err := sect.Data()
+		if err != nil {
+			return nil, err
+		}
+		stubSize := int(sect.Entsize)
+		if stubSize == 0 {
+			stubSize = 16
+		}
+		for off := 0; off+stubSize <= len(data); off += stubSize {
+			pc := sect.Addr + uint64(off)
+			slot, ok := decode(pc, data[off:off+stubSize])
+			if !ok {
+				continue
+			}
+			name, ok := slots[slot]
+			if !ok {
+				continue
+			}
+			out = append(out, Sym{Name: name + "@plt", Value: pc, Size: uint64(stubSize), Kind: SymText, HasAddr: true, section: i})
+		}
+	}
+	return out, nil
+}
+
+// gotSlots returns the names of the dynamic symbols that fill each
+// GOT slot, indexed by slot address.
+func (f *elfFile) gotSlots() (map[uint64]string, error) {
+	dynsym := -1
+	for i, sect := range f.elf.Sections {
+		if sect.Type == elf.SHT_DYNSYM {
+			dynsym = i
+		}
+	}
+	if dynsym < 0 {
+		return nil, nil
+	}
+	syms, err := f.elf.DynamicSymbols()
+	if err != nil {
+		return nil, err
+	}
+	slots := make(map[uint64]string)
+	for _, rsect := range f.elf.Sections {
+		if (rsect.Type != elf.SHT_REL && rsect.Type != elf.SHT_RELA) || int(rsect.Link) != dynsym {
+			continue
+		}
+		relocs, err := f.decodeRelocs(rsect, syms)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range relocs {
+			if r.sym != "" {
+				slots[r.off] = r.sym
+			}
+		}
+	}
+	return slots, nil
+}
+
+// pltAMD64 returns the GOT slot used by an x86-64 PLT stub, which
+// contains a "jmp *slot(%rip)", possibly with a bnd prefix and
+// preceded by endbr64.
+func pltAMD64(pc uint64, stub []byte) (uint64, bool) {
+	for i := 0; i+6 <= len(stub); i++ {
+		if stub[i] == 0xff && stub[i+1] == 0x25 {
+			disp := int32(binary.LittleEndian.Uint32(stub[i+2:]))
+			return pc + uint64(i) + 6 + uint64(int64(disp)), true
+		}
+	}
+	return 0, false
+}
+
+// plt386 returns the GOT slot used by a 386 PLT stub. Non-PIC stubs
+// contain "jmp *slot", and PIC stubs contain "jmp *off(%ebx)", where
+// %ebx points to the .got.plt section.
+func plt386(gotPLT uint64, stub []byte) (uint64, bool) {
+	for i := 0; i+6 <= len(stub); i++ {
+		if stub[i] != 0xff {
+			continue
+		}
+		v := binary.LittleEndian.Uint32(stub[i+2:])
+		switch stub[i+1] {
+		case 0x25:
+			return uint64(v), true
+		case 0xa3:
+			return uint64(uint32(gotPLT + uint64(v))), true
+		}
+	}
+	return 0, false
+}
+
+// pltARM64 returns the GOT slot used by an arm64 PLT stub, which
+// loads the slot with:
+//
+//	adrp x16, slot
+//	ldr x17, [x16, #:lo12:slot]
+func pltARM64(pc uint64, stub []byte) (uint64, bool) {
+	for i := 0; i+8 <= len(stub); i += 4 {
+		adrp := binary.LittleEndian.Uint32(stub[i:])
+		ldr := binary.LittleEndian.Uint32(stub[i+4:])
+		if adrp&0x9f00001f != 0x90000010 || ldr&0xffc003ff != 0xf9400211 {
+			continue
+		}
+		imm := int64(adrp>>29&3|adrp>>3&0x1ffffc) << 43 >> 31
+		page := (pc+uint64(i))&^0xfff + uint64(imm)
+		return page + uint64(ldr>>10&0xfff)*8, true
+	}
+	return 0, false
+}
@@ -0,0 +1,288 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package obj
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"github.com/aclements/objbrowse/internal/arch"
+)
+
+// wasmFile is a WebAssembly module. Addresses are offsets in the
+// module file, so a function's address is the offset of its body in
+// the code section.
+type wasmFile struct {
+	data    []byte
+	syms    []Sym
+	sects   []Section
+	buildID string
+}
+
+const wasmMagic = "\x00asm"
+
+// WebAssembly section IDs.
+const (
+	wasmSectCustom = 0
+	wasmSectImport = 2
+	wasmSectExport = 7
+	wasmSectCode   = 10
+)
+
+var wasmSectNames = [...]string{
+	"custom", "type", "import", "function", "table", "memory", "global",
+	"export", "start", "element", "code", "data", "datacount", "tag",
+}
+
+var errWasmTruncated = errors.New("truncated WebAssembly module")
+
+func openWasm(r io.ReaderAt) (Obj, error) {
+	var hdr [8]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil || string(hdr[:4]) != wasmMagic {
+		return nil, fmt.Errorf("not a WebAssembly module")
+	}
+	if v := binary.LittleEndian.Uint32(hdr[4:]); v != 1 {
+		return nil, fmt.Errorf("unsupported WebAssembly version %d", v)
+	}
+	data, err := ioutil.ReadAll(io.NewSectionReader(r, 0, math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+	f := &wasmFile{data: data}
+	if err := f.readSyms(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// wasmReader decodes WebAssembly binary encodings.
+type wasmReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *wasmReader) byte() byte {
+	if r.err != nil || r.pos >= len(r.b) {
+		r.err = errWasmTruncated
+		return 0
+	}
+	r.pos++
+	return r.b[r.pos-1]
+}
+
+func (r *wasmReader) uleb() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		r.err = errWasmTruncated
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+// bytes reads a length-prefixed byte vector.
+func (r *wasmReader) bytes() []byte {
+	n := r.uleb()
+	if r.err != nil || n > uint64(len(r.b)-r.pos) {
+		r.err = errWasmTruncated
+		return nil
+	}
+	r.pos += int(n)
+	return r.b[r.pos-int(n) : r.pos]
+}
+
+func (r *wasmReader) name() string {
+	return string(r.bytes())
+}
+
+// limits skips a table or memory limits structure.
+func (r *wasmReader) limits() {
+	flags := r.byte()
+	r.uleb()
+	if flags&1 != 0 {
+		r.uleb()
+	}
+}
+
+func (f *wasmFile) readSyms() error {
+	var imports []string         // Names of imported functions
+	names := map[uint64]string{} // Function names from the name section
+	exports := map[uint64]string{}
+	type body struct{ off, size uint64 }
+	var bodies []body
+	codeSect := 0
+
+	r := &wasmReader{b: f.data, pos: 8}
+	for r.pos < len(r.b) && r.err == nil {
+		id := r.byte()
+		payload := r.bytes()
+		if r.err != nil {
+			break
+		}
+		base := uint64(r.pos - len(payload))
+		sr := &wasmReader{b: payload}
+		sect := Section{Addr: base, Size: uint64(len(payload)), Offset: base}
+		if int(id) < len(wasmSectNames) {
+			sect.Name = wasmSectNames[id]
+		} else {
+			sect.Name = fmt.Sprintf("section%d", id)
+		}
+		if id == wasmSectCustom {
+			// Custom sections are named by their
+			// contents.
+			cr := &wasmReader{b: payload}
+			if name := cr.name(); cr.err == nil {
+				sect.Name = name
+			}
+		}
+		if id == wasmSectCode {
+			sect.Flags = SectionExec
+			codeSect = len(f.sects) + 1
+		}
+		f.sects = append(f.sects, sect)
+		switch id {
+		case wasmSectImport:
+			n := sr.uleb()
+			for i := uint64(0); i < n && sr.err == nil; i++ {
+				module, field := sr.name(), sr.name()
+				switch sr.byte() {
+				case 0: // Function
+					sr.uleb()
+					imports = append(imports, module+"."+field)
+				case 1: // Table
+					sr.byte()
+					sr.limits()
+				case 2: // Memory
+					sr.limits()
+				case 3: // Global
+					sr.byte()
+					sr.byte()
+				case 4: // Tag
+					sr.byte()
+					sr.uleb()
+				default:
+					return fmt.Errorf("bad WebAssembly import kind")
+				}
+			}
+		case wasmSectExport:
+			n := sr.uleb()
+			for i := uint64(0); i < n && sr.err == nil; i++ {
+				name := sr.name()
+				kind, idx := sr.byte(), sr.uleb()
+				if _, ok := exports[idx]; kind == 0 && !ok {
+					exports[idx] = name
+				}
+			}
+		case wasmSectCode:
+			n := sr.uleb()
+			for i := uint64(0); i < n && sr.err == nil; i++ {
+				b := sr.bytes()
+				bodies = append(bodies, body{base + uint64(sr.pos-len(b)), uint64(len(b))})
+			}
+		case wasmSectCustom:
+			name := sr.name()
+			if name == "go:buildid" && sr.err == nil {
+				f.buildID = string(sr.b[sr.pos:])
+			}
+			if name != "name" {
+				break
+			}
+			// The name section is optional, so ignore
+			// errors in it.
+			for sr.pos < len(sr.b) && sr.err == nil {
+				sub := sr.byte()
+				ssr := &wasmReader{b: sr.bytes()}
+				if sub != 1 {
+					// Not function names.
+					continue
+				}
+				n := ssr.uleb()
+				for i := uint64(0); i < n && ssr.err == nil; i++ {
+					idx := ssr.uleb()
+					names[idx] = ssr.name()
+				}
+			}
+			sr.err = nil
+		}
+		if sr.err != nil {
+			return fmt.Errorf("bad WebAssembly section %d: %v", id, sr.err)
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	// Imported functions come first in the function index space.
+	name := func(idx uint64, def string) string {
+		if name, ok := names[idx]; ok {
+			return name
+		}
+		if name, ok := exports[idx]; ok {
+			return name
+		}
+		return def
+	}
+	for i, imp := range imports {
+		f.syms = append(f.syms, Sym{Name: name(uint64(i), imp), Kind: SymUndef})
+	}
+	for i, b := range bodies {
+		idx := uint64(len(imports) + i)
+		s := Sym{Name: name(idx, fmt.Sprintf("func[%d]", idx)), Value: b.off, Size: b.size, Kind: SymText, HasAddr: true, Section: "code", section: codeSect}
+		f.syms = append(f.syms, s)
+	}
+	return nil
+}
+
+func (f *wasmFile) Info() ObjInfo {
+	return ObjInfo{arch.Wasm}
+}
+
+func (f *wasmFile) Data(ptr, size uint64) ([]byte, error) {
+	if ptr >= uint64(len(f.data)) {
+		return nil, nil
+	}
+	if end := uint64(len(f.data)); ptr+size > end {
+		size = end - ptr
+	}
+	return f.data[ptr : ptr+size], nil
+}
+
+func (f *wasmFile) Symbols() ([]Sym, error) {
+	return append([]Sym(nil), f.syms...), nil
+}
+
+func (f *wasmFile) SymbolData(s Sym) ([]byte, error) {
+	return f.Data(s.Value, s.Size)
+}
+
+func (f *wasmFile) BuildID() (string, error) {
+	if f.buildID == "" {
+		return "", ErrNoBuildID
+	}
+	return f.buildID, nil
+}
+
+// Sections returns the sections of the module. Since addresses are
+// file offsets, each section's address is its offset.
+func (f *wasmFile) Sections() ([]Section, error) {
+	return append([]Section(nil), f.sects...), nil
+}
+
+func (f *wasmFile) DWARF() (*dwarf.Data, error) {
+	return nil, ErrNotSupported
+}
+
+func (f *wasmFile) Relocations(s Sym) ([]Reloc, error) {
+	return nil, ErrNotSupported
+}
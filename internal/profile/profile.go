@@ -0,0 +1,367 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package profile decodes pprof profiles.
+//
+// It decodes only the parts of the profile.proto format needed to
+// attribute samples to machine addresses: sample types, samples,
+// mappings, and locations. Function and line information is ignored,
+// since objbrowse gets that from the binary.
+package profile
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// A Profile is a decoded pprof profile.
+type Profile struct {
+	// SampleTypes describes the values of each Sample.
+	SampleTypes []ValueType
+
+	// DefaultSampleType is the Type of the preferred sample
+	// type, or "" if the profile doesn't say.
+	DefaultSampleType string
+
+	Samples  []Sample
+	Mappings []*Mapping
+}
+
+// A ValueType describes a kind of sample value, such as "cpu" in
+// "nanoseconds".
+type ValueType struct {
+	Type, Unit string
+}
+
+// A Sample is a set of values recorded at a stack of locations.
+type Sample struct {
+	// Locations is the stack, leaf first.
+	Locations []*Location
+	// Values has one value for each of the profile's
+	// SampleTypes.
+	Values []int64
+}
+
+// A Mapping is a region of the profiled process's address space
+// mapped from a file.
+type Mapping struct {
+	ID uint64
+	// Start and Limit are the bounds [Start, Limit) of the
+	// mapping in the process's address space.
+	Start, Limit uint64
+	// Offset is the file offset mapped at Start.
+	Offset  uint64
+	File    string
+	BuildID string
+}
+
+// A Location is a PC in the profiled process.
+type Location struct {
+	ID uint64
+	// Mapping is the mapping containing Address, or nil if
+	// unknown.
+	Mapping *Mapping
+	// Address is the PC in the process's address space, or 0 if
+	// unknown.
+	Address uint64
+}
+
+// SampleIndex returns the index in Sample.Values of the preferred
+// sample type. This is DefaultSampleType if set and otherwise the
+// last sample type, which is what pprof uses.
+func (p *Profile) SampleIndex() int {
+	for i, t := range p.SampleTypes {
+		if t.Type == p.DefaultSampleType {
+			return i
+		}
+	}
+	return len(p.SampleTypes) - 1
+}
+
+// Parse decodes a pprof profile from r. The profile may be
+// gzip-compressed.
+func Parse(r io.Reader) (*Profile, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	} else {
+		r = br
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data)
+}
+
+// Profile message field numbers from profile.proto.
+const (
+	profSampleType        = 1
+	profSample            = 2
+	profMapping           = 3
+	profLocation          = 4
+	profStringTable       = 6
+	profDefaultSampleType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	mappingID      = 1
+	mappingStart   = 2
+	mappingLimit   = 3
+	mappingOffset  = 4
+	mappingFile    = 5
+	mappingBuildID = 6
+
+	locationID        = 1
+	locationMappingID = 2
+	locationAddress   = 3
+)
+
+func parse(data []byte) (*Profile, error) {
+	// String fields are indexes into the string table, which may
+	// come after the messages that use them, so collect indexes
+	// first and resolve them at the end.
+	var strs []string
+	type valueType struct{ typ, unit int64 }
+	var sampleTypes []valueType
+	var defaultType int64
+	type sample struct {
+		locs   []uint64
+		values []int64
+	}
+	var samples []sample
+	type mapping struct {
+		m             *Mapping
+		file, buildID int64
+	}
+	var mappings []mapping
+	type location struct {
+		l         *Location
+		mappingID uint64
+	}
+	var locs []location
+
+	err := decodeMessage(data, func(field int, f fieldValue) error {
+		switch field {
+		case profSampleType:
+			var vt valueType
+			err := decodeMessage(f.bytes, func(field int, f fieldValue) error {
+				switch field {
+				case valueTypeType:
+					vt.typ = int64(f.varint)
+				case valueTypeUnit:
+					vt.unit = int64(f.varint)
+				}
+				return nil
+			})
+			sampleTypes = append(sampleTypes, vt)
+			return err
+		case profSample:
+			var s sample
+			err := decodeMessage(f.bytes, func(field int, f fieldValue) error {
+				switch field {
+				case sampleLocationID:
+					return f.repeated(func(v uint64) { s.locs = append(s.locs, v) })
+				case sampleValue:
+					return f.repeated(func(v uint64) { s.values = append(s.values, int64(v)) })
+				}
+				return nil
+			})
+			samples = append(samples, s)
+			return err
+		case profMapping:
+			m := mapping{m: new(Mapping)}
+			err := decodeMessage(f.bytes, func(field int, f fieldValue) error {
+				switch field {
+				case mappingID:
+					m.m.ID = f.varint
+				case mappingStart:
+					m.m.Start = f.varint
+				case mappingLimit:
+					m.m.Limit = f.varint
+				case mappingOffset:
+					m.m.Offset = f.varint
+				case mappingFile:
+					m.file = int64(f.varint)
+				case mappingBuildID:
+					m.buildID = int64(f.varint)
+				}
+				return nil
+			})
+			mappings = append(mappings, m)
+			return err
+		case profLocation:
+			l := location{l: new(Location)}
+			err := decodeMessage(f.bytes, func(field int, f fieldValue) error {
+				switch field {
+				case locationID:
+					l.l.ID = f.varint
+				case locationMappingID:
+					l.mappingID = f.varint
+				case locationAddress:
+					l.l.Address = f.varint
+				}
+				return nil
+			})
+			locs = append(locs, l)
+			return err
+		case profStringTable:
+			strs = append(strs, string(f.bytes))
+		case profDefaultSampleType:
+			defaultType = int64(f.varint)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	str := func(i int64) (string, error) {
+		if i < 0 || i >= int64(len(strs)) {
+			return "", fmt.Errorf("bad string index %d", i)
+		}
+		return strs[i], nil
+	}
+
+	p := new(Profile)
+	for _, vt := range sampleTypes {
+		typ, err := str(vt.typ)
+		if err != nil {
+			return nil, err
+		}
+		unit, err := str(vt.unit)
+		if err != nil {
+			return nil, err
+		}
+		p.SampleTypes = append(p.SampleTypes, ValueType{typ, unit})
+	}
+	if p.DefaultSampleType, err = str(defaultType); err != nil {
+		return nil, err
+	}
+	mappingByID := make(map[uint64]*Mapping)
+	for _, m := range mappings {
+		if m.m.File, err = str(m.file); err != nil {
+			return nil, err
+		}
+		if m.m.BuildID, err = str(m.buildID); err != nil {
+			return nil, err
+		}
+		p.Mappings = append(p.Mappings, m.m)
+		mappingByID[m.m.ID] = m.m
+	}
+	locByID := make(map[uint64]*Location)
+	for _, l := range locs {
+		if l.mappingID != 0 {
+			if l.l.Mapping = mappingByID[l.mappingID]; l.l.Mapping == nil {
+				return nil, fmt.Errorf("location %d has unknown mapping %d", l.l.ID, l.mappingID)
+			}
+		}
+		locByID[l.l.ID] = l.l
+	}
+	for _, s := range samples {
+		if len(s.values) != len(p.SampleTypes) {
+			return nil, fmt.Errorf("sample has %d values, want %d", len(s.values), len(p.SampleTypes))
+		}
+		ps := Sample{Values: s.values}
+		for _, id := range s.locs {
+			l := locByID[id]
+			if l == nil {
+				return nil, fmt.Errorf("sample has unknown location %d", id)
+			}
+			ps.Locations = append(ps.Locations, l)
+		}
+		p.Samples = append(p.Samples, ps)
+	}
+	return p, nil
+}
+
+// fieldValue is the value of one protobuf field. Depending on the wire
+// type, either varint or bytes is set.
+type fieldValue struct {
+	wireType int
+	varint   uint64
+	bytes    []byte
+}
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// repeated calls f for each element of a repeated integer field,
+// which may be packed into a single length-delimited field.
+func (v fieldValue) repeated(f func(uint64)) error {
+	if v.wireType != wireBytes {
+		f(v.varint)
+		return nil
+	}
+	for b := v.bytes; len(b) > 0; {
+		x, n := binary.Uvarint(b)
+		if n <= 0 {
+			return fmt.Errorf("bad packed varint")
+		}
+		f(x)
+		b = b[n:]
+	}
+	return nil
+}
+
+// decodeMessage calls f for each field of the protobuf message in
+// data.
+func decodeMessage(data []byte, f func(field int, v fieldValue) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("bad field key")
+		}
+		data = data[n:]
+		v := fieldValue{wireType: int(key & 7)}
+		switch v.wireType {
+		case wireVarint:
+			v.varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return fmt.Errorf("bad varint")
+			}
+			data = data[n:]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if v.wireType == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return io.ErrUnexpectedEOF
+			}
+			data = data[size:]
+			continue
+		case wireBytes:
+			l, n := binary.Uvarint(data)
+			if n <= 0 || l > uint64(len(data)-n) {
+				return fmt.Errorf("bad length-delimited field")
+			}
+			v.bytes = data[n : n+int(l)]
+			data = data[n+int(l):]
+		default:
+			return fmt.Errorf("unsupported wire type %d", v.wireType)
+		}
+		if err := f(int(key>>3), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
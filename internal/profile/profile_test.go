@@ -0,0 +1,68 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package profile
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestParseHeap(t *testing.T) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("heap").WriteTo(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ValueType{
+		{"alloc_objects", "count"},
+		{"alloc_space", "bytes"},
+		{"inuse_objects", "count"},
+		{"inuse_space", "bytes"},
+	}
+	if len(p.SampleTypes) != len(want) {
+		t.Fatalf("want sample types %v, got %v", want, p.SampleTypes)
+	}
+	for i := range want {
+		if p.SampleTypes[i] != want[i] {
+			t.Errorf("want sample type %d %v, got %v", i, want[i], p.SampleTypes[i])
+		}
+	}
+	if got := p.SampleTypes[p.SampleIndex()].Type; got != "inuse_space" {
+		t.Errorf("want default sample type inuse_space, got %s", got)
+	}
+
+	if len(p.Mappings) == 0 {
+		t.Fatalf("no mappings")
+	}
+	for _, s := range p.Samples {
+		if len(s.Values) != len(want) {
+			t.Errorf("sample has %d values, want %d", len(s.Values), len(want))
+		}
+		for _, l := range s.Locations {
+			if l.Address != 0 && l.Mapping == nil {
+				t.Errorf("location %d at %#x has no mapping", l.ID, l.Address)
+			}
+		}
+	}
+}
+
+func TestParseBad(t *testing.T) {
+	for _, data := range []string{
+		"\x0a",             // Truncated field.
+		"\x0a\x05\x08",     // Length past end.
+		"\x0a\x02\x08\x01", // Bad string index.
+		"\x32\x00\x0a\x00\x12\x04\x10\x01\x10\x02", // Value count mismatch.
+		"\x32\x00\x12\x02\x08\x07",                 // Unknown location.
+	} {
+		if _, err := Parse(bytes.NewReader([]byte(data))); err == nil {
+			t.Errorf("%q: want error", data)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssa
+
+import "github.com/aclements/objbrowse/internal/asm"
+
+// Dead returns which values of f are dead, indexed by Value.ID. A
+// value is dead if it has no side effects and no live value uses
+// it.
+//
+// Instructions that write memory, transfer control, or have no
+// modeled results (such as instructions that only write flags) are
+// considered to have side effects. Since f doesn't model the
+// registers read by calls and returns, values used only as call
+// arguments or results appear dead.
+func (f *Func) Dead() []bool {
+	n := 0
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			if v.ID >= n {
+				n = v.ID + 1
+			}
+		}
+	}
+
+	live := make([]bool, n)
+	var work []*Value
+	mark := func(v *Value) {
+		if v != nil && !live[v.ID] {
+			live[v.ID] = true
+			work = append(work, v)
+		}
+	}
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			if v.Op == OpInst && f.hasSideEffects(v.Inst) {
+				mark(v)
+			}
+		}
+	}
+	for len(work) > 0 {
+		v := work[len(work)-1]
+		work = work[:len(work)-1]
+		for _, arg := range v.Args {
+			mark(arg)
+		}
+	}
+
+	for i := range live {
+		live[i] = !live[i]
+	}
+	return live
+}
+
+// hasSideEffects returns whether instruction i of f has effects
+// beyond the locations it writes.
+func (f *Func) hasSideEffects(i int) bool {
+	inst := f.Seq.Get(i)
+	if inst.Control().Type != asm.ControlNone {
+		return true
+	}
+	_, w := inst.Effects()
+	return len(w) == 0 || w.Has(asm.LocMem)
+}
+
+// DCE removes dead values from f. The IDs of the remaining values
+// don't change.
+func (f *Func) DCE() {
+	dead := f.Dead()
+	for _, b := range f.Blocks {
+		j := 0
+		for _, v := range b.Values {
+			if !dead[v.ID] {
+				b.Values[j] = v
+				j++
+			}
+		}
+		b.Values = b.Values[:j]
+	}
+	// Dead values may use live values.
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			j := 0
+			for _, use := range v.Uses {
+				if !dead[use.ID] {
+					v.Uses[j] = use
+					j++
+				}
+			}
+			v.Uses = v.Uses[:j]
+		}
+	}
+}
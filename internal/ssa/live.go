@@ -0,0 +1,75 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssa
+
+import "github.com/aclements/objbrowse/internal/asm"
+
+// LiveLocs returns the locations that hold live values on entry to
+// each instruction of f, indexed by instruction index. A location is
+// live at an instruction if the value in it will be read by that or
+// a later instruction before being overwritten.
+//
+// Liveness is computed by walking backward from each use of each
+// value to its definition. Like Dead, this doesn't know about the
+// registers read by calls and returns.
+func (f *Func) LiveLocs() []asm.LocSet {
+	live := make([]asm.LocSet, f.Seq.Len())
+	defBlock := make(map[*Value]*BasicBlock)
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			defBlock[v] = b
+		}
+	}
+
+	// walk marks loc live in instructions [b.Src.Start, end) of
+	// b, stopping at v's definition, and continues into b's
+	// predecessors if v isn't defined in b.
+	var walk func(b *BasicBlock, end int, v *Value, loc asm.Loc, seen map[*BasicBlock]bool)
+	walk = func(b *BasicBlock, end int, v *Value, loc asm.Loc, seen map[*BasicBlock]bool) {
+		for i := end - 1; i >= b.Src.Start; i-- {
+			if v.Op == OpInst && v.Inst == i {
+				return
+			}
+			if live[i] == nil {
+				live[i] = make(asm.LocSet)
+			}
+			live[i].Add(loc)
+		}
+		if defBlock[v] == b {
+			// v is a phi or entry value of b.
+			return
+		}
+		for _, e := range b.Src.Preds {
+			pred := f.Blocks[e.Block.ID]
+			if !seen[pred] {
+				seen[pred] = true
+				walk(pred, pred.Src.End, v, loc, seen)
+			}
+		}
+	}
+
+	for _, b := range f.Blocks {
+		for _, u := range b.Values {
+			switch u.Op {
+			case OpPhi:
+				// A phi argument is used at the end of
+				// the corresponding predecessor.
+				for k, arg := range u.Args {
+					if arg == nil {
+						continue
+					}
+					pred := f.Blocks[b.Src.Preds[k].Block.ID]
+					seen := map[*BasicBlock]bool{pred: true}
+					walk(pred, pred.Src.End, arg, u.PhiLoc, seen)
+				}
+			case OpInst:
+				for k, arg := range u.Args {
+					walk(b, u.Inst+1, arg, u.ArgLocs[k], make(map[*BasicBlock]bool))
+				}
+			}
+		}
+	}
+	return live
+}
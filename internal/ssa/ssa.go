@@ -5,6 +5,7 @@
 package ssa
 
 import (
+	"context"
 	"fmt"
 	"io"
 
@@ -47,13 +48,17 @@ type Value struct {
 	Op Op
 
 	// ID is a small, dense numbering of Values unique within a
-	// Func.
+	// Func. Removing values with Func.DCE leaves gaps.
 	ID int
 
 	// Entry is a value from outside this function. Valid if Op ==
 	// OpEntry.
 	Entry asm.Loc
 
+	// PhiLoc is the assembly location this phi merges. Valid if
+	// Op == OpPhi.
+	PhiLoc asm.Loc
+
 	// Inst is the assembly instruction index that computes this
 	// value. Valid if Op == OpInst.
 	Inst int
@@ -66,6 +71,15 @@ type Value struct {
 	// underlying assembly variable. Or maybe I dump the index and
 	// don't care.
 	Args []*Value
+
+	// ArgLocs are the assembly locations Args were read from.
+	// Valid if Op == OpInst.
+	ArgLocs []asm.Loc
+
+	// Uses are the Values that read this Value, in the order
+	// they appear in the Func. Each Value appears once, even if
+	// it reads this Value as more than one argument.
+	Uses []*Value
 }
 
 type Op uint8
@@ -76,6 +90,15 @@ const (
 	OpInst
 )
 
+var opNames = []string{OpPhi: "phi", OpEntry: "entry", OpInst: "inst"}
+
+func (o Op) String() string {
+	if int(o) < len(opNames) && opNames[o] != "" {
+		return opNames[o]
+	}
+	return fmt.Sprintf("Op(%d)", int(o))
+}
+
 // SSA computes an single static assignment (SSA) form of seq. This
 // augments seq with single-assignment values, and the necessary phi
 // operations. Each instruction in seq becomes an SSA "value"
@@ -83,7 +106,8 @@ const (
 // records which values become arguments to each instruction.
 //
 // The asmBlocks argument must be the result of asm.BasicBlocks on seq.
-func SSA(seq asm.Seq, asmBlocks []*asm.BasicBlock) *Func {
+// SSA returns ctx.Err() if ctx is done before it completes.
+func SSA(ctx context.Context, seq asm.Seq, asmBlocks []*asm.BasicBlock) (*Func, error) {
 	// See https://www.seas.harvard.edu/courses/cs252/2011sp/slides/Lec04-SSA.pdf
 	// for a good overview of the SSA construction algorithm.
 
@@ -99,7 +123,10 @@ func SSA(seq asm.Seq, asmBlocks []*asm.BasicBlock) *Func {
 	fn := &Func{seq, blocks}
 
 	// Compute the dominator tree.
-	idom := graphalg.IDom(asm.BasicBlockGraph(asmBlocks), 0)
+	idom, err := asm.Dominators(ctx, asmBlocks)
+	if err != nil {
+		return nil, err
+	}
 	dom := graphalg.Dom(idom)
 
 	// Compute the dominance frontier for phi placement.
@@ -109,6 +136,9 @@ func SSA(seq asm.Seq, asmBlocks []*asm.BasicBlock) *Func {
 	type rwSet struct{ r, w []asm.Loc }
 	effects := make([]rwSet, seq.Len())
 	for i := range effects {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		r, w := seq.Get(i).Effects()
 		if w.Has(asm.LocMem) {
 			// All mem writes are, in effect, partial, so
@@ -121,7 +151,6 @@ func SSA(seq asm.Seq, asmBlocks []*asm.BasicBlock) *Func {
 
 	// Place phis. We compute the iterated dominance frontier
 	// on-the-fly as we're doing this.
-	phiLocs := map[*Value]asm.Loc{}
 	var addPhis func(bdf []int, loc asm.Loc)
 	addPhis = func(bdf []int, loc asm.Loc) {
 	nextBlock:
@@ -131,13 +160,12 @@ func SSA(seq asm.Seq, asmBlocks []*asm.BasicBlock) *Func {
 			// Add the phi to b if it doesn't already have
 			// one for this variable.
 			for _, v := range b.Values {
-				if v.Op == OpPhi && phiLocs[v] == loc {
+				if v.Op == OpPhi && v.PhiLoc == loc {
 					continue nextBlock
 				}
 			}
 			phiArgs := make([]*Value, len(b.Src.Preds))
-			phi := &Value{Op: OpPhi, Args: phiArgs}
-			phiLocs[phi] = loc
+			phi := &Value{Op: OpPhi, PhiLoc: loc, Args: phiArgs}
 			b.Values = append(b.Values, phi)
 
 			// Iterate on the dominance frontier since we
@@ -146,6 +174,9 @@ func SSA(seq asm.Seq, asmBlocks []*asm.BasicBlock) *Func {
 		}
 	}
 	for bi, b := range blocks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		for i := b.Src.Start; i < b.Src.End; i++ {
 			for _, w := range effects[i].w {
 				// Add phi for variable w to b's DF.
@@ -173,12 +204,15 @@ func SSA(seq asm.Seq, asmBlocks []*asm.BasicBlock) *Func {
 	}
 	var walk func(node int)
 	walk = func(node int) {
+		if ctx.Err() != nil {
+			return
+		}
 		b := blocks[node]
 		undoPos := len(undoStack)
 
 		// Transform phis.
 		for _, val := range b.Values {
-			w := phiLocs[val]
+			w := val.PhiLoc
 			undoStack = append(undoStack, undo{w, vals[w]})
 			vals[w] = val
 		}
@@ -194,6 +228,7 @@ func SSA(seq asm.Seq, asmBlocks []*asm.BasicBlock) *Func {
 					addEntry(r)
 				}
 				val.Args = append(val.Args, vals[r])
+				val.ArgLocs = append(val.ArgLocs, r)
 			}
 
 			// Map write set into new values.
@@ -212,7 +247,7 @@ func SSA(seq asm.Seq, asmBlocks []*asm.BasicBlock) *Func {
 					break
 				}
 
-				phiLoc := phiLocs[phi]
+				phiLoc := phi.PhiLoc
 				if vals[phiLoc] == nil {
 					addEntry(phiLoc)
 				}
@@ -233,6 +268,9 @@ func SSA(seq asm.Seq, asmBlocks []*asm.BasicBlock) *Func {
 		}
 	}
 	walk(0)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	// Combine the entry values into the entry block.
 	blocks[0].Values = append(entryValues, blocks[0].Values...)
@@ -294,7 +332,21 @@ func SSA(seq asm.Seq, asmBlocks []*asm.BasicBlock) *Func {
 		}
 	}
 
-	return fn
+	// Build use lists.
+	for _, b := range blocks {
+		for _, val := range b.Values {
+			for _, arg := range val.Args {
+				if arg == nil {
+					continue
+				}
+				if n := len(arg.Uses); n == 0 || arg.Uses[n-1] != val {
+					arg.Uses = append(arg.Uses, val)
+				}
+			}
+		}
+	}
+
+	return fn, nil
 }
 
 // Fprint writes a pretty representation of the basic blocks and
@@ -311,7 +363,7 @@ func (f *Func) Fprint(w io.Writer) {
 			fmt.Fprintf(w, "v%d = ", val.ID)
 			switch val.Op {
 			case OpPhi:
-				fmt.Fprintf(w, "phi")
+				fmt.Fprintf(w, "phi %v", val.PhiLoc)
 			case OpEntry:
 				fmt.Fprintf(w, "entry %v", val.Entry)
 			case OpInst:
@@ -0,0 +1,118 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package ssa
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aclements/objbrowse/internal/arch"
+	"github.com/aclements/objbrowse/internal/asm"
+)
+
+// diamond is amd64 code that sets BX differently on each side of a
+// branch and reads it where the branches join.
+var diamond = []byte{
+	0x48, 0x85, 0xc0, // TESTQ AX, AX
+	0x74, 0x07, // JE 0xc
+	0xbb, 0x01, 0x00, 0x00, 0x00, // MOVL $1, BX
+	0xeb, 0x05, // JMP 0x11
+	0xbb, 0x02, 0x00, 0x00, 0x00, // 0xc: MOVL $2, BX
+	0x48, 0x89, 0xd9, // 0x11: MOVQ BX, CX
+	0xc3, // RET
+}
+
+func TestPhiDiamond(t *testing.T) {
+	seq, err := asm.Disasm(arch.AMD64, diamond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bbs, err := asm.BasicBlocks(context.Background(), seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := SSA(context.Background(), seq, bbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Find the values of the two MOVLs and the MOVQ.
+	insts := make(map[int]*Value)
+	var join *BasicBlock
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			if v.Op == OpInst {
+				insts[v.Inst] = v
+				if v.Inst == 5 {
+					join = b
+				}
+			}
+		}
+	}
+	if join == nil {
+		t.Fatal("MOVQ not found")
+	}
+	if len(join.Src.Preds) != 2 {
+		t.Fatalf("join block has %d predecessors, want 2", len(join.Src.Preds))
+	}
+
+	// The join block must start with a phi of BX whose arguments
+	// are the two MOVLs.
+	phi := join.Values[0]
+	if phi.Op != OpPhi || phi.PhiLoc.String() != "BX" {
+		t.Fatalf("join block starts with %v %v, want phi BX", phi.Op, phi.PhiLoc)
+	}
+	if len(phi.Args) != 2 {
+		t.Fatalf("phi has %d args, want 2", len(phi.Args))
+	}
+	for i, e := range join.Src.Preds {
+		// Each argument must come from the MOVL in the
+		// corresponding predecessor.
+		arg := phi.Args[i]
+		if arg == nil || arg.Op != OpInst || arg.Inst < e.Block.Start || arg.Inst >= e.Block.End {
+			t.Errorf("phi arg %d is %+v, want MOVL in b%d", i, arg, e.Block.ID)
+		}
+	}
+	if phi.Args[0] == phi.Args[1] {
+		t.Errorf("phi args are the same value")
+	}
+
+	// The MOVQ must read BX from the phi, not from either MOVL.
+	movq := insts[5]
+	found := false
+	for i, loc := range movq.ArgLocs {
+		if loc.String() == "BX" {
+			found = true
+			if movq.Args[i] != phi {
+				t.Errorf("MOVQ reads BX from %+v, want phi", movq.Args[i])
+			}
+		}
+	}
+	if !found {
+		t.Errorf("MOVQ doesn't read BX")
+	}
+	if len(phi.Uses) != 1 || phi.Uses[0] != movq {
+		t.Errorf("phi uses are %v, want MOVQ", phi.Uses)
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	seq, err := asm.Disasm(arch.AMD64, diamond, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bbs, err := asm.BasicBlocks(context.Background(), seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := asm.BasicBlocks(ctx, seq); err != context.Canceled {
+		t.Errorf("BasicBlocks: want %v, got %v", context.Canceled, err)
+	}
+	if _, err := SSA(ctx, seq, bbs); err != context.Canceled {
+		t.Errorf("SSA: want %v, got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package symtab
+
+import (
+	"sort"
+
+	"github.com/aclements/objbrowse/internal/obj"
+)
+
+// A SymDiff is the change between two tables in the symbols with
+// one name.
+type SymDiff struct {
+	Name string
+
+	// Kind is the kind of the symbol in the new table, or in the
+	// old table if it was removed.
+	Kind obj.SymKind
+
+	// OldSize and NewSize are the total sizes of the symbols
+	// named Name in the old and new tables. InOld and InNew
+	// indicate whether there are any such symbols.
+	OldSize, NewSize uint64
+	InOld, InNew     bool
+}
+
+// Delta returns the change in size from the old table to the new
+// table.
+func (d SymDiff) Delta() int64 {
+	return int64(d.NewSize) - int64(d.OldSize)
+}
+
+// Diff compares the symbols in oldTab and newTab by name and returns
+// the names that were added, removed, or changed in size, sorted by
+// decreasing absolute size change and then by name. Aliases and
+// unnamed symbols are ignored. If several symbols share a name, such
+// as local symbols from different files, their sizes are summed.
+func Diff(oldTab, newTab *Table) []SymDiff {
+	byName := make(map[string]*SymDiff)
+	get := func(sym obj.Sym) *SymDiff {
+		d := byName[sym.Name]
+		if d == nil {
+			d = &SymDiff{Name: sym.Name, Kind: sym.Kind}
+			byName[sym.Name] = d
+		}
+		return d
+	}
+	for _, sym := range oldTab.addr {
+		if sym.Name != "" {
+			d := get(sym)
+			d.OldSize += sym.Size
+			d.InOld = true
+		}
+	}
+	for _, sym := range newTab.addr {
+		if sym.Name != "" {
+			d := get(sym)
+			d.Kind = sym.Kind
+			d.NewSize += sym.Size
+			d.InNew = true
+		}
+	}
+
+	var out []SymDiff
+	for _, d := range byName {
+		if d.InOld != d.InNew || d.OldSize != d.NewSize {
+			out = append(out, *d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		ai, aj := abs(out[i].Delta()), abs(out[j].Delta())
+		if ai != aj {
+			return ai > aj
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
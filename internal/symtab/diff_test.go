@@ -0,0 +1,37 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package symtab
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aclements/objbrowse/internal/obj"
+)
+
+func TestDiff(t *testing.T) {
+	oldTab := NewTable([]obj.Sym{
+		{Name: "same", Value: 0x100, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+		{Name: "grow", Value: 0x110, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+		{Name: "gone", Value: 0x120, Size: 0x8, Kind: obj.SymText, HasAddr: true},
+		{Name: "dup", Value: 0x128, Size: 0x4, Kind: obj.SymText, Local: true, HasAddr: true},
+		{Name: "dup", Value: 0x12c, Size: 0x4, Kind: obj.SymText, Local: true, HasAddr: true},
+	})
+	newTab := NewTable([]obj.Sym{
+		{Name: "same", Value: 0x200, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+		{Name: "grow", Value: 0x210, Size: 0x30, Kind: obj.SymText, HasAddr: true},
+		{Name: "added", Value: 0x240, Size: 0x4, Kind: obj.SymData, HasAddr: true},
+		{Name: "dup", Value: 0x244, Size: 0x8, Kind: obj.SymText, Local: true, HasAddr: true},
+	})
+
+	want := []SymDiff{
+		{Name: "grow", Kind: obj.SymText, OldSize: 0x10, NewSize: 0x30, InOld: true, InNew: true},
+		{Name: "gone", Kind: obj.SymText, OldSize: 0x8, InOld: true},
+		{Name: "added", Kind: obj.SymData, NewSize: 0x4, InNew: true},
+	}
+	if got := Diff(oldTab, newTab); !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
@@ -5,8 +5,11 @@
 package symtab
 
 import (
+	"regexp"
 	"sort"
+	"strings"
 
+	"github.com/aclements/objbrowse/internal/demangle"
 	"github.com/aclements/objbrowse/internal/obj"
 )
 
@@ -14,6 +17,14 @@ import (
 type Table struct {
 	addr []obj.Sym
 	name map[string]int
+
+	// dups maps from names shared by more than one symbol in
+	// addr to the indexes of those symbols, in address order.
+	dups map[string][]int
+
+	// aliases maps from an index in addr to the names of other
+	// symbols at the same address that were merged into it.
+	aliases map[int][]string
 }
 
 // NewTable creates a new table for syms.
@@ -39,7 +50,12 @@ func NewTable(syms []obj.Sym) *Table {
 			return vi < vj
 		}
 
-		// Secondary sort by name.
+		// Group aliases together.
+		if syms[i].Section != syms[j].Section {
+			return syms[i].Section < syms[j].Section
+		}
+
+		// Then sort by name.
 		return syms[i].Name < syms[j].Name
 	})
 
@@ -48,22 +64,185 @@ func NewTable(syms []obj.Sym) *Table {
 		syms = syms[1:]
 	}
 
-	// Create name map for fast name lookup.
-	name := make(map[string]int)
-	for i, s := range syms {
-		name[s.Name] = i
+	// Merge aliases: symbols at the same address in the same
+	// section. Keep the most informative symbol of each group
+	// and look up the others by name only.
+	t := &Table{name: make(map[string]int), dups: make(map[string][]int), aliases: make(map[int][]string)}
+	for len(syms) > 0 {
+		n := 1
+		for n < len(syms) && isAlias(syms[0], syms[n]) {
+			n++
+		}
+		group := syms[:n]
+		syms = syms[n:]
+
+		best := 0
+		for i := range group {
+			if betterSym(group[i], group[best]) {
+				best = i
+			}
+		}
+		idx := len(t.addr)
+		t.addr = append(t.addr, group[best])
+		for i, s := range group {
+			prev, ok := t.name[s.Name]
+			if ok && prev != idx && s.Name != "" {
+				if t.dups[s.Name] == nil {
+					t.dups[s.Name] = []int{prev}
+				}
+				if d := t.dups[s.Name]; d[len(d)-1] != idx {
+					t.dups[s.Name] = append(d, idx)
+				}
+			}
+			if !ok || i == best {
+				t.name[s.Name] = idx
+			}
+			if i != best && s.Name != "" {
+				t.aliases[idx] = append(t.aliases[idx], s.Name)
+			}
+		}
 	}
 
-	return &Table{syms, name}
+	return t
+}
+
+// isAlias returns whether a and b are different names for the same
+// thing.
+func isAlias(a, b obj.Sym) bool {
+	// Symbols that don't have addresses, such as absolute
+	// symbols, just happen to have the same value. Likewise,
+	// members of an archive overlap, but obj.Open puts the
+	// member name in Section.
+	return a.HasAddr && b.HasAddr && a.Value == b.Value && a.Section == b.Section && a.Kind == b.Kind
+}
+
+// betterSym returns whether a is more informative than b.
+func betterSym(a, b obj.Sym) bool {
+	if (a.Name != "") != (b.Name != "") {
+		// Prefer named symbols.
+		return a.Name != ""
+	}
+	if a.Local != b.Local {
+		return !a.Local
+	}
+	if a.Weak != b.Weak {
+		return !a.Weak
+	}
+	// Prefer symbols with recorded sizes.
+	aSize, bSize := a.Size != 0 && !a.SizeSynthesized, b.Size != 0 && !b.SizeSynthesized
+	if aSize != bSize {
+		return aSize
+	}
+	return a.Size > b.Size
 }
 
-// Syms returns all symbols in Table in address order. The caller must
-// not modify the returned slice.
+// Syms returns all symbols in Table in address order, omitting
+// aliases. The caller must not modify the returned slice.
 func (t *Table) Syms() []obj.Sym {
 	return t.addr
 }
 
-// Name returns the symbol with the given name.
+// ByKind returns the symbols in Table of kind k in address order,
+// omitting aliases.
+func (t *Table) ByKind(k obj.SymKind) []obj.Sym {
+	var out []obj.Sym
+	for _, sym := range t.addr {
+		if sym.Kind == k {
+			out = append(out, sym)
+		}
+	}
+	return out
+}
+
+// A SortOrder is an order for listing symbols.
+type SortOrder int
+
+const (
+	// SortAddr sorts symbols by address.
+	SortAddr SortOrder = iota
+	// SortName sorts symbols by name.
+	SortName
+	// SortSize sorts symbols by size, largest first.
+	SortSize
+)
+
+// SortSyms sorts syms in place in the given order. Symbols that are
+// equal in that order are sorted by address.
+func SortSyms(syms []obj.Sym, order SortOrder) {
+	var less func(a, b *obj.Sym) bool
+	switch order {
+	case SortName:
+		less = func(a, b *obj.Sym) bool { return a.Name < b.Name }
+	case SortSize:
+		less = func(a, b *obj.Sym) bool { return a.Size > b.Size }
+	default:
+		less = func(a, b *obj.Sym) bool { return false }
+	}
+	sort.SliceStable(syms, func(i, j int) bool {
+		a, b := &syms[i], &syms[j]
+		if less(a, b) {
+			return true
+		} else if less(b, a) {
+			return false
+		}
+		return a.Value < b.Value
+	})
+}
+
+// Search returns the symbols in Table whose name contains substr,
+// ignoring case, in address order. A symbol also matches if one of
+// its aliases or its name as formatted by fs contains substr.
+func (t *Table) Search(substr string, fs []*demangle.Formatter) []obj.Sym {
+	substr = strings.ToLower(substr)
+	return t.search(func(name string) bool {
+		return strings.Contains(strings.ToLower(name), substr)
+	}, fs)
+}
+
+// SearchRegex returns the symbols in Table whose name matches re, in
+// address order. Like Search, a symbol also matches if one of its
+// aliases or its name as formatted by fs matches re.
+func (t *Table) SearchRegex(re *regexp.Regexp, fs []*demangle.Formatter) []obj.Sym {
+	return t.search(re.MatchString, fs)
+}
+
+func (t *Table) search(match func(name string) bool, fs []*demangle.Formatter) []obj.Sym {
+	var out []obj.Sym
+	for i, sym := range t.addr {
+		found := match(sym.Name)
+		for _, alias := range t.aliases[i] {
+			found = found || match(alias)
+		}
+		if !found && len(fs) > 0 {
+			// Demangling is expensive, so only do it if
+			// nothing else matched.
+			if dn := demangle.Format(sym.Name, fs); dn != "" {
+				found = match(dn)
+			}
+		}
+		if found {
+			out = append(out, sym)
+		}
+	}
+	return out
+}
+
+// Aliases returns the names of other symbols at the same address as
+// sym that were merged into sym.
+func (t *Table) Aliases(sym obj.Sym) []string {
+	if i, ok := t.name[sym.Name]; ok && t.addr[i].Value == sym.Value {
+		return t.aliases[i]
+	}
+	for _, i := range t.dups[sym.Name] {
+		if t.addr[i].Value == sym.Value {
+			return t.aliases[i]
+		}
+	}
+	return nil
+}
+
+// Name returns the symbol with the given name. If name is an alias
+// of another symbol, Name returns that symbol.
 func (t *Table) Name(name string) (obj.Sym, bool) {
 	if i, ok := t.name[name]; ok {
 		return t.addr[i], true
@@ -71,6 +250,24 @@ func (t *Table) Name(name string) (obj.Sym, bool) {
 	return obj.Sym{}, false
 }
 
+// Names returns all of the symbols with the given name, including
+// symbols it is an alias of, in address order. Local symbols in
+// different compilation units may share a name, in which case Name
+// returns only one of them.
+func (t *Table) Names(name string) []obj.Sym {
+	if dups, ok := t.dups[name]; ok {
+		out := make([]obj.Sym, len(dups))
+		for i, idx := range dups {
+			out[i] = t.addr[idx]
+		}
+		return out
+	}
+	if sym, ok := t.Name(name); ok {
+		return []obj.Sym{sym}
+	}
+	return nil
+}
+
 // Addr returns the symbol containing addr.
 func (t *Table) Addr(addr uint64) (obj.Sym, bool) {
 	i := sort.Search(len(t.addr), func(i int) bool {
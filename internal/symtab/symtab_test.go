@@ -0,0 +1,296 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package symtab
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/aclements/objbrowse/internal/demangle"
+	"github.com/aclements/objbrowse/internal/obj"
+)
+
+func TestAliases(t *testing.T) {
+	syms := []obj.Sym{
+		{Name: "local", Value: 0x100, Size: 0x10, Kind: obj.SymText, Local: true, HasAddr: true, Section: ".text"},
+		{Name: "global", Value: 0x100, Size: 0x10, Kind: obj.SymText, HasAddr: true, Section: ".text"},
+		{Name: "marker", Value: 0x100, Kind: obj.SymText, HasAddr: true, Section: ".text"},
+		{Name: "next", Value: 0x110, Size: 0x10, Kind: obj.SymText, HasAddr: true, Section: ".text"},
+		{Name: "abs", Value: 0x110, Kind: obj.SymAbsolute},
+	}
+	tab := NewTable(syms)
+
+	var names []string
+	for _, s := range tab.Syms() {
+		names = append(names, s.Name)
+	}
+	if want := []string{"global", "abs", "next"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("want symbols %v, got %v", want, names)
+	}
+
+	if s, ok := tab.Addr(0x108); !ok || s.Name != "global" {
+		t.Errorf("want global at 0x108, got %v", s.Name)
+	}
+	if s, ok := tab.Name("local"); !ok || s.Name != "global" {
+		t.Errorf("want local to resolve to global, got %v", s.Name)
+	}
+	g, _ := tab.Name("global")
+	if want, got := []string{"local", "marker"}, tab.Aliases(g); !reflect.DeepEqual(want, got) {
+		t.Errorf("want aliases %v, got %v", want, got)
+	}
+}
+
+func TestArchiveAliases(t *testing.T) {
+	// Every member of an archive has its own .text at address 0.
+	if testing.Short() {
+		t.Skip("skipping gcc in short mode")
+	}
+	for _, tool := range []string{"gcc", "ar"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found", tool)
+		}
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.c": "int fa(void) { return 1; }\nint fa2(void) __attribute__((alias(\"fa\")));\n",
+		"b.c": "int fb(void) { return 2; }\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"gcc", "-c", "a.c", "b.c"},
+		{"ar", "rc", "lib.a", "a.o", "b.o"},
+	} {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%s failed: %v\n%s", args[0], err, out)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(dir, "lib.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	bin, err := obj.Open(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	syms, err := bin.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tab := NewTable(syms)
+	for name, want := range map[string]string{
+		"a.o:fa":  "a.o:fa",
+		"a.o:fa2": "a.o:fa",
+		"b.o:fb":  "b.o:fb",
+	} {
+		sym, ok := tab.Name(name)
+		if !ok {
+			t.Errorf("%s: not found", name)
+		} else if sym.Name != want {
+			t.Errorf("%s: want %s, got %s", name, want, sym.Name)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	tab := NewTable([]obj.Sym{
+		{Name: "f", Value: 0x100, Size: 0x10, Kind: obj.SymText, Local: true, HasAddr: true, Section: ".text"},
+		{Name: "f", Value: 0x200, Size: 0x10, Kind: obj.SymText, Local: true, HasAddr: true, Section: ".text"},
+		{Name: "g", Value: 0x200, Size: 0x10, Kind: obj.SymText, HasAddr: true, Section: ".text"},
+		{Name: "h", Value: 0x300, Size: 0x10, Kind: obj.SymText, HasAddr: true, Section: ".text"},
+	})
+	var addrs []uint64
+	for _, s := range tab.Names("f") {
+		addrs = append(addrs, s.Value)
+	}
+	if want := []uint64{0x100, 0x200}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("want f at %#x, got %#x", want, addrs)
+	}
+	if syms := tab.Names("h"); len(syms) != 1 || syms[0].Value != 0x300 {
+		t.Errorf("want one h at 0x300, got %v", syms)
+	}
+	if syms := tab.Names("missing"); len(syms) != 0 {
+		t.Errorf("want no symbols, got %v", syms)
+	}
+	g, _ := tab.Name("g")
+	if want, got := []string{"f"}, tab.Aliases(g); !reflect.DeepEqual(want, got) {
+		t.Errorf("want aliases %v, got %v", want, got)
+	}
+}
+
+func TestByKind(t *testing.T) {
+	tab := NewTable([]obj.Sym{
+		{Name: "f", Value: 0x100, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+		{Name: "x", Value: 0x200, Size: 0x8, Kind: obj.SymData, HasAddr: true},
+		{Name: "g", Value: 0x110, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+	})
+	var names []string
+	for _, s := range tab.ByKind(obj.SymText) {
+		names = append(names, s.Name)
+	}
+	if want := []string{"f", "g"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("want text symbols %v, got %v", want, names)
+	}
+	if syms := tab.ByKind(obj.SymBSS); len(syms) != 0 {
+		t.Errorf("want no BSS symbols, got %v", syms)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	tab := NewTable([]obj.Sym{
+		{Name: "runtime.mallocgc", Value: 0x100, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+		{Name: "main.main", Value: 0x110, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+		{Name: "runtime.MemStats", Value: 0x200, Size: 0x8, Kind: obj.SymData, HasAddr: true},
+		{Name: "_ZN3foo6MallocEv", Value: 0x120, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+	})
+	for _, test := range []struct {
+		substr string
+		want   []string
+	}{
+		{"malloc", []string{"runtime.mallocgc", "_ZN3foo6MallocEv"}},
+		{"RUNTIME.", []string{"runtime.mallocgc", "runtime.MemStats"}},
+		{"foo::", []string{"_ZN3foo6MallocEv"}},
+		{"nothing", nil},
+	} {
+		var names []string
+		for _, s := range tab.Search(test.substr, demangle.Formatters) {
+			names = append(names, s.Name)
+		}
+		if !reflect.DeepEqual(names, test.want) {
+			t.Errorf("%q: want %v, got %v", test.substr, test.want, names)
+		}
+	}
+}
+
+func TestSearchRegex(t *testing.T) {
+	tab := NewTable([]obj.Sym{
+		{Name: "runtime.gcStart", Value: 0x100, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+		{Name: "runtime.mallocgc", Value: 0x110, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+		{Name: "main.gc", Value: 0x120, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+		{Name: "runtime.gcController", Value: 0x200, Size: 0x8, Kind: obj.SymData, HasAddr: true},
+	})
+	var names []string
+	for _, s := range tab.SearchRegex(regexp.MustCompile(`^runtime\..*gc`), nil) {
+		names = append(names, s.Name)
+	}
+	if want := []string{"runtime.gcStart", "runtime.mallocgc", "runtime.gcController"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("want %v, got %v", want, names)
+	}
+}
+
+func TestSplitName(t *testing.T) {
+	for name, want := range map[string][]string{
+		"net/http.(*Server).Serve":      {"net/", "http.", "(*Server).", "Serve"},
+		"github.com/a/b.F":              {"github.com/", "a/", "b.", "F"},
+		"main.G[go.shape.int]":          {"main.", "G[go.shape.int]"},
+		"main.(*T[net/http.Handler]).M": {"main.", "(*T[net/http.Handler]).", "M"},
+		"runtime.main.func1":            {"runtime.", "main.", "func1"},
+		"_start":                        {"_start"},
+		"type:.eq.[2]interface {}":      {"type:.", "eq.", "[2]interface {}"},
+	} {
+		if got := SplitName(name); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: want %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	tab := NewTable([]obj.Sym{
+		{Name: "runtime.mallocgc", Value: 0x100, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+		{Name: "runtime.main", Value: 0x110, Size: 0x20, Kind: obj.SymText, HasAddr: true},
+		{Name: "runtime.main.func1", Value: 0x130, Size: 0x8, Kind: obj.SymText, HasAddr: true},
+		{Name: "main.main", Value: 0x140, Size: 0x4, Kind: obj.SymText, HasAddr: true},
+	})
+	root := tab.Tree()
+	if root.Size != 0x3c || root.Count != 4 {
+		t.Errorf("root: want size 0x3c count 4, got %#x %d", root.Size, root.Count)
+	}
+	var names []string
+	for _, c := range root.Children {
+		names = append(names, c.Name)
+	}
+	if want := []string{"main.", "runtime."}; !reflect.DeepEqual(names, want) {
+		t.Errorf("want root children %v, got %v", want, names)
+	}
+	rt := root.Find("runtime.")
+	if rt == nil || rt.Size != 0x38 || rt.Count != 3 {
+		t.Fatalf("runtime.: want size 0x38 count 3, got %+v", rt)
+	}
+	m := root.Find("runtime.main.")
+	if m == nil || len(m.Syms) != 1 || m.Syms[0].Name != "runtime.main" || m.Count != 2 {
+		t.Errorf("runtime.main.: want runtime.main with 2 symbols, got %+v", m)
+	}
+	if root.Find("runtime.nothing") != nil {
+		t.Errorf("found nonexistent node")
+	}
+}
+
+func TestSortSyms(t *testing.T) {
+	syms := []obj.Sym{
+		{Name: "b", Value: 0x100, Size: 0x10},
+		{Name: "a", Value: 0x200, Size: 0x30},
+		{Name: "c", Value: 0x50, Size: 0x10},
+	}
+	for _, test := range []struct {
+		order SortOrder
+		want  []string
+	}{
+		{SortAddr, []string{"c", "b", "a"}},
+		{SortName, []string{"a", "b", "c"}},
+		{SortSize, []string{"a", "c", "b"}},
+	} {
+		SortSyms(syms, test.order)
+		var names []string
+		for _, s := range syms {
+			names = append(names, s.Name)
+		}
+		if !reflect.DeepEqual(names, test.want) {
+			t.Errorf("order %d: want %v, got %v", test.order, test.want, names)
+		}
+	}
+}
+
+func TestAddr(t *testing.T) {
+	tab := NewTable([]obj.Sym{
+		{Name: "a", Value: 0x100, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+		{Name: "b", Value: 0x120, Size: 0x10, Kind: obj.SymText, HasAddr: true},
+		{Name: "end", Value: 0x130, Kind: obj.SymText, HasAddr: true},
+	})
+	for _, test := range []struct {
+		addr uint64
+		want string
+	}{
+		{0xff, ""},
+		{0x100, "a"},
+		{0x10f, "a"},
+		{0x110, ""},
+		{0x12f, "b"},
+		// Zero-sized symbols at the end don't cover
+		// everything after them.
+		{0x130, ""},
+	} {
+		name := ""
+		if s, ok := tab.Addr(test.addr); ok {
+			name = s.Name
+		}
+		if name != test.want {
+			t.Errorf("%#x: want %q, got %q", test.addr, test.want, name)
+		}
+	}
+	if name, base := tab.SymName(0x128); name != "b" || base != 0x120 {
+		t.Errorf("SymName(0x128): want b+0x120, got %s+%#x", name, base)
+	}
+}
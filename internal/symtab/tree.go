@@ -0,0 +1,171 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package symtab
+
+import (
+	"sort"
+
+	"github.com/aclements/objbrowse/internal/obj"
+)
+
+// A TreeNode is a node in a prefix tree of symbol names.
+type TreeNode struct {
+	// Name is this node's component of the symbol name,
+	// including its trailing separator, if any. The full name of
+	// a node is the concatenation of the Names from the root to
+	// that node. The root's Name is "".
+	Name string
+
+	// Syms are the symbols whose full name ends at this node.
+	// If a symbol's name is also the prefix of other names, it's
+	// recorded at the prefix's node. For example, "runtime.main"
+	// is recorded at the node for "runtime.main.", whose children
+	// include "func1".
+	Syms []obj.Sym
+
+	// Children are the child nodes, sorted by Name.
+	Children []*TreeNode
+
+	// Size and Count are the total size and number of the
+	// symbols at and under this node.
+	Size  uint64
+	Count int
+}
+
+// Child returns n's child with the given name, or nil.
+func (n *TreeNode) Child(name string) *TreeNode {
+	i := sort.Search(len(n.Children), func(i int) bool {
+		return n.Children[i].Name >= name
+	})
+	if i < len(n.Children) && n.Children[i].Name == name {
+		return n.Children[i]
+	}
+	return nil
+}
+
+// Find returns the node whose full name is prefix, or nil. prefix
+// must end on a component boundary as defined by SplitName.
+func (n *TreeNode) Find(prefix string) *TreeNode {
+	for _, part := range SplitName(prefix) {
+		if n = n.Child(part); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// Tree returns a prefix tree of the names of the symbols in Table,
+// omitting aliases. Names are split into components by SplitName.
+func (t *Table) Tree() *TreeNode {
+	type building struct {
+		*TreeNode
+		children map[string]*building
+	}
+	newNode := func(name string) *building {
+		return &building{&TreeNode{Name: name}, make(map[string]*building)}
+	}
+	root := newNode("")
+	for _, sym := range t.addr {
+		node := root
+		node.Size += sym.Size
+		node.Count++
+		for _, part := range SplitName(sym.Name) {
+			child := node.children[part]
+			if child == nil {
+				child = newNode(part)
+				node.children[part] = child
+			}
+			node = child
+			node.Size += sym.Size
+			node.Count++
+		}
+		node.Syms = append(node.Syms, sym)
+	}
+
+	// Convert the maps into sorted children lists. If a symbol's
+	// name is also a prefix of other symbols, such as a function
+	// and its closures, merge its leaf into the prefix node.
+	var finish func(b *building) *TreeNode
+	finish = func(b *building) *TreeNode {
+		for name, child := range b.children {
+			if len(child.children) != 0 {
+				continue
+			}
+			for _, sep := range []string{".", "/"} {
+				if prefix := b.children[name+sep]; prefix != nil {
+					prefix.Syms = append(prefix.Syms, child.Syms...)
+					prefix.Size += child.Size
+					prefix.Count += child.Count
+					delete(b.children, name)
+					break
+				}
+			}
+		}
+		for _, child := range b.children {
+			b.Children = append(b.Children, finish(child))
+		}
+		sort.Slice(b.Children, func(i, j int) bool {
+			return b.Children[i].Name < b.Children[j].Name
+		})
+		return b.TreeNode
+	}
+	return finish(root)
+}
+
+// SplitName splits a symbol name into components for a prefix tree.
+// Each component includes its trailing separator. The package path
+// is split at each "/", and the rest of the name is split at each
+// ".". Separators inside parentheses or brackets, such as in method
+// receivers and generic type arguments, don't split the name.
+//
+// For example, "net/http.(*Server).Serve" splits into "net/",
+// "http.", "(*Server).", and "Serve".
+func SplitName(name string) []string {
+	// Find the end of the package path: the last "/" outside
+	// parentheses and brackets.
+	pathEnd, depth := -1, 0
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth == 0 {
+				pathEnd = i
+			}
+		}
+	}
+
+	var parts []string
+	start := 0
+	depth = 0
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth == 0 && i <= pathEnd {
+				parts = append(parts, name[start:i+1])
+				start = i + 1
+			}
+		case '.':
+			if depth == 0 && i > pathEnd {
+				parts = append(parts, name[start:i+1])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(name) {
+		parts = append(parts, name[start:])
+	}
+	return parts
+}
@@ -0,0 +1,167 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+
+	"github.com/aclements/objbrowse/internal/obj"
+	"github.com/aclements/objbrowse/internal/symtab"
+)
+
+// An AnnotationOverlay is a user-supplied set of instruction
+// annotations, loaded from the file given by the -overlay flag. This
+// lets external tools add results to the disassembly view.
+//
+// The overlay file is a JSON object of the form:
+//
+//	{
+//	  "name": "my analysis",
+//	  "annotations": [
+//	    {"pc": "0x401000", "label": "hot", "color": "#ff8080"},
+//	    {"sym": "main.main", "off": "0x10", "label": "bug?", "title": "possible nil dereference"}
+//	  ]
+//	}
+//
+// "name" is the column header and is optional. Each annotation gives
+// its location either as an absolute "pc", or as a symbol "sym" and
+// an offset "off" from the start of that symbol. Addresses and
+// offsets may be JSON numbers or strings in Go integer syntax (such
+// as "0x10"). "label" is the text shown in the column, "color" is an
+// optional CSS background color for the cell, and "title" is optional
+// hover text. A PC may have more than one annotation.
+type AnnotationOverlay struct {
+	name string
+	// annots is sorted by PC.
+	annots []annotation
+}
+
+type annotation struct {
+	pc uint64
+	AnnotationJS
+}
+
+// overlayFile is the JSON schema of an overlay file.
+type overlayFile struct {
+	Name        string `json:"name"`
+	Annotations []struct {
+		PC    overlayInt `json:"pc"`
+		Sym   string     `json:"sym"`
+		Off   overlayInt `json:"off"`
+		Label string     `json:"label"`
+		Color string     `json:"color"`
+		Title string     `json:"title"`
+	} `json:"annotations"`
+}
+
+// overlayInt is an integer that may be written in JSON as either a
+// number or a string.
+type overlayInt struct {
+	val uint64
+	set bool
+}
+
+func (x *overlayInt) UnmarshalJSON(b []byte) error {
+	var s string
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	} else {
+		s = string(b)
+	}
+	v, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return fmt.Errorf("bad address %s: %v", b, err)
+	}
+	x.val, x.set = v, true
+	return nil
+}
+
+// LoadAnnotationOverlay reads an overlay file from path, resolving
+// symbolic locations using symTab.
+func LoadAnnotationOverlay(path string, symTab *symtab.Table) (*AnnotationOverlay, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f overlayFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	o := &AnnotationOverlay{name: f.Name}
+	if o.name == "" {
+		o.name = "overlay"
+	}
+	for i, a := range f.Annotations {
+		var pc uint64
+		switch {
+		case a.Sym != "":
+			sym, ok := symTab.Name(a.Sym)
+			if !ok {
+				return nil, fmt.Errorf("%s: annotation %d: unknown symbol %q", path, i, a.Sym)
+			}
+			pc = sym.Value + a.Off.val
+		case a.PC.set:
+			pc = a.PC.val
+		default:
+			return nil, fmt.Errorf("%s: annotation %d: missing pc or sym", path, i)
+		}
+		o.annots = append(o.annots, annotation{pc, AnnotationJS{a.Label, a.Color, a.Title}})
+	}
+	sort.SliceStable(o.annots, func(i, j int) bool {
+		return o.annots[i].pc < o.annots[j].pc
+	})
+	return o, nil
+}
+
+type AnnotationOverlayJS struct {
+	Name   string
+	Ranges []AnnotationRangeJS
+}
+
+// AnnotationRangeJS is the set of annotations at a single PC.
+type AnnotationRangeJS struct {
+	Start  AddrJS `json:"start"`
+	End    AddrJS `json:"end"`
+	Annots []AnnotationJS
+}
+
+type AnnotationJS struct {
+	Label string
+	Color string `json:",omitempty"`
+	Title string `json:",omitempty"`
+}
+
+// forSym returns the annotations that fall within sym, or nil if
+// there are none.
+func (o *AnnotationOverlay) forSym(sym obj.Sym) *AnnotationOverlayJS {
+	if o == nil {
+		return nil
+	}
+	end := sym.Value + sym.Size
+	i := sort.Search(len(o.annots), func(i int) bool {
+		return o.annots[i].pc >= sym.Value
+	})
+	var ranges []AnnotationRangeJS
+	for ; i < len(o.annots) && o.annots[i].pc < end; i++ {
+		a := &o.annots[i]
+		if len(ranges) > 0 && ranges[len(ranges)-1].Start == AddrJS(a.pc) {
+			r := &ranges[len(ranges)-1]
+			r.Annots = append(r.Annots, a.AnnotationJS)
+			continue
+		}
+		ranges = append(ranges, AnnotationRangeJS{AddrJS(a.pc), AddrJS(a.pc + 1), []AnnotationJS{a.AnnotationJS}})
+	}
+	if ranges == nil {
+		return nil
+	}
+	return &AnnotationOverlayJS{o.name, ranges}
+}
@@ -0,0 +1,41 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+"use strict";
+
+// AnnotationOverlay adds a column of user-supplied annotations (from
+// the -overlay flag) to the disassembly table.
+class AnnotationOverlay {
+    constructor(info) {
+        this._name = info.Name;
+        for (let r of info.Ranges) {
+            r.start = new AddrJS(r.start);
+            r.end = new AddrJS(r.end);
+        }
+        this._map = new IntervalMap(info.Ranges);
+    }
+
+    // render adds annotations to a table. rowMap is an IntervalMap
+    // from addresses to rows, where each value has a "tr" property
+    // that is a DOM "tr" element. "table" is an object with "header"
+    // and "groupHeader" properties.
+    render(table, rowMap) {
+        $(table.groupHeader).append($("<th>"));
+        $(table.header).append($("<th>").text(this._name));
+
+        for (let r of rowMap.ranges) {
+            const td = $("<td>").addClass("annot").appendTo(r.tr);
+            for (let ar of this._map.intersect([r])) {
+                for (let a of ar.Annots) {
+                    const span = $("<span>").text(a.Label).appendTo(td);
+                    if (a.Color)
+                        span.css({"background-color": a.Color});
+                    if (a.Title)
+                        span.attr("title", a.Title);
+                    td.append(" ");
+                }
+            }
+        }
+    }
+}
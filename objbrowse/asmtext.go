@@ -0,0 +1,55 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// writeAsmText writes the disassembly in info as plain text, in the
+// style of "go tool objdump". This serves /s/{name}?format=text.
+func writeAsmText(w http.ResponseWriter, info *SymInfo) {
+	av, ok := info.AsmView.(*AsmViewJS)
+	if !ok {
+		if info.AsmError != "" {
+			http.Error(w, "cannot disassemble: "+info.AsmError, http.StatusInternalServerError)
+		} else {
+			http.Error(w, info.Name+" is not a text symbol", http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	header := "TEXT " + info.Name + "(SB)"
+	if len(av.Insts) > 0 && av.Insts[0].File != "" {
+		header += " " + av.Insts[0].File
+	}
+	fmt.Fprintln(w, header)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, inst := range av.Insts {
+		pos := ""
+		if inst.File != "" {
+			pos = fmt.Sprintf("%s:%d", filepath.Base(inst.File), inst.Line)
+		}
+		text := inst.Op
+		if inst.Prefix != "" {
+			text = inst.Prefix + " " + text
+		}
+		if len(inst.Args) > 0 {
+			text += " " + strings.Join(inst.Args, ", ")
+		}
+		fmt.Fprintf(tw, "  %s\t%#x\t%s\t%s\n", pos, inst.PC, inst.Bytes, text)
+		if inst.FallsTo != 0 {
+			// Reordered listing. See reorderRPO.
+			fmt.Fprintf(tw, "  \t\t\t; continues at %#x\n", inst.FallsTo)
+		}
+	}
+	tw.Flush()
+}
@@ -5,10 +5,14 @@
 package main
 
 import (
-	"os"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/aclements/objbrowse/internal/asm"
+	"github.com/aclements/objbrowse/internal/functab"
 	"github.com/aclements/objbrowse/internal/obj"
 	"github.com/aclements/objbrowse/internal/ssa"
 	"github.com/aclements/objbrowse/internal/symtab"
@@ -18,84 +22,339 @@ type AsmView struct {
 	fi     *FileInfo
 	symTab *symtab.Table
 
-	liveness *LivenessOverlay
+	liveness    *LivenessOverlay
+	annotations *AnnotationOverlay
+	profile     *ProfileOverlay
 }
 
-func NewAsmView(fi *FileInfo, symTab *symtab.Table) (*AsmView, error) {
-	return &AsmView{fi, symTab, NewLivenessOverlay(fi, symTab)}, nil
+// NewAsmView returns a new disassembly view. annotations and profile
+// may be nil.
+func NewAsmView(fi *FileInfo, symTab *symtab.Table, annotations *AnnotationOverlay, profile *ProfileOverlay) (*AsmView, error) {
+	return &AsmView{fi, symTab, NewLivenessOverlay(fi, symTab), annotations, profile}, nil
 }
 
 type AsmViewJS struct {
 	Insts  []Disasm
 	LastPC AddrJS
 
-	Liveness interface{} `json:",omitempty"`
+	// Base is the address of the symbol. If Partial is set, Insts
+	// covers only part of the symbol, so this may be before the
+	// first instruction.
+	Base    AddrJS
+	Partial bool `json:",omitempty"`
+
+	// Syntax is the assembly syntax of Insts.
+	Syntax string
+
+	// RPO indicates Insts are grouped by basic block in reverse
+	// postorder rather than in address order. See reorderRPO.
+	RPO bool `json:",omitempty"`
+
+	Liveness    interface{} `json:",omitempty"`
+	Annotations interface{} `json:",omitempty"`
+
+	// Profile is the column of per-instruction samples from the
+	// -pprof profile.
+	Profile interface{} `json:",omitempty"`
+
+	// RegLiveness is the register liveness derived from the SSA
+	// form, as opposed to Liveness, which comes from the stack
+	// maps.
+	RegLiveness []RegLivenessJS `json:",omitempty"`
 }
 
 type Disasm struct {
-	PC      AddrJS
+	PC AddrJS
+	// Offset is the offset of this instruction's first byte in
+	// the symbol's data. Its bytes are [Offset, Offset+len(Bytes)/2).
+	Offset uint64
+	// Bytes is the hex-encoded machine code of this instruction.
+	Bytes string
+	// File and Line are the source position of this instruction,
+	// if known, from the Go function table or, failing that, the
+	// DWARF line table.
+	File string `json:",omitempty"`
+	Line int    `json:",omitempty"`
+	// Inline is the inlining stack of this instruction, innermost
+	// first, if it's in an inlined call.
+	Inline []functab.InlineFrame `json:",omitempty"`
+	// SPAdj is the size of the stack frame at this instruction,
+	// if known.
+	SPAdj *int `json:",omitempty"`
+	// Prefix is the text of any prefixes before Op, such as
+	// "LOCK;" in Go syntax or "lock" in GNU syntax.
+	Prefix  string `json:",omitempty"`
 	Op      string
 	Args    []string
 	Control ControlJS
+
+	// Prefixes are the decoded prefixes of this instruction,
+	// including those that don't appear in Prefix.
+	Prefixes []asm.Prefix `json:",omitempty"`
+
+	// MemArgs lists the indexes of Args that are memory operands.
+	MemArgs []int `json:",omitempty"`
+
+	// Refs are the static addresses computed by operands of this
+	// instruction, such as PC-relative addresses.
+	Refs []RefJS `json:",omitempty"`
+
+	// Reads and Writes are the locations (registers, flags, and
+	// memory) this instruction reads and writes.
+	Reads, Writes []string `json:",omitempty"`
+
+	// Defs maps each location this instruction reads to the PCs
+	// of the instructions that may have last written it.
+	// Locations that are live on entry to the function are
+	// omitted.
+	Defs map[string][]AddrJS `json:",omitempty"`
+
+	// Uses maps each location this instruction writes to the PCs
+	// of the instructions that may later read the value it
+	// wrote. This is the inverse of Defs.
+	Uses map[string][]AddrJS `json:",omitempty"`
+
+	// LoopDepth is the number of natural loops containing this
+	// instruction. Irreducible indicates this instruction is in
+	// a cycle that isn't a natural loop.
+	LoopDepth   int  `json:",omitempty"`
+	Irreducible bool `json:",omitempty"`
+
+	// FallsTo is the PC of the instruction that follows this one
+	// in address order if execution may continue to it, but
+	// it's not the next instruction in Insts. This only happens
+	// in reordered listings.
+	FallsTo AddrJS `json:",omitempty"`
 }
 
 type ControlJS struct {
 	Type        asm.ControlType
 	Conditional bool
-	TargetPC    AddrJS
+	// TargetPC is the target of a direct branch or call, or 0 if
+	// unknown.
+	TargetPC AddrJS
+	// FallthroughPC is the PC of the next instruction if
+	// execution may continue to it after a branch or call, or
+	// 0 otherwise.
+	FallthroughPC AddrJS `json:",omitempty"`
+	// Targets are the targets of an indirect jump through a jump
+	// table.
+	Targets []AddrJS `json:",omitempty"`
+}
+
+type RefJS struct {
+	// Arg is the index in Args of the operand.
+	Arg  int
+	Addr AddrJS
+	// Sym and Offset give the symbol containing Addr, if any.
+	Sym    string `json:",omitempty"`
+	Offset AddrJS `json:",omitempty"`
+	// Value describes the data at Addr, such as the contents of
+	// a string or the function of a funcval, if known.
+	Value string `json:",omitempty"`
 }
 
-func (v *AsmView) DecodeSym(sym obj.Sym, data []byte) (interface{}, error) {
-	var info AsmViewJS
+// An AsmWindow selects part of a symbol to disassemble. The zero
+// AsmWindow selects the whole symbol.
+type AsmWindow struct {
+	asm.Range
+
+	// Anchor is the PC of an instruction in Range. Disassembly
+	// starts at or shortly after Range.Start in step with Anchor.
+	Anchor uint64
+}
+
+// DecodeSym disassembles the part of sym selected by win in the given
+// syntax. If rpo is set and win selects all of sym, the instructions
+// are reordered by basic block. It returns ctx.Err() if ctx is done
+// before disassembly completes.
+func (v *AsmView) DecodeSym(ctx context.Context, sym obj.Sym, data []byte, syntax asm.Syntax, win AsmWindow, rpo bool) (interface{}, error) {
+	info := AsmViewJS{Syntax: syntax.String(), Base: AddrJS(sym.Value)}
 
 	if sym.Kind != obj.SymText {
 		return nil, nil
 	}
 
-	insts, err := asm.Disasm(v.fi.Obj.Info().Arch, data, sym.Value)
+	insts, err := disasmSym(ctx, v.fi.Obj, sym, data, win)
 	if err != nil {
 		return nil, err
 	}
+	// In relocatable objects, operands that refer to other
+	// symbols are zero until they're relocated, so name them from
+	// the relocations. The disassembly is still useful without
+	// them, so ignore errors.
+	relocs, _ := v.fi.Obj.Relocations(sym)
+
+	if insts.Len() > 0 {
+		last := insts.Get(insts.Len() - 1)
+		info.Partial = insts.Get(0).PC() != sym.Value || last.PC()+uint64(last.Len()) != sym.Value+uint64(len(data))
+	}
 
+	var defs, uses []map[asm.Loc][]int
+	var loopDepth []int
+	var irreducible []bool
+	var bbs []*asm.BasicBlock
 	if true { // TODO
-		bbs, err := asm.BasicBlocks(insts)
+		bbs, err = asm.BasicBlocks(ctx, insts)
 		if err != nil {
 			return nil, err
 		}
 
-		f := ssa.SSA(insts, bbs)
-		f.Fprint(os.Stdout)
+		f, err := ssa.SSA(ctx, insts, bbs)
+		if err != nil {
+			return nil, err
+		}
+		defs = instDefs(f)
+		uses = instUses(defs)
+		info.RegLiveness = regLiveness(f)
+
+		idom, err := asm.Dominators(ctx, bbs)
+		if err != nil {
+			return nil, err
+		}
+		loops, err := asm.Loops(ctx, bbs, idom)
+		if err != nil {
+			return nil, err
+		}
+		loopDepth = make([]int, insts.Len())
+		irreducible = make([]bool, insts.Len())
+		for _, b := range bbs {
+			for i := b.Start; i < b.End; i++ {
+				loopDepth[i] = loops.Depth[b.ID]
+				irreducible[i] = loops.Irreducible[b.ID]
+			}
+		}
 	}
 
-	//var lines []string
 	var disasms []Disasm
 	for i := 0; i < insts.Len(); i++ {
+		if i%1024 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		inst := insts.Get(i)
 		// TODO: Often the address lookups are for type.*,
-		// go.string.*, or go.func.*. These are pretty
-		// useless. We should at least link to the right place
-		// in a hex dump. It would be way better if we could
-		// do something like printing the string or resolving
-		// the pointer in the funcval.
-		disasm := inst.GoSyntax(v.symTab.SymName)
-		op, args := parseAsm(disasm)
+		// which are pretty useless. It would be better to
+		// resolve these to the type they describe.
+		off := inst.PC() - sym.Value
+		symName := v.symTab.SymName
+		reloc := relocIn(relocs, off, off+uint64(inst.Len()))
+		if reloc != nil {
+			symName = relocSymName(reloc, sym.Value+reloc.Offset, inst.PC()+uint64(inst.Len()))
+		}
+		disasm := syntax.Format(inst, symName)
+		op, args := parseAsm(syntax, disasm)
+		var prefix string
+		if j := strings.LastIndex(op, " "); j >= 0 {
+			prefix, op = op[:j], op[j+1:]
+		}
 		control := inst.Control()
-		//r, w := inst.Effects()
+		memArgs, _ := memArgIndexes(inst, syntax, args)
+		if reloc != nil {
+			// The disassembler doesn't look up
+			// PC-relative memory operands with a
+			// displacement of 0, so name them here.
+			name, _ := symName(inst.PC() + uint64(inst.Len()))
+			for _, j := range memArgs {
+				args[j] = relocMemArg(syntax, args[j], name)
+			}
+		}
+		var next asm.Inst
+		if i+1 < insts.Len() {
+			next = insts.Get(i + 1)
+		}
+		var refs []RefJS
+		if reloc == nil {
+			// The addresses of relocated operands aren't
+			// known.
+			refs = v.refs(inst, next, syntax, args)
+		}
+		r, w := inst.Effects()
+		var rdefs map[string][]AddrJS
+		for loc, def := range defs[i] {
+			if rdefs == nil {
+				rdefs = make(map[string][]AddrJS)
+			}
+			for _, j := range def {
+				rdefs[loc.String()] = append(rdefs[loc.String()], AddrJS(insts.Get(j).PC()))
+			}
+		}
+
+		var ruses map[string][]AddrJS
+		for loc, use := range uses[i] {
+			if ruses == nil {
+				ruses = make(map[string][]AddrJS)
+			}
+			for _, j := range use {
+				ruses[loc.String()] = append(ruses[loc.String()], AddrJS(insts.Get(j).PC()))
+			}
+		}
+
+		controlJS := ControlJS{
+			Type:        control.Type,
+			Conditional: control.Conditional,
+			TargetPC:    AddrJS(control.TargetPC),
+		}
+		if control.Type != asm.ControlNone && control.FallsThrough() {
+			controlJS.FallthroughPC = AddrJS(inst.PC() + uint64(inst.Len()))
+		}
+		for _, pc := range control.Targets {
+			controlJS.Targets = append(controlJS.Targets, AddrJS(pc))
+		}
+
+		var file string
+		var line int
+		var inline []functab.InlineFrame
+		var spAdj *int
+		if v.fi.FuncTab != nil {
+			var fn *functab.Func
+			file, line, fn, _ = v.fi.FuncTab.LineForPC(inst.PC())
+			if fn != nil {
+				if adj, ok := fn.SPAdj(inst.PC()); ok {
+					spAdj = &adj
+				}
+				if inline = fn.InlineStack(inst.PC()); len(inline) <= 1 {
+					inline = nil
+				}
+			}
+		}
 
-		//lines = append(lines, fmt.Sprintf("%s %x %x", disasm, r, w))
 		disasms = append(disasms, Disasm{
-			PC:   AddrJS(inst.PC()),
-			Op:   op,
-			Args: args,
-			Control: ControlJS{
-				Type:        control.Type,
-				Conditional: control.Conditional,
-				TargetPC:    AddrJS(control.TargetPC),
-			},
+			PC:       AddrJS(inst.PC()),
+			Offset:   off,
+			Bytes:    fmt.Sprintf("%x", data[off:off+uint64(inst.Len())]),
+			File:     file,
+			Line:     line,
+			Inline:   inline,
+			SPAdj:    spAdj,
+			Prefix:   prefix,
+			Op:       op,
+			Args:     args,
+			Control:  controlJS,
+			Prefixes: inst.Prefixes(),
+			MemArgs:  memArgs,
+			Refs:     refs,
+			Reads:    locNames(r),
+			Writes:   locNames(w),
+			Defs:     rdefs,
+			Uses:     ruses,
+
+			LoopDepth:   loopDepth[i],
+			Irreducible: irreducible[i],
 		})
 		info.LastPC = AddrJS(inst.PC() + uint64(inst.Len()))
 	}
 	info.Insts = disasms
+	if rpo && win == (AsmWindow{}) {
+		info.Insts = reorderRPO(insts, bbs, disasms)
+		info.RPO = true
+	}
+
+	if a := v.annotations.forSym(sym); a != nil {
+		info.Annotations = a
+	}
+	if p := v.profile.forSym(sym); p != nil {
+		info.Profile = p
+	}
 
 	// Process liveness information.
 	l, err := v.liveness.liveness(sym, insts)
@@ -108,22 +367,441 @@ func (v *AsmView) DecodeSym(sym obj.Sym, data []byte) (interface{}, error) {
 	return &info, nil
 }
 
-func parseAsm(disasm string) (op string, args []string) {
-	i := strings.Index(disasm, " ")
-	// Include prefixes in op. In Go syntax, these are followed by
-	// a semicolon.
-	for i > 0 && disasm[i-1] == ';' {
-		j := strings.Index(disasm[i+1:], " ")
-		if j == -1 {
-			i = -1
-		} else {
-			i += 1 + j
+// disasmSym disassembles the part of text symbol sym selected by win,
+// where sym's contents are data. It resolves jump tables and treats
+// any jump tables in sym as data. It returns ctx.Err() if ctx is done
+// first.
+func disasmSym(ctx context.Context, bin obj.Obj, sym obj.Sym, data []byte, win AsmWindow) (asm.Seq, error) {
+	arch := bin.Info().Arch
+	pc := sym.Value
+	if win.End != 0 {
+		r := asm.AlignRange(arch, data, pc, win.Range, win.Anchor)
+		if r.Start >= r.End {
+			return nil, fmt.Errorf("PC range %#x-%#x is outside %s", win.Start, win.End, sym.Name)
+		}
+		data, pc = data[r.Start-pc:r.End-pc], r.Start
+	}
+	insts, err := asm.Disasm(arch, data, pc)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := asm.JumpTables(ctx, arch, insts, bin.Data)
+	if err != nil {
+		return nil, err
+	}
+	var embedded []asm.Range
+	for _, t := range tables {
+		if pc <= t.Addr && t.Addr < pc+uint64(len(data)) {
+			embedded = append(embedded, asm.Range{Start: t.Addr, End: t.Addr + t.Size})
 		}
 	}
-	if i == -1 {
+	if embedded != nil {
+		// Disassemble again without the tables. This changes
+		// the instruction indexes, so find the tables again.
+		if insts, err = asm.DisasmData(arch, data, pc, embedded); err != nil {
+			return nil, err
+		}
+		if tables, err = asm.JumpTables(ctx, arch, insts, bin.Data); err != nil {
+			return nil, err
+		}
+	}
+	return asm.WithJumpTables(insts, tables), nil
+}
+
+// relocIn returns the first relocation in relocs that patches bytes
+// in [start, end) of its symbol, or nil if there is none. relocs must
+// be sorted by Offset.
+func relocIn(relocs []obj.Reloc, start, end uint64) *obj.Reloc {
+	i := sort.Search(len(relocs), func(i int) bool {
+		return relocs[i].Offset >= start
+	})
+	if i < len(relocs) && relocs[i].Offset < end {
+		return &relocs[i]
+	}
+	return nil
+}
+
+// relocSymName returns a symbol lookup function for formatting an
+// instruction ending at instEnd whose operand is patched by r at
+// address at. It names every address as r's target plus its addend.
+//
+// If the operand is PC-relative and not yet relocated, its address
+// is instEnd. An explicit addend is relative to the patched field,
+// such as -4 to reach the end of a 4-byte displacement, so it's
+// adjusted to be relative to instEnd.
+func relocSymName(r *obj.Reloc, at, instEnd uint64) func(addr uint64) (string, uint64) {
+	return func(addr uint64) (string, uint64) {
+		addend := r.Addend
+		if addend != 0 && addr == instEnd {
+			addend += int64(instEnd - at)
+		}
+		name := r.Sym
+		if addend != 0 {
+			name += fmt.Sprintf("%+#x", addend)
+		}
+		return name, addr
+	}
+}
+
+// pcRelZero is how each syntax formats a PC-relative memory operand
+// with a displacement of 0.
+var pcRelZero = map[asm.Syntax][2]string{
+	asm.SyntaxGo:    {"0(IP)", "%s(SB)"},
+	asm.SyntaxGNU:   {"(%rip)", "%s(%%rip)"},
+	asm.SyntaxIntel: {"[rip]", "[rip+%s]"},
+}
+
+// relocMemArg returns memory operand arg in the given syntax with a
+// PC-relative displacement of 0 replaced by symbol name.
+func relocMemArg(syntax asm.Syntax, arg, name string) string {
+	f, ok := pcRelZero[syntax]
+	if !ok || !strings.Contains(arg, f[0]) {
+		return arg
+	}
+	return strings.Replace(arg, f[0], fmt.Sprintf(f[1], name), 1)
+}
+
+// refs returns the static addresses computed by inst's operands,
+// resolved to symbols. args are inst's arguments in the given syntax,
+// and next is the instruction following inst, or nil.
+func (v *AsmView) refs(inst, next asm.Inst, syntax asm.Syntax, args []string) []RefJS {
+	var out []RefJS
+	for _, ref := range asm.Refs(inst) {
+		r := RefJS{Arg: ref.Arg, Addr: AddrJS(ref.Addr)}
+		if sym, ok := v.symTab.Addr(ref.Addr); ok && sym.Value != 0 {
+			r.Sym, r.Offset = sym.Name, AddrJS(ref.Addr-sym.Value)
+			r.Value = v.refValue(sym, ref.Addr, next)
+		}
+		if syntax != asm.SyntaxGo {
+			// Other syntaxes may reorder the arguments.
+			// Find the operand by the symbol the
+			// disassembler resolved it to or, failing
+			// that, the PC-relative register.
+			key := r.Sym
+			if key == "" {
+				key = "rip"
+			}
+			r.Arg = -1
+			for i, arg := range args {
+				if strings.Contains(arg, key) {
+					if r.Arg != -1 {
+						r.Arg = -1
+						break
+					}
+					r.Arg = i
+				}
+			}
+		}
+		if r.Arg < 0 || r.Arg >= len(args) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// maxRefString is the maximum number of bytes of a string shown by
+// refValue.
+const maxRefString = 64
+
+// refValue returns a description of the data at addr in sym, or ""
+// if there's nothing interesting to say. next is the instruction
+// following the one that computed addr, or nil.
+//
+// This recognizes references to Go string data, string headers that
+// point to string data, and funcvals. Static funcvals are often
+// merged into an unnamed region of read-only data, so this treats
+// any pointer to the start of a function as a funcval.
+func (v *AsmView) refValue(sym obj.Sym, addr uint64, next asm.Inst) string {
+	bin := v.fi.Obj
+	arch := bin.Info().Arch
+	if isStringSym(sym.Name) {
+		// Individual string symbols span exactly the string.
+		// The linker merges string data into one symbol, in
+		// which case the length is usually loaded by the next
+		// instruction.
+		var n uint64
+		if !strings.HasSuffix(sym.Name, ".*") && !sym.SizeSynthesized && addr < sym.Value+sym.Size {
+			n = sym.Value + sym.Size - addr
+		} else if n = immLen(next); n == 0 {
+			return ""
+		}
+		return v.readString(addr, n)
+	}
+	if sym.Kind != obj.SymData && sym.Kind != obj.SymROData {
+		return ""
+	}
+
+	data, err := bin.Data(addr, uint64(2*arch.PtrSize))
+	if err != nil || len(data) < arch.PtrSize {
+		return ""
+	}
+	ptr := arch.Ptr(data)
+	target, ok := v.symTab.Addr(ptr)
+	if !ok {
+		return ""
+	}
+	switch {
+	case target.Kind == obj.SymText && target.Value == ptr:
+		// The first word of a funcval is the function's PC.
+		return "func " + target.Name
+	case isStringSym(target.Name) && len(data) == 2*arch.PtrSize:
+		// A string header.
+		if n := arch.Ptr(data[arch.PtrSize:]); 0 < n && n < 1<<30 {
+			return v.readString(ptr, n)
+		}
+	}
+	return ""
+}
+
+// readString returns the string of n bytes at addr, quoted and
+// truncated to maxRefString bytes.
+func (v *AsmView) readString(addr, n uint64) string {
+	trunc := n > maxRefString
+	if trunc {
+		n = maxRefString
+	}
+	data, err := v.fi.Obj.Data(addr, n)
+	if err != nil || uint64(len(data)) < n {
+		return ""
+	}
+	s := strconv.Quote(string(data))
+	if trunc {
+		s += "..."
+	}
+	return s
+}
+
+// immLen returns the immediate loaded by inst if it's a move of a
+// plausible string length, or 0 if not.
+func immLen(inst asm.Inst) uint64 {
+	if inst == nil {
+		return 0
+	}
+	op, args := parseAsm(asm.SyntaxGo, inst.GoSyntax(nil))
+	if !strings.HasPrefix(op, "MOV") || len(args) != 2 || !strings.HasPrefix(args[0], "$") {
+		return 0
+	}
+	n, err := strconv.ParseUint(args[0][1:], 0, 32)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// isStringSym returns whether name is a Go string data symbol.
+func isStringSym(name string) bool {
+	return strings.HasPrefix(name, "go.string.") || strings.HasPrefix(name, "go:string.")
+}
+
+func locNames(s asm.LocSet) []string {
+	var names []string
+	for _, loc := range s.Ordered() {
+		names = append(names, loc.String())
+	}
+	return names
+}
+
+// instDefs returns, for each instruction in f, the instructions that
+// may have written each location the instruction reads.
+func instDefs(f *ssa.Func) []map[asm.Loc][]int {
+	defs := make([]map[asm.Loc][]int, f.Seq.Len())
+	// Resolve values to the instructions that compute them,
+	// looking through phis.
+	var resolve func(v *ssa.Value, seen map[*ssa.Value]bool, out []int) []int
+	resolve = func(v *ssa.Value, seen map[*ssa.Value]bool, out []int) []int {
+		if v == nil || seen[v] {
+			return out
+		}
+		seen[v] = true
+		switch v.Op {
+		case ssa.OpInst:
+			out = append(out, v.Inst)
+		case ssa.OpPhi:
+			for _, arg := range v.Args {
+				out = resolve(arg, seen, out)
+			}
+		}
+		return out
+	}
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			if v.Op != ssa.OpInst {
+				continue
+			}
+			for i, arg := range v.Args {
+				def := resolve(arg, make(map[*ssa.Value]bool), nil)
+				if len(def) == 0 {
+					continue
+				}
+				if defs[v.Inst] == nil {
+					defs[v.Inst] = make(map[asm.Loc][]int)
+				}
+				sort.Ints(def)
+				defs[v.Inst][v.ArgLocs[i]] = def
+			}
+		}
+	}
+	return defs
+}
+
+// instUses inverts defs, the result of instDefs. It returns, for each
+// instruction, the instructions that may read each location the
+// instruction writes, in increasing order.
+func instUses(defs []map[asm.Loc][]int) []map[asm.Loc][]int {
+	uses := make([]map[asm.Loc][]int, len(defs))
+	for j, m := range defs {
+		for loc, def := range m {
+			for _, i := range def {
+				if uses[i] == nil {
+					uses[i] = make(map[asm.Loc][]int)
+				}
+				if u := uses[i][loc]; len(u) == 0 || u[len(u)-1] != j {
+					uses[i][loc] = append(u, j)
+				}
+			}
+		}
+	}
+	return uses
+}
+
+// parseAsm splits an instruction in the given syntax into its
+// opcode, including any prefixes, and its arguments.
+func parseAsm(syntax asm.Syntax, disasm string) (op string, args []string) {
+	if syntax != asm.SyntaxGo {
+		return parseGNUAsm(disasm)
+	}
+	i := prefixLen(disasm)
+	j := strings.Index(disasm[i:], " ")
+	if j == -1 {
 		return disasm, []string{}
 	}
-	op, disasm = disasm[:i], disasm[i+1:]
+	op, disasm = disasm[:i+j], disasm[i+j+1:]
 	args = strings.Split(disasm, ", ")
 	return
 }
+
+// prefixLen returns the length of the prefixes at the start of
+// disasm, including the space after them.
+func prefixLen(disasm string) int {
+	i := 0
+	for {
+		j := strings.Index(disasm[i:], " ")
+		if j == -1 {
+			return i
+		}
+		// In Go syntax, REP prefixes are followed by a
+		// semicolon and prefixes are upper case.
+		word := strings.ToLower(strings.TrimSuffix(disasm[i:i+j], ";"))
+		if !prefixes[word] && !strings.HasPrefix(word, "rex") {
+			return i
+		}
+		i += j + 1
+	}
+}
+
+// prefixes is the set of x86 prefixes, in lower case. These are
+// separated from the opcode by a space.
+var prefixes = map[string]bool{
+	"cs": true, "ds": true, "es": true, "fs": true, "gs": true, "ss": true,
+	"lock": true, "rep": true, "repn": true, "repne": true,
+	"addrsize": true, "datasize": true,
+	"addr16": true, "addr32": true, "data16": true, "data32": true,
+	"bnd": true, "xacquire": true, "xrelease": true,
+	"pt": true, "pn": true, "hint-taken": true, "hint-not-taken": true,
+}
+
+// parseGNUAsm is parseAsm for GNU and Intel syntax. Arguments may
+// be separated by "," or ", " and memory arguments may themselves
+// contain commas, such as "(%rax,%rbx,8)" or "[x0, #8]".
+func parseGNUAsm(disasm string) (op string, args []string) {
+	i := prefixLen(disasm)
+	j := strings.Index(disasm[i:], " ")
+	if j == -1 {
+		return disasm, []string{}
+	}
+	op, disasm = disasm[:i+j], disasm[i+j+1:]
+	args = []string{}
+	depth, start := 0, 0
+	for i := 0; i < len(disasm); i++ {
+		switch disasm[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(disasm[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	args = append(args, strings.TrimSpace(disasm[start:]))
+	return
+}
+
+// memArgIndexes returns the indexes in args of the memory operands of
+// inst, where args are inst's arguments in the given syntax. asm
+// numbers memory operands by their position in Go syntax, so it
+// also returns the corresponding Go syntax indexes. If it can't
+// match up the operands, it returns nil, nil.
+func memArgIndexes(inst asm.Inst, syntax asm.Syntax, args []string) (idx, goIdx []int) {
+	for _, ma := range inst.MemArgs() {
+		goIdx = append(goIdx, ma.Arg)
+	}
+	if syntax == asm.SyntaxGo || goIdx == nil {
+		return goIdx, goIdx
+	}
+	// Other syntaxes may reorder the arguments, so only match up
+	// a lone memory operand, which is the only argument in
+	// parentheses or brackets.
+	if len(goIdx) != 1 {
+		return nil, nil
+	}
+	for i, arg := range args {
+		if strings.ContainsAny(arg, "([") {
+			idx = append(idx, i)
+		}
+	}
+	if len(idx) != 1 {
+		return nil, nil
+	}
+	return idx, goIdx
+}
+
+// reorderRPO returns disasms, which must be the disassembly of insts,
+// grouped by the basic blocks bbs in reverse postorder. This lays out
+// the function top to bottom so most jumps go forward and a block is
+// usually followed by the block it falls through to. Instructions
+// that aren't in any block, such as padding, come last in address
+// order.
+func reorderRPO(insts asm.Seq, bbs []*asm.BasicBlock, disasms []Disasm) []Disasm {
+	out := make([]Disasm, 0, len(disasms))
+	placed := make([]bool, len(disasms))
+	rpo := cfgRPO(bbs)
+	for k, id := range rpo {
+		b := bbs[id]
+		if b.Start == b.End {
+			continue
+		}
+		out = append(out, disasms[b.Start:b.End]...)
+		for i := b.Start; i < b.End; i++ {
+			placed[i] = true
+		}
+
+		// If the block falls through to a block that isn't
+		// next, say where it goes.
+		last := insts.Get(b.End - 1)
+		if b.End < len(disasms) && last.Control().FallsThrough() {
+			if k+1 == len(rpo) || bbs[rpo[k+1]].Start != b.End {
+				out[len(out)-1].FallsTo = disasms[b.End].PC
+			}
+		}
+	}
+	for i, ok := range placed {
+		if !ok {
+			out = append(out, disasms[i])
+		}
+	}
+	return out
+}
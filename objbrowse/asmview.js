@@ -30,22 +30,62 @@ class AsmView {
         const view = this;
         const insts = data.Insts;
 
+        // Create syntax selector. Changing the syntax reloads the
+        // page.
+        const syntax = $('<select class="asm-syntax">').appendTo(container);
+        for (let [name, label] of [["go", "Go"], ["gnu", "GNU (AT&T)"], ["intel", "Intel"]])
+            $("<option>").attr("value", name).text(label).appendTo(syntax);
+        syntax.val(data.Syntax);
+        syntax.change(() => {
+            const params = new URLSearchParams(window.location.search);
+            params.set("syntax", syntax.val());
+            window.location.search = params.toString();
+        });
+
+        // Create block order toggle. This only works on whole
+        // symbols. Changing it reloads the page.
+        if (!data.Partial) {
+            const rpo = $('<input type="checkbox">').prop("checked", !!data.RPO);
+            $('<label class="asm-order">').append(rpo).append(" Control-flow order").
+                attr("title", "Group instructions by basic block in reverse postorder").
+                appendTo(container);
+            rpo.change(() => {
+                const params = new URLSearchParams(window.location.search);
+                if (rpo.prop("checked"))
+                    params.set("order", "rpo");
+                else
+                    params.delete("order");
+                window.location.search = params.toString();
+            });
+        }
+
+        // If this is only part of the symbol, say so and link to
+        // the whole symbol.
+        if (data.Partial) {
+            const params = new URLSearchParams(window.location.search);
+            for (let p of ["pc", "start", "len"])
+                params.delete(p);
+            const note = $('<div class="asm-partial">').appendTo(container);
+            note.text("Showing 0x" + insts[0].PC + " to 0x" + data.LastPC + ". ");
+            $("<a>").attr("href", "?" + params.toString()).text("Show all").appendTo(note);
+        }
+
         // Create table.
         const table = $('<table class="disasm">').appendTo(container);
         this._table = table;
 
         // Create table header.
         const groupHeader = $("<thead>").appendTo(table).
-              append($('<td colspan="5">'));
+              append($('<td colspan="8">'));
         const header = $("<thead>").appendTo(table).
-              append($('<td colspan="5">'));
+              append($('<td colspan="8">'));
         const tableInfo = {table: table, groupHeader: groupHeader, header: header};
 
         // Create a zero-height TD at the top that will contain the
         // control flow arrows SVG.
         const arrowTD = $("<td>");
         $("<tr>").appendTo(table).
-            append($('<td colspan="4">')).
+            append($('<td colspan="7">')).
             append(arrowTD);
         var arrowSVG;
 
@@ -53,9 +93,10 @@ class AsmView {
         const rows = [];
         const pcToRow = new Map();
         const pcRanges = [];
-        const basePC = new AddrJS(insts[0].PC);
+        const basePC = new AddrJS(data.Base);
+        var prevSPAdj;
         for (var inst of insts) {
-            const args = AsmView._formatArgs(inst.Args);
+            const args = AsmView._formatArgs(inst.Args, inst.MemArgs, inst.Refs, inst.PC, data.Syntax);
             const pc = new AddrJS(inst.PC);
             const pcDelta = pc.sub(basePC);
             // Create the row. The last TD is to extend the highlight over
@@ -63,11 +104,26 @@ class AsmView {
             const row = $("<tr>").
                   append($("<td>").text("0x"+inst.PC).addClass("pos")).
                   append($("<td>").text("+0x"+pcDelta).addClass("pos")).
-                  append($("<td>").text(inst.Op).addClass("asm-inst")).
-                  append($("<td>").append(args).addClass("asm-inst")).
+                  append($("<td>").text(AsmView._formatBytes(inst.Bytes)).addClass("asm-bytes")).
+                  append(AsmView._formatLine(inst)).
+                  append(AsmView._formatSPAdj(inst, prevSPAdj)).
+                  append($("<td>").append(AsmView._formatOp(inst)).addClass("asm-inst").
+                         css("padding-left", inst.Inline ? (inst.Inline.length - 1) + "em" : "")).
+                  append($("<td>").append(args, AsmView._formatFollow(inst)).addClass("asm-inst")).
                   append($("<td>")); // Extend the highlight over the arrows SVG
             table.append(row);
 
+            // Mark loop nesting depth on the PC column.
+            const pcTD = row.children().first();
+            if (inst.LoopDepth)
+                pcTD.addClass("asm-loop" + Math.min(inst.LoopDepth, 4)).
+                    attr("title", "loop depth " + inst.LoopDepth);
+            if (inst.Irreducible)
+                pcTD.addClass("asm-irreducible").
+                    attr("title", "irreducible control flow");
+
+            prevSPAdj = inst.SPAdj;
+
             const rowMeta = {elt: row, i: rows.length, width: 1, arrows: []};
             rows.push(rowMeta);
             pcToRow.set(inst.PC, rowMeta);
@@ -79,19 +135,61 @@ class AsmView {
                     table.append($("<tr>").css({height: "1em"}));
             }
 
+            // In a reordered listing, say where execution
+            // continues if it isn't the next row.
+            if (inst.FallsTo) {
+                const to = new AddrJS(inst.FallsTo);
+                const link = $('<a href="#">').text("0x" + inst.FallsTo).click((ev) => {
+                    ev.preventDefault();
+                    highlightRanges([{start: to, end: to.add(new AddrJS(1))}], null);
+                });
+                table.append($("<tr>").append($("<td>"), $('<td colspan="6">').addClass("asm-fallsto").append("↓ continues at ", link)));
+                table.append($("<tr>").css({height: "1em"}));
+            }
+
             // On-click handler.
             row.click(() => {
                 highlightRanges([pcRanges[rowMeta.i]], view);
             });
+
+            // Show register effects on hover and mark the
+            // instructions that last wrote the registers this
+            // instruction reads and the instructions that read
+            // the registers it writes.
+            const effects = [];
+            if (inst.Reads)
+                effects.push("reads: " + inst.Reads.join(", "));
+            if (inst.Writes)
+                effects.push("writes: " + inst.Writes.join(", "));
+            if (effects.length > 0)
+                row.attr("title", effects.join("\n"));
+            const defs = inst.Defs, uses = inst.Uses;
+            if (defs || uses) {
+                row.hover(() => {
+                    for (let loc in defs)
+                        for (let pc of defs[loc])
+                            if (pcToRow.has(pc))
+                                pcToRow.get(pc).elt.addClass("asm-def");
+                    for (let loc in uses)
+                        for (let pc of uses[loc])
+                            if (pcToRow.has(pc))
+                                pcToRow.get(pc).elt.addClass("asm-use");
+                }, () => {
+                    $(".asm-def", table).removeClass("asm-def");
+                    $(".asm-use", table).removeClass("asm-use");
+                });
+            }
         }
         this._rows = rows;
 
-        // Complete the PC ranges.
-        for (let i = 1; i < pcRanges.length; i++) {
-            pcRanges[i-1].end = pcRanges[i].start;
+        // Complete the PC ranges. The rows may not be in address
+        // order, so this sorts them first.
+        const byPC = pcRanges.slice().sort((a, b) => a.start.compare(b.start));
+        for (let i = 1; i < byPC.length; i++) {
+            byPC[i-1].end = byPC[i].start;
         }
-        pcRanges[pcRanges.length-1].end = new AddrJS(data.LastPC);
-        this._pcs = new IntervalMap(pcRanges);
+        byPC[byPC.length-1].end = new AddrJS(data.LastPC);
+        this._pcs = new IntervalMap(byPC);
 
         // Collect control-flow arrows.
         const arrows = [];
@@ -99,6 +197,14 @@ class AsmView {
             if (inst.Control.Type == 0)
                 continue;
 
+            if (inst.Control.Targets) {
+                // Jump table. Draw an arrow to each target.
+                for (let pc of new Set(inst.Control.Targets))
+                    arrows.push({0: pcToRow.get(inst.PC),
+                                 1: pcToRow.get(pc),
+                                 pos: 0, control: inst.Control});
+                continue;
+            }
             arrows.push({0: pcToRow.get(inst.PC),
                          1: pcToRow.get(inst.Control.TargetPC),
                          pos: 0, control: inst.Control});
@@ -193,29 +299,164 @@ class AsmView {
         // Add liveness.
         if (data.Liveness)
             new LivenessOverlay(data.Liveness).render(tableInfo, this._pcs);
+        if (data.RegLiveness)
+            new RegLivenessOverlay(data.RegLiveness).render(tableInfo, this._pcs);
+
+        // Add user-supplied annotations.
+        if (data.Annotations)
+            new AnnotationOverlay(data.Annotations).render(tableInfo, this._pcs);
+
+        // Add profile samples. These use the same rendering as
+        // annotations.
+        if (data.Profile)
+            new AnnotationOverlay(data.Profile).render(tableInfo, this._pcs);
+    }
+
+    // _formatFollow returns buttons that follow the control flow of
+    // inst and leave a breadcrumb in the trail, or null if inst
+    // doesn't affect control flow.
+    static _formatFollow(inst) {
+        const c = inst.Control;
+        if (c.Type == 0)
+            return null;
+        const span = $("<span>").addClass("asm-follow");
+        const button = (text, title, action) => {
+            $("<span>").text(text).attr("title", title).appendTo(span).click((ev) => {
+                ev.stopPropagation();
+                trail.follow(new AddrJS(inst.PC), action);
+            });
+        };
+        switch (c.Type) {
+        case ControlCall:
+            button("⤷", "Step into call", "taken");
+            break;
+        case ControlRet:
+            button("⤶", "Return to caller", "taken");
+            break;
+        case ControlExit:
+            button("⤷", "Follow call", "taken");
+            break;
+        default:
+            button("⤷", "Follow jump", "taken");
+        }
+        if (c.FallthroughPC)
+            button("↓", c.Type == ControlCall ? "Step over call" : "Continue without jumping", "next");
+        return span;
+    }
+
+    // _formatBytes formats hex-encoded machine code as
+    // space-separated bytes.
+    static _formatBytes(hex) {
+        return hex.replace(/(..)(?!$)/g, "$1 ");
+    }
+
+    // _formatLine returns a TD showing the source position of inst.
+    // Instructions from inlined calls are labeled with the inlined
+    // function and show the inlining stack on hover.
+    static _formatLine(inst) {
+        const td = $("<td>").addClass("asm-line");
+        if (!inst.File)
+            return td;
+        const base = inst.File.substring(inst.File.lastIndexOf("/") + 1);
+        td.text(base + ":" + inst.Line).attr("title", inst.File + ":" + inst.Line);
+        if (inst.Inline) {
+            td.addClass("asm-inlined").text(inst.Inline[0].Func + " " + td.text());
+            td.attr("title", inst.Inline.map((f) => f.Func + " " + f.File + ":" + f.Line).join("\n"));
+        }
+        return td;
+    }
+
+    // _formatSPAdj returns a TD showing the stack frame size at
+    // inst, highlighting changes from the previous instruction.
+    static _formatSPAdj(inst, prev) {
+        const td = $("<td>").addClass("asm-spadj");
+        if (inst.SPAdj === undefined)
+            return td;
+        td.text(inst.SPAdj).attr("title", "frame size: " + inst.SPAdj);
+        if (prev !== undefined && prev != inst.SPAdj)
+            td.addClass("asm-spadj-change");
+        return td;
     }
 
-    static _formatArgs(args) {
+    // _formatOp formats an instruction's opcode, with its prefixes
+    // dimmed. LOCK prefixes are highlighted since they make the
+    // instruction atomic.
+    static _formatOp(inst) {
+        const elts = [];
+        if (inst.Prefix) {
+            const span = $("<span>").addClass("asm-prefix").text(inst.Prefix);
+            if ((inst.Prefixes || []).some((p) => p.Name == "LOCK" && !p.Implicit))
+                span.addClass("asm-lock");
+            elts.push(span[0], document.createTextNode(" "));
+        }
+        const op = $("<span>").text(inst.Op);
+        if (inst.Prefixes) {
+            const names = inst.Prefixes.map((p) => p.Implicit ? "(" + p.Name + ")" : p.Name);
+            op.attr("title", "prefixes: " + names.join(" "));
+        }
+        elts.push(op[0]);
+        return $(elts);
+    }
+
+    static _formatArgs(args, memArgs, refs, pc, syntax) {
         const elts = [];
         var i = 0;
         for (var arg of args) {
+            const argIndex = i;
             if (i++ > 0)
                 elts.push(document.createTextNode(", "));
 
+            const ref = refs && refs.find((ref) => ref.Arg == argIndex);
             var r;
-            if (r = /([^+]*)(\+(0x)?[0-9]+)?\(SB\)/.exec(arg)) {
+            if (ref && ref.Sym) {
+                // Link to the referenced symbol.
+                const offset = new AddrJS(ref.Offset);
+                const ranges = [{start: offset, end: offset.add(new AddrJS(1))}];
+                const url = "/s/" + ref.Sym + "#+" + formatRanges(ranges);
+                const a = $("<a>").attr("href", url).text(arg);
+                a.attr("title", "0x" + ref.Addr);
+                elts.push(a[0]);
+            } else if (r = /([^+]*)(\+(0x)?[0-9]+)?\(SB\)/.exec(arg)) {
                 const offset = parseInt(r[2]);
                 const ranges = [{start: new AddrJS(offset),
                                  end: new AddrJS(offset+1)}];
                 const url = "/s/" + r[1] + "#+" + formatRanges(ranges);
                 elts.push($("<a>").attr("href", url).text(arg)[0]);
+            } else if (memArgs && memArgs.includes(argIndex)) {
+                // Clicking a memory operand highlights all
+                // instructions that access the same location.
+                const span = $("<span>").addClass("asm-mem").text(arg);
+                span.attr("title", "Click to show accesses to this location");
+                span.click((ev) => {
+                    ev.stopPropagation();
+                    AsmView._highlightAccesses(pc, argIndex, syntax);
+                });
+                elts.push(span[0]);
+            } else if (ref) {
+                // Show the effective address of unresolved
+                // references.
+                elts.push($("<span>").attr("title", "0x" + ref.Addr).text(arg)[0]);
             } else {
                 elts.push(document.createTextNode(arg))
             }
         }
+
+        // Show what references point to, such as string contents.
+        const values = (refs || []).filter((ref) => ref.Value).map((ref) => ref.Value);
+        if (values.length > 0)
+            elts.push($("<span>").addClass("asm-ref-value").text("  // " + values.join(", "))[0]);
         return $(elts);
     }
 
+    static _highlightAccesses(pc, arg, syntax) {
+        $.getJSON("/api/memaccess", {sym: symName, pc: pc, arg: arg, syntax: syntax}, (res) => {
+            const ranges = [];
+            for (let a of res.Accesses)
+                ranges.push({start: new AddrJS(a.start), end: new AddrJS(a.end)});
+            highlightRanges(ranges, null);
+        });
+    }
+
     highlightRanges(ranges, scroll) {
         // Clear row highlights.
         $(".highlight", this._table).removeClass("highlight");
@@ -0,0 +1,73 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// symCacheSize is the number of symbol pages kept in the symbol
+// cache.
+const symCacheSize = 64
+
+// symCacheKey identifies a rendered symbol page.
+type symCacheKey struct {
+	name   string
+	addr   uint64
+	syntax string
+	win    AsmWindow
+	dce    bool
+	rpo    bool
+}
+
+// symCache is an LRU cache of rendered symbol pages. Since the binary
+// never changes, entries never need to be invalidated. It is safe
+// for concurrent use.
+type symCache struct {
+	mu    sync.Mutex
+	size  int
+	lru   list.List // Of *symCacheEntry, most recent first
+	elems map[symCacheKey]*list.Element
+}
+
+type symCacheEntry struct {
+	key  symCacheKey
+	info *SymInfo
+}
+
+func newSymCache(size int) *symCache {
+	return &symCache{size: size, elems: make(map[symCacheKey]*list.Element)}
+}
+
+// get returns the cached page for key, or nil if it isn't cached.
+func (c *symCache) get(key symCacheKey) *SymInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.elems[key]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*symCacheEntry).info
+}
+
+// put adds info to the cache under key, evicting the least recently
+// used page if the cache is full.
+func (c *symCache) put(key symCacheKey, info *SymInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.elems[key]; ok {
+		elem.Value.(*symCacheEntry).info = info
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.elems[key] = c.lru.PushFront(&symCacheEntry{key, info})
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.elems, oldest.Value.(*symCacheEntry).key)
+	}
+}
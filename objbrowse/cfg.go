@@ -0,0 +1,130 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/aclements/objbrowse/internal/asm"
+	"github.com/aclements/objbrowse/internal/obj"
+)
+
+// CFGJS is the control-flow graph of a function.
+type CFGJS struct {
+	Blocks []CFGBlockJS
+
+	// Loops are the natural loops of the function, outermost
+	// first.
+	Loops []asm.Loop
+}
+
+type CFGBlockJS struct {
+	ID int
+
+	// Start and End are the PC range of the block's
+	// instructions. They're omitted for an empty entry block.
+	Start AddrJS `json:",omitempty"`
+	End   AddrJS `json:",omitempty"`
+
+	Preds, Succs []int
+
+	// IDom is the ID of the block's immediate dominator, or -1
+	// for the entry block.
+	IDom int
+
+	// LoopDepth is the number of natural loops containing the
+	// block. Irreducible indicates the block is in a cycle that
+	// isn't a natural loop.
+	LoopDepth   int
+	Irreducible bool `json:",omitempty"`
+}
+
+// funcCFG returns the basic blocks of the text symbol sym. It returns
+// ctx.Err() if ctx is done first.
+func funcCFG(ctx context.Context, bin obj.Obj, sym obj.Sym) (asm.Seq, []*asm.BasicBlock, error) {
+	data, err := bin.SymbolData(sym)
+	if err != nil {
+		return nil, nil, err
+	}
+	insts, err := disasmSym(ctx, bin, sym, data, AsmWindow{})
+	if err != nil {
+		return nil, nil, err
+	}
+	bbs, err := asm.BasicBlocks(ctx, insts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return insts, bbs, nil
+}
+
+// cfgToJS converts the basic blocks bbs of insts to their JSON form.
+func cfgToJS(ctx context.Context, insts asm.Seq, bbs []*asm.BasicBlock) (CFGJS, error) {
+	idom, err := asm.Dominators(ctx, bbs)
+	if err != nil {
+		return CFGJS{}, err
+	}
+	loops, err := asm.Loops(ctx, bbs, idom)
+	if err != nil {
+		return CFGJS{}, err
+	}
+	out := CFGJS{Loops: loops.Loops}
+	if out.Loops == nil {
+		out.Loops = []asm.Loop{}
+	}
+	for _, b := range bbs {
+		bjs := CFGBlockJS{ID: b.ID, Preds: []int{}, Succs: []int{}, IDom: idom[b.ID], LoopDepth: loops.Depth[b.ID], Irreducible: loops.Irreducible[b.ID]}
+		if b.Start < b.End {
+			last := insts.Get(b.End - 1)
+			bjs.Start = AddrJS(insts.Get(b.Start).PC())
+			bjs.End = AddrJS(last.PC() + uint64(last.Len()))
+		}
+		for _, e := range b.Preds {
+			bjs.Preds = append(bjs.Preds, e.Block.ID)
+		}
+		for _, e := range b.Succs {
+			bjs.Succs = append(bjs.Succs, e.Block.ID)
+		}
+		out.Blocks = append(out.Blocks, bjs)
+	}
+	return out, nil
+}
+
+// httpCFG returns the control-flow graph, dominator tree, and loops of
+// the function named by the path after /api/cfg/ as JSON.
+func (s *state) httpCFG(w http.ResponseWriter, r *http.Request) {
+	sym, ok := s.symTab.Name(strings.TrimPrefix(r.URL.Path, "/api/cfg/"))
+	if !ok || sym.Kind != obj.SymText {
+		http.Error(w, "unknown text symbol", http.StatusNotFound)
+		return
+	}
+
+	insts, bbs, err := funcCFG(r.Context(), s.bin, sym)
+	if r.Context().Err() != nil {
+		// Timed out. The timeout handler has responded.
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	cfg, err := cfgToJS(r.Context(), insts, bbs)
+	if r.Context().Err() != nil {
+		// Timed out. The timeout handler has responded.
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
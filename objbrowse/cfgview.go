@@ -0,0 +1,208 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aclements/objbrowse/internal/asm"
+	"github.com/aclements/objbrowse/internal/obj"
+)
+
+// CFGView draws the control-flow graph of a function alongside its
+// disassembly.
+type CFGView struct {
+	fi *FileInfo
+}
+
+func NewCFGView(fi *FileInfo) *CFGView {
+	return &CFGView{fi}
+}
+
+// DecodeSym lays out the control-flow graph of sym. Like SSAView,
+// this only works on whole symbols, so it returns nil if win selects
+// part of sym.
+func (v *CFGView) DecodeSym(ctx context.Context, sym obj.Sym, win AsmWindow) (interface{}, error) {
+	if sym.Kind != obj.SymText || win != (AsmWindow{}) {
+		return nil, nil
+	}
+	insts, bbs, err := funcCFG(ctx, v.fi.Obj, sym)
+	if err != nil {
+		return nil, err
+	}
+	return cfgViewToJS(ctx, insts, bbs)
+}
+
+// CFGViewJS is a control-flow graph with a layered layout. Blocks
+// are assigned to layers so that, other than back edges, edges
+// usually point to a lower layer. Pos orders the blocks within a
+// layer.
+type CFGViewJS struct {
+	Blocks []CFGViewBlockJS
+	Edges  []CFGEdgeJS
+}
+
+type CFGViewBlockJS struct {
+	ID int
+
+	// Start and End are the PC range of the block's
+	// instructions. They're omitted for an empty entry block.
+	Start AddrJS `json:",omitempty"`
+	End   AddrJS `json:",omitempty"`
+
+	// Insts is the number of instructions in the block.
+	Insts int
+
+	LoopDepth int
+
+	Layer, Pos int
+}
+
+type CFGEdgeJS struct {
+	From, To int
+
+	// Kind is "fall" for an edge to the next instruction,
+	// "taken" for a jump, or "back" for a jump to a block that
+	// dominates From.
+	Kind string
+}
+
+func cfgViewToJS(ctx context.Context, insts asm.Seq, bbs []*asm.BasicBlock) (*CFGViewJS, error) {
+	idom, err := asm.Dominators(ctx, bbs)
+	if err != nil {
+		return nil, err
+	}
+	loops, err := asm.Loops(ctx, bbs, idom)
+	if err != nil {
+		return nil, err
+	}
+	dominates := func(a, b int) bool {
+		for ; b != -1; b = idom[b] {
+			if a == b {
+				return true
+			}
+		}
+		return false
+	}
+
+	out := &CFGViewJS{Edges: []CFGEdgeJS{}}
+	for _, b := range bbs {
+		bjs := CFGViewBlockJS{ID: b.ID, Insts: b.End - b.Start, LoopDepth: loops.Depth[b.ID]}
+		fallsThrough := true
+		if b.Start < b.End {
+			last := insts.Get(b.End - 1)
+			bjs.Start = AddrJS(insts.Get(b.Start).PC())
+			bjs.End = AddrJS(last.PC() + uint64(last.Len()))
+			fallsThrough = last.Control().FallsThrough()
+		}
+		out.Blocks = append(out.Blocks, bjs)
+
+		for _, e := range b.Succs {
+			kind := "taken"
+			if dominates(e.Block.ID, b.ID) {
+				kind = "back"
+			} else if fallsThrough && e.Block.Start == b.End {
+				kind = "fall"
+			}
+			out.Edges = append(out.Edges, CFGEdgeJS{b.ID, e.Block.ID, kind})
+		}
+	}
+
+	layoutCFG(bbs, out.Blocks)
+	return out, nil
+}
+
+// cfgRPO returns the IDs of bbs in reverse postorder. Where there's
+// a choice, a block's fall-through successor comes right after it.
+func cfgRPO(bbs []*asm.BasicBlock) []int {
+	rpo := make([]int, 0, len(bbs))
+	visited := make([]bool, len(bbs))
+	var visit func(b *asm.BasicBlock)
+	visit = func(b *asm.BasicBlock) {
+		visited[b.ID] = true
+		// Visit the fall-through successor last so it's
+		// first in reverse postorder.
+		var fall *asm.BasicBlock
+		for _, e := range b.Succs {
+			if e.Block.Start == b.End && b.Start < b.End {
+				fall = e.Block
+				continue
+			}
+			if !visited[e.Block.ID] {
+				visit(e.Block)
+			}
+		}
+		if fall != nil && !visited[fall.ID] {
+			visit(fall)
+		}
+		rpo = append(rpo, b.ID)
+	}
+	visit(bbs[0])
+	for i, j := 0, len(rpo)-1; i < j; i, j = i+1, j-1 {
+		rpo[i], rpo[j] = rpo[j], rpo[i]
+	}
+	return rpo
+}
+
+// layoutCFG assigns each block a Layer and Pos.
+//
+// Layers are the longest path from the entry block, ignoring edges
+// that go backward in reverse postorder. This includes all back
+// edges and breaks any irreducible cycles. Blocks within a layer are
+// ordered by the average position of their predecessors in earlier
+// layers, which tends to reduce edge crossings, and then by block ID,
+// which is in address order.
+func layoutCFG(bbs []*asm.BasicBlock, blocks []CFGViewBlockJS) {
+	rpo := cfgRPO(bbs)
+	rpoNum := make([]int, len(bbs))
+	for i, id := range rpo {
+		rpoNum[id] = i
+	}
+
+	// Assign layers.
+	var layers [][]int
+	for _, id := range rpo {
+		layer := 0
+		for _, e := range bbs[id].Preds {
+			if p := e.Block.ID; rpoNum[p] < rpoNum[id] && blocks[p].Layer+1 > layer {
+				layer = blocks[p].Layer + 1
+			}
+		}
+		blocks[id].Layer = layer
+		for len(layers) <= layer {
+			layers = append(layers, nil)
+		}
+		layers[layer] = append(layers[layer], id)
+	}
+
+	// Order blocks within each layer.
+	for _, layer := range layers {
+		bary := make(map[int]float64, len(layer))
+		for _, id := range layer {
+			sum, n := 0.0, 0
+			for _, e := range bbs[id].Preds {
+				if p := e.Block.ID; blocks[p].Layer < blocks[id].Layer {
+					sum += float64(blocks[p].Pos)
+					n++
+				}
+			}
+			if n > 0 {
+				bary[id] = sum / float64(n)
+			}
+		}
+		sort.Slice(layer, func(i, j int) bool {
+			bi, bj := bary[layer[i]], bary[layer[j]]
+			if bi != bj {
+				return bi < bj
+			}
+			// Break ties by address order.
+			return layer[i] < layer[j]
+		})
+		for pos, id := range layer {
+			blocks[id].Pos = pos
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+"use strict";
+
+class CFGView {
+    constructor(data, container) {
+        this._container = container;
+        const view = this;
+
+        const boxWidth = 120, boxHeight = 34;
+        const hGap = 24, vGap = 36;
+        const margin = 8;
+        const markerHeight = 8;
+        // backGap is the space to the right of the graph for
+        // routing edges that go up.
+        const backGap = 48;
+
+        // Compute block positions. Each layer is centered.
+        const layerLen = [];
+        for (let b of data.Blocks) {
+            while (layerLen.length <= b.Layer)
+                layerLen.push(0);
+            layerLen[b.Layer]++;
+        }
+        const maxLen = Math.max(...layerLen);
+        const graphWidth = maxLen * boxWidth + (maxLen - 1) * hGap;
+        const pos = new Map();
+        for (let b of data.Blocks) {
+            const rowWidth = layerLen[b.Layer] * boxWidth + (layerLen[b.Layer] - 1) * hGap;
+            pos.set(b.ID, {
+                x: margin + (graphWidth - rowWidth) / 2 + b.Pos * (boxWidth + hGap),
+                y: margin + b.Layer * (boxHeight + vGap),
+            });
+        }
+
+        const svgNS = "http://www.w3.org/2000/svg";
+        const svg = $(document.createElementNS(svgNS, "svg")).attr({
+            class: "cfg",
+            width: 2 * margin + graphWidth + backGap,
+            height: 2 * margin + layerLen.length * boxHeight + (layerLen.length - 1) * vGap,
+        }).appendTo(container);
+        this._svg = svg;
+
+        // Draw edges first so blocks are on top.
+        let backIndex = 0;
+        for (let e of data.Edges) {
+            const from = pos.get(e.From), to = pos.get(e.To);
+            const path = $(document.createElementNS(svgNS, "path")).addClass("cfg-" + e.Kind);
+            if (to.y > from.y) {
+                // Downward edge from the bottom of From to the top
+                // of To.
+                const x1 = from.x + boxWidth / 2, y1 = from.y + boxHeight;
+                const x2 = to.x + boxWidth / 2, y2 = to.y - markerHeight;
+                const dy = (y2 - y1) / 2;
+                path.attr("d", "M " + x1 + " " + y1 + " C " + x1 + " " + (y1 + dy) + " " + x2 + " " + (y2 - dy) + " " + x2 + " " + y2);
+            } else {
+                // Upward (or sideways) edge. Route it around the
+                // right side of the graph, staggering each edge so
+                // they don't overlap.
+                const x1 = from.x + boxWidth, y1 = from.y + boxHeight / 2;
+                const x2 = to.x + boxWidth + markerHeight, y2 = to.y + boxHeight / 2;
+                const xr = margin + graphWidth + 8 + (backIndex++ % 4) * (backGap - 8) / 4;
+                path.attr("d", "M " + x1 + " " + y1 + " C " + xr + " " + y1 + " " + xr + " " + y2 + " " + x2 + " " + y2);
+            }
+            path.attr({fill: "none", "marker-end": "url(#tri)"});
+            path.append($(document.createElementNS(svgNS, "title")).text("b" + e.From + " → b" + e.To + " (" + e.Kind + ")"));
+            svg.append(path);
+        }
+
+        // Draw blocks. Clicking a block highlights its
+        // instructions.
+        const ranges = [];
+        for (let b of data.Blocks) {
+            const p = pos.get(b.ID);
+            const g = $(document.createElementNS(svgNS, "g")).addClass("cfg-block").appendTo(svg);
+            const rect = $(document.createElementNS(svgNS, "rect")).attr({
+                x: p.x, y: p.y, width: boxWidth, height: boxHeight, rx: 3,
+            }).appendTo(g);
+            if (b.LoopDepth > 0)
+                rect.css("fill", "hsl(45, 100%, " + Math.max(95 - 8 * b.LoopDepth, 60) + "%)");
+            const label = $(document.createElementNS(svgNS, "text")).attr({
+                x: p.x + 4, y: p.y + 14,
+            }).text("b" + b.ID + (b.Insts > 0 ? " (" + b.Insts + " inst" + (b.Insts == 1 ? "" : "s") + ")" : "")).appendTo(g);
+            if (b.Start) {
+                $(document.createElementNS(svgNS, "text")).attr({
+                    x: p.x + 4, y: p.y + 28,
+                }).addClass("pos").text("0x" + b.Start).appendTo(g);
+
+                const r = {start: new AddrJS(b.Start), end: new AddrJS(b.End), g: g};
+                ranges.push(r);
+                g.click(() => {
+                    highlightRanges([{start: r.start, end: r.end}], view);
+                });
+            }
+        }
+        this._ranges = new IntervalMap(ranges);
+    }
+
+    highlightRanges(ranges, scroll) {
+        // Clear highlights.
+        $(".cfg-block.highlight", this._svg).removeClass("highlight");
+
+        // New highlights.
+        let first = true;
+        for (let match of this._ranges.intersect(ranges)) {
+            match.g.addClass("highlight");
+            if (first && scroll)
+                scrollTo(this._container, match.g);
+            first = false;
+        }
+    }
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"html/template"
+	"net/http"
+	"sync"
+
+	"github.com/aclements/objbrowse/internal/symtab"
+)
+
+// DiffJS compares the symbols of the -base binary with this binary.
+type DiffJS struct {
+	Base, New string
+
+	// BaseSize and NewSize are the total sizes of all symbols
+	// in each binary.
+	BaseSize, NewSize uint64
+
+	// Syms are the symbols that were added, removed, or changed
+	// in size, sorted by decreasing absolute size change.
+	Syms []SymDiffJS
+}
+
+type SymDiffJS struct {
+	Name string
+	Kind string
+
+	// Status is "added", "removed", or "changed".
+	Status string
+
+	BaseSize, NewSize uint64
+	Delta             int64
+}
+
+// symDiff is the lazily-computed comparison with the -base binary.
+type symDiff struct {
+	once sync.Once
+	diff *DiffJS
+}
+
+// symDiff returns the comparison of the -base binary with this
+// binary, or nil if there's no -base binary.
+func (s *state) symDiff() *DiffJS {
+	if s.base == nil {
+		return nil
+	}
+	d := &s.diff
+	d.once.Do(func() {
+		out := &DiffJS{Base: *flagBase, New: flag.Arg(0), Syms: []SymDiffJS{}}
+		out.BaseSize = totalSize(s.base)
+		out.NewSize = totalSize(s.symTab)
+		for _, sd := range symtab.Diff(s.base, s.symTab) {
+			status := "changed"
+			if !sd.InOld {
+				status = "added"
+			} else if !sd.InNew {
+				status = "removed"
+			}
+			out.Syms = append(out.Syms, SymDiffJS{sd.Name, string(sd.Kind), status, sd.OldSize, sd.NewSize, sd.Delta()})
+		}
+		d.diff = out
+	})
+	return d.diff
+}
+
+// totalSize returns the total size of the symbols in t, omitting
+// aliases.
+func totalSize(t *symtab.Table) uint64 {
+	var size uint64
+	for _, sym := range t.Syms() {
+		size += sym.Size
+	}
+	return size
+}
+
+// httpDiff returns the comparison with the -base binary as JSON.
+func (s *state) httpDiff(w http.ResponseWriter, r *http.Request) {
+	diff := s.symDiff()
+	if diff == nil {
+		http.Error(w, "no -base binary", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// httpDiffPage shows the symbols that changed between the -base
+// binary and this binary.
+func (s *state) httpDiffPage(w http.ResponseWriter, r *http.Request) {
+	diff := s.symDiff()
+	if diff == nil {
+		http.Error(w, "no -base binary", http.StatusNotFound)
+		return
+	}
+	if err := tmplDiff.Execute(w, diff); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+var tmplDiff = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Symbol size changes</title>
+<link rel="stylesheet" type="text/css" href="/objbrowse.css" />
+</head>
+<body>
+<h1>Symbol size changes</h1>
+<script src="https://code.jquery.com/jquery-3.3.1.min.js"></script>
+<script src="/diffview.js"></script>
+<script>new DiffView({{$}}, document.body)</script>
+</body>
+</html>
+`))
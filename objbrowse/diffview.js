@@ -0,0 +1,55 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+"use strict";
+
+// DiffView lists the symbols that were added, removed, or changed in
+// size between the -base binary and this binary.
+class DiffView {
+    constructor(data, container) {
+        const delta = data.NewSize - data.BaseSize;
+        let pct = "";
+        if (data.BaseSize > 0)
+            pct = " (" + DiffView._sign(delta) + (100 * delta / data.BaseSize).toFixed(2) + "%)";
+        const counts = {added: 0, removed: 0, changed: 0};
+        for (let s of data.Syms)
+            counts[s.Status]++;
+
+        const summary = $("<table>").addClass("diff-summary").appendTo(container);
+        summary.append($("<tr>").append($("<th>").text("Base"), $("<td>").text(data.Base), $("<td>").addClass("diff-size").text(DiffView._bytes(data.BaseSize))));
+        summary.append($("<tr>").append($("<th>").text("New"), $("<td>").text(data.New), $("<td>").addClass("diff-size").text(DiffView._bytes(data.NewSize))));
+        summary.append($("<tr>").append($("<th>").text("Change"), $("<td>").text(counts.added + " added, " + counts.removed + " removed, " + counts.changed + " changed"), $("<td>").addClass("diff-size").text(DiffView._sign(delta) + DiffView._bytes(delta) + pct)));
+
+        if (data.Syms.length == 0) {
+            $("<p>").text("No symbols changed.").appendTo(container);
+            return;
+        }
+
+        const table = $("<table>").addClass("diff").appendTo(container);
+        table.append($("<tr>").append(
+            $("<th>").text("Delta"), $("<th>").text("Base"), $("<th>").text("New"),
+            $("<th>").text("Kind"), $("<th>").text("Name")));
+        for (let s of data.Syms) {
+            let name = s.Name;
+            if (s.Status != "removed")
+                name = $("<a>").attr("href", "/s/" + s.Name).text(s.Name);
+            $("<tr>").addClass("diff-" + s.Status).append(
+                $("<td>").addClass("diff-size").text(DiffView._sign(s.Delta) + DiffView._bytes(s.Delta)),
+                $("<td>").addClass("diff-size").text(s.Status == "added" ? "" : DiffView._bytes(s.BaseSize)),
+                $("<td>").addClass("diff-size").text(s.Status == "removed" ? "" : DiffView._bytes(s.NewSize)),
+                $("<td>").text(s.Kind),
+                $("<td>").append(name)
+            ).appendTo(table);
+        }
+    }
+
+    static _sign(n) {
+        return n > 0 ? "+" : n < 0 ? "-" : "";
+    }
+
+    // _bytes formats the magnitude of n with digit grouping.
+    static _bytes(n) {
+        return Math.abs(n).toLocaleString("en-US");
+    }
+}
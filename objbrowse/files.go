@@ -0,0 +1,182 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"debug/dwarf"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FilesJS lists the source files and compilation units of a binary.
+type FilesJS struct {
+	// Files are the source files referenced by the binary,
+	// sorted by path.
+	Files []SourceFileJS
+
+	// CUs are the compilation units of the binary. Go binaries
+	// record these in both the function table and DWARF.
+	CUs []CompUnitJS
+}
+
+type SourceFileJS struct {
+	Path string
+
+	// Funcs are the names of the Go functions whose entry points
+	// are in this file.
+	Funcs []string `json:",omitempty"`
+}
+
+type CompUnitJS struct {
+	// Name is the name of the compilation unit, if known.
+	Name string `json:",omitempty"`
+
+	// Source is where this compilation unit was found: "functab"
+	// or "dwarf".
+	Source string
+
+	// Files are the indexes in FilesJS.Files of this
+	// compilation unit's files.
+	Files []int
+}
+
+// fileList is the lazily-computed list of source files of a binary.
+type fileList struct {
+	once  sync.Once
+	files FilesJS
+}
+
+// sourceFiles returns the source files and compilation units of the
+// binary from both the function table and DWARF. Files that appear
+// in both are listed once.
+func (s *state) sourceFiles() *FilesJS {
+	l := &s.fileList
+	l.once.Do(func() {
+		l.files = collectFiles(s.fi)
+	})
+	return &l.files
+}
+
+func collectFiles(fi *FileInfo) FilesJS {
+	index := make(map[string]int)
+	var out FilesJS
+	add := func(file string) int {
+		if !strings.HasPrefix(file, "<") {
+			// Leave names like "<autogenerated>" alone.
+			file = path.Clean(file)
+		}
+		i, ok := index[file]
+		if !ok {
+			i = len(out.Files)
+			index[file] = i
+			out.Files = append(out.Files, SourceFileJS{Path: file})
+		}
+		return i
+	}
+
+	if ft := fi.FuncTab; ft != nil {
+		for _, cu := range ft.CompUnits() {
+			cuJS := CompUnitJS{Source: "functab"}
+			for _, file := range cu.Files {
+				cuJS.Files = append(cuJS.Files, add(file))
+			}
+			out.CUs = append(out.CUs, cuJS)
+		}
+		for _, fn := range ft.Funcs {
+			if file, _, ok := fn.SourceLine(fn.PC); ok {
+				f := &out.Files[add(file)]
+				f.Funcs = append(f.Funcs, fn.Name)
+			}
+		}
+	}
+
+	if dw, err := fi.Obj.DWARF(); err == nil {
+		dr := dw.Reader()
+		for {
+			ent, err := dr.Next()
+			if ent == nil || err != nil {
+				break
+			}
+			if ent.Tag != dwarf.TagCompileUnit && ent.Tag != dwarf.TagSkeletonUnit {
+				dr.SkipChildren()
+				continue
+			}
+			dr.SkipChildren()
+			lr, err := dw.LineReader(ent)
+			if err != nil || lr == nil {
+				continue
+			}
+			name, _ := ent.Val(dwarf.AttrName).(string)
+			cuJS := CompUnitJS{Name: name, Source: "dwarf"}
+			for _, file := range lr.Files() {
+				// Entry 0 is nil before DWARF 5.
+				if file != nil {
+					cuJS.Files = append(cuJS.Files, add(file.Name))
+				}
+			}
+			out.CUs = append(out.CUs, cuJS)
+		}
+	}
+
+	// Sort files by path and renumber the compilation units.
+	order := make([]int, len(out.Files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return out.Files[order[i]].Path < out.Files[order[j]].Path
+	})
+	newIndex := make([]int, len(order))
+	files := make([]SourceFileJS, len(order))
+	for i, old := range order {
+		newIndex[old] = i
+		files[i] = out.Files[old]
+	}
+	out.Files = files
+	for _, cu := range out.CUs {
+		for i, old := range cu.Files {
+			cu.Files[i] = newIndex[old]
+		}
+	}
+	return out
+}
+
+// httpFiles returns the source files and compilation units of the
+// binary as JSON.
+func (s *state) httpFiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.sourceFiles()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// httpFilesPage shows a tree of the binary's source files.
+func (s *state) httpFilesPage(w http.ResponseWriter, r *http.Request) {
+	if err := tmplFiles.Execute(w, s.sourceFiles()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+var tmplFiles = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Source files</title>
+<link rel="stylesheet" type="text/css" href="/objbrowse.css" />
+</head>
+<body>
+<h1>Source files</h1>
+<script src="https://code.jquery.com/jquery-3.3.1.min.js"></script>
+<script src="/fileview.js"></script>
+<script>new FileView({{$}}, document.body)</script>
+</body>
+</html>
+`))
@@ -0,0 +1,55 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+"use strict";
+
+// FileView shows the source files of a binary as a directory tree.
+// Each file lists the functions defined in it.
+class FileView {
+    constructor(data, container) {
+        // Build the directory tree. Each node maps a path
+        // component to a child node, and files are leaves.
+        const root = {dirs: new Map(), files: []};
+        for (let file of data.Files) {
+            const parts = file.Path.split("/");
+            let node = root;
+            for (let part of parts.slice(0, -1)) {
+                if (part === "")
+                    part = "/";
+                if (!node.dirs.has(part))
+                    node.dirs.set(part, {dirs: new Map(), files: []});
+                node = node.dirs.get(part);
+            }
+            node.files.push({name: parts[parts.length - 1], file: file});
+        }
+
+        const tree = $("<div>").addClass("fileview").appendTo(container);
+        this._render(root, tree);
+    }
+
+    _render(node, container) {
+        // Collapse chains of directories with only one child.
+        for (let [name, dir] of [...node.dirs.entries()].sort()) {
+            while (dir.files.length == 0 && dir.dirs.size == 1) {
+                const [sub, child] = dir.dirs.entries().next().value;
+                name = name == "/" ? "/" + sub : name + "/" + sub;
+                dir = child;
+            }
+            const details = $("<details>").appendTo(container);
+            $("<summary>").addClass("fileview-dir").text(name + "/").appendTo(details);
+            this._render(dir, details);
+        }
+        for (let {name, file} of node.files) {
+            if (!file.Funcs) {
+                $("<div>").addClass("fileview-file").text(name).appendTo(container);
+                continue;
+            }
+            const details = $("<details>").appendTo(container);
+            $("<summary>").addClass("fileview-file").text(name).appendTo(details);
+            const ul = $("<ul>").appendTo(details);
+            for (let fn of file.Funcs)
+                $("<li>").append($("<a>").attr("href", "/s/" + fn).text(fn)).appendTo(ul);
+        }
+    }
+}
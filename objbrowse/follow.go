@@ -0,0 +1,202 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/aclements/objbrowse/internal/asm"
+	"github.com/aclements/objbrowse/internal/obj"
+)
+
+// FollowJS is the result of following the control flow of one
+// instruction. The frontend uses this to walk execution paths,
+// keeping a trail of the blocks visited and a stack of calls.
+type FollowJS struct {
+	// PC is the instruction that was followed and Block is the PC
+	// range of its basic block.
+	PC    AddrJS
+	Block RangeJS
+
+	// Action is the edge that was followed: "taken" for the
+	// target of a jump or call, or "next" for the following
+	// instruction.
+	Action string
+
+	// Targets are where execution goes. This usually has one
+	// element, but may have several for a jump table and none
+	// for a return or a jump to an unknown target.
+	Targets []FollowTargetJS
+
+	// Return is the return address of a followed call. The
+	// frontend pushes this on its call stack.
+	Return *FollowTargetJS `json:",omitempty"`
+
+	// Ret indicates the instruction is a return. The frontend
+	// pops its call stack to find where it goes.
+	Ret bool `json:",omitempty"`
+}
+
+type FollowTargetJS struct {
+	PC AddrJS
+
+	// Sym and Offset give the symbol containing PC, if any.
+	Sym    string `json:",omitempty"`
+	Offset AddrJS `json:",omitempty"`
+
+	// Block is the PC range of the basic block containing PC,
+	// if PC is in a text symbol.
+	Block *RangeJS `json:",omitempty"`
+}
+
+type RangeJS struct {
+	Start, End AddrJS
+}
+
+// httpFollow follows the control flow of the instruction at the "pc"
+// query parameter, in hex. The "action" parameter is "taken" to
+// follow the jump or call, "next" to continue to the next
+// instruction, or "" to follow the jump or call if there is one.
+func (s *state) httpFollow(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	pc, err := strconv.ParseUint(q.Get("pc"), 16, 64)
+	if err != nil {
+		http.Error(w, "bad pc", http.StatusBadRequest)
+		return
+	}
+	action := q.Get("action")
+	if action != "" && action != "taken" && action != "next" {
+		http.Error(w, "bad action: must be taken or next", http.StatusBadRequest)
+		return
+	}
+	sym, ok := s.symTab.Addr(pc)
+	if !ok || sym.Kind != obj.SymText {
+		http.Error(w, fmt.Sprintf("no text symbol at %#x", pc), http.StatusNotFound)
+		return
+	}
+
+	insts, bbs, err := funcCFG(r.Context(), s.bin, sym)
+	if r.Context().Err() != nil {
+		// Timed out. The timeout handler has responded.
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	i := sort.Search(insts.Len(), func(i int) bool {
+		return insts.Get(i).PC() >= pc
+	})
+	if i == insts.Len() || insts.Get(i).PC() != pc {
+		http.Error(w, fmt.Sprintf("no instruction at %#x", pc), http.StatusNotFound)
+		return
+	}
+	inst := insts.Get(i)
+	control := inst.Control()
+	next := pc + uint64(inst.Len())
+
+	out := FollowJS{PC: AddrJS(pc), Action: action, Targets: []FollowTargetJS{}}
+	if b := blockAt(insts, bbs, i); b != nil {
+		out.Block = *b
+	}
+	if action == "" {
+		out.Action = "next"
+		switch control.Type {
+		case asm.ControlJump, asm.ControlCall, asm.ControlRet, asm.ControlJumpUnknown, asm.ControlExit:
+			out.Action = "taken"
+		}
+	}
+
+	if out.Action == "next" {
+		if !control.FallsThrough() {
+			http.Error(w, fmt.Sprintf("instruction at %#x doesn't continue to the next instruction", pc), http.StatusBadRequest)
+			return
+		}
+		out.Targets = append(out.Targets, s.followTarget(r.Context(), next, sym, insts, bbs))
+	} else {
+		switch control.Type {
+		case asm.ControlJump, asm.ControlJumpUnknown:
+			// The targets of an indirect jump are known
+			// if it's through a jump table.
+			targets := control.Targets
+			if control.TargetPC != 0 {
+				targets = []uint64{control.TargetPC}
+			}
+			seen := make(map[uint64]bool)
+			for _, t := range targets {
+				if !seen[t] {
+					seen[t] = true
+					out.Targets = append(out.Targets, s.followTarget(r.Context(), t, sym, insts, bbs))
+				}
+			}
+		case asm.ControlCall, asm.ControlExit:
+			if control.TargetPC != 0 {
+				out.Targets = append(out.Targets, s.followTarget(r.Context(), control.TargetPC, sym, insts, bbs))
+			}
+			if control.Type == asm.ControlCall {
+				ret := s.followTarget(r.Context(), next, sym, insts, bbs)
+				out.Return = &ret
+			}
+		case asm.ControlRet:
+			out.Ret = true
+		default:
+			http.Error(w, fmt.Sprintf("instruction at %#x doesn't jump", pc), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// followTarget describes the target pc of a control-flow edge from a
+// function with instructions insts, basic blocks bbs, and symbol sym.
+// If pc is in another function, its blocks are computed as needed.
+func (s *state) followTarget(ctx context.Context, pc uint64, sym obj.Sym, insts asm.Seq, bbs []*asm.BasicBlock) FollowTargetJS {
+	out := FollowTargetJS{PC: AddrJS(pc)}
+	tsym, ok := s.symTab.Addr(pc)
+	if !ok {
+		return out
+	}
+	out.Sym, out.Offset = tsym.Name, AddrJS(pc-tsym.Value)
+	if tsym.Kind != obj.SymText {
+		return out
+	}
+	if tsym.Value != sym.Value {
+		var err error
+		insts, bbs, err = funcCFG(ctx, s.bin, tsym)
+		if err != nil {
+			return out
+		}
+	}
+	i := sort.Search(insts.Len(), func(i int) bool {
+		return insts.Get(i).PC() >= pc
+	})
+	if i < insts.Len() && insts.Get(i).PC() == pc {
+		out.Block = blockAt(insts, bbs, i)
+	}
+	return out
+}
+
+// blockAt returns the PC range of the basic block containing
+// instruction i of insts, or nil if it isn't in a block, such as
+// unreachable padding.
+func blockAt(insts asm.Seq, bbs []*asm.BasicBlock, i int) *RangeJS {
+	for _, b := range bbs {
+		if b.Start <= i && i < b.End {
+			last := insts.Get(b.End - 1)
+			return &RangeJS{AddrJS(insts.Get(b.Start).PC()), AddrJS(last.PC() + uint64(last.Len()))}
+		}
+	}
+	return nil
+}
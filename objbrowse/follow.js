@@ -0,0 +1,150 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+"use strict";
+
+// Trail walks execution paths through the disassembly. Following a
+// jump or call leaves a breadcrumb of the instructions from where
+// execution arrived to the followed instruction, and following a
+// call pushes its return address so a later return can go back.
+//
+// The trail is kept in session storage so it survives following
+// control flow into other symbols.
+class Trail {
+    constructor(container) {
+        this._div = $("<div>").addClass("trail").prependTo(container);
+        this._load();
+        this._render();
+    }
+
+    _load() {
+        const saved = JSON.parse(window.sessionStorage.getItem("objbrowse-trail") || "null");
+        // crumbs are {sym, base, start, end} with hex string
+        // addresses. stack is the FollowTargetJS of each return
+        // address. cursor is where execution last arrived, in
+        // cursorSym.
+        this._crumbs = saved ? saved.crumbs : [];
+        this._stack = saved ? saved.stack : [];
+        this._cursor = saved && saved.cursor ? new AddrJS(saved.cursor) : null;
+        this._cursorSym = saved ? saved.cursorSym : null;
+    }
+
+    _save(cursor, cursorSym) {
+        this._cursor = cursor;
+        this._cursorSym = cursorSym;
+        window.sessionStorage.setItem("objbrowse-trail", JSON.stringify({
+            crumbs: this._crumbs, stack: this._stack,
+            cursor: cursor ? cursor.toString() : null, cursorSym: cursorSym,
+        }));
+    }
+
+    _render() {
+        const div = this._div.empty();
+        if (this._crumbs.length == 0 && this._stack.length == 0) {
+            div.hide();
+            return;
+        }
+        div.show().append("Trail: ");
+        this._crumbs.forEach((c, i) => {
+            if (i > 0)
+                div.append(" → ");
+            const link = $('<a href="#">').text(c.sym + "+0x" + new AddrJS(c.start).sub(new AddrJS(c.base)).toString());
+            link.attr("title", "Back up to here");
+            link.click((ev) => {
+                ev.preventDefault();
+                this._crumbs.splice(i);
+                this._goto(c.sym, new AddrJS(c.start), new AddrJS(c.end));
+            });
+            div.append(link);
+        });
+        if (this._stack.length > 0)
+            div.append($("<span>").addClass("trail-stack").text(" (call depth " + this._stack.length + ")"));
+        div.append(" ", $('<a href="#">').text("clear").click((ev) => {
+            ev.preventDefault();
+            this._crumbs = [];
+            this._stack = [];
+            this._save(null, null);
+            this._render();
+        }));
+    }
+
+    // follow follows the control flow of the instruction at pc.
+    // action is "taken" or "next".
+    follow(pc, action) {
+        $.getJSON("/api/follow", {pc: pc.toString(), action: action}).
+            done((res) => this._followed(res)).
+            fail((xhr) => this._error(xhr.responseText));
+    }
+
+    _followed(res) {
+        // Leave a breadcrumb from where execution arrived in this
+        // block to the followed instruction.
+        const pc = new AddrJS(res.PC);
+        let start = new AddrJS(res.Block.Start);
+        const cursor = this._cursorSym === symName ? this._cursor : null;
+        if (cursor && start.compare(cursor) <= 0 && cursor.compare(pc) <= 0)
+            start = cursor;
+        this._crumbs.push({sym: symName, base: baseAddr.toString(), start: start.toString(), end: pc.add(new AddrJS(1)).toString()});
+
+        if (res.Return)
+            this._stack.push(res.Return);
+        if (res.Ret) {
+            if (this._stack.length == 0) {
+                this._error("Return with an empty call stack");
+                return;
+            }
+            this._gotoTarget(this._stack.pop());
+            return;
+        }
+        if (res.Targets.length == 0) {
+            this._error("Unknown jump target");
+            return;
+        }
+        if (res.Targets.length == 1) {
+            this._gotoTarget(res.Targets[0]);
+            return;
+        }
+
+        // Jump table. Let the user pick.
+        this._render();
+        const choose = $("<div>").text("Jump table: ").appendTo(this._div);
+        for (let t of res.Targets) {
+            choose.append(" ", $('<a href="#">').text("+0x" + t.Offset).click((ev) => {
+                ev.preventDefault();
+                this._gotoTarget(t);
+            }));
+        }
+    }
+
+    _gotoTarget(t) {
+        const start = new AddrJS(t.PC);
+        let end = start.add(new AddrJS(1));
+        if (t.Block)
+            end = new AddrJS(t.Block.End);
+        this._goto(t.Sym, start, end);
+    }
+
+    // _goto highlights [start, end) in sym, loading sym's page if
+    // it's not this page.
+    _goto(sym, start, end) {
+        const hash = "#" + formatRanges([{start: start, end: end}]);
+        if (sym === symName) {
+            this._save(start, sym);
+            this._render();
+            highlightRanges([{start: start, end: end}], null);
+            return;
+        }
+        if (!sym) {
+            this._error("No symbol at 0x" + start.toString());
+            return;
+        }
+        this._save(start, sym);
+        window.location = "/s/" + sym + hash;
+    }
+
+    _error(msg) {
+        this._render();
+        $("<div>").addClass("error").text(msg).appendTo(this._div.show());
+    }
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aclements/objbrowse/internal/asm"
+	"github.com/aclements/objbrowse/internal/obj"
+	"github.com/aclements/objbrowse/internal/symtab"
+)
+
+// FuncView shows the raw runtime _func structure of a Go function.
+// This is the authoritative low-level record of the function, so it's
+// useful for debugging pclntab decoding.
+type FuncView struct {
+	fi     *FileInfo
+	symTab *symtab.Table
+}
+
+func NewFuncView(fi *FileInfo, symTab *symtab.Table) *FuncView {
+	return &FuncView{fi, symTab}
+}
+
+type FuncViewJS struct {
+	// Badges are notable properties of the function, such as
+	// "NOSPLIT" or "SPWRITE".
+	Badges []string `json:",omitempty"`
+
+	Fields []FuncViewField
+}
+
+type FuncViewField struct {
+	Name  string
+	Value string
+}
+
+func (v *FuncView) DecodeSym(sym obj.Sym) (interface{}, error) {
+	if sym.Kind != obj.SymText {
+		return nil, nil
+	}
+	fn := v.fi.funcForPC(sym.Value)
+	if fn == nil {
+		return nil, nil
+	}
+
+	var info FuncViewJS
+	add := func(name, format string, args ...interface{}) {
+		info.Fields = append(info.Fields, FuncViewField{name, fmt.Sprintf(format, args...)})
+	}
+	raw := &fn.Raw
+	add("entry", "%#x", raw.Entry)
+	add("nameoff", "%#x", raw.NameOff)
+	add("args", "%#x", raw.Args)
+	add("deferreturn", "%#x", raw.DeferReturn)
+	add("pcsp", "%#x", raw.PCSP)
+	add("pcfile", "%#x", raw.PCFile)
+	add("pcln", "%#x", raw.PCLn)
+	add("npcdata", "%d", raw.NPCData)
+	add("funcID", "%d", raw.FuncID)
+	add("flag", "%#x (%s)", raw.Flag, fn.Flags())
+	add("nfuncdata", "%d", raw.NFuncData)
+	for i, off := range raw.PCData {
+		add(fmt.Sprintf("pcdata[%d]", i), "%#x", off)
+	}
+	for i, ptr := range raw.FuncData {
+		name, base := v.symTab.SymName(ptr)
+		if name == "" {
+			add(fmt.Sprintf("funcdata[%d]", i), "%#x", ptr)
+		} else {
+			add(fmt.Sprintf("funcdata[%d]", i), "%#x <%s+%#x>", ptr, name, ptr-base)
+		}
+	}
+
+	if v.isNosplit(sym) {
+		info.Badges = append(info.Badges, "NOSPLIT")
+	}
+	if fn.WritesSP() {
+		info.Badges = append(info.Badges, "SPWRITE")
+	}
+	if fn.IsTopFrame() {
+		info.Badges = append(info.Badges, "TOPFRAME")
+	}
+	if fn.IsAsm() {
+		info.Badges = append(info.Badges, "ASM")
+	}
+	return info, nil
+}
+
+// isNosplit returns whether the function sym never grows its stack,
+// either because it's marked NOSPLIT or because the compiler
+// determined it doesn't need a stack check. This isn't recorded in
+// the function table, so we look for a call to runtime.morestack.
+func (v *FuncView) isNosplit(sym obj.Sym) bool {
+	data, err := v.fi.Obj.SymbolData(sym)
+	if err != nil {
+		return false
+	}
+	insts, err := asm.Disasm(v.fi.Obj.Info().Arch, data, sym.Value)
+	if err != nil {
+		return false
+	}
+	for i := 0; i < insts.Len(); i++ {
+		c := insts.Get(i).Control()
+		if c.Type != asm.ControlCall && c.Type != asm.ControlJump ||
+			sym.Value <= c.TargetPC && c.TargetPC < sym.Value+sym.Size {
+			continue
+		}
+		if name, _ := v.symTab.SymName(c.TargetPC); strings.HasPrefix(name, "runtime.morestack") {
+			return false
+		}
+	}
+	return true
+}
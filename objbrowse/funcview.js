@@ -0,0 +1,28 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+"use strict";
+
+class FuncView {
+    constructor(data, container) {
+        if (data.Badges) {
+            const badges = $('<div>').addClass('fv-badges').appendTo(container);
+            for (let badge of data.Badges)
+                $('<span>').addClass('fv-badge').text(badge).appendTo(badges);
+        }
+        const table = $('<table class="fv">').appendTo(container);
+        table.append($('<tr>').append($('<th colspan="2">').addClass('fv-title').text("_func")));
+        for (let field of data.Fields) {
+            table.append($('<tr>').append(
+                $('<td>').addClass('fv-name').text(field.Name)
+            ).append(
+                $('<td>').addClass('fv-val').text(field.Value)
+            ));
+        }
+    }
+
+    highlightRanges(ranges, scroll) {
+        // Nothing in this view is address-based.
+    }
+}
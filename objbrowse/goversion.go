@@ -0,0 +1,91 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aclements/objbrowse/internal/obj"
+	"github.com/aclements/objbrowse/internal/symtab"
+)
+
+// GoVersion is the Go release a binary was built with.
+type GoVersion struct {
+	// Major and Minor are the release numbers; for example,
+	// "go1.14" is 1, 14. If the version is unknown, both are 0.
+	Major, Minor int
+
+	// Raw is the full version string, such as "go1.14.2" or
+	// "devel +abcdef".
+	Raw string
+}
+
+// Known reports whether the Go version of the binary was determined.
+func (v GoVersion) Known() bool {
+	return v.Major != 0
+}
+
+// AtLeast reports whether v is a known version at least major.minor.
+func (v GoVersion) AtLeast(major, minor int) bool {
+	if !v.Known() {
+		return false
+	}
+	return v.Major > major || v.Major == major && v.Minor >= minor
+}
+
+func (v GoVersion) String() string {
+	if v.Raw == "" {
+		return "unknown"
+	}
+	return v.Raw
+}
+
+// readGoVersion returns the Go version recorded in
+// runtime.buildVersion.
+func readGoVersion(bin obj.Obj, symTab *symtab.Table) (GoVersion, error) {
+	a := bin.Info().Arch
+	if a == nil {
+		return GoVersion{}, fmt.Errorf("unknown architecture")
+	}
+	sym, ok := symTab.Name("runtime.buildVersion")
+	if !ok {
+		return GoVersion{}, fmt.Errorf("no runtime.buildVersion symbol")
+	}
+	// buildVersion is a string header.
+	hdr, err := bin.Data(sym.Value, uint64(2*a.PtrSize))
+	if err != nil {
+		return GoVersion{}, err
+	}
+	if len(hdr) < 2*a.PtrSize {
+		return GoVersion{}, fmt.Errorf("runtime.buildVersion is truncated")
+	}
+	ptr, n := a.Ptr(hdr), a.Ptr(hdr[a.PtrSize:])
+	if n > 1024 {
+		return GoVersion{}, fmt.Errorf("runtime.buildVersion is too long")
+	}
+	str, err := bin.Data(ptr, n)
+	if err != nil {
+		return GoVersion{}, err
+	}
+	return parseGoVersion(string(str)), nil
+}
+
+// parseGoVersion parses a Go version string. If the string doesn't
+// contain a release number, it returns an unknown version with just
+// Raw set.
+func parseGoVersion(s string) GoVersion {
+	v := GoVersion{Raw: s}
+	i := strings.Index(s, "go1.")
+	if i < 0 {
+		return v
+	}
+	var minor int
+	if _, err := fmt.Sscanf(s[i+len("go1."):], "%d", &minor); err != nil {
+		return v
+	}
+	v.Major, v.Minor = 1, minor
+	return v
+}
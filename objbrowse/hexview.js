@@ -42,10 +42,18 @@ class HexView {
             tdPos.setAttribute("class", "pos");
             tdPos.textContent = "0x" + rowAddr;
             tr.appendChild(tdPos);
+            const tdOff = document.createElement("td");
+            tdOff.setAttribute("class", "pos");
+            tdOff.textContent = "+0x" + off.toString(16);
+            tr.appendChild(tdOff);
             const tdData = document.createElement("td");
             tdData.setAttribute("class", "hv-data");
             tdData.textContent = this._formatLine(off);
             tr.appendChild(tdData);
+            const tdASCII = document.createElement("td");
+            tdASCII.setAttribute("class", "hv-ascii");
+            tdASCII.textContent = this._formatASCII(off);
+            tr.appendChild(tdASCII);
 
             const startAddr = rowAddr;
             const nextAddr = this._addr.add(new AddrJS(off + 16));
@@ -75,6 +83,19 @@ class HexView {
         return line;
     }
 
+    // _formatASCII returns the printable ASCII representation of
+    // one line of data, starting at offset "start". Other bytes are
+    // shown as ".".
+    _formatASCII(start) {
+        const dataLen = this._data.length / 2;
+        let line = "";
+        for (let i = 0; i < 16 && start + i < dataLen; i++) {
+            const b = parseInt(this._data.substr((start+i) * 2, 2), 16);
+            line += (b >= 0x20 && b < 0x7f) ? String.fromCharCode(b) : ".";
+        }
+        return line;
+    }
+
     // _makeOffsets string offsets of each byte in a formatted line.
     _makeOffsets() {
         const offsets = [];
@@ -123,6 +144,25 @@ class HexView {
         }
     }
 
+    // _highlightASCII highlights the characters in ASCII TD "td"
+    // according to the boolean vector "marks". The data in TD must
+    // start at "start".
+    _highlightASCII(td, start, marks) {
+        const line = this._formatASCII(start);
+        td.textContent = "";    // Clear TD
+        for (let i = 0, j = 0; i < line.length; i = j) {
+            for (j = i + 1; j < line.length && marks[i] == marks[j]; j++) {}
+            if (marks[i]) {
+                const span = document.createElement("span");
+                span.setAttribute("class", "highlight");
+                span.textContent = line.substring(i, j);
+                td.appendChild(span);
+            } else {
+                td.appendChild(document.createTextNode(line.substring(i, j)));
+            }
+        }
+    }
+
     highlightRanges(ranges, scroll) {
         // Clear highlights.
         $(".highlight", this._lazyTable.tableElt).removeClass("highlight");
@@ -136,7 +176,10 @@ class HexView {
         const marks = [];
         const view = this;
         function getDataTD(tr) {
-            return tr.childNodes[1]
+            return tr.childNodes[2]
+        }
+        function getASCIITD(tr) {
+            return tr.childNodes[3]
         }
         function openLine(newLine) {
             if (markLine === newLine)
@@ -146,8 +189,8 @@ class HexView {
                 const tr = view._lazyTable.getRow(markLine);
                 if (firstTR === undefined)
                     firstTR = tr;
-                const td = getDataTD(tr);
-                view._highlightTD(td, markLine * 16, marks);
+                view._highlightTD(getDataTD(tr), markLine * 16, marks);
+                view._highlightASCII(getASCIITD(tr), markLine * 16, marks);
             }
             // Clear marks
             for (let i = 0; i < 16; i++)
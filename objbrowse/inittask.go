@@ -0,0 +1,258 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/aclements/objbrowse/internal/arch"
+	"github.com/aclements/objbrowse/internal/symtab"
+)
+
+// An InitTask is a package's initialization task.
+type InitTask struct {
+	// Name is the name of the inittask symbol, such as
+	// "fmt..inittask".
+	Name string
+	Addr uint64
+
+	// Funcs are the init functions of this task, in the order
+	// they run.
+	Funcs []InitFunc
+}
+
+type InitFunc struct {
+	PC   uint64
+	Name string
+}
+
+// Package returns the import path of the package this task
+// initializes.
+func (t *InitTask) Package() string {
+	return strings.TrimSuffix(t.Name, "..inittask")
+}
+
+// initOrder returns the initialization tasks of a Go binary in the
+// order the runtime runs them.
+func initOrder(fi *FileInfo, symTab *symtab.Table) ([]*InitTask, error) {
+	ver := fi.GoVersion
+	a := fi.Obj.Info().Arch
+	if a == nil {
+		return nil, fmt.Errorf("unknown architecture")
+	}
+	r := &initReader{fi, symTab, a}
+	switch {
+	case ver.AtLeast(1, 21):
+		return r.orderLinker()
+	case ver.AtLeast(1, 13):
+		return r.orderDeps()
+	}
+	return nil, fmt.Errorf("init order not supported for Go version %s", ver)
+}
+
+type initReader struct {
+	fi     *FileInfo
+	symTab *symtab.Table
+	arch   *arch.Arch
+}
+
+func (r *initReader) data(addr, size uint64) ([]byte, error) {
+	data, err := r.fi.Obj.Data(addr, size)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) < size {
+		return nil, fmt.Errorf("data at %#x is truncated", addr)
+	}
+	return data, nil
+}
+
+// ptrs reads n pointers starting at addr.
+func (r *initReader) ptrs(addr, n uint64) ([]uint64, error) {
+	p := uint64(r.arch.PtrSize)
+	if n > 1<<20 {
+		return nil, fmt.Errorf("too many pointers at %#x", addr)
+	}
+	data, err := r.data(addr, n*p)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]uint64, n)
+	for i := range out {
+		out[i] = r.arch.Ptr(data[uint64(i)*p:])
+	}
+	return out, nil
+}
+
+func (r *initReader) newTask(addr uint64, pcs []uint64) *InitTask {
+	name, _ := r.symTab.SymName(addr)
+	if name == "" {
+		name = fmt.Sprintf("%#x", addr)
+	}
+	t := &InitTask{Name: name, Addr: addr}
+	for _, pc := range pcs {
+		fname, _ := r.symTab.SymName(pc)
+		t.Funcs = append(t.Funcs, InitFunc{pc, fname})
+	}
+	return t
+}
+
+// orderLinker reads the init order of Go 1.21 and later binaries. In
+// these, the linker computes the initialization order and records
+// it in runtime.runtime_inittasks (for the runtime) and
+// go:main.inittasks (for everything else). Each task is
+//
+//	struct { state, nfns uint32; fns [nfns]uintptr }
+func (r *initReader) orderLinker() ([]*InitTask, error) {
+	var tasks []uint64
+	if sym, ok := r.symTab.Name("runtime.runtime_inittasks"); ok {
+		// This is a slice header.
+		hdr, err := r.ptrs(sym.Value, 2)
+		if err != nil {
+			return nil, err
+		}
+		ts, err := r.ptrs(hdr[0], hdr[1])
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, ts...)
+	}
+	sym, ok := r.symTab.Name("go:main.inittasks")
+	if !ok {
+		return nil, fmt.Errorf("no go:main.inittasks symbol")
+	}
+	// This is an array.
+	ts, err := r.ptrs(sym.Value, sym.Size/uint64(r.arch.PtrSize))
+	if err != nil {
+		return nil, err
+	}
+	if sym.SizeSynthesized {
+		// The array length is a guess (e.g., Mach-O doesn't
+		// record sizes), so stop at the first pointer that
+		// isn't to an inittask.
+		for i, t := range ts {
+			if name, _ := r.symTab.SymName(t); !strings.HasSuffix(name, "..inittask") {
+				ts = ts[:i]
+				break
+			}
+		}
+	}
+	tasks = append(tasks, ts...)
+
+	var out []*InitTask
+	seen := make(map[uint64]bool)
+	for _, addr := range tasks {
+		// The runtime's tasks may also appear in the main
+		// list, but each task only runs once.
+		if seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		hdr, err := r.data(addr, 8)
+		if err != nil {
+			return nil, err
+		}
+		nfns := r.arch.Uint32(hdr[4:])
+		pcs, err := r.ptrs(addr+8, uint64(nfns))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r.newTask(addr, pcs))
+	}
+	return out, nil
+}
+
+// orderDeps reads the init order of Go 1.13 through 1.20 binaries. In
+// these, the runtime walks a dependency graph of tasks rooted at
+// runtime..inittask and then main..inittask, running each task's
+// dependencies before its own functions. Each task is
+//
+//	struct { state, ndeps, nfns uintptr; deps [ndeps]*initTask; fns [nfns]uintptr }
+func (r *initReader) orderDeps() ([]*InitTask, error) {
+	var out []*InitTask
+	seen := make(map[uint64]bool)
+	var visit func(addr uint64) error
+	visit = func(addr uint64) error {
+		if seen[addr] {
+			return nil
+		}
+		seen[addr] = true
+		hdr, err := r.ptrs(addr, 3)
+		if err != nil {
+			return err
+		}
+		ndeps, nfns := hdr[1], hdr[2]
+		p := uint64(r.arch.PtrSize)
+		deps, err := r.ptrs(addr+3*p, ndeps)
+		if err != nil {
+			return err
+		}
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		pcs, err := r.ptrs(addr+(3+ndeps)*p, nfns)
+		if err != nil {
+			return err
+		}
+		out = append(out, r.newTask(addr, pcs))
+		return nil
+	}
+
+	for _, root := range []string{"runtime..inittask", "main..inittask"} {
+		sym, ok := r.symTab.Name(root)
+		if !ok {
+			return nil, fmt.Errorf("no %s symbol", root)
+		}
+		if err := visit(sym.Value); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+type InitInfo struct {
+	Tasks []*InitTask
+	Error string
+}
+
+func (s *state) httpInit(w http.ResponseWriter, r *http.Request) {
+	var info InitInfo
+	tasks, err := initOrder(s.fi, s.symTab)
+	if err != nil {
+		info.Error = err.Error()
+	}
+	info.Tasks = tasks
+
+	if err := tmplInit.Execute(w, info); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+var tmplInit = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Initialization order</title>
+<link rel="stylesheet" type="text/css" href="/objbrowse.css" />
+</head>
+<body>
+<h1>Initialization order</h1>
+{{if .Error}}<p class="init-error">Unable to determine initialization order: {{.Error}}</p>{{end}}
+<table class="init">
+<thead><tr><th>#</th><th>Package</th><th>Init functions</th></tr></thead>
+{{range $i, $t := .Tasks}}
+<tr><td>{{$i}}</td><td><a href="/s/{{$t.Name}}">{{$t.Package}}</a></td><td>
+{{- range $t.Funcs}}{{if .Name}}<a href="/s/{{.Name}}">{{.Name}}</a>{{else}}{{printf "%#x" .PC}}{{end}}<br>{{end -}}
+</td></tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
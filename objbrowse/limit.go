@@ -0,0 +1,26 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "net/http"
+
+// maxRequestBody is the maximum size of an HTTP request body.
+const maxRequestBody = 1 << 20
+
+// limit wraps h so that a single request can't monopolize the server.
+// It limits the size of the request body and the duration of the
+// request. If the request takes longer than -timeout, the client
+// receives a 503 and the request's context is canceled so any
+// analysis in progress can stop.
+func limit(h http.HandlerFunc) http.Handler {
+	body := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+		h(w, r)
+	})
+	if *flagTimeout <= 0 {
+		return body
+	}
+	return http.TimeoutHandler(body, *flagTimeout, "request timed out")
+}
@@ -5,38 +5,21 @@
 package main
 
 import (
-	"log"
+	"fmt"
 
 	"github.com/aclements/objbrowse/internal/asm"
 	"github.com/aclements/objbrowse/internal/functab"
 	"github.com/aclements/objbrowse/internal/obj"
+	"github.com/aclements/objbrowse/internal/ssa"
 	"github.com/aclements/objbrowse/internal/symtab"
 )
 
 type LivenessOverlay struct {
-	fi       *FileInfo
-	pcToFunc map[uint64]*functab.Func
+	fi *FileInfo
 }
 
 func NewLivenessOverlay(fi *FileInfo, symTab *symtab.Table) *LivenessOverlay {
-	// Collect function info.
-	pcToFunc := make(map[uint64]*functab.Func)
-	pclntab, ok := symTab.Name("runtime.pclntab")
-	if ok {
-		data, err := fi.Obj.SymbolData(pclntab)
-		if err != nil {
-			log.Fatal(err)
-		}
-		funcTab, err := functab.NewFuncTab(data, fi.Obj)
-		if err != nil {
-			log.Fatal(err)
-		}
-		for _, fn := range funcTab.Funcs {
-			pcToFunc[fn.PC] = fn
-		}
-	}
-
-	return &LivenessOverlay{fi, pcToFunc}
+	return &LivenessOverlay{fi}
 }
 
 type LivenessJS struct {
@@ -74,7 +57,7 @@ func pcTableToJS(t functab.PCTable) []LivenessRangeJS {
 }
 
 func (o *LivenessOverlay) liveness(sym obj.Sym, insts asm.Seq) (interface{}, error) {
-	fn := o.pcToFunc[sym.Value]
+	fn := o.fi.funcForPC(sym.Value)
 	if fn == nil {
 		return nil, nil
 	}
@@ -82,6 +65,9 @@ func (o *LivenessOverlay) liveness(sym obj.Sym, insts asm.Seq) (interface{}, err
 	// TODO: Perhaps more of this knowledge should be in functab.
 	var l LivenessJS
 	arch := o.fi.Obj.Info().Arch
+	if arch == nil {
+		return nil, fmt.Errorf("unknown architecture")
+	}
 	l.PtrSize = arch.PtrSize
 	l.VarpDelta = -l.PtrSize
 	l.ArgpDelta = arch.MinFrameSize
@@ -108,3 +94,28 @@ func (o *LivenessOverlay) liveness(sym obj.Sym, insts asm.Seq) (interface{}, err
 
 	return l, nil
 }
+
+// RegLivenessJS is the set of registers that hold live values on
+// entry to an instruction.
+type RegLivenessJS struct {
+	Start AddrJS   `json:"start"`
+	End   AddrJS   `json:"end"`
+	Regs  []string `json:"regs"`
+}
+
+// regLiveness computes the registers that hold live SSA values at
+// each instruction of f. Memory isn't a register, so it's omitted.
+func regLiveness(f *ssa.Func) []RegLivenessJS {
+	var out []RegLivenessJS
+	for i, locs := range f.LiveLocs() {
+		inst := f.Seq.Get(i)
+		r := RegLivenessJS{Start: AddrJS(inst.PC()), End: AddrJS(inst.PC() + uint64(inst.Len())), Regs: []string{}}
+		for _, loc := range locs.Ordered() {
+			if loc != asm.LocMem {
+				r.Regs = append(r.Regs, loc.String())
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
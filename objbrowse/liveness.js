@@ -76,10 +76,10 @@ class LivenessOverlay {
 
         // Create table header.
         if (liveMin < liveMax)
-            $(table.groupHeader).append($("<th>").text("locals").addClass("flag").attr("colspan", (liveMax - liveMin) / ptrSize));
+            $(table.groupHeader).append($("<th>").text("locals (stack map)").addClass("flag").attr("colspan", (liveMax - liveMin) / ptrSize));
         if (this._haveArgs) {
             $(table.groupHeader).append($("<th>"));
-            $(table.groupHeader).append($("<th>").text("args").addClass("flag").attr("colspan", (argMax - argMin) / ptrSize));
+            $(table.groupHeader).append($("<th>").text("args (stack map)").addClass("flag").attr("colspan", (argMax - argMin) / ptrSize));
         }
 
         for (let i = liveMin; i < liveMax; i += ptrSize)
@@ -125,6 +125,32 @@ class LivenessOverlay {
     }
 }
 
+// RegLivenessOverlay shows the registers that hold live values at
+// each instruction, as derived from the SSA form.
+class RegLivenessOverlay {
+    constructor(info) {
+        for (let r of info) {
+            r.start = new AddrJS(r.start);
+            r.end = new AddrJS(r.end);
+        }
+        this._map = new IntervalMap(info);
+    }
+
+    // render adds register liveness to a table. See
+    // LivenessOverlay.render.
+    render(table, rowMap) {
+        $(table.groupHeader).append($("<th>").text("registers (SSA)"));
+        $(table.header).append($("<th>").text("live"));
+
+        for (let r of rowMap.ranges) {
+            const regs = [];
+            for (let l of this._map.intersect([r]))
+                regs.push(...l.regs);
+            $(r.tr).append($("<td>").text(regs.join(" ")).addClass("asm-live-regs"));
+        }
+    }
+}
+
 class Bitmap {
     constructor(nbits, bytes) {
         this.n = nbits;
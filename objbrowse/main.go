@@ -6,37 +6,43 @@ package main
 
 import (
 	"bytes"
+	"debug/dwarf"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"html/template"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
 	"strconv"
+	"time"
 
+	"github.com/aclements/objbrowse/internal/asm"
+	"github.com/aclements/objbrowse/internal/functab"
 	"github.com/aclements/objbrowse/internal/obj"
 	"github.com/aclements/objbrowse/internal/symtab"
 )
 
 var (
-	httpFlag   = flag.String("http", "localhost:0", "HTTP service address (e.g., ':6060')")
-	flagStatic = flag.String("static", defaultStatic(), "`path` to static files")
+	httpFlag     = flag.String("http", "localhost:0", "HTTP service address (e.g., ':6060')")
+	flagStatic   = flag.String("static", "", "serve static files from `path` instead of the copies built into the binary")
+	flagDemangle = flag.Bool("demangle", false, "display demangled C++ symbol names")
+	flagNM       = flag.Bool("nm", false, "print the symbol table in nm format and exit")
+	flagNMSort   = flag.Bool("n", false, "with -nm, sort symbols numerically by address")
+	flagOverlay  = flag.String("overlay", "", "load instruction annotations from JSON `file`")
+	flagPprof    = flag.String("pprof", "", "overlay samples from pprof profile `file`")
+	flagPprofMap = flag.String("pprof-mapping", "", "with -pprof, use the profile mapping whose file name contains `substr` (default matches build ID or name)")
+	flagTimeout  = flag.Duration("timeout", time.Minute, "maximum `duration` of a single HTTP request")
+	flagArch     = flag.String("arch", "", "for universal binaries, the `GOARCH` to browse (default host architecture)")
+	flagDebug    = flag.String("debug", "", "read symbols and DWARF from separate debug `file` (default follows .gnu_debuglink)")
+	flagDWP      = flag.String("dwp", "", "read split DWARF from DWARF package `file` (default objfile.dwp or .dwo files)")
+	flagExe      = flag.String("exe", "", "if objfile is a core dump, read symbols from executable `file`")
+	flagSyntax   = flag.String("syntax", "go", "default assembly `syntax`: go, gnu (AT&T on x86), or intel")
+	flagBase     = flag.String("base", "", "compare symbol sizes with those of base `objfile` at /diff")
 )
 
-func defaultStatic() string {
-	path, err := os.Executable()
-	if err != nil {
-		return ""
-	}
-	path2, err := filepath.EvalSymlinks(path)
-	if err != nil {
-		path2 = path
-	}
-	return filepath.Dir(path2)
-}
-
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [flags] objfile\n", os.Args[0])
@@ -47,11 +53,16 @@ func main() {
 		flag.Usage()
 		os.Exit(2)
 	}
-	if *flagStatic == "" {
-		fmt.Fprintf(os.Stderr, "Unable to find static resources.\nPlease provide -static flag.\n")
+	if *flagNM {
+		if err := writeNM(os.Stdout, openBin(), *flagNMSort); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if _, err := asm.ParseSyntax(*flagSyntax); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(2)
 	}
-
 	state := open()
 	state.serve()
 }
@@ -59,27 +70,143 @@ func main() {
 type state struct {
 	bin    obj.Obj
 	symTab *symtab.Table
+	fi     *FileInfo
 
 	symView    *SymView
 	hexView    *HexView
 	asmView    *AsmView
 	sourceView *SourceView
+	funcView   *FuncView
+	typeView   *TypeView
+	ssaView    *SSAView
+	cfgView    *CFGView
+	relocView  *RelocView
+
+	// symCache caches rendered symbol pages.
+	symCache *symCache
+
+	// fileList is the list of source files, computed on first
+	// use.
+	fileList fileList
+
+	// symTree is the prefix tree of symbol names, computed on
+	// first use.
+	symTree symTree
+
+	// base is the symbol table of the -base binary, or nil.
+	// diff is its comparison with symTab, computed on first use.
+	base *symtab.Table
+	diff symDiff
 }
 
 type FileInfo struct {
 	Obj obj.Obj
+
+	// GoVersion is the Go version this binary was built with,
+	// if known.
+	GoVersion GoVersion
+
+	// Stripped indicates this binary appears to have been
+	// stripped of its symbol table. In this case, the symbol
+	// table has a symbol for each section instead.
+	Stripped bool
+
+	// BuildID is the build ID of this binary, or "" if it
+	// doesn't have one.
+	BuildID string
+
+	// FuncTab is the decoded Go function table, or nil if this
+	// binary doesn't have one.
+	FuncTab *functab.FuncTab
+
+	// SplitDWARF is the DWARF data split out of the binary
+	// with -gsplit-dwarf, if any. There's one *dwarf.Data for
+	// each split unit or .dwo file.
+	SplitDWARF []*dwarf.Data
 }
 
-func open() *state {
-	f, err := os.Open(flag.Arg(0))
+// funcForPC returns the Go function containing pc, or nil if there
+// isn't one.
+func (fi *FileInfo) funcForPC(pc uint64) *functab.Func {
+	if fi.FuncTab == nil {
+		return nil
+	}
+	return fi.FuncTab.FuncForPC(pc)
+}
+
+func newFileInfo(bin obj.Obj, symTab *symtab.Table) *FileInfo {
+	fi := &FileInfo{Obj: bin}
+
+	if _, ok := symTab.Name("runtime.buildVersion"); ok {
+		v, err := readGoVersion(bin, symTab)
+		if err != nil {
+			log.Printf("reading Go version: %v", err)
+		}
+		fi.GoVersion = v
+	}
+
+	if id, err := bin.BuildID(); err == nil {
+		fi.BuildID = id
+	} else if err != obj.ErrNoBuildID {
+		log.Printf("reading build ID: %v", err)
+	}
+
+	// Collect function info.
+	pclntab, ok := symTab.Name("runtime.pclntab")
+	if !ok {
+		return fi
+	}
+	data, err := bin.SymbolData(pclntab)
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("reading runtime.pclntab: %v", err)
+		return fi
+	}
+	funcTab, err := functab.NewFuncTab(data, bin)
+	if err != nil {
+		log.Printf("decoding runtime.pclntab: %v", err)
+		return fi
+	}
+	fi.FuncTab = funcTab
+	return fi
+}
+
+func openBin() obj.Obj {
+	if *flagExe != "" {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		bin, err := obj.OpenCore(f, openObj(*flagExe))
+		if err != nil {
+			log.Fatal(err)
+		}
+		return bin
 	}
+	return openObj(flag.Arg(0))
+}
 
-	bin, err := obj.Open(f)
+// openObj opens the object file at path, along with its separate
+// debug file, if any.
+func openObj(path string) obj.Obj {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bin, err := obj.OpenArch(f, *flagArch)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := obj.LoadDebugLink(bin, path, *flagDebug); err != nil {
+		if *flagDebug != "" {
+			log.Fatal(err)
+		}
+		log.Printf("loading separate debug info: %v", err)
+	}
+	return bin
+}
+
+func open() *state {
+	bin := openBin()
 
 	syms, err := bin.Symbols()
 	if err != nil {
@@ -89,13 +216,61 @@ func open() *state {
 	symTab := symtab.NewTable(syms)
 
 	// TODO: Do something with the error.
-	fi := &FileInfo{bin}
+	fi := newFileInfo(bin, symTab)
+	if fi.FuncTab == nil && !hasText(syms) {
+		log.Printf("warning: %s appears to be stripped; showing sections instead of symbols", flag.Arg(0))
+		sectSyms, err := obj.SectionSyms(bin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		syms = append(syms, sectSyms...)
+		symTab = symtab.NewTable(syms)
+		fi.Stripped = true
+	}
 	symView := NewSymView(fi, symTab)
 	hexView := NewHexView(fi)
-	asmView, _ := NewAsmView(fi, symTab)
-	sourceView, _ := NewSourceView(fi)
+	var annotations *AnnotationOverlay
+	if *flagOverlay != "" {
+		annotations, err = LoadAnnotationOverlay(*flagOverlay, symTab)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	var prof *ProfileOverlay
+	if *flagPprof != "" {
+		prof, err = LoadProfileOverlay(*flagPprof, *flagPprofMap, flag.Arg(0), fi)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	asmView, _ := NewAsmView(fi, symTab, annotations, prof)
+	sourceView, _ := NewSourceView(fi, prof)
+	funcView := NewFuncView(fi, symTab)
+	typeView := NewTypeView(fi, symTab)
+	ssaView := NewSSAView(fi, symTab)
+	cfgView := NewCFGView(fi)
+	relocView := NewRelocView(fi)
+
+	var base *symtab.Table
+	if *flagBase != "" {
+		baseSyms, err := openObj(*flagBase).Symbols()
+		if err != nil {
+			log.Fatalf("%s: %v", *flagBase, err)
+		}
+		base = symtab.NewTable(baseSyms)
+	}
 
-	return &state{bin, symTab, symView, hexView, asmView, sourceView}
+	return &state{bin, symTab, fi, symView, hexView, asmView, sourceView, funcView, typeView, ssaView, cfgView, relocView, newSymCache(symCacheSize), fileList{}, symTree{}, base, symDiff{}}
+}
+
+// hasText returns whether syms contains any text symbols.
+func hasText(syms []obj.Sym) bool {
+	for _, s := range syms {
+		if s.Kind == obj.SymText {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *state) serve() {
@@ -103,8 +278,8 @@ func (s *state) serve() {
 	if err != nil {
 		log.Fatalf("failed to create server socket: %v", err)
 	}
-	http.HandleFunc("/", s.httpMain)
-	fs := http.FileServer(http.Dir(*flagStatic))
+	http.Handle("/", limit(s.httpMain))
+	fs := http.FileServer(staticFS())
 	http.Handle("/objbrowse.css", fs)
 	http.Handle("/objbrowse.js", fs)
 	http.Handle("/symview.js", fs)
@@ -112,7 +287,31 @@ func (s *state) serve() {
 	http.Handle("/asmview.js", fs)
 	http.Handle("/sourceview.js", fs)
 	http.Handle("/liveness.js", fs)
-	http.HandleFunc("/s/", s.httpSym)
+	http.Handle("/annotate.js", fs)
+	http.Handle("/funcview.js", fs)
+	http.Handle("/typeview.js", fs)
+	http.Handle("/fileview.js", fs)
+	http.Handle("/symtree.js", fs)
+	http.Handle("/diffview.js", fs)
+	http.Handle("/ssaview.js", fs)
+	http.Handle("/cfgview.js", fs)
+	http.Handle("/follow.js", fs)
+	http.Handle("/relocview.js", fs)
+	http.Handle("/s/", limit(s.httpSym))
+	http.Handle("/api/sym/", limit(s.httpSymAPI))
+	http.Handle("/api/syms", limit(s.httpSyms))
+	http.Handle("/api/memaccess", limit(s.httpMemAccess))
+	http.Handle("/api/ssa/", limit(s.httpSSA))
+	http.Handle("/api/cfg/", limit(s.httpCFG))
+	http.Handle("/api/follow", limit(s.httpFollow))
+	http.Handle("/nm", limit(s.httpNM))
+	http.Handle("/init", limit(s.httpInit))
+	http.Handle("/files", limit(s.httpFilesPage))
+	http.Handle("/api/files", limit(s.httpFiles))
+	http.Handle("/tree", limit(s.httpSymTreePage))
+	http.Handle("/api/symtree", limit(s.httpSymTree))
+	http.Handle("/diff", limit(s.httpDiffPage))
+	http.Handle("/api/diff", limit(s.httpDiff))
 	addr := "http://" + ln.Addr().String()
 	fmt.Printf("Listening on %s\n", addr)
 	err = http.Serve(ln, nil)
@@ -120,21 +319,37 @@ func (s *state) serve() {
 }
 
 type SymsInfo struct {
+	BuildID  string `json:",omitempty"`
+	Stripped bool   `json:",omitempty"`
+
+	// HasBase indicates there's a -base binary to compare with.
+	HasBase bool `json:",omitempty"`
+
 	SymView interface{} `json:",omitempty"`
 }
 
 func (s *state) httpMain(w http.ResponseWriter, r *http.Request) {
-	// TODO: More nm-like information (type and maybe value)
-	// TODO: Make hierarchical on "."?
-	// TODO: Filter by symbol type.
-	// TODO: Option to demangle (do hierarchy splitting before demangling)
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
 		return
 	}
 
+	q, err := parseSymViewQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("limit") == "" {
+		// Large binaries have too many symbols to show at
+		// once, so the page is paginated by default.
+		q.Limit = symPageSize
+	}
+
 	var info SymsInfo
-	sv, err := s.symView.Decode()
+	info.BuildID = s.fi.BuildID
+	info.Stripped = s.fi.Stripped
+	info.HasBase = s.base != nil
+	sv, err := s.symView.Decode(q)
 	if err != nil {
 		log.Print(err)
 	} else {
@@ -184,27 +399,37 @@ func (a AddrJS) MarshalJSONTo(buf *bytes.Buffer) error {
 
 type SymInfo struct {
 	Title string
+	Name  string
 	Base  AddrJS
 
 	HexView    interface{} `json:",omitempty"`
 	AsmView    interface{} `json:",omitempty"`
 	SourceView interface{} `json:",omitempty"`
+	FuncView   interface{} `json:",omitempty"`
+	TypeView   interface{} `json:",omitempty"`
+	SSAView    interface{} `json:",omitempty"`
+	CFGView    interface{} `json:",omitempty"`
+	RelocView  interface{} `json:",omitempty"`
+
+	// AsmError is the error from disassembling the symbol, such
+	// as an unsupported architecture.
+	AsmError string `json:",omitempty"`
+
+	// Others are the other symbols with the same name, if any.
+	Others []SymChoiceJS `json:",omitempty"`
+}
+
+// SymChoiceJS is one of several symbols that share a name.
+type SymChoiceJS struct {
+	Addr    AddrJS
+	Kind    string
+	Section string
 }
 
 func (s *state) httpSym(w http.ResponseWriter, r *http.Request) {
-	// TODO: Highlight sources of data read by instruction and
-	// sinks of data written by instruction.
-
-	// TODO: Option to show dot basic block graph with cross-links
-	// to assembly listing? Maybe also dominator tree? Maybe this
-	// is another parallel view?
-
-	// TODO: Have parallel views of symbols: hex dump,
-	// disassembly, and source. For data symbols, just have hex
-	// dump. Cross-link the views, so clicking on a line of source
-	// highlights all of the assembly for the line and the hex
-	// corresponding to those instructions, etc. Could have
-	// further parallel views, too, like decoding hex values using
+	// TODO: Show the dominator tree from /api/cfg/ in CFGView?
+
+	// TODO: More parallel views, like decoding hex values using
 	// DWARF type information.
 
 	// TODO: Allow selecting a range of lines and highlighting all
@@ -214,34 +439,126 @@ func (s *state) httpSym(w http.ResponseWriter, r *http.Request) {
 	// information? (Could also use this for liveness, etc.) Would
 	// be nice if this were "pluggable".
 
-	// TODO: Have a way to navigate control flow, leaving behind
-	// "breadcrumbs" of sequential control flow. E.g., clicking on
-	// a jump adds instructions between current position and jump
-	// to a breadcrumb list and follows the jump. Clicking on a
-	// ret does the same and then uses the call stack to go back
-	// to where you came from. Also have a way to back up in this
-	// control flow (and maybe a way to fork, probably just using
-	// browser tabs).
+	// TODO: Have a way to fork a control flow trail (see
+	// follow.js), probably just using browser tabs.
 
-	// TODO: Option to re-order assembly so control-flow is more
-	// local. Maybe edge spring model? Or topo order?
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "html" && format != "text" {
+		http.Error(w, "unknown format "+format, http.StatusBadRequest)
+		return
+	}
 
-	var info SymInfo
+	info := s.symInfo(w, r, r.URL.Path[len("/s/"):])
+	if info == nil {
+		return
+	}
+	if format == "text" {
+		writeAsmText(w, info)
+		return
+	}
+	s.writeSym(w, info)
+}
+
+// httpSymAPI serves the same SymInfo as httpSym, as JSON.
+func (s *state) httpSymAPI(w http.ResponseWriter, r *http.Request) {
+	info := s.symInfo(w, r, r.URL.Path[len("/api/sym/"):])
+	if info == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
 
-	symName := r.URL.Path[3:]
+// symInfo returns the SymInfo for symbol symName, using the query
+// parameters of r. If it fails, it writes an error to w and returns
+// nil.
+func (s *state) symInfo(w http.ResponseWriter, r *http.Request, symName string) *SymInfo {
+	var info SymInfo
 	info.Title = symName
+	info.Name = symName
 
+	if symName == "" {
+		// Unnamed symbols are in the table, but aren't
+		// meaningful to look up by name.
+		http.Error(w, "missing symbol name", http.StatusBadRequest)
+		return nil
+	}
 	sym, ok := s.symTab.Name(symName)
 	if !ok {
-		fmt.Fprintln(w, "unknown symbol")
-		return
+		http.Error(w, "unknown symbol: "+symName, http.StatusNotFound)
+		return nil
+	}
+	// Local symbols may share a name. The "addr" query parameter
+	// picks one of them.
+	syms := s.symTab.Names(symName)
+	if str := r.URL.Query().Get("addr"); str != "" {
+		addr, err := strconv.ParseUint(str, 0, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bad addr: %v", err), http.StatusBadRequest)
+			return nil
+		}
+		ok = false
+		for _, s := range syms {
+			if s.Value == addr {
+				sym, ok = s, true
+				break
+			}
+		}
+		if !ok {
+			http.Error(w, fmt.Sprintf("no symbol %s at %#x", symName, addr), http.StatusNotFound)
+			return nil
+		}
+	}
+	for _, s := range syms {
+		if s.Value != sym.Value {
+			info.Others = append(info.Others, SymChoiceJS{AddrJS(s.Value), string(s.Kind), s.Section})
+		}
+	}
+	if len(info.Others) > 0 {
+		info.Title = fmt.Sprintf("%s @ %#x", symName, sym.Value)
 	}
 	info.Base = AddrJS(sym.Value)
 
+	syntaxName := r.URL.Query().Get("syntax")
+	if syntaxName == "" {
+		syntaxName = *flagSyntax
+	}
+	syntax, err := asm.ParseSyntax(syntaxName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	win, err := parseAsmWindow(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	dce, err := parseDCE(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	rpo, err := parseAsmOrder(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+
+	key := symCacheKey{symName, sym.Value, syntax.String(), win, dce, rpo}
+	if cached := s.symCache.get(key); cached != nil {
+		return cached
+	}
+
 	data, err := s.bin.SymbolData(sym)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return nil
 	}
 
 	// Process HexView.
@@ -254,16 +571,28 @@ func (s *state) httpSym(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Process AsmView.
-	av, err := s.asmView.DecodeSym(sym, data)
+	ctx := r.Context()
+	av, err := s.asmView.DecodeSym(ctx, sym, data, syntax, win, rpo)
+	if ctx.Err() != nil {
+		// The request timed out or was canceled. The
+		// timeout handler has already responded.
+		return nil
+	}
 	if err != nil {
-		// TODO: Display this to the user.
 		log.Print(err)
+		info.AsmError = err.Error()
 	} else {
 		info.AsmView = av
+		if av, ok := av.(*AsmViewJS); ok {
+			s.sourceView.tagInsts(ctx, s.fi, sym, av)
+		}
 	}
 
 	// Process SourceView.
-	sv, err := s.sourceView.DecodeSym(&FileInfo{s.bin}, sym)
+	sv, err := s.sourceView.DecodeSym(ctx, s.fi, sym)
+	if ctx.Err() != nil {
+		return nil
+	}
 	if err != nil {
 		// TODO: Display this to the user.
 		log.Print(err)
@@ -271,6 +600,116 @@ func (s *state) httpSym(w http.ResponseWriter, r *http.Request) {
 		info.SourceView = sv
 	}
 
+	// Process FuncView.
+	fv, err := s.funcView.DecodeSym(sym)
+	if err != nil {
+		log.Print(err)
+	} else {
+		info.FuncView = fv
+	}
+
+	// Process TypeView.
+	tv, err := s.typeView.DecodeSym(sym)
+	if err != nil {
+		log.Print(err)
+	} else {
+		info.TypeView = tv
+	}
+
+	// Process RelocView.
+	rv, err := s.relocView.DecodeSym(sym)
+	if err != nil {
+		log.Print(err)
+	} else {
+		info.RelocView = rv
+	}
+
+	// Process SSAView and CFGView. These depend on disassembly.
+	if info.AsmView != nil {
+		ssav, err := s.ssaView.DecodeSym(ctx, sym, syntax, win, dce)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Print(err)
+		} else {
+			info.SSAView = ssav
+		}
+
+		cfgv, err := s.cfgView.DecodeSym(ctx, sym, win)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Print(err)
+		} else {
+			info.CFGView = cfgv
+		}
+	}
+
+	s.symCache.put(key, &info)
+	return &info
+}
+
+// pcWindow is the number of bytes of code to disassemble on either
+// side of a PC requested with ?pc=.
+const pcWindow = 2048
+
+// parseAsmWindow parses the part of a symbol to disassemble from the
+// query parameters "pc", or "start" and optionally "len". Values may
+// be decimal or 0x-prefixed hex.
+func parseAsmWindow(q url.Values) (AsmWindow, error) {
+	parse := func(name string) (uint64, bool, error) {
+		str := q.Get(name)
+		if str == "" {
+			return 0, false, nil
+		}
+		v, err := strconv.ParseUint(str, 0, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("bad %s: %v", name, err)
+		}
+		return v, true, nil
+	}
+	var win AsmWindow
+	if pc, ok, err := parse("pc"); err != nil {
+		return win, err
+	} else if ok {
+		win.Start, win.End, win.Anchor = 0, pc+pcWindow, pc
+		if pc > pcWindow {
+			win.Start = pc - pcWindow
+		}
+		return win, nil
+	}
+	start, ok, err := parse("start")
+	if err != nil || !ok {
+		return win, err
+	}
+	win.Start, win.End, win.Anchor = start, ^uint64(0), start
+	if n, ok, err := parse("len"); err != nil {
+		return win, err
+	} else if ok {
+		win.End = start + n
+	}
+	return win, nil
+}
+
+// parseAsmOrder parses the "order" query parameter, which is "addr"
+// (the default) to list instructions in address order or "rpo" to
+// group them by basic block in reverse postorder. It returns whether
+// the order is "rpo".
+func parseAsmOrder(q url.Values) (bool, error) {
+	switch order := q.Get("order"); order {
+	case "", "addr":
+		return false, nil
+	case "rpo":
+		return true, nil
+	default:
+		return false, fmt.Errorf("bad order %q: must be addr or rpo", order)
+	}
+}
+
+// writeSym writes the page for a symbol.
+func (s *state) writeSym(w http.ResponseWriter, info *SymInfo) {
 	if err := tmplSym.Execute(w, info); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -304,6 +743,13 @@ var tmplSym = template.Must(template.New("").Parse(`<!DOCTYPE html>
 <script src="/asmview.js"></script>
 <script src="/sourceview.js"></script>
 <script src="/liveness.js"></script>
+<script src="/annotate.js"></script>
+<script src="/funcview.js"></script>
+<script src="/typeview.js"></script>
+<script src="/ssaview.js"></script>
+<script src="/cfgview.js"></script>
+<script src="/follow.js"></script>
+<script src="/relocview.js"></script>
 <script>render(document.body, {{$}})</script>
 </body></html>
 `))
@@ -0,0 +1,137 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/aclements/objbrowse/internal/asm"
+	"github.com/aclements/objbrowse/internal/obj"
+)
+
+type MemAccessJS struct {
+	Accesses []MemAccessInstJS
+}
+
+type MemAccessInstJS struct {
+	Start AddrJS `json:"start"`
+	End   AddrJS `json:"end"`
+	Read  bool   `json:"read"`
+	Write bool   `json:"write"`
+}
+
+// httpMemAccess finds the instructions in a function that access a
+// memory location. The location is given either as a memory operand
+// of an instruction (pc and arg parameters, where arg is an argument
+// index in the optional syntax parameter) or as a range of the stack
+// frame (off and size parameters, where off is relative to the SP on
+// entry to the function).
+func (s *state) httpMemAccess(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sym, ok := s.symTab.Name(q.Get("sym"))
+	if !ok || sym.Kind != obj.SymText {
+		http.Error(w, "unknown text symbol", http.StatusNotFound)
+		return
+	}
+
+	data, err := s.bin.SymbolData(sym)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	insts, err := disasmSym(r.Context(), s.bin, sym, data, AsmWindow{})
+	if r.Context().Err() != nil {
+		// Timed out. The timeout handler has responded.
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Get the frame layout.
+	var spAdj asm.SPAdjFunc
+	if fn := s.fi.funcForPC(sym.Value); fn != nil {
+		pcsp := fn.PCSP.Decode()
+		spAdj = func(pc uint64) (int64, bool) {
+			v, ok := pcsp.Lookup(pc)
+			return int64(v), ok
+		}
+	}
+
+	var accesses []asm.MemAccess
+	if q.Get("off") != "" {
+		if spAdj == nil {
+			http.Error(w, "no frame information for "+sym.Name, http.StatusNotFound)
+			return
+		}
+		off, err1 := strconv.ParseInt(q.Get("off"), 0, 64)
+		size, err2 := strconv.ParseInt(q.Get("size"), 0, 64)
+		if err1 != nil || err2 != nil || size <= 0 {
+			http.Error(w, "bad off or size", http.StatusBadRequest)
+			return
+		}
+		accesses = asm.FrameAccesses(insts, spAdj, off, size)
+	} else {
+		pc, err1 := strconv.ParseUint(q.Get("pc"), 16, 64)
+		arg, err2 := strconv.Atoi(q.Get("arg"))
+		if err1 != nil || err2 != nil {
+			http.Error(w, "bad pc or arg", http.StatusBadRequest)
+			return
+		}
+		i := -1
+		for j := 0; j < insts.Len(); j++ {
+			if insts.Get(j).PC() == pc {
+				i = j
+				break
+			}
+		}
+		if i == -1 {
+			http.Error(w, fmt.Sprintf("no instruction at %#x", pc), http.StatusBadRequest)
+			return
+		}
+		if q.Get("syntax") != "" {
+			syntax, err := asm.ParseSyntax(q.Get("syntax"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			// Map arg to its Go syntax index.
+			inst := insts.Get(i)
+			_, args := parseAsm(syntax, syntax.Format(inst, s.symTab.SymName))
+			idx, goIdx := memArgIndexes(inst, syntax, args)
+			goArg := -1
+			for j := range idx {
+				if idx[j] == arg {
+					goArg = goIdx[j]
+				}
+			}
+			arg = goArg
+		}
+		accesses, err = asm.ArgAccesses(insts, spAdj, i, arg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	info := MemAccessJS{Accesses: []MemAccessInstJS{}}
+	for _, a := range accesses {
+		inst := insts.Get(a.Inst)
+		info.Accesses = append(info.Accesses, MemAccessInstJS{
+			AddrJS(inst.PC()), AddrJS(inst.PC() + uint64(inst.Len())),
+			a.Read, a.Write,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
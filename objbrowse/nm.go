@@ -0,0 +1,98 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/aclements/objbrowse/internal/demangle"
+	"github.com/aclements/objbrowse/internal/obj"
+)
+
+// writeNM writes the symbol table of bin to w in the same format as
+// nm. If numeric is true, symbols are sorted by address; otherwise
+// they are sorted by name.
+func writeNM(w io.Writer, bin obj.Obj, numeric bool) error {
+	// The symbol table trims undefined symbols, but nm prints
+	// them, so get a fresh list from the object.
+	syms, err := bin.Symbols()
+	if err != nil {
+		return err
+	}
+
+	// Like nm, don't print unnamed symbols (e.g., section
+	// symbols) or debugging symbols.
+	out := syms[:0]
+	for _, sym := range syms {
+		if sym.Name != "" && !sym.Debug {
+			out = append(out, sym)
+		}
+	}
+	syms = out
+
+	if numeric {
+		sort.SliceStable(syms, func(i, j int) bool {
+			if syms[i].Value != syms[j].Value {
+				return syms[i].Value < syms[j].Value
+			}
+			return syms[i].Name < syms[j].Name
+		})
+	} else {
+		sort.SliceStable(syms, func(i, j int) bool {
+			return syms[i].Name < syms[j].Name
+		})
+	}
+
+	width := 16
+	if arch := bin.Info().Arch; arch != nil {
+		width = 2 * arch.PtrSize
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, sym := range syms {
+		kind := nmKind(sym)
+		name := sym.Name
+		if *flagDemangle && demangle.IsCxx(name) {
+			if dn, err := demangle.Cxx(name); err == nil {
+				name = dn
+			}
+		}
+		if sym.Kind == obj.SymUndef {
+			fmt.Fprintf(bw, "%*s %c %s\n", width, "", kind, name)
+		} else {
+			fmt.Fprintf(bw, "%0*x %c %s\n", width, sym.Value, kind, name)
+		}
+	}
+	return bw.Flush()
+}
+
+// nmKind returns the nm type character for sym.
+func nmKind(sym obj.Sym) byte {
+	kind := byte(sym.Kind)
+	switch {
+	case sym.Weak && sym.Kind == obj.SymUndef:
+		return 'w'
+	case sym.Weak:
+		// nm uses "V" for weak objects, but we don't
+		// distinguish symbol types.
+		return 'W'
+	case sym.Local && 'A' <= kind && kind <= 'Z' && kind != obj.SymUndef:
+		// nm uses lower case for local symbols.
+		return kind + 'a' - 'A'
+	}
+	return kind
+}
+
+func (s *state) httpNM(w http.ResponseWriter, r *http.Request) {
+	numeric := r.URL.Query().Get("n") != ""
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := writeNM(w, s.bin, numeric); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
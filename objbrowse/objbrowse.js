@@ -370,6 +370,12 @@ class Panels {
         this._cols.push({div: div});
         return div[0];
     }
+
+    // firstCol returns the container element of the leftmost
+    // column, or null if there are no columns.
+    firstCol() {
+        return this._cols.length == 0 ? null : this._cols[0].div[0];
+    }
 }
 
 function scrollTo(container, elt) {
@@ -381,21 +387,63 @@ function scrollTo(container, elt) {
 var asmView;
 var sourceView;
 var hexView;
+var funcView;
+var typeView;
+var ssaView;
+var cfgView;
+var relocView;
+var trail;
 var baseAddr;
+var symName;
 
 function render(container, info) {
     const panels = new Panels(container);
-    if (info.SymView)
-        new SymView(info.SymView, panels.addCol());
+    if (info.SymView) {
+        const col = panels.addCol();
+        if (info.Stripped)
+            $("<div>").addClass("stripped").text("This binary appears to be stripped. Each section is shown as a single symbol.").appendTo(col);
+        if (info.BuildID)
+            $("<div>").addClass("buildid").text("Build ID: " + info.BuildID).appendTo(col);
+        $("<div>").append($("<a>").attr("href", "/files").text("Browse by source file")).appendTo(col);
+        $("<div>").append($("<a>").attr("href", "/tree").text("Browse by package")).appendTo(col);
+        if (info.HasBase)
+            $("<div>").append($("<a>").attr("href", "/diff").text("Compare with base binary")).appendTo(col);
+        new SymView(info.SymView, col);
+    }
     if (info.HexView)
         hexView = new HexView(info.HexView, panels.addCol());
-    if (info.AsmView)
-        asmView = new AsmView(info.AsmView, panels.addCol());
+    if (info.AsmView) {
+        const col = panels.addCol();
+        asmView = new AsmView(info.AsmView, col);
+        trail = new Trail(col);
+    }
+    else if (info.AsmError)
+        $("<div>").addClass("error").text("Cannot disassemble: " + info.AsmError).appendTo(panels.addCol());
+    if (info.SSAView)
+        ssaView = new SSAView(info.SSAView, panels.addCol());
+    if (info.CFGView)
+        cfgView = new CFGView(info.CFGView, panels.addCol());
     if (info.SourceView)
         sourceView = new SourceView(info.SourceView, panels.addCol());
+    if (info.FuncView)
+        funcView = new FuncView(info.FuncView, panels.addCol());
+    if (info.TypeView)
+        typeView = new TypeView(info.TypeView, panels.addCol());
+    if (info.RelocView)
+        relocView = new RelocView(info.RelocView, panels.addCol());
+    if (info.Others) {
+        // Other symbols share this name. Link to each of them.
+        const div = $("<div>").addClass("ambiguous").text("There are " + (info.Others.length + 1) + " symbols named " + info.Name + ". This is the one at 0x" + info.Base + ". Others: ");
+        for (let o of info.Others) {
+            const params = new URLSearchParams({addr: "0x" + o.Addr});
+            div.append(" ", $("<a>").attr("href", "/s/" + info.Name + "?" + params.toString()).text("0x" + o.Addr + " (" + o.Kind + (o.Section ? ", " + o.Section : "") + ")"));
+        }
+        div.prependTo(panels.firstCol() || panels.addCol());
+    }
 
     if (info.Base) {
         baseAddr = new AddrJS(info.Base);
+        symName = info.Name;
 
         window.addEventListener("hashchange", onHashChange, false);
         $.fx.off = true;  // Inhibit scrolling animations during setup.
@@ -430,8 +478,14 @@ function highlightRanges(ranges, cause) {
         hexView.highlightRanges(ranges, cause !== hexView);
     if (asmView)
         asmView.highlightRanges(ranges, cause !== asmView);
+    if (ssaView)
+        ssaView.highlightRanges(ranges, cause !== ssaView);
+    if (cfgView)
+        cfgView.highlightRanges(ranges, cause !== cfgView);
     if (sourceView)
         sourceView.highlightRanges(ranges, cause !== sourceView);
+    if (relocView)
+        relocView.highlightRanges(ranges, cause !== relocView);
 
     const newHash = "#" + formatRanges(ranges);
     onHashChange.lastHash = newHash; // Inhibit hashchange listener
@@ -0,0 +1,214 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aclements/objbrowse/internal/obj"
+	"github.com/aclements/objbrowse/internal/profile"
+)
+
+// A ProfileOverlay attributes the samples of a pprof profile, loaded
+// from the file given by the -pprof flag, to the instructions of the
+// binary. Each sample is attributed to its leaf PC, so these are
+// "flat" sample counts.
+type ProfileOverlay struct {
+	// name and unit describe the sample values, such as "cpu"
+	// and "nanoseconds".
+	name, unit string
+
+	// total is the sum of all samples in the profile, including
+	// those outside this binary.
+	total int64
+
+	// samples is sorted by pc, with one entry per PC.
+	samples []pcSample
+}
+
+type pcSample struct {
+	pc    uint64
+	value int64
+}
+
+// LoadProfileOverlay reads a pprof profile from path and maps its
+// samples to PCs in fi.
+//
+// The profile's addresses are process addresses, so they're mapped
+// to the binary through one of the profile's mappings. If mapping is
+// not "", it picks the first mapping whose file name contains
+// mapping. Otherwise, the mapping is the one with the same build ID
+// as the binary, or failing that, the same file name as binPath, or
+// failing that, the first mapping, which pprof uses for the main
+// executable.
+func LoadProfileOverlay(path, mapping, binPath string, fi *FileInfo) (*ProfileOverlay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := profile.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(p.SampleTypes) == 0 {
+		return nil, fmt.Errorf("%s: profile has no sample types", path)
+	}
+
+	m, err := profileMapping(p, mapping, binPath, fi)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	sects, err := fi.Obj.Sections()
+	if err != nil {
+		return nil, err
+	}
+	// toPC maps a process address in m to a PC in the binary by
+	// way of its file offset.
+	toPC := func(addr uint64) (uint64, bool) {
+		if m.Start == 0 && m.Limit == 0 && m.Offset == 0 {
+			// The profile doesn't have mapping information,
+			// so assume addresses are link addresses.
+			return addr, true
+		}
+		if addr < m.Start || addr >= m.Limit {
+			return 0, false
+		}
+		off := addr - m.Start + m.Offset
+		for _, s := range sects {
+			if s.Flags&obj.SectionExec != 0 && s.Offset != 0 && s.Offset <= off && off < s.Offset+s.Size {
+				return s.Addr + (off - s.Offset), true
+			}
+		}
+		return 0, false
+	}
+
+	idx := p.SampleIndex()
+	o := &ProfileOverlay{name: p.SampleTypes[idx].Type, unit: p.SampleTypes[idx].Unit}
+	byPC := make(map[uint64]int64)
+	for _, s := range p.Samples {
+		v := s.Values[idx]
+		o.total += v
+		if len(s.Locations) == 0 {
+			continue
+		}
+		leaf := s.Locations[0]
+		if leaf.Mapping != m && leaf.Mapping != nil {
+			continue
+		}
+		if pc, ok := toPC(leaf.Address); ok {
+			byPC[pc] += v
+		}
+	}
+	for pc, v := range byPC {
+		o.samples = append(o.samples, pcSample{pc, v})
+	}
+	sort.Slice(o.samples, func(i, j int) bool {
+		return o.samples[i].pc < o.samples[j].pc
+	})
+	return o, nil
+}
+
+// profileMapping returns the mapping of p that corresponds to the
+// binary. See LoadProfileOverlay.
+func profileMapping(p *profile.Profile, mapping, binPath string, fi *FileInfo) (*profile.Mapping, error) {
+	if len(p.Mappings) == 0 {
+		// Legacy profiles may have no mappings at all.
+		return &profile.Mapping{}, nil
+	}
+	if mapping != "" {
+		for _, m := range p.Mappings {
+			if strings.Contains(m.File, mapping) {
+				return m, nil
+			}
+		}
+		return nil, fmt.Errorf("no mapping matching %q", mapping)
+	}
+	if fi.BuildID != "" {
+		for _, m := range p.Mappings {
+			if m.BuildID == fi.BuildID {
+				return m, nil
+			}
+		}
+	}
+	for _, m := range p.Mappings {
+		if filepath.Base(m.File) == filepath.Base(binPath) {
+			return m, nil
+		}
+	}
+	return p.Mappings[0], nil
+}
+
+// sum returns the total sample value of the PCs in [start, end).
+func (o *ProfileOverlay) sum(start, end uint64) int64 {
+	i := sort.Search(len(o.samples), func(i int) bool {
+		return o.samples[i].pc >= start
+	})
+	var v int64
+	for ; i < len(o.samples) && o.samples[i].pc < end; i++ {
+		v += o.samples[i].value
+	}
+	return v
+}
+
+// percent formats v as a percentage of the profile's total.
+func (o *ProfileOverlay) percent(v int64) string {
+	if o.total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(v)/float64(o.total))
+}
+
+// forSym returns the samples in sym as an annotation column, or nil
+// if there are none. Each cell shows its percentage of the total
+// profile and is shaded relative to the hottest instruction in sym.
+func (o *ProfileOverlay) forSym(sym obj.Sym) *AnnotationOverlayJS {
+	if o == nil {
+		return nil
+	}
+	end := sym.Value + sym.Size
+	i := sort.Search(len(o.samples), func(i int) bool {
+		return o.samples[i].pc >= sym.Value
+	})
+	j := i
+	var max int64
+	for ; j < len(o.samples) && o.samples[j].pc < end; j++ {
+		if v := o.samples[j].value; v > max {
+			max = v
+		}
+	}
+	if i == j {
+		return nil
+	}
+	var ranges []AnnotationRangeJS
+	for _, s := range o.samples[i:j] {
+		a := AnnotationJS{
+			Label: o.percent(s.value),
+			Color: fmt.Sprintf("rgba(255, 0, 0, %.2f)", 0.1+0.6*float64(s.value)/float64(max)),
+			Title: fmt.Sprintf("%d %s", s.value, o.unit),
+		}
+		ranges = append(ranges, AnnotationRangeJS{AddrJS(s.pc), AddrJS(s.pc + 1), []AnnotationJS{a}})
+	}
+	return &AnnotationOverlayJS{o.name, ranges}
+}
+
+// ProfileJS describes the profile for views that compute their own
+// per-row sample values.
+type ProfileJS struct {
+	Name  string
+	Unit  string
+	Total int64
+}
+
+func (o *ProfileOverlay) js() *ProfileJS {
+	if o == nil {
+		return nil
+	}
+	return &ProfileJS{o.name, o.unit, o.total}
+}
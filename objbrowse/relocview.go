@@ -0,0 +1,59 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/aclements/objbrowse/internal/obj"
+)
+
+// RelocView lists the relocations that apply to a symbol. Usually
+// only relocatable objects have these.
+type RelocView struct {
+	fi *FileInfo
+}
+
+func NewRelocView(fi *FileInfo) *RelocView {
+	return &RelocView{fi}
+}
+
+type RelocViewJS struct {
+	Relocs []RelocJS
+}
+
+type RelocJS struct {
+	// Addr is the address of the patched bytes and Offset is
+	// their offset in the symbol.
+	Addr, Offset AddrJS
+
+	// Type is the name of the relocation type or, if that's not
+	// known, its number.
+	Type string
+
+	// Sym and Addend give the target of the relocation.
+	Sym    string
+	Addend int64
+}
+
+// DecodeSym returns the relocations of sym, or nil if it has none or
+// the object format doesn't support relocations.
+func (v *RelocView) DecodeSym(sym obj.Sym) (interface{}, error) {
+	relocs, err := v.fi.Obj.Relocations(sym)
+	if err == obj.ErrNotSupported || len(relocs) == 0 {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	out := &RelocViewJS{}
+	for _, r := range relocs {
+		typ := r.TypeName
+		if typ == "" {
+			typ = fmt.Sprint(r.Type)
+		}
+		out.Relocs = append(out.Relocs, RelocJS{AddrJS(sym.Value + r.Offset), AddrJS(r.Offset), typ, r.Sym, r.Addend})
+	}
+	return out, nil
+}
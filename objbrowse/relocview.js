@@ -0,0 +1,53 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+"use strict";
+
+class RelocView {
+    constructor(data, container) {
+        this._container = container;
+        const view = this;
+        const table = $('<table class="fv">').appendTo(container);
+        table.append($('<tr>').append($('<th colspan="3">').addClass('fv-title').text("Relocations")));
+
+        // Clicking a relocation highlights the bytes it patches.
+        // Several relocations may patch the same address, so rows
+        // are grouped by address for highlighting.
+        const byAddr = new Map();
+        for (let r of data.Relocs) {
+            let target = r.Sym;
+            if (r.Addend != 0)
+                target += (r.Addend < 0 ? "-0x" + (-r.Addend).toString(16) : "+0x" + r.Addend.toString(16));
+            const tr = $('<tr>').addClass('fv-reloc').append(
+                $('<td>').addClass('fv-name').text("+0x" + r.Offset),
+                $('<td>').addClass('fv-name').text(r.Type),
+                $('<td>').addClass('fv-val').text(target),
+            ).appendTo(table);
+            const start = new AddrJS(r.Addr);
+            if (!byAddr.has(r.Addr))
+                byAddr.set(r.Addr, {start: start, end: start.add(new AddrJS(1)), rows: []});
+            const rng = byAddr.get(r.Addr);
+            rng.rows.push(tr);
+            tr.click(() => {
+                highlightRanges([{start: rng.start, end: rng.end}], view);
+            });
+        }
+        this._ranges = new IntervalMap(Array.from(byAddr.values()));
+    }
+
+    highlightRanges(ranges, scroll) {
+        // Clear highlights.
+        $("tr.highlight", this._container).removeClass("highlight");
+
+        // New highlights.
+        let first = true;
+        for (let match of this._ranges.intersect(ranges)) {
+            for (let tr of match.rows)
+                tr.addClass("highlight");
+            if (first && scroll)
+                scrollTo(this._container, match.rows[0]);
+            first = false;
+        }
+    }
+}
@@ -6,18 +6,27 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"debug/dwarf"
+	"flag"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"sort"
 
+	"github.com/aclements/objbrowse/internal/functab"
 	"github.com/aclements/objbrowse/internal/obj"
 )
 
 type SourceView struct {
+	// dw is the object's DWARF, or nil if it has none, in which
+	// case source lines come from the Go function table.
 	dw     *dwarf.Data
 	ranges []CURange
+
+	// profile, if not nil, provides per-line sample values.
+	profile *ProfileOverlay
 }
 
 type CURange struct {
@@ -25,13 +34,28 @@ type CURange struct {
 	CU        *dwarf.Entry
 }
 
-func NewSourceView(fi *FileInfo) (*SourceView, error) {
+// NewSourceView returns a new source view. profile may be nil.
+func NewSourceView(fi *FileInfo, profile *ProfileOverlay) (*SourceView, error) {
 	// Load the DWARF.
 	dw, err := fi.Obj.DWARF()
 	if err != nil {
+		if fi.FuncTab != nil {
+			// Fall back to the function table's line
+			// tables.
+			return &SourceView{profile: profile}, nil
+		}
 		return nil, err
 	}
 
+	// If the debug info was split out with -gsplit-dwarf, the
+	// skeleton units in dw still have address ranges and line
+	// tables, which is all we need here.
+	split, err := obj.SplitDWARF(fi.Obj, flag.Arg(0), *flagDWP)
+	if err != nil {
+		log.Printf("loading split DWARF: %v", err)
+	}
+	fi.SplitDWARF = split
+
 	// Create an address index for the CUs.
 	var ranges []CURange
 	dr := dw.Reader()
@@ -41,7 +65,7 @@ func NewSourceView(fi *FileInfo) (*SourceView, error) {
 			break
 		}
 
-		if ent.Tag != dwarf.TagCompileUnit {
+		if ent.Tag != dwarf.TagCompileUnit && ent.Tag != dwarf.TagSkeletonUnit {
 			dr.SkipChildren()
 			continue
 		}
@@ -61,7 +85,7 @@ func NewSourceView(fi *FileInfo) (*SourceView, error) {
 		return ranges[i].Low < ranges[j].Low
 	})
 
-	return &SourceView{dw, ranges}, nil
+	return &SourceView{dw, ranges, profile}, nil
 }
 
 func (v *SourceView) addrToCU(addr uint64) *dwarf.Entry {
@@ -80,6 +104,10 @@ func (v *SourceView) addrToCU(addr uint64) *dwarf.Entry {
 
 type SourceViewJS struct {
 	Blocks []SourceViewBlock
+
+	// Profile describes the -pprof profile, if any, in which case
+	// each block has Samples.
+	Profile *ProfileJS `json:",omitempty"`
 }
 
 type SourceViewBlock struct {
@@ -88,9 +116,27 @@ type SourceViewBlock struct {
 	Text  []string // Excludes trailing \n
 	PCs   [][][2]AddrJS
 	Error string `json:",omitempty"`
+
+	// Lines, if non-nil, are the line numbers of the lines in
+	// this block, which has no Text because the source file
+	// couldn't be read. PCs is parallel to Lines. Otherwise, the
+	// lines are Start, Start+1, and so on.
+	Lines []int `json:",omitempty"`
+
+	// Samples are the profile sample values of each line, if
+	// there's a profile.
+	Samples []int64 `json:",omitempty"`
+}
+
+// A srcLine is a range of PCs [pc, end) that map to a single source
+// line.
+type srcLine struct {
+	pc, end uint64
+	file    string
+	line    int
 }
 
-func (v *SourceView) DecodeSym(fi *FileInfo, sym obj.Sym) (interface{}, error) {
+func (v *SourceView) DecodeSym(ctx context.Context, fi *FileInfo, sym obj.Sym) (interface{}, error) {
 	// contextLines is the number of extra lines to include around
 	// every source line. 0 means no context.
 	const contextLines = 5
@@ -103,29 +149,20 @@ func (v *SourceView) DecodeSym(fi *FileInfo, sym obj.Sym) (interface{}, error) {
 	if sym.Kind != obj.SymText {
 		return nil, nil
 	}
-
-	// Find sym.
-	cu := v.addrToCU(sym.Value)
-	if cu == nil {
-		return nil, fmt.Errorf("no DWARF data for symbol %s", sym.Name)
+	if v == nil {
+		// The object has no usable DWARF or function table.
+		return nil, nil
 	}
 
-	// Get line table.
-	lr, err := v.dw.LineReader(cu)
+	lines, err := v.symLines(ctx, fi, sym)
 	if err != nil {
 		return nil, err
 	}
-
-	// Decode the line table for this PC range.
-	var line, nextLine dwarf.LineEntry
-	if err = lr.SeekPC(sym.Value, &line); err == dwarf.ErrUnknownPC {
-		return nil, fmt.Errorf("no line table for symbol %s", sym.Name)
-	} else if err != nil {
-		return nil, err
+	if len(lines) == 0 {
+		return nil, nil
 	}
 
 	// Collect line ranges and PCs.
-	end := sym.Value + sym.Size
 	type rang struct {
 		file     string
 		from, to int // [from, to)
@@ -136,26 +173,18 @@ func (v *SourceView) DecodeSym(fi *FileInfo, sym obj.Sym) (interface{}, error) {
 		line int
 	}
 	pcMap := map[pcKey][][2]uint64{}
-	for line.Address < end {
-		ranges = append(ranges, rang{line.File.Name, line.Line - contextLines, line.Line + contextLines + 1})
+	for _, l := range lines {
+		ranges = append(ranges, rang{l.file, l.line - contextLines, l.line + contextLines + 1})
 
-		if err = lr.Next(&nextLine); err == io.EOF {
-			break
-		} else if err != nil {
-			return nil, err
-		}
-
-		pck := pcKey{line.File.Name, line.Line}
+		pck := pcKey{l.file, l.line}
 		pcRanges := pcMap[pck]
-		if len(pcRanges) > 0 && pcRanges[len(pcRanges)-1][1] == line.Address {
+		if len(pcRanges) > 0 && pcRanges[len(pcRanges)-1][1] == l.pc {
 			// Extend existing range.
-			pcRanges[len(pcRanges)-1][1] = nextLine.Address
+			pcRanges[len(pcRanges)-1][1] = l.end
 		} else {
 			// Add a new PC range.
-			pcMap[pck] = append(pcRanges, [2]uint64{line.Address, nextLine.Address})
+			pcMap[pck] = append(pcRanges, [2]uint64{l.pc, l.end})
 		}
-
-		line = nextLine
 	}
 
 	// Sort and merge lines ranges.
@@ -194,6 +223,13 @@ func (v *SourceView) DecodeSym(fi *FileInfo, sym obj.Sym) (interface{}, error) {
 		}
 		pcMap[pck] = pcRanges2
 	}
+	linePCs := func(file string, line int) [][2]AddrJS {
+		var pcRanges [][2]AddrJS
+		for _, pcr := range pcMap[pcKey{file, line}] {
+			pcRanges = append(pcRanges, [2]AddrJS{AddrJS(pcr[0]), AddrJS(pcr[1])})
+		}
+		return pcRanges
+	}
 
 	// Fetch source text.
 	//
@@ -204,20 +240,34 @@ func (v *SourceView) DecodeSym(fi *FileInfo, sym obj.Sym) (interface{}, error) {
 	var fName string
 	var lineNo int
 	for _, r := range ranges {
-		if f == nil || r.file != fName {
+		if r.file != fName {
 			f.Close()
 
 			fName = r.file
 			f, err = os.Open(fName)
 			if err != nil {
-				if r.file != fName {
-					blocks = append(blocks, SourceViewBlock{Path: r.file, Error: err.Error()})
+				// Show just the line numbers that
+				// have instructions.
+				block := SourceViewBlock{Path: fName, Error: err.Error()}
+				for pck := range pcMap {
+					if pck.file == fName {
+						block.Lines = append(block.Lines, pck.line)
+					}
+				}
+				sort.Ints(block.Lines)
+				for _, line := range block.Lines {
+					block.PCs = append(block.PCs, linePCs(fName, line))
 				}
+				blocks = append(blocks, block)
 				f, s = nil, nil
 				continue
 			}
 			s, lineNo = bufio.NewScanner(f), 1
 		}
+		if f == nil {
+			// Already reported.
+			continue
+		}
 
 		// Skip to the block.
 		for ; lineNo < r.from && s.Scan(); lineNo++ {
@@ -229,12 +279,7 @@ func (v *SourceView) DecodeSym(fi *FileInfo, sym obj.Sym) (interface{}, error) {
 		start := lineNo
 		for ; lineNo < r.to && s.Scan(); lineNo++ {
 			text = append(text, s.Text())
-
-			var pcRanges [][2]AddrJS
-			for _, pcr := range pcMap[pcKey{fName, lineNo}] {
-				pcRanges = append(pcRanges, [2]AddrJS{AddrJS(pcr[0]), AddrJS(pcr[1])})
-			}
-			lineRanges = append(lineRanges, pcRanges)
+			lineRanges = append(lineRanges, linePCs(fName, lineNo))
 		}
 
 		if err := s.Err(); err != nil {
@@ -245,5 +290,144 @@ func (v *SourceView) DecodeSym(fi *FileInfo, sym obj.Sym) (interface{}, error) {
 	}
 	f.Close()
 
-	return SourceViewJS{Blocks: blocks}, nil
+	info := SourceViewJS{Blocks: blocks}
+	if v.profile != nil {
+		info.Profile = v.profile.js()
+		for i := range blocks {
+			b := &blocks[i]
+			b.Samples = make([]int64, len(b.PCs))
+			for j, pcs := range b.PCs {
+				for _, r := range pcs {
+					b.Samples[j] += v.profile.sum(uint64(r[0]), uint64(r[1]))
+				}
+			}
+		}
+	}
+	return info, nil
+}
+
+// symLines returns the source lines of text symbol sym in PC order,
+// preferring the DWARF line table and falling back to the Go function
+// table.
+func (v *SourceView) symLines(ctx context.Context, fi *FileInfo, sym obj.Sym) ([]srcLine, error) {
+	var lines []srcLine
+	err := fmt.Errorf("no DWARF data for symbol %s", sym.Name)
+	if v.dw != nil {
+		lines, err = v.dwarfLines(ctx, sym)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	if err != nil && fi.FuncTab != nil {
+		if ftLines := funcTabLines(fi.FuncTab, sym); len(ftLines) > 0 {
+			lines, err = ftLines, nil
+		}
+	}
+	return lines, err
+}
+
+// tagInsts fills in the source position of each instruction in av
+// that doesn't already have one from the Go function table, using the
+// DWARF line table.
+func (v *SourceView) tagInsts(ctx context.Context, fi *FileInfo, sym obj.Sym, av *AsmViewJS) {
+	if v == nil || v.dw == nil {
+		return
+	}
+	var lines []srcLine
+	for i := range av.Insts {
+		inst := &av.Insts[i]
+		if inst.File != "" {
+			continue
+		}
+		if lines == nil {
+			var err error
+			if lines, err = v.dwarfLines(ctx, sym); err != nil || len(lines) == 0 {
+				return
+			}
+		}
+		pc := uint64(inst.PC)
+		j := sort.Search(len(lines), func(j int) bool { return lines[j].end > pc })
+		if j < len(lines) && lines[j].pc <= pc {
+			inst.File, inst.Line = lines[j].file, lines[j].line
+		}
+	}
+}
+
+// dwarfLines returns the source lines of sym from the DWARF line
+// table, in PC order.
+func (v *SourceView) dwarfLines(ctx context.Context, sym obj.Sym) ([]srcLine, error) {
+	// Find sym.
+	cu := v.addrToCU(sym.Value)
+	if cu == nil {
+		return nil, fmt.Errorf("no DWARF data for symbol %s", sym.Name)
+	}
+
+	// Get line table.
+	lr, err := v.dw.LineReader(cu)
+	if err != nil {
+		return nil, err
+	}
+	if lr == nil {
+		return nil, fmt.Errorf("no line table for symbol %s", sym.Name)
+	}
+
+	// Decode the line table for this PC range.
+	var line, nextLine dwarf.LineEntry
+	if err = lr.SeekPC(sym.Value, &line); err == dwarf.ErrUnknownPC {
+		return nil, fmt.Errorf("no line table for symbol %s", sym.Name)
+	} else if err != nil {
+		return nil, err
+	}
+	end := sym.Value + sym.Size
+	var lines []srcLine
+	for line.Address < end {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err = lr.Next(&nextLine); err == io.EOF {
+			lines = append(lines, srcLine{line.Address, end, line.File.Name, line.Line})
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		lines = append(lines, srcLine{line.Address, nextLine.Address, line.File.Name, line.Line})
+		line = nextLine
+	}
+	return lines, nil
+}
+
+// funcTabLines returns the source lines of sym from the Go function
+// table, in PC order.
+func funcTabLines(ft *functab.FuncTab, sym obj.Sym) []srcLine {
+	fn := ft.FuncForPC(sym.Value)
+	if fn == nil || fn.Raw.PCFile == 0 || fn.Raw.PCLn == 0 {
+		return nil
+	}
+	end := sym.Value + sym.Size
+
+	// The position changes wherever either the file or the line
+	// table does.
+	var pcs []uint64
+	pcs = append(pcs, fn.PCFile.Decode().PCs...)
+	pcs = append(pcs, fn.PCLine.Decode().PCs...)
+	pcs = append(pcs, sym.Value, end)
+	sort.Slice(pcs, func(i, j int) bool { return pcs[i] < pcs[j] })
+
+	var lines []srcLine
+	for i, pc := range pcs[:len(pcs)-1] {
+		next := pcs[i+1]
+		if pc == next || pc < sym.Value || next > end {
+			continue
+		}
+		file, line, ok := fn.SourceLine(pc)
+		if !ok {
+			continue
+		}
+		if n := len(lines); n > 0 && lines[n-1].end == pc && lines[n-1].file == file && lines[n-1].line == line {
+			lines[n-1].end = next
+			continue
+		}
+		lines = append(lines, srcLine{pc, next, file, line})
+	}
+	return lines
 }
@@ -11,10 +11,14 @@ class SourceView {
         const table = $("<table>").css({borderCollapse: "collapse"}).appendTo(container);
         this._table = table;
 
+        // With a profile, there's a column of per-line samples.
+        const prof = data.Profile;
+        const cols = prof ? 3 : 2;
+
         let prevPath = "";
         let pcRanges = [];
         for (let block of data.Blocks) {
-            const th = $('<th colspan="2">');
+            const th = $('<th>').attr("colspan", cols);
             if (block.Path == prevPath) {
                 th.text("\u22ef");
             } else {
@@ -24,17 +28,32 @@ class SourceView {
             table.append($('<tr>').append(th));
 
             if (block.Error) {
-                const th = $('<th colspan="2">').addClass('sv-error').text(block.Error);
+                const th = $('<th>').attr("colspan", cols).addClass('sv-error').text(block.Error);
                 table.append($('<tr>').append(th));
             }
 
+            // If the source couldn't be read, there's no Text, but
+            // Lines lists the lines that have instructions.
+            const nLines = block.Lines ? block.Lines.length : block.Text.length;
             let lineNo = block.Start;
-            for (let i = 0; i < block.Text.length; i++) {
+            for (let i = 0; i < nLines; i++) {
+                if (block.Lines)
+                    lineNo = block.Lines[i];
+                const src = $('<td>').addClass('sv-src');
+                if (block.Lines)
+                    src.addClass('sv-missing').text(block.Path.replace(/.*\//, "") + ":" + lineNo);
+                else
+                    src.text(block.Text[i]);
                 const tr = $('<tr>').append(
                     $('<td>').addClass('pos').text(lineNo)
-                ).append(
-                    $('<td>').addClass('sv-src').text(block.Text[i])
                 );
+                if (prof) {
+                    const td = $('<td>').addClass('sv-samples').appendTo(tr);
+                    const n = block.Samples[i];
+                    if (n > 0 && prof.Total > 0)
+                        td.text((100 * n / prof.Total).toFixed(1) + "%").attr("title", n + " " + prof.Unit + " " + prof.Name);
+                }
+                tr.append(src);
                 table.append(tr);
                 let pcs = block.PCs[i];
                 if (pcs) {
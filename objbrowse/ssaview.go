@@ -0,0 +1,206 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aclements/objbrowse/internal/asm"
+	"github.com/aclements/objbrowse/internal/obj"
+	"github.com/aclements/objbrowse/internal/ssa"
+	"github.com/aclements/objbrowse/internal/symtab"
+)
+
+// SSAView shows the SSA form of a function alongside its
+// disassembly.
+type SSAView struct {
+	fi     *FileInfo
+	symTab *symtab.Table
+}
+
+func NewSSAView(fi *FileInfo, symTab *symtab.Table) *SSAView {
+	return &SSAView{fi, symTab}
+}
+
+// DecodeSym computes the SSA form of sym. The SSA form is only
+// computed over whole symbols, so this returns nil if win selects
+// part of sym. If dce is set, dead values are removed; otherwise
+// they're marked dead.
+func (v *SSAView) DecodeSym(ctx context.Context, sym obj.Sym, syntax asm.Syntax, win AsmWindow, dce bool) (interface{}, error) {
+	if sym.Kind != obj.SymText || win != (AsmWindow{}) {
+		return nil, nil
+	}
+	f, err := funcSSA(ctx, v.fi.Obj, sym)
+	if err != nil {
+		return nil, err
+	}
+	return ssaToJS(f, syntax, v.symTab.SymName, dce), nil
+}
+
+// SSAJS is the SSA form of a function.
+type SSAJS struct {
+	// DCE indicates dead values have been removed.
+	DCE bool
+
+	Blocks []SSABlockJS
+}
+
+type SSABlockJS struct {
+	ID           int
+	Preds, Succs []int
+	Values       []SSAValueJS
+}
+
+type SSAValueJS struct {
+	ID int
+	Op string
+
+	// Loc is the location an "entry" value comes from or a "phi"
+	// value merges.
+	Loc string `json:",omitempty"`
+
+	// PC and Inst are the address and disassembly of the
+	// instruction that computes an "inst" value. End is the PC
+	// following the instruction.
+	PC   AddrJS `json:",omitempty"`
+	End  AddrJS `json:",omitempty"`
+	Inst string `json:",omitempty"`
+
+	// Args are the IDs of the values this value reads. For
+	// "inst" values, ArgLocs are the locations they're read
+	// from. A phi argument is -1 if the location isn't defined
+	// on that edge.
+	Args    []int
+	ArgLocs []string `json:",omitempty"`
+
+	// Uses are the IDs of the values that read this value.
+	Uses []int
+
+	// Dead indicates no live value uses this value and it has
+	// no side effects.
+	Dead bool `json:",omitempty"`
+}
+
+// funcSSA computes the SSA form of the text symbol sym. It returns
+// ctx.Err() if ctx is done first.
+func funcSSA(ctx context.Context, bin obj.Obj, sym obj.Sym) (*ssa.Func, error) {
+	insts, bbs, err := funcCFG(ctx, bin, sym)
+	if err != nil {
+		return nil, err
+	}
+	return ssa.SSA(ctx, insts, bbs)
+}
+
+// ssaToJS converts f to its JSON form, formatting instructions in
+// the given syntax. If dce is set, it first removes dead values from
+// f.
+func ssaToJS(f *ssa.Func, syntax asm.Syntax, symname func(uint64) (string, uint64), dce bool) SSAJS {
+	out := SSAJS{DCE: dce}
+	dead := f.Dead()
+	if dce {
+		f.DCE()
+	}
+	for _, b := range f.Blocks {
+		bjs := SSABlockJS{ID: b.Src.ID, Preds: []int{}, Succs: []int{}, Values: []SSAValueJS{}}
+		for _, e := range b.Src.Preds {
+			bjs.Preds = append(bjs.Preds, e.Block.ID)
+		}
+		for _, e := range b.Src.Succs {
+			bjs.Succs = append(bjs.Succs, e.Block.ID)
+		}
+		for _, v := range b.Values {
+			vjs := SSAValueJS{ID: v.ID, Op: v.Op.String(), Args: []int{}, Uses: []int{}, Dead: dead[v.ID]}
+			switch v.Op {
+			case ssa.OpEntry:
+				vjs.Loc = v.Entry.String()
+			case ssa.OpPhi:
+				vjs.Loc = v.PhiLoc.String()
+			case ssa.OpInst:
+				inst := f.Seq.Get(v.Inst)
+				vjs.PC = AddrJS(inst.PC())
+				vjs.End = AddrJS(inst.PC() + uint64(inst.Len()))
+				vjs.Inst = syntax.Format(inst, symname)
+				for _, loc := range v.ArgLocs {
+					vjs.ArgLocs = append(vjs.ArgLocs, loc.String())
+				}
+			}
+			for _, arg := range v.Args {
+				if arg == nil {
+					vjs.Args = append(vjs.Args, -1)
+				} else {
+					vjs.Args = append(vjs.Args, arg.ID)
+				}
+			}
+			for _, use := range v.Uses {
+				vjs.Uses = append(vjs.Uses, use.ID)
+			}
+			bjs.Values = append(bjs.Values, vjs)
+		}
+		out.Blocks = append(out.Blocks, bjs)
+	}
+	return out
+}
+
+// parseDCE parses the "dce" query parameter, which controls whether
+// dead SSA values are removed. It defaults to true.
+func parseDCE(q url.Values) (bool, error) {
+	str := q.Get("dce")
+	if str == "" {
+		return true, nil
+	}
+	dce, err := strconv.ParseBool(str)
+	if err != nil {
+		return false, fmt.Errorf("bad dce: %v", err)
+	}
+	return dce, nil
+}
+
+// httpSSA returns the SSA form of the function named by the path
+// after /api/ssa/ as JSON. The optional syntax parameter selects the
+// assembly syntax of instructions and the optional dce parameter
+// controls whether dead values are removed.
+func (s *state) httpSSA(w http.ResponseWriter, r *http.Request) {
+	sym, ok := s.symTab.Name(strings.TrimPrefix(r.URL.Path, "/api/ssa/"))
+	if !ok || sym.Kind != obj.SymText {
+		http.Error(w, "unknown text symbol", http.StatusNotFound)
+		return
+	}
+	syntaxName := r.URL.Query().Get("syntax")
+	if syntaxName == "" {
+		syntaxName = *flagSyntax
+	}
+	syntax, err := asm.ParseSyntax(syntaxName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dce, err := parseDCE(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f, err := funcSSA(r.Context(), s.bin, sym)
+	if r.Context().Err() != nil {
+		// Timed out. The timeout handler has responded.
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ssaToJS(f, syntax, s.symTab.SymName, dce)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+"use strict";
+
+class SSAView {
+    constructor(data, container) {
+        this._container = container;
+        const view = this;
+
+        // Create dead code toggle. Changing it reloads the page.
+        const dce = $('<input type="checkbox">').prop("checked", data.DCE);
+        $('<label class="ssa-dce">').append(dce).append(" Hide dead values").appendTo(container);
+        dce.change(() => {
+            const params = new URLSearchParams(window.location.search);
+            if (dce.prop("checked"))
+                params.delete("dce");
+            else
+                params.set("dce", "0");
+            window.location.search = params.toString();
+        });
+
+        const table = $('<table class="ssa">').appendTo(container);
+        this._table = table;
+
+        const rows = new Map();
+        const pcRanges = [];
+        const valueRanges = new Map();
+        for (let block of data.Blocks) {
+            let head = "b" + block.ID + ":";
+            if (block.Preds.length > 0)
+                head += " ← " + block.Preds.map((id) => "b" + id).join(" ");
+            if (block.Succs.length > 0)
+                head += " → " + block.Succs.map((id) => "b" + id).join(" ");
+            table.append($("<tr>").append($('<th colspan="2">').addClass("ssa-block").text(head)));
+
+            for (let v of block.Values) {
+                let op = v.Op;
+                if (v.Op == "inst")
+                    op = v.Inst;
+                else if (v.Loc)
+                    op += " " + v.Loc;
+                const args = v.Args.map((id) => id < 0 ? "_" : "v" + id);
+                const tr = $("<tr>").append(
+                    $("<td>").addClass("pos").text(v.PC ? "0x" + v.PC : "")
+                ).append(
+                    $("<td>").addClass("ssa-value").text(
+                        "v" + v.ID + " = " + op + (args.length > 0 ? " " + args.join(" ") : ""))
+                );
+                if (v.Dead)
+                    tr.addClass("ssa-dead");
+                table.append(tr);
+                rows.set(v.ID, tr);
+
+                if (v.PC) {
+                    const r = {start: new AddrJS(v.PC), end: new AddrJS(v.End), tr: tr};
+                    pcRanges.push(r);
+                    valueRanges.set(v.ID, r);
+                }
+
+                // On click, highlight the instructions of this
+                // value and all of its uses.
+                tr.click(() => {
+                    const ranges = [];
+                    for (let id of [v.ID].concat(v.Uses))
+                        if (valueRanges.has(id))
+                            ranges.push(valueRanges.get(id));
+                    ranges.sort((a, b) => a.start.compare(b.start));
+                    highlightRanges(ranges, view);
+                });
+
+                // Mark the values this value reads and the values
+                // that read it on hover.
+                tr.hover(() => {
+                    for (let id of v.Args)
+                        if (rows.has(id))
+                            rows.get(id).addClass("ssa-arg");
+                    for (let id of v.Uses)
+                        if (rows.has(id))
+                            rows.get(id).addClass("ssa-use");
+                }, () => {
+                    $(".ssa-arg", table).removeClass("ssa-arg");
+                    $(".ssa-use", table).removeClass("ssa-use");
+                });
+            }
+        }
+
+        this._pcRanges = new IntervalMap(pcRanges);
+    }
+
+    highlightRanges(ranges, scroll) {
+        // Clear highlights.
+        $(".highlight", this._table).removeClass("highlight");
+
+        // New highlights.
+        var first = true;
+        for (let match of this._pcRanges.intersect(ranges)) {
+            match.tr.addClass("highlight");
+            if (first && scroll)
+                scrollTo(this._container, match.tr);
+            first = false;
+        }
+    }
+}
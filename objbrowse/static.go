@@ -0,0 +1,26 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"embed"
+	"net/http"
+)
+
+// static holds the JavaScript and CSS served alongside the pages, so
+// the binary works without its source tree.
+//
+//go:embed *.js *.css
+var static embed.FS
+
+// staticFS returns the file system to serve static files from. This
+// is the embedded copy unless -static gives a directory, which is
+// useful for editing the JavaScript without rebuilding.
+func staticFS() http.FileSystem {
+	if *flagStatic != "" {
+		return http.Dir(*flagStatic)
+	}
+	return http.FS(static)
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sync"
+
+	"github.com/aclements/objbrowse/internal/symtab"
+)
+
+// SymTreeJS is one level of the prefix tree of symbol names.
+type SymTreeJS struct {
+	// Prefix is the full name of the parent of Nodes.
+	Prefix string
+	Nodes  []SymTreeNodeJS
+}
+
+type SymTreeNodeJS struct {
+	// Name is the node's component of the symbol name. Prefix
+	// plus Name is the node's full name.
+	Name string
+
+	// Size and Count are the total size and number of symbols
+	// at and under this node.
+	Size  uint64
+	Count int
+
+	// Syms are the names of the symbols at this node.
+	Syms []string `json:",omitempty"`
+
+	// HasChildren indicates the node has children, which can be
+	// fetched using the node's full name as the prefix.
+	HasChildren bool `json:",omitempty"`
+}
+
+// symTree is the lazily-computed prefix tree of symbol names.
+type symTree struct {
+	once sync.Once
+	root *symtab.TreeNode
+}
+
+func (s *state) symbolTree() *symtab.TreeNode {
+	t := &s.symTree
+	t.once.Do(func() {
+		t.root = s.symTab.Tree()
+	})
+	return t.root
+}
+
+// symTreeLevel returns the children of the tree node for prefix, or
+// false if there is no such node.
+func (s *state) symTreeLevel(prefix string) (SymTreeJS, bool) {
+	node := s.symbolTree().Find(prefix)
+	if node == nil {
+		return SymTreeJS{}, false
+	}
+	out := SymTreeJS{Prefix: prefix, Nodes: []SymTreeNodeJS{}}
+	for _, child := range node.Children {
+		njs := SymTreeNodeJS{Name: child.Name, Size: child.Size, Count: child.Count, HasChildren: len(child.Children) > 0}
+		for _, sym := range child.Syms {
+			njs.Syms = append(njs.Syms, sym.Name)
+		}
+		out.Nodes = append(out.Nodes, njs)
+	}
+	return out, true
+}
+
+// httpSymTree returns the children of the symbol tree node named by
+// the "prefix" query parameter as JSON.
+func (s *state) httpSymTree(w http.ResponseWriter, r *http.Request) {
+	level, ok := s.symTreeLevel(r.URL.Query().Get("prefix"))
+	if !ok {
+		http.Error(w, "unknown prefix", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(level); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// httpSymTreePage shows the symbols as a tree grouped by package.
+func (s *state) httpSymTreePage(w http.ResponseWriter, r *http.Request) {
+	level, _ := s.symTreeLevel("")
+	if err := tmplSymTree.Execute(w, level); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+var tmplSymTree = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Symbols by package</title>
+<link rel="stylesheet" type="text/css" href="/objbrowse.css" />
+</head>
+<body>
+<h1>Symbols by package</h1>
+<script src="https://code.jquery.com/jquery-3.3.1.min.js"></script>
+<script src="/symtree.js"></script>
+<script>new SymTree({{$}}, document.body)</script>
+</body>
+</html>
+`))
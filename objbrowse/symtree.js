@@ -0,0 +1,56 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+"use strict";
+
+// SymTree shows symbols as a tree of name prefixes, split on "/" and
+// ".", with the total size under each node. Levels of the tree are
+// fetched from the server as they're expanded.
+class SymTree {
+    constructor(data, container) {
+        const tree = $("<div>").addClass("symtree").appendTo(container);
+        this._render(data, tree);
+    }
+
+    _render(level, container) {
+        for (let node of level.Nodes) {
+            const full = level.Prefix + node.Name;
+            const label = $("<span>").addClass("symtree-name").text(node.Name);
+            const info = $("<span>").addClass("symtree-size").
+                  text(SymTree._formatSize(node.Size) + ", " + node.Count + (node.Count == 1 ? " symbol" : " symbols"));
+            const links = $("<span>");
+            for (let sym of node.Syms || [])
+                links.append(" ", $("<a>").attr("href", "/s/" + sym).text(sym == full ? "view" : sym));
+
+            if (!node.HasChildren) {
+                $("<div>").addClass("symtree-leaf").append(label, " ", info, links).appendTo(container);
+                continue;
+            }
+            const details = $("<details>").appendTo(container);
+            $("<summary>").append(label, " ", info, links).appendTo(details);
+            let loaded = false;
+            details.on("toggle", () => {
+                if (loaded || !details[0].open)
+                    return;
+                loaded = true;
+                $.getJSON("/api/symtree", {prefix: full}).
+                    done((sub) => { this._render(sub, details); }).
+                    fail((xhr) => {
+                        $("<div>").addClass("error").text(xhr.responseText).appendTo(details);
+                    });
+            });
+        }
+    }
+
+    // _formatSize formats a size in bytes in binary units.
+    static _formatSize(n) {
+        const units = ["B", "KiB", "MiB", "GiB"];
+        let i = 0;
+        while (n >= 1024 && i < units.length - 1) {
+            n /= 1024;
+            i++;
+        }
+        return (i == 0 ? n : n.toFixed(1)) + " " + units[i];
+    }
+}
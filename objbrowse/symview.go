@@ -7,7 +7,14 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 
+	"github.com/aclements/objbrowse/internal/demangle"
 	"github.com/aclements/objbrowse/internal/obj"
 	"github.com/aclements/objbrowse/internal/symtab"
 )
@@ -22,11 +29,179 @@ func NewSymView(fi *FileInfo, symTab *symtab.Table) *SymView {
 }
 
 type SymViewJS struct {
-	Syms SymViewSymsJS
+	// Kind is the symbol kind Syms is limited to, if any.
+	Kind string `json:",omitempty"`
+
+	// Search is the substring Syms is limited to, if any.
+	Search string `json:",omitempty"`
+
+	// Regexp is the regular expression Syms is limited to, if
+	// any.
+	Regexp string `json:",omitempty"`
+
+	// Sort is the order of Syms: "name", "addr", or "size".
+	Sort string
+
+	// Demangle lists the names of the formatters applied to the
+	// names in Syms.
+	Demangle []string
+
+	// Formatters are the available name formatters.
+	Formatters []FormatterJS
+
+	// Kinds are the common symbol kinds, for filtering.
+	Kinds []SymKindJS
+
+	// Syms is the page of symbols starting at index Offset of
+	// the Total symbols selected. Limit is the page size, or 0
+	// if Syms has all of the selected symbols.
+	Syms                 SymViewSymsJS
+	Offset, Limit, Total int
+}
+
+type SymKindJS struct {
+	Kind string
+	Name string
+}
+
+type FormatterJS struct {
+	Name  string
+	Label string
+}
+
+// symKinds are the symbol kinds the symbol list offers to filter by.
+var symKinds = []SymKindJS{
+	{string(obj.SymText), "text"},
+	{string(obj.SymData), "data"},
+	{string(obj.SymROData), "rodata"},
+	{string(obj.SymBSS), "bss"},
+}
+
+// SymViewQuery selects the symbols to list.
+type SymViewQuery struct {
+	// Kind limits the list to symbols of this kind, if not 0.
+	Kind obj.SymKind
+
+	// Search limits the list to symbols matching this substring,
+	// if not "". See symtab.Table.Search.
+	Search string
+
+	// Regexp limits the list to symbols matching this regular
+	// expression, if not nil. See symtab.Table.SearchRegex.
+	Regexp *regexp.Regexp
+
+	// Sort is the order to list symbols in.
+	Sort symtab.SortOrder
+
+	// Demangle are the formatters to apply to symbol names for
+	// display.
+	Demangle []*demangle.Formatter
+
+	// Offset and Limit select a page of the symbols. If Limit is
+	// 0, the page extends to the last symbol.
+	Offset, Limit int
+}
+
+// symPageSize is the default number of symbols on a page of the
+// symbol list.
+const symPageSize = 5000
+
+// symSortOrders maps the values of the "sort" query parameter to
+// sort orders.
+var symSortOrders = map[string]symtab.SortOrder{
+	"name": symtab.SortName,
+	"addr": symtab.SortAddr,
+	"size": symtab.SortSize,
+}
+
+// parseSymViewQuery parses a SymViewQuery from the query parameters
+// "kind", which is a symbol kind letter such as "T", "q", which is a
+// substring to search for, "re", which is a regular expression to
+// search for, "sort", which is "name" (the default),
+// "addr", or "size", "offset" and "limit", which select a page of
+// the results, and "demangle", which is a comma-separated list of
+// demangle.Formatter names. For compatibility, "demangle" may also
+// be a boolean, where true means "cxx". It defaults to "cxx" if the
+// -demangle flag is set.
+func parseSymViewQuery(q url.Values) (SymViewQuery, error) {
+	svq := SymViewQuery{Search: q.Get("q"), Sort: symtab.SortName}
+	if *flagDemangle {
+		svq.Demangle = []*demangle.Formatter{demangle.FormatterByName("cxx")}
+	}
+	if str := q.Get("demangle"); str != "" {
+		svq.Demangle = nil
+		if d, err := strconv.ParseBool(str); err == nil {
+			if d {
+				svq.Demangle = append(svq.Demangle, demangle.FormatterByName("cxx"))
+			}
+		} else {
+			names := make(map[string]bool)
+			for _, name := range strings.Split(str, ",") {
+				if demangle.FormatterByName(name) == nil {
+					return svq, fmt.Errorf("unknown demangler %q", name)
+				}
+				names[name] = true
+			}
+			// Keep the formatters in priority order.
+			for _, f := range demangle.Formatters {
+				if names[f.Name] {
+					svq.Demangle = append(svq.Demangle, f)
+				}
+			}
+		}
+	}
+	if re := q.Get("re"); re != "" {
+		var err error
+		svq.Regexp, err = regexp.Compile(re)
+		if err != nil {
+			return svq, fmt.Errorf("bad re: %v", err)
+		}
+	}
+	if sort := q.Get("sort"); sort != "" {
+		order, ok := symSortOrders[sort]
+		if !ok {
+			return svq, fmt.Errorf("unknown sort %q", sort)
+		}
+		svq.Sort = order
+	}
+	for _, p := range []struct {
+		name string
+		val  *int
+	}{{"offset", &svq.Offset}, {"limit", &svq.Limit}} {
+		if str := q.Get(p.name); str != "" {
+			n, err := strconv.Atoi(str)
+			if err != nil || n < 0 {
+				return svq, fmt.Errorf("bad %s %q", p.name, str)
+			}
+			*p.val = n
+		}
+	}
+	if kind := q.Get("kind"); kind != "" {
+		if len(kind) != 1 {
+			return svq, fmt.Errorf("bad kind %q", kind)
+		}
+		switch k := obj.SymKind(kind[0]); k {
+		case obj.SymText, obj.SymData, obj.SymROData, obj.SymBSS, obj.SymAbsolute, obj.SymTLS, obj.SymUnknown:
+			svq.Kind = k
+		default:
+			return svq, fmt.Errorf("unknown kind %q", kind)
+		}
+	}
+	return svq, nil
 }
 
 type SymViewSymsJS struct {
 	Syms []obj.Sym
+
+	// Demangle are the formatters to apply to symbol names for
+	// display.
+	Demangle []*demangle.Formatter
+
+	// symTab provides the aliases of each symbol.
+	symTab *symtab.Table
+
+	// obj provides the data of each symbol for its preview.
+	obj obj.Obj
 }
 
 func (s *SymViewSymsJS) MarshalJSON() ([]byte, error) {
@@ -50,6 +225,35 @@ func (s *SymViewSymsJS) MarshalJSON() ([]byte, error) {
 		buf.WriteByte(byte(sym.Kind))
 		buf.WriteString("\",")
 		AddrJS(sym.Value).MarshalJSONTo(buf)
+		buf.WriteString(",\"")
+		buf.WriteString(symSize(sym))
+		buf.WriteString("\",")
+		enc.Encode(sym.Section)
+		// If the name can be demangled, add the display name.
+		// The raw name is still used for links.
+		dn := demangle.Format(sym.Name, s.Demangle)
+		aliases := s.symTab.Aliases(sym)
+		preview := symPreview(s.obj, sym)
+		if dn != "" || len(aliases) > 0 || preview != "" {
+			buf.WriteByte(',')
+			if dn == "" {
+				buf.WriteString("null")
+			} else {
+				enc.Encode(dn)
+			}
+		}
+		if len(aliases) > 0 || preview != "" {
+			buf.WriteByte(',')
+			if len(aliases) == 0 {
+				buf.WriteString("[]")
+			} else {
+				enc.Encode(aliases)
+			}
+		}
+		if preview != "" {
+			buf.WriteByte(',')
+			enc.Encode(preview)
+		}
 		buf.WriteByte(']')
 	}
 	buf.WriteByte(']')
@@ -57,6 +261,210 @@ func (s *SymViewSymsJS) MarshalJSON() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (v *SymView) Decode() (interface{}, error) {
-	return &SymViewJS{SymViewSymsJS{v.symTab.Syms()}}, nil
+// symPreviewBytes is the number of bytes of a data symbol shown in
+// the symbol list.
+const symPreviewBytes = 8
+
+// symPreview formats the first few bytes of data symbol sym in hex,
+// followed by "…" if there's more. It returns "" for symbols that
+// aren't initialized data, or if the data can't be read.
+func symPreview(o obj.Obj, sym obj.Sym) string {
+	if (sym.Kind != obj.SymData && sym.Kind != obj.SymROData) || sym.Size == 0 {
+		return ""
+	}
+	head := sym
+	if head.Size > symPreviewBytes {
+		head.Size = symPreviewBytes
+	}
+	data, err := o.SymbolData(head)
+	if err != nil || len(data) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, x := range data {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%02x", x)
+	}
+	if sym.Size > uint64(len(data)) {
+		b.WriteString(" …")
+	}
+	return b.String()
+}
+
+// symSize formats the size of sym. If the size was guessed rather
+// than recorded in the object, it's prefixed with "~".
+func symSize(sym obj.Sym) string {
+	size := fmt.Sprintf("%#x", sym.Size)
+	if sym.SizeSynthesized {
+		return "~" + size
+	}
+	return size
+}
+
+func (v *SymView) Decode(q SymViewQuery) (interface{}, error) {
+	info := &SymViewJS{Kinds: symKinds, Search: q.Search, Demangle: []string{}}
+	for _, f := range q.Demangle {
+		info.Demangle = append(info.Demangle, f.Name)
+	}
+	for _, f := range demangle.Formatters {
+		info.Formatters = append(info.Formatters, FormatterJS{f.Name, f.Label})
+	}
+	if q.Regexp != nil {
+		info.Regexp = q.Regexp.String()
+	}
+	if q.Kind != 0 {
+		info.Kind = string(q.Kind)
+	}
+	for name, order := range symSortOrders {
+		if order == q.Sort {
+			info.Sort = name
+		}
+	}
+	syms := v.Syms(q)
+	info.Total = len(syms)
+	info.Offset, info.Limit = q.Offset, q.Limit
+	info.Syms = SymViewSymsJS{page(syms, q.Offset, q.Limit), q.Demangle, v.symTab, v.fi.Obj}
+	return info, nil
+}
+
+// page returns the up to limit symbols of syms starting at offset. If
+// limit is 0, it returns all symbols from offset on.
+func page(syms []obj.Sym, offset, limit int) []obj.Sym {
+	if offset > len(syms) {
+		offset = len(syms)
+	}
+	syms = syms[offset:]
+	if limit > 0 && limit < len(syms) {
+		syms = syms[:limit]
+	}
+	return syms
+}
+
+// Syms returns the symbols selected by q, in the order given by q.
+// The caller must not modify the returned slice.
+func (v *SymView) Syms(q SymViewQuery) []obj.Sym {
+	syms := v.symTab.Syms()
+	switch {
+	case q.Search != "" || q.Regexp != nil:
+		if q.Search != "" {
+			syms = v.symTab.Search(q.Search, q.Demangle)
+		}
+		if q.Regexp != nil {
+			reSyms := v.symTab.SearchRegex(q.Regexp, q.Demangle)
+			if q.Search == "" {
+				syms = reSyms
+			} else {
+				// Keep symbols that match both.
+				match := make(map[obj.Sym]bool, len(reSyms))
+				for _, sym := range reSyms {
+					match[sym] = true
+				}
+				var both []obj.Sym
+				for _, sym := range syms {
+					if match[sym] {
+						both = append(both, sym)
+					}
+				}
+				syms = both
+			}
+		}
+		if q.Kind != 0 {
+			var kindSyms []obj.Sym
+			for _, sym := range syms {
+				if sym.Kind == q.Kind {
+					kindSyms = append(kindSyms, sym)
+				}
+			}
+			syms = kindSyms
+		}
+	case q.Kind != 0:
+		syms = v.symTab.ByKind(q.Kind)
+	}
+	if q.Sort != symtab.SortAddr {
+		if q.Search == "" && q.Regexp == nil && q.Kind == 0 {
+			// Don't sort the Table's own slice.
+			syms = append([]obj.Sym(nil), syms...)
+		}
+		symtab.SortSyms(syms, q.Sort)
+	}
+	return syms
+}
+
+// symsAPIVersion is the version of the /api/syms response format. It
+// changes only if existing fields change meaning or are removed.
+const symsAPIVersion = 1
+
+// SymsAPIJS is the response of /api/syms.
+type SymsAPIJS struct {
+	Version int
+	Syms    []SymAPIJS
+
+	// Total is the number of symbols selected. This is more than
+	// len(Syms) if the request asked for one page with offset
+	// and limit.
+	Total int
+}
+
+// SymAPIJS is a symbol in the response of /api/syms.
+type SymAPIJS struct {
+	Name    string
+	Value   uint64
+	Size    uint64
+	Kind    string
+	Local   bool
+	HasAddr bool
+	Section string `json:",omitempty"`
+
+	// Aliases are the names of other symbols at the same address
+	// that were merged into this symbol.
+	Aliases []string `json:",omitempty"`
+}
+
+// httpSyms serves the symbol table as JSON. It accepts the same
+// query parameters as the symbol list. See parseSymViewQuery.
+//
+// If the "view" parameter is set, the response is instead the
+// SymViewJS the symbol list is built from. The symbol list uses this
+// to search as the user types.
+func (s *state) httpSyms(w http.ResponseWriter, r *http.Request) {
+	q, err := parseSymViewQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if view, _ := strconv.ParseBool(r.URL.Query().Get("view")); view {
+		sv, err := s.symView.Decode(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(sv); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	syms := s.symView.Syms(q)
+	total := len(syms)
+	syms = page(syms, q.Offset, q.Limit)
+	resp := SymsAPIJS{Version: symsAPIVersion, Syms: make([]SymAPIJS, len(syms)), Total: total}
+	for i, sym := range syms {
+		resp.Syms[i] = SymAPIJS{
+			Name:    sym.Name,
+			Value:   sym.Value,
+			Size:    sym.Size,
+			Kind:    string(sym.Kind),
+			Local:   sym.Local,
+			HasAddr: sym.HasAddr,
+			Section: sym.Section,
+			Aliases: s.symTab.Aliases(sym),
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 }
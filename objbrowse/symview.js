@@ -4,19 +4,117 @@
 
 "use strict";
 
+// previewASCII returns the ASCII text of a symbol data preview,
+// which is a list of hex bytes, with "." for unprintable bytes.
+function previewASCII(preview) {
+    let text = "";
+    for (let x of preview.split(" ")) {
+        const b = parseInt(x, 16);
+        if (isNaN(b))
+            text += "…";
+        else
+            text += (b >= 0x20 && b < 0x7f) ? String.fromCharCode(b) : ".";
+    }
+    return text;
+}
+
 class SymView {
     constructor(data, container) {
         const self = this;
-        this._allSyms = data.Syms;
-        this._sort = "name";
+        // The server already sorted the symbols, but sort them
+        // again on the client so the columns can be re-sorted.
+        this._sort = {"addr": "value"}[data.Sort] || data.Sort || "name";
         $(container).addClass("symview");
+        this._setSyms(data.Syms);
+        const sections = new Set(this._allSyms.map((sym) => sym[4]));
 
-        // Parse symbol addresses.
-        for (let sym of data.Syms) {
-            sym[2] = new AddrJS(sym[2]);
+        // Add symbol kind links. These reload the page, since the
+        // server does the filtering.
+        const kinds = $('<div class="symview-kinds">').appendTo(container).text("Show: ");
+        const kindLink = (kind, name) => {
+            const params = new URLSearchParams(window.location.search);
+            if (kind == "")
+                params.delete("kind");
+            else
+                params.set("kind", kind);
+            const a = $("<a>").attr("href", "?" + params.toString()).text(name);
+            if (kind == (data.Kind || ""))
+                a.addClass("symview-kind-cur");
+            kinds.append(a, " ");
+        };
+        kindLink("", "all");
+        for (let k of data.Kinds)
+            kindLink(k.Kind, k.Name);
+
+        // Add demangling toggles, one for each name formatter.
+        // Changing them reloads the page, since the server
+        // demangles names.
+        const demangles = $('<span class="symview-demangle">').appendTo(container).text("Demangle: ");
+        for (let f of data.Formatters) {
+            const cb = $('<input type="checkbox">').val(f.Name).prop("checked", data.Demangle.includes(f.Name));
+            $("<label>").append(cb).append(" " + f.Label + " ").appendTo(demangles);
+            cb.change(() => {
+                const names = $("input:checked", demangles).map((i, el) => el.value).get();
+                const params = new URLSearchParams(window.location.search);
+                params.set("demangle", names.length == 0 ? "0" : names.join(","));
+                window.location.search = params.toString();
+            });
         }
 
-        // Add search box.
+        // Add server-side search. Unlike the filter below, this
+        // limits the symbols sent to the browser, which keeps large
+        // binaries responsive. The list updates as the user types,
+        // and the URL tracks the search so it can be shared.
+        const form = $('<form method="get" class="symview-search">').appendTo(container);
+        for (let [name, val] of new URLSearchParams(window.location.search))
+            if (name != "q" && name != "re")
+                $('<input type="hidden">').attr("name", name).val(val).appendTo(form);
+        const q = $('<input type="search" name="q" size="40" placeholder="search names">').val(data.Search || "").appendTo(form);
+        form.append(" ");
+        const re = $('<input type="search" name="re" size="30" placeholder="search regexp">').val(data.Regexp || "").appendTo(form);
+        form.append(" ", $('<input type="submit" value="Search">'));
+        // Submitting with an empty box would otherwise leave an
+        // empty parameter in the URL.
+        form.submit(() => {
+            $("input[type=search]", form).filter((i, el) => el.value == "").prop("disabled", true);
+        });
+        let searchTimer = null, searchSeq = 0;
+        const liveSearch = () => {
+            const params = new URLSearchParams(window.location.search);
+            for (let [name, input] of [["q", q], ["re", re]]) {
+                if (input.val() == "")
+                    params.delete(name);
+                else
+                    params.set(name, input.val());
+            }
+            // A new search starts at the first page.
+            params.delete("offset");
+            window.history.replaceState(null, "", "?" + params.toString());
+            params.set("view", "1");
+            params.set("limit", self._limit);
+            const seq = ++searchSeq;
+            $.getJSON("/api/syms?" + params.toString()).
+                done((res) => {
+                    if (seq != searchSeq)
+                        return; // Superseded by a later search.
+                    re[0].setCustomValidity("");
+                    self._setSyms(res.Syms);
+                    self._setPage(res);
+                    self._updateFilter();
+                }).
+                fail((xhr) => {
+                    if (seq == searchSeq)
+                        re[0].setCustomValidity(xhr.responseText);
+                });
+        };
+        $([q[0], re[0]]).on("input", () => {
+            // Each search is a server round trip, so wait until
+            // the user pauses typing.
+            clearTimeout(searchTimer);
+            searchTimer = setTimeout(liveSearch, 250);
+        });
+
+        // Add filter box.
         //
         // TODO: Also accept an address to search for.
         const search = $('<input type="text" size="40" autofocus="true" placeholder="filter regexp">').appendTo(container);
@@ -53,6 +151,24 @@ class SymView {
         // the browser show the validation message.
         search.change(() => { onSearch(true); search[0].reportValidity(); });
 
+        // Add section filter.
+        self._section = null;
+        const sectSel = $('<select>').appendTo(container);
+        sectSel.append($('<option>').val("").text("all sections"));
+        for (let sect of Array.from(sections).sort()) {
+            if (sect != "")
+                sectSel.append($('<option>').val(sect).text(sect));
+        }
+        sectSel.on('change', () => {
+            self._section = sectSel.val() == "" ? null : sectSel.val();
+            self._updateFilter();
+        });
+
+        // Add page navigation.
+        this._pageNav = $('<div class="symview-page">').appendTo(container);
+        this._limit = data.Limit;
+        this._setPage(data);
+
         // Add table.
         const table = $('<table class="symview-table">').appendTo(container);
         this._table = table;
@@ -65,9 +181,64 @@ class SymView {
         }, 1);
     }
 
+    // _setSyms sets the list of symbols from the server's compact
+    // encoding (see SymViewSymsJS). It parses symbol addresses and
+    // fills in display names. If the server demangled a name, it's
+    // in the sixth element. The seventh element lists other names
+    // for the symbol, and the eighth is a hex preview of a data
+    // symbol's first few bytes.
+    _setSyms(syms) {
+        const nameCount = new Map();
+        for (let sym of syms) {
+            nameCount.set(sym[0], (nameCount.get(sym[0]) || 0) + 1);
+            sym[2] = new AddrJS(sym[2]);
+            if (sym.length < 6 || sym[5] === null) {
+                sym[5] = sym[0];
+            }
+            if (sym.length < 7) {
+                sym[6] = [];
+            }
+            if (sym.length < 8) {
+                sym[7] = "";
+            }
+        }
+        this._allSyms = syms;
+        // Names shared by several symbols link by address, too.
+        this._dupNames = new Set();
+        for (let [name, n] of nameCount)
+            if (n > 1)
+                this._dupNames.add(name);
+    }
+
+    // _setPage shows the position of the current page of symbols in
+    // data, a SymViewJS, and links to the neighboring pages.
+    _setPage(data) {
+        const nav = this._pageNav.empty();
+        const n = data.Syms.length;
+        this._paginated = n < data.Total;
+        if (!this._paginated) {
+            nav.text(data.Total + (data.Total == 1 ? " symbol" : " symbols"));
+            return;
+        }
+        nav.text("Showing " + (data.Offset + 1) + "–" + (data.Offset + n) + " of " + data.Total + " symbols ");
+        const link = (text, offset) => {
+            const params = new URLSearchParams(window.location.search);
+            params.set("offset", offset);
+            nav.append(" ", $("<a>").attr("href", "?" + params.toString()).text(text));
+        };
+        if (data.Offset > 0)
+            link("previous", Math.max(0, data.Offset - data.Limit));
+        if (data.Offset + n < data.Total)
+            link("next", data.Offset + n);
+        const all = new URLSearchParams(window.location.search);
+        all.delete("offset");
+        all.set("limit", "0");
+        nav.append(" ", $("<a>").attr("href", "?" + all.toString()).text("all"));
+    }
+
     _updateFilter() {
         // Create a filtered copy of the syms list.
-        if (this._filterRe == null) {
+        if (this._filterRe == null && this._section == null) {
             this._syms = this._allSyms;
             this._populate();
             return;
@@ -75,7 +246,9 @@ class SymView {
 
         const syms = [];
         for (let sym of this._allSyms) {
-            if (this._filterRe.test(sym[0])) {
+            if (this._section != null && sym[4] != this._section)
+                continue;
+            if (this._filterRe == null || this._filterRe.test(sym[5]) || this._filterRe.test(sym[0]) || sym[6].some((name) => this._filterRe.test(name))) {
                 syms.push(sym);
             }
         }
@@ -91,6 +264,11 @@ class SymView {
         const NAME = 0;
         const TYPE = 1;
         const VALUE = 2;
+        const SIZE = 3;
+        const SECTION = 4;
+        const DISPLAY = 5;
+        const ALIASES = 6;
+        const PREVIEW = 7;
 
         // Crete table header.
         const t = this._table;
@@ -98,23 +276,52 @@ class SymView {
         const colName = $('<td width="30em">Name</td>');
         const colType = $('<td width="3em">Type</td>');
         const colValue = $('<td width="10em">Value</td>');
-        t.css({"width": (30+3+10)+"em"});
+        const colSize = $('<td width="6em">Size</td>');
+        const colSection = $('<td width="8em">Section</td>');
+        const colPreview = $('<td width="18em">Data</td>');
+        t.css({"width": (30+3+10+6+8+18)+"em"});
         t.append(
-            $('<thead>').append(colName).append(colType).append(colValue)
+            $('<thead>').append(colName).append(colType).append(colValue).append(colSize).append(colSection).append(colPreview)
         );
-        colName.click(() => { self._sort = "name"; self._populate(); });
-        colValue.click(() => { self._sort = "value"; self._populate(); });
-        $([colName[0], colValue[0]]).css({"cursor": "pointer"});
+        // If this is one page of symbols, the server must sort so
+        // the pages are in order. The server doesn't sort by
+        // section, so that only sorts this page.
+        const sortBy = (sort, serverSort) => {
+            if (self._paginated && serverSort) {
+                const params = new URLSearchParams(window.location.search);
+                params.set("sort", serverSort);
+                params.delete("offset");
+                window.location.search = params.toString();
+                return;
+            }
+            self._sort = sort;
+            self._populate();
+        };
+        colName.click(() => { sortBy("name", "name"); });
+        colValue.click(() => { sortBy("value", "addr"); });
+        colSize.click(() => { sortBy("size", "size"); });
+        colSection.click(() => { sortBy("section", null); });
+        $([colName[0], colValue[0], colSize[0], colSection[0]]).css({"cursor": "pointer"});
 
         // Sort symbols.
         const syms = this._syms;
         let sortCol;
         if (this._sort == "name") {
-            syms.sort((a, b) => a[NAME] < b[NAME] ? -1 : +(a[NAME] > b[NAME]));
+            syms.sort((a, b) => a[DISPLAY] < b[DISPLAY] ? -1 : +(a[DISPLAY] > b[DISPLAY]));
             sortCol = colName;
         } else if (this._sort == "value") {
             syms.sort((a, b) => a[VALUE].compare(b[VALUE]));
             sortCol = colValue;
+        } else if (this._sort == "size") {
+            // Largest first. Sizes are hex, possibly prefixed
+            // with "~" if they were guessed.
+            const size = (sym) => parseInt(sym[SIZE].replace("~", ""), 16);
+            syms.sort((a, b) => size(b) - size(a) || a[VALUE].compare(b[VALUE]));
+            sortCol = colSize;
+        } else if (this._sort == "section") {
+            // Group by section, in address order within each.
+            syms.sort((a, b) => a[SECTION] < b[SECTION] ? -1 : a[SECTION] > b[SECTION] ? 1 : a[VALUE].compare(b[VALUE]));
+            sortCol = colSection;
         } else {
             throw("bad sort " + this._sort);
         }
@@ -127,11 +334,17 @@ class SymView {
             for (let i = start; i < start + n; i++) {
                 const sym = self._syms[i];
                 const tr = $('<tr>').append([
-                    $('<td>').addClass('symview-name').text(sym[NAME]),
+                    $('<td>').addClass('symview-name').text(sym[DISPLAY]).attr("title", sym[ALIASES].length == 0 ? sym[NAME] : sym[NAME] + "\nalso known as: " + sym[ALIASES].join(", ")),
                     $('<td>').text(sym[TYPE]),
                     $('<td>').text(sym[VALUE]),
+                    $('<td>').text(sym[SIZE]).attr("title", sym[SIZE][0] == "~" ? "size guessed from the next symbol's address" : null),
+                    $('<td>').text(sym[SECTION]),
+                    $('<td>').addClass('symview-preview').text(sym[PREVIEW]).attr("title", sym[PREVIEW] ? previewASCII(sym[PREVIEW]) : null),
                 ]);
-                tr.click(() => { window.location.href = '/s/' + sym[NAME]; })
+                let href = '/s/' + sym[NAME];
+                if (self._dupNames.has(sym[NAME]))
+                    href += '?addr=0x' + sym[VALUE];
+                tr.click(() => { window.location.href = href; })
                 rows.push(tr[0]);
             }
             return rows;
@@ -0,0 +1,196 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/aclements/objbrowse/internal/obj"
+	"github.com/aclements/objbrowse/internal/symtab"
+)
+
+// TypeView decodes the runtime type descriptor of a Go type.* symbol.
+type TypeView struct {
+	fi     *FileInfo
+	symTab *symtab.Table
+}
+
+func NewTypeView(fi *FileInfo, symTab *symtab.Table) *TypeView {
+	return &TypeView{fi, symTab}
+}
+
+type TypeViewJS struct {
+	Fields []TypeViewField
+}
+
+type TypeViewField struct {
+	Name  string
+	Value string
+	// Link is the name of a symbol this field refers to, if any.
+	Link string `json:",omitempty"`
+}
+
+var goKinds = []string{
+	"invalid", "bool", "int", "int8", "int16", "int32", "int64",
+	"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+	"float32", "float64", "complex64", "complex128",
+	"array", "chan", "func", "interface", "map", "ptr", "slice",
+	"string", "struct", "unsafe.Pointer",
+}
+
+var goTFlags = []string{
+	"uncommon", "extraStar", "named", "regularMemory",
+	"gcMaskOnDemand", "directIface",
+}
+
+// isTypeSym returns whether sym is a Go runtime type descriptor.
+func isTypeSym(sym obj.Sym) bool {
+	if sym.Kind == obj.SymText {
+		// type..eq.* and similar are functions.
+		return false
+	}
+	name := sym.Name
+	if !strings.HasPrefix(name, "type.") && !strings.HasPrefix(name, "type:") {
+		return false
+	}
+	rest := name[len("type."):]
+	// Skip auxiliary symbols like type..namedata.* and the
+	// type.* symbol that covers all types.
+	return rest != "" && rest[0] != '.' && rest != "*"
+}
+
+func (v *TypeView) DecodeSym(sym obj.Sym) (interface{}, error) {
+	if !isTypeSym(sym) {
+		return nil, nil
+	}
+	ver := v.fi.GoVersion
+	// Go 1.7 introduced name offsets. The layout of the common
+	// part of _type has been stable since.
+	if !ver.AtLeast(1, 7) {
+		return nil, fmt.Errorf("%s: cannot decode type descriptor for Go version %s", sym.Name, ver)
+	}
+	a := v.fi.Obj.Info().Arch
+	if a == nil {
+		return nil, fmt.Errorf("%s: unknown architecture", sym.Name)
+	}
+	types, ok := v.symTab.Name("runtime.types")
+	if !ok {
+		return nil, fmt.Errorf("%s: no runtime.types symbol", sym.Name)
+	}
+
+	p := a.PtrSize
+	size := 4*p + 16
+	data, err := v.fi.Obj.Data(sym.Value, uint64(size))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < size {
+		return nil, fmt.Errorf("%s: type descriptor is truncated", sym.Name)
+	}
+	tflag := data[2*p+4]
+	kind := data[2*p+7]
+	str := int32(a.Uint32(data[4*p+8:]))
+	ptrToThis := int32(a.Uint32(data[4*p+12:]))
+
+	var info TypeViewJS
+	add := func(name, link, format string, args ...interface{}) {
+		info.Fields = append(info.Fields, TypeViewField{name, fmt.Sprintf(format, args...), link})
+	}
+	addPtr := func(name string, ptr uint64) {
+		sname, base := v.symTab.SymName(ptr)
+		switch {
+		case ptr == 0:
+			add(name, "", "nil")
+		case sname == "":
+			add(name, "", "%#x", ptr)
+		case ptr == base:
+			add(name, sname, "%#x <%s>", ptr, sname)
+		default:
+			add(name, sname, "%#x <%s+%#x>", ptr, sname, ptr-base)
+		}
+	}
+
+	typeName, err := v.readName(types.Value+uint64(int64(str)), ver)
+	if err != nil {
+		typeName = fmt.Sprintf("<%s>", err)
+	} else if tflag&(1<<1) != 0 {
+		// tflagExtraStar
+		typeName = strings.TrimPrefix(typeName, "*")
+	}
+	add("name", "", "%s", typeName)
+
+	kindName := fmt.Sprintf("kind(%d)", kind&31)
+	if int(kind&31) < len(goKinds) {
+		kindName = goKinds[kind&31]
+	}
+	// Before Go 1.26, the kind byte also had flag bits.
+	if kind&(1<<5) != 0 {
+		kindName += "|directIface"
+	}
+	if kind&(1<<6) != 0 {
+		kindName += "|gcProg"
+	}
+	add("kind", "", "%s", kindName)
+	add("size", "", "%d", a.Ptr(data[0:]))
+	add("ptrdata", "", "%d", a.Ptr(data[p:]))
+	add("hash", "", "%#08x", a.Uint32(data[2*p:]))
+
+	var flags []string
+	for i, name := range goTFlags {
+		if tflag&(1<<uint(i)) != 0 {
+			flags = append(flags, name)
+		}
+	}
+	add("tflag", "", "%#x %s", tflag, strings.Join(flags, "|"))
+	add("align", "", "%d", data[2*p+5])
+	add("fieldAlign", "", "%d", data[2*p+6])
+	if ver.AtLeast(1, 14) {
+		addPtr("equal", a.Ptr(data[2*p+8:]))
+	} else {
+		addPtr("alg", a.Ptr(data[2*p+8:]))
+	}
+	addPtr("gcdata", a.Ptr(data[3*p+8:]))
+	add("str", "", "%#x", str)
+	if ptrToThis == 0 {
+		add("ptrToThis", "", "none")
+	} else {
+		addPtr("ptrToThis", types.Value+uint64(int64(ptrToThis)))
+	}
+	return info, nil
+}
+
+// readName decodes a runtime name structure at addr.
+func (v *TypeView) readName(addr uint64, ver GoVersion) (string, error) {
+	hdr, err := v.fi.Obj.Data(addr, 1+binary.MaxVarintLen64)
+	if err != nil {
+		return "", err
+	}
+	if len(hdr) < 3 {
+		return "", fmt.Errorf("name at %#x is truncated", addr)
+	}
+	var n uint64
+	var hdrLen int
+	if ver.AtLeast(1, 17) {
+		// Go 1.17 switched to a varint length.
+		var l int
+		n, l = binary.Uvarint(hdr[1:])
+		if l <= 0 {
+			return "", fmt.Errorf("bad name length at %#x", addr)
+		}
+		hdrLen = 1 + l
+	} else {
+		n, hdrLen = uint64(hdr[1])<<8|uint64(hdr[2]), 3
+	}
+	str, err := v.fi.Obj.Data(addr+uint64(hdrLen), n)
+	if err != nil {
+		return "", err
+	}
+	if uint64(len(str)) < n {
+		return "", fmt.Errorf("name at %#x is truncated", addr)
+	}
+	return string(str), nil
+}
@@ -0,0 +1,27 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+"use strict";
+
+class TypeView {
+    constructor(data, container) {
+        const table = $('<table class="fv">').appendTo(container);
+        table.append($('<tr>').append($('<th colspan="2">').addClass('fv-title').text("_type")));
+        for (let field of data.Fields) {
+            const val = $('<td>').addClass('fv-val');
+            if (field.Link) {
+                $('<a>').attr("href", "/s/" + field.Link).text(field.Value).appendTo(val);
+            } else {
+                val.text(field.Value);
+            }
+            table.append($('<tr>').append(
+                $('<td>').addClass('fv-name').text(field.Name)
+            ).append(val));
+        }
+    }
+
+    highlightRanges(ranges, scroll) {
+        // Nothing in this view is address-based.
+    }
+}